@@ -0,0 +1,106 @@
+package art
+
+import "bytes"
+
+// alias redirects keys starting with from to the same suffix under to.
+type alias struct {
+	from, to []byte
+}
+
+// Alias makes lookups under aliasPrefix resolve against targetPrefix, like a
+// symlink for a namespace: after Alias("latest/", "v2/"), Search of
+// "latest/config" returns the value stored at "v2/config". No keys are
+// copied. A real key stored under aliasPrefix takes precedence over the key
+// it would resolve to. Aliases are not chained: a resolved key is not
+// resolved again. Aliasing the same prefix again replaces the target, and a
+// nil targetPrefix removes the alias.
+func (t *Tree[T]) Alias(aliasPrefix, targetPrefix []byte) {
+	for {
+		old := t.aliases.Load()
+		var next []alias
+		if old != nil {
+			for _, a := range *old {
+				if !bytes.Equal(a.from, aliasPrefix) {
+					next = append(next, a)
+				}
+			}
+		}
+		if targetPrefix != nil {
+			a := alias{
+				from: append([]byte(nil), aliasPrefix...),
+				to:   append([]byte(nil), targetPrefix...),
+			}
+			// Longest prefixes first so the most specific alias wins
+			i := 0
+			for i < len(next) && len(next[i].from) >= len(a.from) {
+				i++
+			}
+			next = append(next[:i], append([]alias{a}, next[i:]...)...)
+		}
+		if t.aliases.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// resolveAlias returns the alias covering key, if any.
+func (t *Tree[T]) resolveAlias(key []byte) (alias, bool) {
+	aliases := t.aliases.Load()
+	if aliases == nil {
+		return alias{}, false
+	}
+	for _, a := range *aliases {
+		if bytes.HasPrefix(key, a.from) {
+			return a, true
+		}
+	}
+	return alias{}, false
+}
+
+// rewrite replaces the alias prefix of key with its target.
+func (a alias) rewrite(key []byte) []byte {
+	return append(append([]byte(nil), a.to...), key[len(a.from):]...)
+}
+
+// scanAlias merges the real keys under prefix with the keys of the aliased
+// target subtree, presented under the alias. Real keys win on duplicates.
+func (t *Tree[T]) scanAlias(prefix []byte, a alias, fn func(key []byte, val T) bool) {
+	var real []Entry[T]
+	t.scanPrefix(prefix, func(key []byte, val T) bool {
+		real = append(real, Entry[T]{Key: key, Value: val})
+		return true
+	})
+
+	i := 0
+	stopped := false
+	t.scanPrefix(a.rewrite(prefix), func(target []byte, val T) bool {
+		key := append(append([]byte(nil), a.from...), target[len(a.to):]...)
+		for ; i < len(real); i++ {
+			c := bytes.Compare(real[i].Key, key)
+			if c > 0 {
+				break
+			}
+			if !fn(real[i].Key, real[i].Value) {
+				stopped = true
+				return false
+			}
+			if c == 0 {
+				i++
+				return true
+			}
+		}
+		if !fn(key, val) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if stopped {
+		return
+	}
+	for ; i < len(real); i++ {
+		if !fn(real[i].Key, real[i].Value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package art
+
+import "testing"
+
+func TestAliasSearch(t *testing.T) {
+	tree := NewART[string]()
+	tree.Insert([]byte("v2/config"), "v2 config")
+	tree.Insert([]byte("v2/data"), "v2 data")
+	tree.Insert([]byte("v3/config"), "v3 config")
+	tree.Alias([]byte("latest/"), []byte("v2/"))
+
+	val, found := tree.Search([]byte("latest/config"))
+	if !found || val.(string) != "v2 config" {
+		t.Errorf("Expected 'v2 config' through alias, got %v (found=%v)", val, found)
+	}
+	if _, found := tree.Search([]byte("latest/missing")); found {
+		t.Error("Expected miss for key absent from the target")
+	}
+
+	// Real keys under the alias take precedence
+	tree.Insert([]byte("latest/data"), "pinned")
+	if val, _ := tree.Search([]byte("latest/data")); val.(string) != "pinned" {
+		t.Errorf("Expected real key to win over alias, got %v", val)
+	}
+
+	// Re-pointing the alias switches namespaces
+	tree.Alias([]byte("latest/"), []byte("v3/"))
+	if val, _ := tree.Search([]byte("latest/config")); val.(string) != "v3 config" {
+		t.Errorf("Expected 'v3 config' after re-pointing, got %v", val)
+	}
+
+	tree.Alias([]byte("latest/"), nil)
+	if _, found := tree.Search([]byte("latest/config")); found {
+		t.Error("Expected miss after removing the alias")
+	}
+}
+
+func TestAliasScanPrefix(t *testing.T) {
+	tree := NewART[int]()
+	tree.Insert([]byte("v2/a"), 1)
+	tree.Insert([]byte("v2/c"), 3)
+	tree.Insert([]byte("v2/d"), 4)
+	tree.Insert([]byte("cur/b"), 20)
+	tree.Insert([]byte("cur/c"), 30)
+	tree.Alias([]byte("cur/"), []byte("v2/"))
+
+	var keys []string
+	var vals []int
+	tree.ScanPrefix([]byte("cur/"), func(key []byte, val int) bool {
+		keys = append(keys, string(key))
+		vals = append(vals, val)
+		return true
+	})
+	expectedKeys := []string{"cur/a", "cur/b", "cur/c", "cur/d"}
+	expectedVals := []int{1, 20, 30, 4}
+	if len(keys) != len(expectedKeys) {
+		t.Fatalf("Expected %v, got %v", expectedKeys, keys)
+	}
+	for i := range expectedKeys {
+		if keys[i] != expectedKeys[i] || vals[i] != expectedVals[i] {
+			t.Errorf("Expected %s=%d at %d, got %s=%d", expectedKeys[i], expectedVals[i], i, keys[i], vals[i])
+		}
+	}
+
+	count := 0
+	tree.ScanPrefix([]byte("cur/"), func(key []byte, val int) bool {
+		count++
+		return count < 2
+	})
+	if count != 2 {
+		t.Errorf("Expected scan to stop after 2 keys, got %d", count)
+	}
+}
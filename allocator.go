@@ -0,0 +1,183 @@
+package art
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Node4, Node16, Node48, Node256 and NodeWide are the inner node types of
+// a tree, named only so that an Allocator outside the package can supply
+// them. Their fields are unexported: an allocator creates them with new, or
+// hands out ones it got back from FreeNode, and never looks inside.
+type (
+	Node4    = node4
+	Node16   = node16
+	Node48   = node48
+	Node256  = node256
+	NodeWide = nodeWide
+)
+
+// InnerNode is one of *Node4, *Node16, *Node48, *Node256 or *NodeWide, as
+// passed to Allocator.FreeNode.
+type InnerNode = node
+
+// Allocator supplies the memory of a tree's inner nodes, so that pools or
+// arenas can replace the collector's allocation. The Alloc methods return
+// a node the tree does not use; the tree overwrites all of its fields, so
+// a recycled node need not be cleared. Every inner node a tree links in
+// comes from its allocator, including the overflow node48s of
+// WithMaxNode48 and the wide nodes of WithWideStride, though a wide node's
+// rows of child pointers come from the heap. The copies Freeze and
+// Snapshot take are not the tree's and come from the heap too.
+//
+// FreeNode receives each inner node a write unlinked from the tree: the
+// node a grow or shrink replaced, or one a delete or Compact collapsed.
+// The nodes CompactLive collapses are left to the collector. It is never
+// called for a node still reachable from the root, nor while an operation
+// that could have reached the node before it was unlinked is still
+// running: such nodes wait until those operations return, so the
+// allocator may hand them out again at once. A tree with an allocator
+// calls it from concurrent writers, which it must allow. RCU trees share
+// replaced nodes with readers and snapshots and never free them. Nodes
+// passed to FreeNode are no longer reclaimed by the collector while the
+// allocator holds them, which WithRetireBudget's accounting does not see. A
+// node48's overflow chain is freed with it; an overflow node dropped
+// because its children were all deleted is left to the collector.
+type Allocator interface {
+	AllocNode4() *Node4
+	AllocNode16() *Node16
+	AllocNode48() *Node48
+	AllocNode256() *Node256
+	AllocNodeWide() *NodeWide
+	FreeNode(n InnerNode)
+}
+
+// heapAllocator is the default Allocator: nodes come from the heap and are
+// reclaimed by the collector.
+type heapAllocator struct{}
+
+func (heapAllocator) AllocNode4() *node4       { return new(node4) }
+func (heapAllocator) AllocNode16() *node16     { return new(node16) }
+func (heapAllocator) AllocNode48() *node48     { return new(node48) }
+func (heapAllocator) AllocNode256() *node256   { return new(node256) }
+func (heapAllocator) AllocNodeWide() *nodeWide { return new(nodeWide) }
+func (heapAllocator) FreeNode(n node)          {}
+
+// reserveOverflow makes sure a child can be added to n without allocating:
+// if n is a capped node48 whose chain is full, it links a new overflow
+// node48 from a at the end. The caller holds n's write lock.
+func reserveOverflow(a Allocator, n node) {
+	n48, ok := n.(*node48)
+	if !ok || !n48.capped {
+		return
+	}
+	for n48.numOfChildren == 48 {
+		if n48.next() == nil {
+			n48.setNext(allocNode48(a))
+		}
+		n48 = n48.next()
+	}
+}
+
+// WithAllocator makes the tree allocate its inner nodes from a and return
+// the nodes it unlinks to it.
+func WithAllocator(a Allocator) Option {
+	return func(c *config) {
+		c.allocator = a
+	}
+}
+
+// free passes the inner nodes among nodes, which the caller has just
+// unlinked and made obsolete, to the tree's allocator once no operation
+// can still be reading them. Without an allocator there is nothing to do.
+func (t *Tree[T]) free(nodes ...node) {
+	if t.epochs == nil {
+		return
+	}
+	inner := make([]node, 0, len(nodes))
+	for _, n := range nodes {
+		switch n.getType() {
+		case nodeType48:
+			for n48 := n.(*node48); n48 != nil; n48 = n48.next() {
+				inner = append(inner, n48)
+			}
+		case nodeType4, nodeType16, nodeType256, nodeTypeWide:
+			inner = append(inner, n)
+		}
+	}
+	t.epochs.retire(inner)
+}
+
+// epochs holds the nodes a tree unlinks until no operation that could have
+// reached them is still running. Every operation that follows child
+// pointers pins the current epoch's counter while it runs. A node unlinked
+// during epoch e is freed when the epoch advances from e+1 to e+2: each of
+// the two advances waits for the counter of the other parity to drain, and
+// an operation pinned before the unlink holds one of them. Operations pin
+// the newest counter, so the older one drains even under steady traffic.
+type epochs struct {
+	cur    atomic.Uint64
+	pinned [2]atomic.Int64
+	// waiting reports nodes in limbo, so unpin skips the lock otherwise
+	waiting atomic.Bool
+	// mu guards limbo and serializes advancing the epoch
+	mu    sync.Mutex
+	limbo [2][]node
+	alloc Allocator
+}
+
+// pin counts an operation in the current epoch until it passes the
+// returned counter to unpin. It does nothing on a nil epochs, which trees
+// without an allocator have.
+func (e *epochs) pin() *atomic.Int64 {
+	if e == nil {
+		return nil
+	}
+	c := &e.pinned[e.cur.Load()&1]
+	c.Add(1)
+	return c
+}
+
+// unpin ends an operation pin began, and frees what it was the last to
+// hold back.
+func (e *epochs) unpin(c *atomic.Int64) {
+	if e == nil {
+		return
+	}
+	if c.Add(-1) == 0 && e.waiting.Load() {
+		e.advance()
+	}
+}
+
+// retire puts nodes, which the caller has just unlinked, in the current
+// epoch's limbo.
+func (e *epochs) retire(nodes []node) {
+	e.mu.Lock()
+	p := e.cur.Load() & 1
+	e.limbo[p] = append(e.limbo[p], nodes...)
+	e.waiting.Store(true)
+	e.mu.Unlock()
+	e.advance()
+}
+
+// advance moves the epoch on as far as the pinned operations allow, at
+// most twice, passing the nodes of each epoch it closes to the allocator.
+func (e *epochs) advance() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := 0; i < 2 && len(e.limbo[0])+len(e.limbo[1]) > 0; i++ {
+		cur := e.cur.Load()
+		// the previous epoch's counter, which the next epoch reuses
+		prev := (cur + 1) & 1
+		if e.pinned[prev].Load() != 0 {
+			break
+		}
+		for j, n := range e.limbo[prev] {
+			e.alloc.FreeNode(n)
+			e.limbo[prev][j] = nil
+		}
+		e.limbo[prev] = e.limbo[prev][:0]
+		e.cur.Store(cur + 1)
+	}
+	e.waiting.Store(len(e.limbo[0])+len(e.limbo[1]) > 0)
+}
@@ -0,0 +1,164 @@
+package art_test
+
+import (
+	"sync"
+	"testing"
+
+	"art"
+)
+
+// poolAllocator is an Allocator written outside the package: it keeps the
+// nodes it gets back and hands them out again.
+type poolAllocator struct {
+	mu                    sync.Mutex
+	allocs, reused, frees int
+	node48, wide          int
+	free4                 []*art.Node4
+	free16                []*art.Node16
+	free48                []*art.Node48
+	free256               []*art.Node256
+	freeWide              []*art.NodeWide
+}
+
+// take returns the last pooled node, or a new one.
+func take[N any](p *poolAllocator, pool *[]*N) *N {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.allocs++
+	if k := len(*pool); k > 0 {
+		n := (*pool)[k-1]
+		*pool = (*pool)[:k-1]
+		p.reused++
+		return n
+	}
+	return new(N)
+}
+
+func (p *poolAllocator) AllocNode4() *art.Node4   { return take(p, &p.free4) }
+func (p *poolAllocator) AllocNode16() *art.Node16 { return take(p, &p.free16) }
+func (p *poolAllocator) AllocNode48() *art.Node48 {
+	p.mu.Lock()
+	p.node48++
+	p.mu.Unlock()
+	return take(p, &p.free48)
+}
+func (p *poolAllocator) AllocNode256() *art.Node256 { return take(p, &p.free256) }
+func (p *poolAllocator) AllocNodeWide() *art.NodeWide {
+	p.mu.Lock()
+	p.wide++
+	p.mu.Unlock()
+	return take(p, &p.freeWide)
+}
+
+func (p *poolAllocator) FreeNode(n art.InnerNode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.frees++
+	switch n := n.(type) {
+	case *art.Node4:
+		p.free4 = append(p.free4, n)
+	case *art.Node16:
+		p.free16 = append(p.free16, n)
+	case *art.Node48:
+		p.free48 = append(p.free48, n)
+	case *art.Node256:
+		p.free256 = append(p.free256, n)
+	case *art.NodeWide:
+		p.freeWide = append(p.freeWide, n)
+	}
+}
+
+func TestExternalAllocator(t *testing.T) {
+	for name, opts := range map[string][]art.Option{
+		"plain": nil,
+		"max48": {art.WithMaxNode48()},
+		"wide":  {art.WithWideStride()},
+		"rcu":   {art.WithRCUReads()},
+		"rcu48": {art.WithRCUReads(), art.WithMaxNode48()},
+	} {
+		p := &poolAllocator{}
+		tree := art.NewART[int](append(opts, art.WithAllocator(p))...)
+		// Two bytes below "k", each one of 200 values without zeros
+		key := func(i int) []byte {
+			return []byte{'k', byte(i/200 + 1), byte(i%200 + 1)}
+		}
+		// Full fan-out on both bytes overflows capped node48s and gives
+		// Compact node256s to widen
+		const n = 200 * 200
+		for round := 0; round < 2; round++ {
+			for i := 0; i < n; i++ {
+				tree.Insert(key(i), i)
+			}
+			tree.Compact()
+			if tree.Len() != n {
+				t.Fatalf("Expected %d keys, got %d", n, tree.Len())
+			}
+			if err := tree.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < n; i++ {
+				if !tree.Delete(key(i)) {
+					t.Fatalf("Expected to delete key %d", i)
+				}
+			}
+		}
+		if p.allocs == 0 {
+			t.Errorf("%s: expected the tree to allocate from the allocator", name)
+		}
+		if name == "plain" && p.reused == 0 {
+			t.Error("Expected the second round to reuse freed nodes")
+		}
+		// Each of the 201 nodes of 200 children chains four overflow
+		// node48s when capped
+		if (name == "max48" || name == "rcu48") && p.node48 < 2*5*201 {
+			t.Errorf("%s: expected the overflow node48s from the allocator, got %d node48s", name, p.node48)
+		}
+		if name == "wide" && p.wide == 0 {
+			t.Error("Expected the wide nodes from the allocator")
+		}
+	}
+}
+
+func TestExternalAllocatorConcurrentReads(t *testing.T) {
+	p := &poolAllocator{}
+	tree := art.NewART[int](art.WithAllocator(p))
+	// Stable keys readers check, below the prefixes writers churn
+	stable := func(i int) []byte { return []byte{'s', byte(i/200 + 1), byte(i%200 + 1)} }
+	for i := 0; i < 2000; i++ {
+		tree.Insert(stable(i), i)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i = (i + 1) % 2000 {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if v, ok := tree.Search(stable(i)); !ok || v.(int) != i {
+					t.Errorf("Search(%v) = %v, %v while nodes were reused", stable(i), v, ok)
+					return
+				}
+			}
+		}()
+	}
+	// Each round grows the nodes above the stable keys' leaves and shrinks
+	// them again, returning them to the pool for the next round
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 2000; i++ {
+			tree.Insert([]byte{'s', byte(i/200 + 1), byte(i%200 + 1), 'x'}, -1)
+		}
+		for i := 0; i < 2000; i++ {
+			tree.Delete([]byte{'s', byte(i/200 + 1), byte(i%200 + 1), 'x'})
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if p.reused == 0 {
+		t.Error("Expected the rounds to reuse freed nodes")
+	}
+}
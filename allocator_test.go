@@ -0,0 +1,174 @@
+package art
+
+import (
+	"maps"
+	"sync"
+	"testing"
+)
+
+// countingAllocator counts the nodes it hands out and gets back, and fails
+// the test if a node it gets back is still reachable from the tree.
+type countingAllocator struct {
+	t      *testing.T
+	tree   *Tree[int]
+	allocs map[nodeType]int
+	frees  map[nodeType]int
+}
+
+func newCountingAllocator(t *testing.T) *countingAllocator {
+	return &countingAllocator{t: t, allocs: map[nodeType]int{}, frees: map[nodeType]int{}}
+}
+
+func (a *countingAllocator) AllocNode4() *node4 {
+	a.allocs[nodeType4]++
+	return new(node4)
+}
+func (a *countingAllocator) AllocNode16() *node16 {
+	a.allocs[nodeType16]++
+	return new(node16)
+}
+func (a *countingAllocator) AllocNode48() *node48 {
+	a.allocs[nodeType48]++
+	return new(node48)
+}
+func (a *countingAllocator) AllocNode256() *node256 {
+	a.allocs[nodeType256]++
+	return new(node256)
+}
+func (a *countingAllocator) AllocNodeWide() *nodeWide {
+	a.allocs[nodeTypeWide]++
+	return new(nodeWide)
+}
+func (a *countingAllocator) FreeNode(n node) {
+	a.frees[n.getType()]++
+	walkNodes(a.tree.root(), nil, func(reached node, _ []byte, _ []node) bool {
+		if reached == n {
+			a.t.Errorf("FreeNode(%s %p) while it is reachable", n.getType(), n)
+		}
+		return true
+	})
+}
+
+func TestAllocatorGrowShrink(t *testing.T) {
+	a := newCountingAllocator(t)
+	tree := NewART[int](WithAllocator(a))
+	a.tree = tree
+	// The root node4 grows to a node256 ...
+	for b := 1; b < 256; b++ {
+		tree.Insert([]byte{byte(b)}, b)
+	}
+	want := map[nodeType]int{nodeType4: 1, nodeType16: 1, nodeType48: 1, nodeType256: 1}
+	if !maps.Equal(a.allocs, want) {
+		t.Errorf("allocations after growing = %v, want %v", a.allocs, want)
+	}
+	if !maps.Equal(a.frees, map[nodeType]int{nodeType4: 1, nodeType16: 1, nodeType48: 1}) {
+		t.Errorf("frees after growing = %v", a.frees)
+	}
+
+	// ... and shrinks back to a node4
+	for b := 1; b < 253; b++ {
+		tree.Delete([]byte{byte(b)})
+	}
+	if typ := tree.root().getType(); typ != nodeType4 {
+		t.Fatalf("root is a %s after the deletes", typ)
+	}
+	want = map[nodeType]int{nodeType4: 2, nodeType16: 2, nodeType48: 2, nodeType256: 1}
+	if !maps.Equal(a.allocs, want) {
+		t.Errorf("allocations after shrinking = %v, want %v", a.allocs, want)
+	}
+	want = map[nodeType]int{nodeType4: 1, nodeType16: 2, nodeType48: 2, nodeType256: 1}
+	if !maps.Equal(a.frees, want) {
+		t.Errorf("frees after shrinking = %v, want %v", a.frees, want)
+	}
+
+	// A split allocates a node4 and a collapse frees it
+	tree.Insert([]byte{253, 'a'}, 0)
+	tree.Delete([]byte{253, 'a'})
+	if a.allocs[nodeType4] != 3 || a.frees[nodeType4] != 2 {
+		t.Errorf("node4 allocations and frees after a split and collapse = %d, %d", a.allocs[nodeType4], a.frees[nodeType4])
+	}
+}
+
+func TestAllocatorWaitsForReaders(t *testing.T) {
+	a := newCountingAllocator(t)
+	tree := NewART[int](WithAllocator(a))
+	a.tree = tree
+	// A reader that reached the root node4 before it grew
+	reader := tree.epochs.pin()
+	for b := 1; b <= 5; b++ {
+		tree.Insert([]byte{byte(b)}, b)
+	}
+	if len(a.frees) != 0 {
+		t.Errorf("frees while a reader may hold the grown node4 = %v", a.frees)
+	}
+	tree.epochs.unpin(reader)
+	if !maps.Equal(a.frees, map[nodeType]int{nodeType4: 1}) {
+		t.Errorf("frees once the reader finished = %v", a.frees)
+	}
+}
+
+// reusingAllocator stands in for an allocator that hands a freed node out
+// again at once: FreeNode empties the node and winds its version back to
+// the one readers saw before it was unlinked, the worst a reuse can do.
+type reusingAllocator struct {
+	heapAllocator
+}
+
+func (reusingAllocator) FreeNode(n node) {
+	var slots []slot
+	switch n := n.(type) {
+	case *node4:
+		slots = n.childPtr[:]
+	case *node16:
+		slots = n.childPtr[:]
+	case *node48:
+		slots = n.childPtr[:]
+	case *node256:
+		slots = n.childPtr[:]
+	}
+	for i := range slots {
+		slots[i].store(nil)
+	}
+	// undo the lock and the obsolete bit of the unlinking write
+	v := n.version().Load()
+	n.version().Store((v &^ OBSOLETE_BIT) - 2*LOCK_INCREMENT)
+}
+
+func TestAllocatorReuseUnderReaders(t *testing.T) {
+	tree := NewART[int](WithAllocator(reusingAllocator{}))
+	stable := func(i int) []byte { return []byte{'s', byte(i/200 + 1), byte(i%200 + 1)} }
+	for i := 0; i < 2000; i++ {
+		tree.Insert(stable(i), i)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i = (i + 1) % 2000 {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if v, ok := tree.Search(stable(i)); !ok || v.(int) != i {
+					t.Errorf("Search(%v) = %v, %v in a node freed under it", stable(i), v, ok)
+					return
+				}
+			}
+		}()
+	}
+	// Growing and shrinking the nodes above the stable keys frees them
+	// while the readers pass through
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 2000; i++ {
+			tree.Insert(append(stable(i), 'x'), -1)
+		}
+		for i := 0; i < 2000; i++ {
+			tree.Delete(append(stable(i), 'x'))
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
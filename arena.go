@@ -0,0 +1,62 @@
+package art
+
+import "sync/atomic"
+
+const (
+	// keyArenaChunk is the size of the buffers WithKeyArena packs keys into
+	keyArenaChunk = 64 << 10
+	// maxArenaKey is the longest key packed into a chunk; longer keys would
+	// waste too much of one and get their own allocation
+	maxArenaKey = keyArenaChunk / 16
+)
+
+// keyArena packs the keys of a tree created with WithKeyArena into shared
+// chunks. It is append-only: writers claim space with an atomic add and a
+// full chunk is replaced, never reused.
+type keyArena struct {
+	chunk atomic.Pointer[arenaChunk]
+}
+
+type arenaChunk struct {
+	buf  []byte
+	used atomic.Int64
+}
+
+// WithKeyArena stores keys packed into shared 64 KiB buffers instead of
+// allocating each leaf's key separately, which saves an allocation per key
+// in bulk loads and append-heavy trees and spares the collector one small
+// object per key. A buffer is reclaimed by the collector once every key in
+// it is deleted, so trees that delete or overwrite many keys can hold more
+// memory than without the arena: an overwrite leaves its copy of the key
+// unused, and a deleted key's bytes stay until its whole buffer goes.
+func WithKeyArena() Option {
+	return func(c *config) {
+		c.keyArena = true
+	}
+}
+
+// alloc returns a zeroed slice of length and capacity n from the arena, or
+// from the heap if a is nil or n is too long to pack.
+func (a *keyArena) alloc(n int) []byte {
+	if a == nil || n > maxArenaKey {
+		return make([]byte, n)
+	}
+	for {
+		c := a.chunk.Load()
+		if c != nil {
+			if end := c.used.Add(int64(n)); end <= int64(len(c.buf)) {
+				return c.buf[end-int64(n) : end : end]
+			}
+		}
+		// The chunk is full; of the writers racing to replace it, the
+		// first wins and the others retry with its chunk
+		a.chunk.CompareAndSwap(c, &arenaChunk{buf: make([]byte, keyArenaChunk)})
+	}
+}
+
+// copy returns a copy of key stored in the arena.
+func (a *keyArena) copy(key []byte) []byte {
+	c := a.alloc(len(key))
+	copy(c, key)
+	return c
+}
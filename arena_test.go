@@ -0,0 +1,122 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestKeyArena(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithInlineValueThreshold(8)}, {WithRCUReads()}} {
+		tree := NewART[[]byte](append(opts, WithKeyArena())...)
+		buf := make([]byte, 0, 32)
+		for i := 0; i < 5000; i++ {
+			// Reusing the caller's buffer must not disturb stored keys
+			buf = fmt.Appendf(buf[:0], "arena/%05d", i)
+			tree.Insert(buf, []byte{byte(i), 'v'})
+		}
+		long := bytes.Repeat([]byte("L"), maxArenaKey+1)
+		tree.Insert(long, []byte("long"))
+
+		for i := 0; i < 5000; i += 2 {
+			tree.Delete([]byte(fmt.Sprintf("arena/%05d", i)))
+		}
+		for i := 1; i < 5000; i += 4 {
+			tree.Insert([]byte(fmt.Sprintf("arena/%05d", i)), []byte{byte(i), 'w'})
+		}
+		for i := 0; i < 5000; i++ {
+			v, found := tree.Search([]byte(fmt.Sprintf("arena/%05d", i)))
+			val, _ := v.([]byte)
+			switch {
+			case i%2 == 0:
+				if found {
+					t.Fatalf("%d options: deleted key %d found", len(opts), i)
+				}
+			case i%4 == 1:
+				if !found || !bytes.Equal(val, []byte{byte(i), 'w'}) {
+					t.Fatalf("%d options: overwritten key %d = %q, %v", len(opts), i, val, found)
+				}
+			default:
+				if !found || !bytes.Equal(val, []byte{byte(i), 'v'}) {
+					t.Fatalf("%d options: key %d = %q, %v", len(opts), i, val, found)
+				}
+			}
+		}
+		if val, _ := tree.Search(long); string(val.([]byte)) != "long" {
+			t.Errorf("%d options: long key = %q", len(opts), val)
+		}
+		if err := tree.CheckInvariants(); err != nil {
+			t.Fatalf("%d options: %v", len(opts), err)
+		}
+
+		// Keys packed next to each other are capped, so appending to one
+		// cannot reach its neighbour
+		l, _, _ := tree.search([]byte("arena/00003"), 0, nil, 0)
+		if cap(l.key) != len(l.key) {
+			t.Errorf("%d options: key %q has capacity %d", len(opts), l.key, cap(l.key))
+		}
+	}
+}
+
+func TestKeyArenaConcurrentInserts(t *testing.T) {
+	tree := NewART[int](WithKeyArena())
+	const workers, perWorker = 8, 4000
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				tree.Insert([]byte(fmt.Sprintf("w%d/%06d", w, i)), w*perWorker+i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if tree.Len() != workers*perWorker {
+		t.Fatalf("Len = %d, want %d", tree.Len(), workers*perWorker)
+	}
+	for w := 0; w < workers; w++ {
+		for i := 0; i < perWorker; i++ {
+			if val, found := tree.Search([]byte(fmt.Sprintf("w%d/%06d", w, i))); !found || val != w*perWorker+i {
+				t.Fatalf("w%d/%06d = %d, %v", w, i, val, found)
+			}
+		}
+	}
+}
+
+// BenchmarkKeyArena reports the live heap and allocations per key over a
+// million inserts with keys allocated per leaf and packed into the arena.
+func BenchmarkKeyArena(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"slices", nil},
+		{"arena", []Option{WithKeyArena()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			const n = 1000000
+			var perKey, allocsPerKey float64
+			key := make([]byte, 0, 32)
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				tree := NewART[int](mode.opts...)
+				for j := 0; j < n; j++ {
+					key = fmt.Appendf(key[:0], "user:%010d", j*2654435761%n)
+					tree.Insert(key, j)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				perKey = float64(after.HeapAlloc-before.HeapAlloc) / n
+				allocsPerKey = float64(after.Mallocs-before.Mallocs) / n
+				runtime.KeepAlive(tree)
+			}
+			b.ReportMetric(perKey, "heap-B/key")
+			b.ReportMetric(allocsPerKey, "allocs/key")
+		})
+	}
+}
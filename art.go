@@ -2,10 +2,14 @@ package art
 
 import (
 	"bytes"
+	"hash/maphash"
 	"log"
 	"reflect"
 	"runtime"
+	"sync"
 	"sync/atomic"
+	"time"
+	"unsafe"
 )
 
 // todo
@@ -14,6 +18,7 @@ import (
 // 4) Improve performance after the OLC shit
 const TerminationChar = '\x00'
 const MaxInlinePrefixLength = 8
+const MaxInlineValueLength = 255
 const (
 	OBSOLETE_BIT   = uint64(1)
 	LOCK_BIT       = uint64(1 << 1)
@@ -28,167 +33,500 @@ const (
 	nodeType16
 	nodeType48
 	nodeType256
+	nodeTypeWide
 )
 
+func (t nodeType) String() string {
+	switch t {
+	case nodeTypeLeaf:
+		return "leaf"
+	case nodeType4:
+		return "node4"
+	case nodeType16:
+		return "node16"
+	case nodeType48:
+		return "node48"
+	case nodeType256:
+		return "node256"
+	case nodeTypeWide:
+		return "wide"
+	}
+	return "unknown"
+}
+
 type Tree[T any] struct {
-	node node
+	node       slot
+	trace      *tracer
+	valueType  reflect.Type
+	historyLen int
+	keyLen     int
+	metrics    *Metrics
+	aliases    atomic.Pointer[[]alias]
+	retirer    *retirer
+	flights    flights[T]
+	batches    batchState[T]
+	transform  func(key []byte) []byte
+	rcu        *rcuState
+	// inlineThreshold is the largest []byte value stored inline
+	inlineThreshold int
+	compactInts     bool
+	maxNode48       bool
+	wideStride      bool
+	growth          *growthState
+	// setLeaves is set when T holds no data, see newSetLeaf
+	setLeaves bool
+	observer  func(StructureEvent)
+	summaries *summaryState
+	// size counts the keys, maintained by every insert and delete
+	size      atomic.Int64
+	errorHook func(error)
+	// validateKey rejects keys before insertion, or is nil
+	validateKey    func(key []byte) error
+	rejectEmptyKey bool
+	selfOrganizing bool
+	fullPaths      bool
+	proactiveGrow  bool
+	columnarLeaves bool
+	// selfCheckInterval is the running self-check's period, or zero
+	selfCheckInterval time.Duration
+	// coalescing holds Inserts for WithWriteCoalescing, or is nil
+	coalescing *coalescer[T]
+	checksums  *checksumState
+	changes    *changeState
+	keys       *keyArena
+	alloc      Allocator
+	// epochs defers freeing unlinked nodes to alloc, or is nil without one
+	epochs    *epochs
+	snapshots SnapshotManager
+	// readOnly is set by SetReadOnly and checked by writers under writers
+	readOnly atomic.Bool
+	// writers is held shared by every mutation and exclusively by Quiesce
+	writers sync.RWMutex
 }
 
-func NewART[T any]() *Tree[T] {
-	return &Tree[T]{
-		node: newNode4(),
+func NewART[T any](opts ...Option) *Tree[T] {
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	alloc := cfg.allocator
+	if alloc == nil {
+		alloc = heapAllocator{}
+	}
+	t := &Tree[T]{
+		valueType:       cfg.valueType,
+		historyLen:      cfg.historyLen,
+		transform:       cfg.keyTransform,
+		inlineThreshold: cfg.inlineThreshold,
+		compactInts:     cfg.compactInts,
+		maxNode48:       cfg.maxNode48,
+		wideStride:      cfg.wideStride,
+		setLeaves:       zeroSize[T]() && !cfg.leafChecksums && !cfg.changeTracking,
+		observer:        cfg.structureObserver,
+		errorHook:       cfg.errorHook,
+		validateKey:     cfg.keyValidator,
+		rejectEmptyKey:  cfg.rejectEmptyKey,
+		selfOrganizing:  cfg.selfOrganizing,
+		fullPaths:       cfg.fullPaths,
+		proactiveGrow:   cfg.proactiveGrow,
+		columnarLeaves:  cfg.columnarLeaves,
+		alloc:           alloc,
+	}
+	t.node.store(allocNode4(alloc))
+	if _, ok := alloc.(heapAllocator); !ok {
+		t.epochs = &epochs{alloc: alloc}
+	}
+	if cfg.metrics {
+		t.metrics = &Metrics{}
+	}
+	if cfg.retireBudget > 0 {
+		t.retirer = &retirer{budget: cfg.retireBudget}
+	}
+	if cfg.rcuReads {
+		t.rcu = newRCUState(alloc)
+	}
+	if cfg.growthMonitor != nil {
+		t.growth = &growthState{fn: cfg.growthMonitor}
+	}
+	if cfg.leafChecksums {
+		t.checksums = &checksumState{seed: maphash.MakeSeed()}
+	}
+	if cfg.changeTracking {
+		t.changes = &changeState{}
 	}
+	if cfg.keyArena {
+		t.keys = &keyArena{}
+	}
+	if cfg.summaryBitsPerKey > 0 {
+		t.summaries = &summaryState{bitsPerKey: cfg.summaryBitsPerKey, seed: maphash.MakeSeed()}
+	}
+	if cfg.traceWriter != nil {
+		t.trace = &tracer{w: cfg.traceWriter}
+	}
+	if cfg.coalesceWindow > 0 {
+		t.coalescing = &coalescer[T]{window: cfg.coalesceWindow, pending: make(map[string]heldInsert[T])}
+	}
+	t.startSelfCheck(cfg.selfCheckInterval)
+	return t
 }
 
-func (t *Tree[T]) insert(key []byte, l *leaf, depth int, parent node, parentVersion uint64) {
+// emptyLike returns an empty tree configured like t, with its own counters.
+func (t *Tree[T]) emptyLike() *Tree[T] {
+	n := &Tree[T]{
+		trace:           t.trace,
+		valueType:       t.valueType,
+		historyLen:      t.historyLen,
+		keyLen:          t.keyLen,
+		transform:       t.transform,
+		inlineThreshold: t.inlineThreshold,
+		compactInts:     t.compactInts,
+		maxNode48:       t.maxNode48,
+		wideStride:      t.wideStride,
+		setLeaves:       t.setLeaves,
+		observer:        t.observer,
+		errorHook:       t.errorHook,
+		validateKey:     t.validateKey,
+		rejectEmptyKey:  t.rejectEmptyKey,
+		selfOrganizing:  t.selfOrganizing,
+		fullPaths:       t.fullPaths,
+		proactiveGrow:   t.proactiveGrow,
+		columnarLeaves:  t.columnarLeaves,
+		alloc:           t.alloc,
+	}
+	n.node.store(allocNode4(t.alloc))
+	if t.epochs != nil {
+		n.epochs = &epochs{alloc: t.alloc}
+	}
+	if t.metrics != nil {
+		n.metrics = &Metrics{}
+	}
+	if t.retirer != nil {
+		n.retirer = &retirer{budget: t.retirer.budget}
+	}
+	if t.rcu != nil {
+		n.rcu = newRCUState(t.alloc)
+	}
+	if t.growth != nil {
+		n.growth = &growthState{fn: t.growth.fn}
+	}
+	n.checksums = t.checksums
+	if t.changes != nil {
+		n.changes = &changeState{}
+	}
+	if t.keys != nil {
+		n.keys = &keyArena{}
+	}
+	if t.summaries != nil {
+		n.summaries = &summaryState{bitsPerKey: t.summaries.bitsPerKey, seed: t.summaries.seed}
+	}
+	if t.coalescing != nil {
+		n.coalescing = &coalescer[T]{window: t.coalescing.window, pending: make(map[string]heldInsert[T])}
+	}
+	return n
+}
+
+func (t *Tree[T]) insert(key []byte, l *leaf, update func(old interface{}) interface{}, depth int, parent node, parentVersion uint64) (err error) {
+	var held heldLocks
+	defer held.recover(&err)
+	// read before l is published, after which a writer may overwrite it
+	dead := holdsTombstone(l)
+	var hash summaryHash
+	// level counts the inner nodes above curNode
+	var level int
 restart:
 	parent = nil
 	parentVersion = 0
 	depth = 0
+	level = 0
 	curNodeAddress := &t.node
 	for {
 		if curNodeAddress == nil {
-			return
+			return nil
 		}
-		curNode := *curNodeAddress
+		curNode := curNodeAddress.load()
+		t.metrics.hook(OperationInsert, curNode, false)
 		version, needToRestart := readLockOrRestart(curNode)
 		if needToRestart {
+			t.metrics.restart(OperationInsert, CauseReadLock)
 			goto restart
 		}
-		needToRestart = !validate(curNode, version)
+		// depth counts parent's prefix, which a collapse of parent moves
+		// into curNode, so parent must still hold the version it was read
+		// at once curNode's has been taken
+		needToRestart = !validate(parent, parentVersion)
 		if needToRestart {
+			t.metrics.restart(OperationInsert, CauseParentValidation)
 			goto restart
 		}
+		t.metrics.hook(OperationInsert, curNode, true)
 		if curNode.getType() == nodeTypeLeaf {
-			needToRestart = upgradeToWriteLockOrRestart(parent, parentVersion)
+			needToRestart = held.upgrade(parent, parentVersion)
 			if needToRestart {
+				t.metrics.restart(OperationInsert, CauseUpgradeLock)
 				goto restart
 			}
-			needToRestart = upgradeToWriteLockOrRestart(curNode, version)
+			needToRestart = held.upgrade(curNode, version)
 			if needToRestart {
-				writeUnlock(parent)
+				held.unlock(parent)
+				t.metrics.restart(OperationInsert, CauseUpgradeLock)
 				goto restart
 			}
 			if len(curNode.(*leaf).key) == len(key) && bytes.Equal(curNode.(*leaf).key, key) {
-				(*curNodeAddress).(*leaf).val = l.val
-				writeUnlock(parent)
-				writeUnlock(curNode)
+				// The overwrite takes effect while the leaf lock is held:
+				// readers validate the leaf's version around their read of
+				// the value and writers of the key serialize on the lock, so
+				// it appears atomic at that point
+				target := curNode.(*leaf)
+				// Reading the old value would run a lazy value's loader,
+				// so only read it when something needs it
+				var old interface{}
+				if update != nil || t.historyLen > 1 {
+					old = target.value()
+				}
+				wasDead := holdsTombstone(target)
+				if update != nil {
+					target.setValue(update(old))
+				} else {
+					target.assign(l)
+				}
+				t.rewritten(wasDead, holdsTombstone(target))
+				t.seal(target)
+				t.carrySeq(target, l)
+				if t.historyLen > 1 {
+					target.pushHistory(old, t.historyLen-1)
+				}
+				t.trace.printf("insert overwrite key=%q leaf=%p version=%d depth=%d", key, curNode, version, depth)
+				held.unlock(parent)
+				held.unlock(curNode)
 				break
 			}
-			newNode := newNode4()
+			newNode := allocNode4(t.alloc)
 			key2 := curNode.(*leaf).key
 			commonPrefix := getCommonPrefix(key, key2, depth)
 			newNode.setPrefix(commonPrefix)
-			depth += int(newNode.prefixLen)
+			splitDepth := depth
+			depth += len(commonPrefix)
 			addChild(newNode, curNode, key2, depth)
 			addChild(newNode, l, key, depth)
-			*curNodeAddress = newNode
-			writeUnlock(parent)
-			writeUnlock(curNode)
+			t.inherit(newNode, curNode)
+			t.recordPath(newNode, key[:depth])
+			curNodeAddress.store(newNode)
+			t.trace.printf("split leaf key=%q leaf=%p version=%d new=%p depth=%d", key, curNode, version, newNode, depth)
+			t.added(dead)
+			held.unlock(parent)
+			held.unlock(curNode)
+			t.observe(NodeSplit, key, splitDepth, nodeType4, nodeType4, commonPrefix)
+			t.placed(level + 1)
 			break
 		}
 		curPrefixPtr := curNode.getPrefix()
 		needToRestart = !validate(curNode, version)
 		if needToRestart {
+			t.metrics.restart(OperationInsert, CausePrefixRace)
 			goto restart
 		}
 		p := checkPrefix(curPrefixPtr, key, depth)
 		if p != len(curPrefixPtr) { // prefix mismatch
-			needToRestart = upgradeToWriteLockOrRestart(parent, parentVersion)
+			needToRestart = held.upgrade(parent, parentVersion)
 			if needToRestart {
+				t.metrics.restart(OperationInsert, CauseUpgradeLock)
 				goto restart
 			}
-			needToRestart = upgradeToWriteLockOrRestart(curNode, version)
+			needToRestart = held.upgrade(curNode, version)
 			if needToRestart {
-				writeUnlock(parent)
+				held.unlock(parent)
+				t.metrics.restart(OperationInsert, CauseUpgradeLock)
 				goto restart
 			}
-			newNode := newNode4()
+			newNode := allocNode4(t.alloc)
 			curPrefix := append([]byte(nil), curPrefixPtr...)
 			addChild(newNode, l, key, depth+p)
 			addChild(newNode, curNode, curPrefix, p)
 			newNode.setPrefix(curPrefix[:p])
 			curNode.setPrefix(curPrefix[p:])
-			*curNodeAddress = newNode
-			writeUnlock(parent)
-			writeUnlock(curNode)
+			t.inherit(newNode, curNode)
+			t.recordPath(newNode, key[:depth+p])
+			curNodeAddress.store(newNode)
+			t.trace.printf("split prefix key=%q node=%p type=%s version=%d new=%p depth=%d", key, curNode, curNode.getType(), version, newNode, depth+p)
+			t.added(dead)
+			held.unlock(parent)
+			held.unlock(curNode)
+			t.observe(NodeSplit, key, depth, nodeType4, nodeType4, curPrefix[:p])
+			t.placed(level + 1)
 			break
 		}
 		depth += len(curPrefixPtr)
+		if t.summaries != nil {
+			// Recorded before the validation below, so a grow racing
+			// with this insert either copies the bits or makes it restart
+			if s := summaryOf(curNode); s != nil {
+				s.add(hash.of(t.summaries, key))
+			}
+		}
 		next := findChild(curNode, key, depth)
 		needToRestart = !validate(curNode, version)
 		if needToRestart {
+			t.metrics.restart(OperationInsert, CauseNodeValidation)
 			goto restart
 		}
-		if next == nil || *next == nil {
-			needToRestart = upgradeToWriteLockOrRestart(parent, parentVersion)
+		if next == nil || next.load() == nil {
+			// Racing inserts of the same key both arrive here with the same
+			// version; only one upgrade succeeds and the loser restarts into
+			// the overwrite branch
+			needToRestart = held.upgrade(parent, parentVersion)
 			if needToRestart {
+				t.metrics.restart(OperationInsert, CauseUpgradeLock)
 				goto restart
 			}
-			needToRestart = upgradeToWriteLockOrRestart(curNode, version)
+			needToRestart = held.upgrade(curNode, version)
 			if needToRestart {
-				writeUnlock(parent)
+				held.unlock(parent)
+				t.metrics.restart(OperationInsert, CauseUpgradeLock)
 				goto restart
 			}
 			if curNode.isFull() {
-				grown := curNode.grow()
+				grown := t.grow(curNode)
 				addChild(grown, l, key, depth)
-				*curNodeAddress = grown
-				writeUnlock(parent)
-				writeUnlockObsolete(curNode)
+				curNodeAddress.store(grown)
+				t.trace.printf("grow key=%q node=%p type=%s version=%d new=%p type=%s depth=%d", key, curNode, curNode.getType(), version, grown, grown.getType(), depth)
+				t.added(dead)
+				held.unlock(parent)
+				held.unlockObsolete(curNode)
+				t.retirer.retire(curNode)
+				t.free(curNode)
+				// the obsolete node's prefix no longer changes
+				t.observe(NodeGrew, key, depth-len(curPrefixPtr), curNode.getType(), grown.getType(), curPrefixPtr)
+				t.grew(curNode.getType(), grown.getType(), level+1)
 			} else {
-				addChild(*curNodeAddress, l, key, depth)
-				writeUnlock(parent)
-				writeUnlock(curNode)
+				reserveOverflow(t.alloc, curNode)
+				addChild(curNode, l, key, depth)
+				t.trace.printf("insert key=%q node=%p type=%s version=%d depth=%d", key, curNode, curNode.getType(), version, depth)
+				t.added(dead)
+				growAhead := t.proactiveGrow && nearlyFull(curNode)
+				held.unlock(parent)
+				held.unlock(curNode)
+				t.observe(ChildAdded, key, depth-len(curPrefixPtr), curNode.getType(), curNode.getType(), nil)
+				if growAhead {
+					// the insert's pin ends when it returns, so the grow
+					// takes its own before parent and curNode can be freed
+					go t.growAhead(t.epochs.pin(), parent, curNode, l.key, depth-len(curPrefixPtr), level+1)
+				}
 			}
+			t.placed(level + 1)
 			break
 		}
 		parent = curNode
 		parentVersion = version
 		curNodeAddress = next
+		level++
 		needToRestart = !validate(curNode, version)
 		if needToRestart {
+			t.metrics.restart(OperationInsert, CauseNodeValidation)
 			goto restart
 		}
 	}
+	return nil
 }
 
-func (t *Tree[T]) search(key []byte, depth int, parent node, parentVersion uint64) (interface{}, bool) {
+// grow returns n grown to the next larger type. In a tree created with
+// WithMaxNode48 the new node48 is capped, so it never grows further.
+func (t *Tree[T]) grow(n node) node {
+	grown := n.grow(t.alloc)
+	if n48, ok := grown.(*node48); ok && t.maxNode48 {
+		n48.capped = true
+	}
+	return grown
+}
+
+// search returns the leaf holding key along with the value read under its
+// validated version.
+func (t *Tree[T]) search(key []byte, depth int, parent node, parentVersion uint64) (*leaf, interface{}, bool) {
+	return t.lookup(key, nil)
+}
+
+// lookup is search recording its restarts and the reason for a miss in res
+// when res is not nil.
+func (t *Tree[T]) lookup(key []byte, res *SearchResult) (*leaf, interface{}, bool) {
+	if t.rejectEmptyKey && len(key) == 0 {
+		res.miss(MissNoChild)
+		return nil, nil, false
+	}
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	if t.rcu != nil {
+		res.miss(MissUntraced)
+		return t.rcuSearch(key)
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	// The root fast path records no reasons
+	if res == nil {
+		if l, val, found, ok := t.searchRoot(key); ok {
+			return l, val, found
+		}
+	}
+	var hash summaryHash
+	var parent node
+	var parentVersion uint64
+	var depth int
+	attempts := 0
 restart:
+	attempts++
+	if res != nil {
+		res.Restarts = attempts - 1
+	}
 	curNodeAddress := &t.node
 	parent = nil
 	parentVersion = 0
 	depth = 0
 	for {
 		if curNodeAddress == nil {
-			return nil, false
+			return nil, nil, false
 		}
-		curNode := *curNodeAddress
+		curNode := curNodeAddress.load()
 		if curNode == nil {
-			return nil, false
+			// A slot read empty may belong to a node a writer is
+			// rearranging, so it only means a miss if the parent held
+			if !validate(parent, parentVersion) {
+				t.metrics.restart(OperationSearch, CauseParentValidation)
+				goto restart
+			}
+			res.miss(MissNoChild)
+			return nil, nil, false
 		}
+		t.metrics.hook(OperationSearch, curNode, false)
 		version, needToRestart := readLockOrRestart(curNode)
 		if needToRestart {
+			t.metrics.restart(OperationSearch, CauseReadLock)
 			goto restart
 		}
 		needToRestart = !validate(parent, parentVersion)
 		if needToRestart {
+			t.metrics.restart(OperationSearch, CauseParentValidation)
 			goto restart
 		}
+		t.metrics.hook(OperationSearch, curNode, true)
 		if curNode.getType() == nodeTypeLeaf {
 			needToRestart = !validate(curNode, version)
 			if needToRestart {
+				t.metrics.restart(OperationSearch, CauseNodeValidation)
 				goto restart
 			}
 			curLeaf := curNode.(*leaf)
 			if len(curLeaf.key) == len(key) && bytes.Equal(curLeaf.key, key) {
+				// The value is read inside the validated window, so an
+				// overwrite holding the leaf lock is seen whole or not at all
+				val := curLeaf.value()
 				needToRestart = !validate(curNode, version)
 				if needToRestart {
+					t.metrics.restart(OperationSearch, CauseNodeValidation)
 					goto restart
 				}
-				return curLeaf.val, true
+				return curLeaf, val, true
 			}
-			return nil, false
+			t.trace.printf("search miss key=%q reason=leaf leaf=%p version=%d depth=%d", key, curNode, version, depth)
+			res.miss(MissLeafKey)
+			return nil, nil, false
 		}
 		pre := curNode.getPrefix()
 		p := checkPrefix(pre, key, depth)
@@ -196,65 +534,765 @@ restart:
 		if p != l {
 			needToRestart = !validate(curNode, version)
 			if needToRestart {
+				t.metrics.restart(OperationSearch, CausePrefixRace)
 				goto restart
 			}
-			return nil, false
+			t.trace.printf("search miss key=%q reason=prefix node=%p type=%s version=%d depth=%d", key, curNode, curNode.getType(), version, depth)
+			res.miss(MissPrefix)
+			return nil, nil, false
 		}
 		depth += len(pre)
+		if t.summaries != nil {
+			if s := summaryOf(curNode); s != nil && !s.mayContain(hash.of(t.summaries, key)) {
+				if !validate(curNode, version) {
+					t.metrics.restart(OperationSearch, CauseNodeValidation)
+					goto restart
+				}
+				t.trace.printf("search miss key=%q reason=summary node=%p type=%s version=%d depth=%d", key, curNode, curNode.getType(), version, depth)
+				res.miss(MissSummary)
+				return nil, nil, false
+			}
+		}
 		nextAdd := findChild(curNode, key, depth)
 		needToRestart = !validate(curNode, version)
 		if needToRestart {
+			t.metrics.restart(OperationSearch, CauseNodeValidation)
 			goto restart
 		}
 		if nextAdd != nil {
+			if t.selfOrganizing {
+				nextAdd, version = t.promote(curNode, nextAdd, version)
+			}
 			parent = curNode
 			parentVersion = version
 			curNodeAddress = nextAdd
 		} else {
 			needToRestart = !validate(curNode, version)
 			if needToRestart {
+				t.metrics.restart(OperationSearch, CauseNodeValidation)
 				goto restart
 			}
+			t.trace.printf("search miss key=%q reason=child node=%p type=%s version=%d depth=%d", key, curNode, curNode.getType(), version, depth)
+			res.miss(MissNoChild)
 			break
 		}
 	}
-	return nil, false
+	return nil, nil, false
+}
+
+// delete removes key, shrinking its parent when it becomes underfull and
+// collapsing a non-root parent left with a single child into that child.
+// Locks are taken top-down (grandparent, parent, leaf, remaining sibling),
+// the same order insert uses, so the two cannot deadlock.
+func (t *Tree[T]) delete(key []byte) bool {
+	return t.deleteStored(t.storedKey(key))
+}
+
+// storedKey returns key in the form the tree stores it, after its key
+// transform.
+func (t *Tree[T]) storedKey(key []byte) []byte {
+	if t.transform != nil {
+		return t.transform(key)
+	}
+	return key
+}
+
+// deleteStored is delete for a key already in its stored form.
+func (t *Tree[T]) deleteStored(key []byte) bool {
+	deleted, _ := t.tryDeleteStored(key)
+	return deleted
+}
+
+// tryDeleteStored is deleteStored that also returns the error, wrapping
+// ErrPanic, of a delete that panicked.
+func (t *Tree[T]) tryDeleteStored(key []byte) (deleted bool, err error) {
+	if t.rcu != nil {
+		return t.rcuDelete(key), nil
+	}
+	var held heldLocks
+	defer func() {
+		if err != nil {
+			t.reportError(err)
+			deleted = false
+		}
+	}()
+	defer held.recover(&err)
+	defer t.epochs.unpin(t.epochs.pin())
+restart:
+	var grandParent, parent node
+	var grandParentVersion, parentVersion uint64
+	var parentAddress *slot
+	depth := 0
+	curNodeAddress := &t.node
+	for {
+		curNode := curNodeAddress.load()
+		if curNode == nil {
+			if !validate(parent, parentVersion) {
+				t.metrics.restart(OperationDelete, CauseParentValidation)
+				goto restart
+			}
+			return false, nil
+		}
+		t.metrics.hook(OperationDelete, curNode, false)
+		version, needToRestart := readLockOrRestart(curNode)
+		if needToRestart {
+			t.metrics.restart(OperationDelete, CauseReadLock)
+			goto restart
+		}
+		if !validate(parent, parentVersion) {
+			t.metrics.restart(OperationDelete, CauseParentValidation)
+			goto restart
+		}
+		t.metrics.hook(OperationDelete, curNode, true)
+		if curNode.getType() == nodeTypeLeaf {
+			if !bytes.Equal(curNode.(*leaf).key, key) {
+				return false, nil
+			}
+			if !t.removeLeaf(curNode.(*leaf), version, key, depth, parent, parentVersion, parentAddress, grandParent, grandParentVersion, &held) {
+				t.metrics.restart(OperationDelete, CauseUpgradeLock)
+				goto restart
+			}
+			return true, nil
+		}
+		pre := curNode.getPrefix()
+		p := checkPrefix(pre, key, depth)
+		if p != len(pre) {
+			if !validate(curNode, version) {
+				t.metrics.restart(OperationDelete, CausePrefixRace)
+				goto restart
+			}
+			return false, nil
+		}
+		depth += len(pre)
+		next := findChild(curNode, key, depth)
+		if !validate(curNode, version) {
+			t.metrics.restart(OperationDelete, CauseNodeValidation)
+			goto restart
+		}
+		if next == nil {
+			return false, nil
+		}
+		grandParent, grandParentVersion = parent, parentVersion
+		parent, parentVersion = curNode, version
+		parentAddress = curNodeAddress
+		curNodeAddress = next
+	}
+}
+
+// removeLeaf unlinks l, found at depth below parent, and restructures parent
+// if needed. It reports false, having released every lock it took, when a
+// version check fails and the delete must restart.
+func (t *Tree[T]) removeLeaf(l *leaf, version uint64, key []byte, depth int, parent node, parentVersion uint64, parentAddress *slot, grandParent node, grandParentVersion uint64, held *heldLocks) bool {
+	count := parent.childCount()
+	collapse := parentAddress != &t.node && count == 2
+	shrink := underfullAt(parent.getType(), count-1)
+	if collapse || shrink {
+		if held.upgrade(grandParent, grandParentVersion) {
+			return false
+		}
+	} else {
+		grandParent = nil
+	}
+	if held.upgrade(parent, parentVersion) {
+		held.unlock(grandParent)
+		return false
+	}
+	if held.upgrade(l, version) {
+		held.unlock(parent)
+		held.unlock(grandParent)
+		return false
+	}
+	var sibling node
+	if collapse {
+		for _, child := range sortedChildren(parent) {
+			if child != node(l) {
+				sibling = child
+			}
+		}
+		if sibling.getType() != nodeTypeLeaf && held.lock(sibling) {
+			held.unlock(l)
+			held.unlock(parent)
+			held.unlock(grandParent)
+			return false
+		}
+	}
+
+	removeChild(parent, key, depth)
+	switch {
+	case collapse:
+		if sibling.getType() != nodeTypeLeaf {
+			// the sibling now hangs where parent did, so it absorbs parent's prefix
+			merged := append(append([]byte(nil), parent.getPrefix()...), sibling.getPrefix()...)
+			sibling.setPrefix(merged)
+		}
+		parentAddress.store(sibling)
+		t.trace.printf("collapse key=%q node=%p type=%s version=%d into=%p depth=%d", key, parent, parent.getType(), parentVersion, sibling, depth)
+		if sibling.getType() != nodeTypeLeaf {
+			held.unlock(sibling)
+		}
+		held.unlockObsolete(parent)
+	case shrink:
+		shrunk := parent.shrink(t.alloc)
+		parentAddress.store(shrunk)
+		t.trace.printf("shrink key=%q node=%p type=%s version=%d new=%p type=%s depth=%d", key, parent, parent.getType(), parentVersion, shrunk, shrunk.getType(), depth)
+		held.unlockObsolete(parent)
+	default:
+		t.trace.printf("delete key=%q node=%p type=%s version=%d depth=%d", key, parent, parent.getType(), parentVersion, depth)
+		held.unlock(parent)
+	}
+	held.unlock(grandParent)
+	held.unlockObsolete(l)
+	t.removed(l)
+	if collapse || shrink {
+		t.retirer.retire(parent, l)
+		t.free(parent)
+	} else {
+		t.retirer.retire(l)
+	}
+	return true
+}
+
+// underfullAt reports whether a node of type typ holding count children
+// should shrink to the next smaller type. The thresholds leave headroom
+// below the smaller type's capacity so that alternating inserts and deletes
+// at a boundary do not grow and shrink the same node repeatedly.
+func underfullAt(typ nodeType, count int) bool {
+	switch typ {
+	case nodeType16:
+		return count <= 3
+	case nodeType48:
+		return count <= 12
+	case nodeType256:
+		return count <= 37
+	}
+	return false
+}
+
+// searchRoot is the fast path for keys resolved directly below a root node4,
+// which covers small trees and the warm-up of larger ones. It skips the
+// general descent's bookkeeping and reports ok=false whenever the general
+// path is needed: the root is not a node4, is locked or obsolete, or the key
+// leads to an inner node.
+func (t *Tree[T]) searchRoot(key []byte) (l *leaf, val interface{}, found bool, ok bool) {
+	root, isNode4 := t.node.load().(*node4)
+	if !isNode4 || len(root.getPrefix()) != 0 {
+		return nil, nil, false, false
+	}
+	version := root.versionLockObsolete.version.Load()
+	if version&(LOCK_BIT|OBSOLETE_BIT) != 0 {
+		return nil, nil, false, false
+	}
+	var child node
+	if next := findChild(root, key, 0); next != nil {
+		child = next.load()
+	}
+	if child != nil {
+		l, isLeaf := child.(*leaf)
+		if !isLeaf {
+			return nil, nil, false, false
+		}
+		// Modify and ReplaceIf write values holding only the leaf lock, so
+		// the leaf's own version covers the read of val
+		leafVersion, needToRestart := readLockOrRestart(l)
+		if needToRestart {
+			return nil, nil, false, false
+		}
+		found = len(l.key) == len(key) && bytes.Equal(l.key, key)
+		val = l.value()
+		if !validate(l, leafVersion) || !validate(root, version) {
+			return nil, nil, false, false
+		}
+		if found {
+			return l, val, true, true
+		}
+		return nil, nil, false, true
+	}
+	if !validate(root, version) {
+		return nil, nil, false, false
+	}
+	t.trace.printf("search miss key=%q reason=child node=%p type=%s version=%d depth=%d", key, root, root.getType(), version, 0)
+	return nil, nil, false, true
+}
+
+// insertRoot is the fast path for adding a new leaf to a root node4 with a
+// free slot. It reports false, without modifying the tree, whenever the
+// general path is needed.
+func (t *Tree[T]) insertRoot(key []byte, l *leaf) (placed bool, err error) {
+	var held heldLocks
+	defer held.recover(&err)
+	dead := holdsTombstone(l)
+	root, isNode4 := t.node.load().(*node4)
+	if !isNode4 || len(root.getPrefix()) != 0 {
+		return false, nil
+	}
+	version := root.versionLockObsolete.version.Load()
+	if version&(LOCK_BIT|OBSOLETE_BIT) != 0 || root.isFull() || findChild(root, key, 0) != nil {
+		return false, nil
+	}
+	if held.upgrade(root, version) {
+		return false, nil
+	}
+	addChild(root, l, key, 0)
+	t.trace.printf("insert key=%q node=%p type=%s version=%d depth=%d", key, root, root.getType(), version, 0)
+	t.added(dead)
+	held.unlock(root)
+	t.observe(ChildAdded, key, 0, nodeType4, nodeType4, nil)
+	return true, nil
 }
+
+// Insert stores val under key, replacing any existing value. The key is
+// copied, so the caller may reuse or mutate its buffer after Insert returns.
+// Insert is linearizable: it takes effect atomically at a point between its
+// call and return, so once it returns no Search sees an older value of the
+// key, and concurrent overwrites of one key are ordered by when they take
+// the key's leaf lock. Which of several concurrent writers wins is not
+// otherwise defined. WithWriteCoalescing gives this up for fewer leaf
+// writes.
+// Insert drops values rejected by the tree's options; use TryInsert to
+// observe the rejection.
 func (t *Tree[T]) Insert(key []byte, val T) {
-	l := &leaf{
-		key:                 key,
-		versionLockObsolete: &atomic.Uint64{},
-		val:                 val,
+	if t.coalescing != nil {
+		t.coalesce(key, val)
+		return
+	}
+	_ = t.TryInsert(key, val)
+}
+
+// TryInsert behaves like Insert but reports values rejected by the tree's
+// options instead of dropping them. A panic during the insert is recovered
+// once the locks it held are released and returned as an error wrapping
+// ErrPanic, which is also passed to the tree's error hook; the tree stays
+// usable, though the insert may or may not have taken effect. A read-only
+// tree rejects the insert with ErrReadOnly.
+func (t *Tree[T]) TryInsert(key []byte, val T) error {
+	if err := t.checkInsert(key, val); err != nil {
+		return err
+	}
+	if t.compactInts {
+		if tag, bits, ok := compact(val); ok {
+			return t.upsertBits(key, tag, bits, nil)
+		}
+	}
+	return t.upsert(key, val, nil)
+}
+
+// checkInsert returns the error TryInsert reports for key and val, if any.
+func (t *Tree[T]) checkInsert(key []byte, val T) error {
+	if t.valueType != nil && !assignable(val, t.valueType) {
+		return ErrValueTypeMismatch
+	}
+	return t.checkKey(key)
+}
+
+// checkKey returns the error TryInsert reports for key, if any.
+func (t *Tree[T]) checkKey(key []byte) error {
+	if t.keyLen > 0 && len(key) != t.keyLen {
+		return ErrKeyLength
+	}
+	if t.rejectEmptyKey && len(key) == 0 {
+		return ErrEmptyKey
+	}
+	if t.validateKey != nil {
+		return t.validateKey(key)
+	}
+	return nil
+}
+
+// upsert inserts val under key, or if key already exists replaces its value
+// with update(old) while holding the leaf's write lock. It returns an error
+// wrapping ErrPanic if the write panicked.
+func (t *Tree[T]) upsert(key []byte, val interface{}, update func(old interface{}) interface{}) error {
+	var bits uint64
+	if t.compactInts {
+		val, bits, _ = compact(val)
+	}
+	return t.upsertBits(key, val, bits, update)
+}
+
+// upsertBits is upsert for a value already split into a compact tag and its
+// bits, or a plain value with zero bits.
+func (t *Tree[T]) upsertBits(key []byte, val interface{}, bits uint64, update func(old interface{}) interface{}) error {
+	defer t.maybeRebuildSummaries()
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return t.place(key, val, bits, update)
+}
+
+// upsertStored inserts val under key, already in its stored form, such as
+// a key read from the leaves of a tree with the same transform. Any value
+// the key held is replaced.
+func (t *Tree[T]) upsertStored(key []byte, val interface{}) error {
+	var bits uint64
+	if t.compactInts {
+		val, bits, _ = compact(val)
+	}
+	defer t.maybeRebuildSummaries()
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return t.placeStored(key, val, bits, nil)
+}
+
+// removeStored deletes key, already in its stored form, as Delete does.
+func (t *Tree[T]) removeStored(key []byte) bool {
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return false
+	}
+	return t.deleteStored(key)
+}
+
+// place is upsertBits for a caller already holding writers.
+func (t *Tree[T]) place(key []byte, val interface{}, bits uint64, update func(old interface{}) interface{}) error {
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	return t.placeStored(key, val, bits, update)
+}
+
+// placeStored is place for a key already in its stored form.
+func (t *Tree[T]) placeStored(key []byte, val interface{}, bits uint64, update func(old interface{}) interface{}) error {
+	if t.inlineThreshold > 0 {
+		key, val = inline(key, val, t.inlineThreshold, t.keys)
+	} else {
+		key = t.keys.copy(key)
+	}
+	var l *leaf
+	if t.setLeaves {
+		l = newSetLeaf(key, val)
+	} else {
+		l = &leaf{
+			key:                 key,
+			versionLockObsolete: &atomic.Uint64{},
+		}
+		l.setRaw(val)
+		l.bits.Store(bits)
+		t.seal(l)
+	}
+	s := t.stamp(l)
+	if t.rcu != nil {
+		t.rcuUpsert(key, l, update, s)
+		return nil
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	placed, err := t.insertRoot(key, l)
+	if err == nil && !placed {
+		err = t.insert(key, l, update, 0, nil, 0)
 	}
-	t.insert(key, l, 0, nil, 0)
+	if err != nil {
+		t.reportError(err)
+		return err
+	}
+	t.markPath(nil, key, s)
+	return nil
+}
+
+// Len returns the number of keys, from a counter maintained by inserts and
+// deletes rather than a traversal. Keys holding a tombstone do not count.
+func (t *Tree[T]) Len() int {
+	return int(t.size.Load())
+}
+
+// Delete removes key and reports whether it was present. A panic during
+// the delete is recovered like one during TryInsert and reported to the
+// tree's error hook, and Delete returns false. Delete on a read-only tree
+// removes nothing and returns false. With WithWriteCoalescing it also
+// discards the Insert of key not yet written, and counts it as present.
+func (t *Tree[T]) Delete(key []byte) bool {
+	if t.coalescing != nil {
+		// Taken before writers, like the flushes it orders the delete with
+		t.coalescing.applying.Lock()
+		defer t.coalescing.applying.Unlock()
+	}
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return false
+	}
+	key = t.storedKey(key)
+	held := t.coalescing != nil && t.coalescing.drop(key)
+	return t.deleteStored(key) || held
 }
+
+// DeleteMin removes the smallest key and returns it with the value it held,
+// for retention that expires the oldest keys first. ok is false when the
+// tree is empty or read-only. Like Delete, it collapses the nodes the
+// removal leaves with a single child and shrinks underfull ones, so a tree
+// drained from the left keeps no structure for the keys it lost. Keys
+// holding a tombstone are not keys to it: they are passed over and left in
+// place. If the smallest key is overwritten concurrently, the value
+// returned may be the one the overwrite replaced.
+func (t *Tree[T]) DeleteMin() (key []byte, val T, ok bool) {
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return nil, val, false
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	for {
+		var min *leaf
+		walk(t.root(), func(l *leaf) bool {
+			min = l
+			return false
+		})
+		if min == nil {
+			return nil, val, false
+		}
+		v, alive := live(readLeaf(min), true)
+		if !alive {
+			// tombstoned since the walk, which now passes over it
+			continue
+		}
+		// a concurrent delete may have taken the key first
+		if t.deleteStored(min.key) {
+			return min.key, valueAs[T](v), true
+		}
+	}
+}
+
+// SearchCanonical is Search that also returns the key as stored in the
+// matching leaf, which under WithKeyTransform is the canonical form of the
+// query. The stored key is shared with the tree and must not be modified.
+func (t *Tree[T]) SearchCanonical(key []byte) (storedKey []byte, val T, found bool) {
+	l, v, found := t.search(key, 0, nil, 0)
+	if v, found = live(v, found); !found {
+		return nil, val, false
+	}
+	return l.key, valueAs[T](v), true
+}
+
+// Search returns the value stored under key. The key is only read for
+// comparison: Search neither retains nor mutates it, so a sub-slice of a
+// larger buffer is safe to pass. A key holding a tombstone is reported
+// absent; Lookup tells the two apart. A Search begun after an Insert of key
+// returned, in the same goroutine or one synchronized with it, finds that
+// value or a later one: a lookup racing a grow or split on its path
+// restarts rather than missing the key.
 func (t *Tree[T]) Search(key []byte) (interface{}, bool) {
-	return t.search(key, 0, nil, 0)
+	_, val, found := t.search(key, 0, nil, 0)
+	if !found {
+		if a, ok := t.resolveAlias(key); ok {
+			_, val, found = t.search(a.rewrite(key), 0, nil, 0)
+		}
+	}
+	return live(val, found)
+}
+
+// SearchInto is Search that copies the value found into *dst instead of
+// returning it boxed, and leaves *dst untouched when key is absent or
+// holds a tombstone. The tree stores values boxed already, so Search
+// allocates nothing either; SearchInto saves the caller the type assertion
+// and the intermediate copy of a large T.
+func (t *Tree[T]) SearchInto(key []byte, dst *T) bool {
+	val, found := t.Search(key)
+	if found {
+		*dst = valueAs[T](val)
+	}
+	return found
+}
+
+// SearchCtx is Search that also fills res with the number of restarts the
+// lookup took and, on a miss, where it decided the key was absent. It is
+// meant for diagnosing unexpected misses under concurrent writes; the
+// lookup skips the root fast path so that every miss has a reason. res
+// describes the last lookup made, which for a key under an alias is the
+// lookup of the rewritten key.
+func (t *Tree[T]) SearchCtx(key []byte, res *SearchResult) (T, bool) {
+	*res = SearchResult{}
+	_, val, found := t.lookup(key, res)
+	if !found {
+		if a, ok := t.resolveAlias(key); ok {
+			_, val, found = t.lookup(a.rewrite(key), res)
+		}
+	}
+	val, found = live(val, found)
+	return valueAs[T](val), found
+}
+
+// SearchHits looks up every key and returns only those found, in input
+// order, with their values at the same positions. Misses are dropped
+// instead of reported, which suits intersecting a query set with the tree.
+// The returned keys are the caller's slices.
+func (t *Tree[T]) SearchHits(keys [][]byte) (hitKeys [][]byte, hitVals []T) {
+	for _, key := range keys {
+		if val, found := t.Search(key); found {
+			hitKeys = append(hitKeys, key)
+			hitVals = append(hitVals, valueAs[T](val))
+		}
+	}
+	return hitKeys, hitVals
 }
 
 type node interface {
 	getType() nodeType
-	findChild(b byte) *node
+	findChild(b byte) *slot
 	isFull() bool
 	getPrefix() []byte
 	addChild(k byte, child node)
-	grow() node
+	grow(a Allocator) node
 	setPrefix(prefix []byte)
 	version() *atomic.Uint64
+	removeChild(k byte)
+	shrink(a Allocator) node
+	childCount() int
+}
+
+// slot holds a child pointer of an inner node, or the root pointer. Writers
+// replace a child with a node of another type while optimistic readers load
+// the slot, and an interface is two words, so a plain load could pair one
+// node's type with another's data. A slot instead holds the interface boxed
+// and publishes each box with a single atomic store; boxes never change
+// once stored, so slots may share them.
+type slot struct {
+	p unsafe.Pointer // *node
+}
+
+func (s *slot) load() node {
+	if p := (*node)(atomic.LoadPointer(&s.p)); p != nil {
+		return *p
+	}
+	return nil
+}
+
+func (s *slot) store(n node) {
+	if n == nil {
+		atomic.StorePointer(&s.p, nil)
+		return
+	}
+	atomic.StorePointer(&s.p, unsafe.Pointer(&n))
+}
+
+// move stores the child held by from in s.
+func (s *slot) move(from *slot) {
+	atomic.StorePointer(&s.p, atomic.LoadPointer(&from.p))
+}
+
+// swap exchanges the children held by s and o.
+func (s *slot) swap(o *slot) {
+	p := atomic.LoadPointer(&s.p)
+	s.move(o)
+	atomic.StorePointer(&o.p, p)
+}
+
+// shift moves the children of slots one slot left from i, emptying the last
+// one, for a removal that keeps them dense.
+func shift(slots []slot, i int) {
+	for ; i+1 < len(slots); i++ {
+		slots[i].move(&slots[i+1])
+	}
+	slots[len(slots)-1].store(nil)
+}
+
+// The other node fields a writer changes in place under the node's lock
+// are likewise read and written with sync/atomic, so that they too are safe
+// to read optimistically: child counts, the key bytes of node4 and node16,
+// packed four to a word, node48's indexes and overflow, and the prefix.
+
+// nodePrefix is an inner node's compressed path. A prefix that fits is
+// stored inline, but only into a node that never had one, and published by
+// its length; any later or longer prefix is a fresh copy published through
+// ptr, which then takes precedence. The bytes a reader reaches therefore
+// never change.
+type nodePrefix struct {
+	ptr    unsafe.Pointer // *[]byte
+	inline [MaxInlinePrefixLength]byte
+	length uint32
+}
+
+func (p *nodePrefix) get() []byte {
+	if long := (*[]byte)(atomic.LoadPointer(&p.ptr)); long != nil {
+		return *long
+	}
+	return p.inline[:atomic.LoadUint32(&p.length)]
+}
+
+// set replaces the prefix. The caller holds the node's write lock, or no
+// reader can reach the node yet.
+func (p *nodePrefix) set(prefix []byte) {
+	if p.ptr == nil && p.length == 0 && len(prefix) <= MaxInlinePrefixLength {
+		copy(p.inline[:], prefix)
+		atomic.StoreUint32(&p.length, uint32(len(prefix)))
+		return
+	}
+	long := append([]byte{}, prefix...)
+	atomic.StorePointer(&p.ptr, unsafe.Pointer(&long))
+}
+
+// heap returns the bytes held by an out-of-line prefix.
+func (p *nodePrefix) heap() int64 {
+	long := (*[]byte)(atomic.LoadPointer(&p.ptr))
+	if long == nil {
+		return 0
+	}
+	return int64(unsafe.Sizeof(*long)) + int64(cap(*long))
+}
+
+// keyAt returns the ith key byte of keys, packed four to a word.
+func keyAt(keys []uint32, i int) byte {
+	return byte(atomic.LoadUint32(&keys[i/4]) >> (8 * (i % 4)))
+}
+
+// setKeyAt stores b as the ith key byte of keys. The caller holds the
+// node's write lock.
+func setKeyAt(keys []uint32, i int, b byte) {
+	shift := 8 * (i % 4)
+	word := keys[i/4]&^(0xff<<shift) | uint32(b)<<shift
+	atomic.StoreUint32(&keys[i/4], word)
+}
+
+// unpackKeys returns the first count key bytes of keys.
+func unpackKeys(keys []uint32, count int) []byte {
+	unpacked := make([]byte, count)
+	for i := range unpacked {
+		unpacked[i] = keyAt(keys, i)
+	}
+	return unpacked
+}
+
+// removeKeyed removes the child under k from the keys and slots of a node4
+// or node16 holding *count children, moving the later ones left.
+func removeKeyed(keys []uint32, slots []slot, count *uint32, k byte) {
+	n := int(*count)
+	for i := 0; i < n; i++ {
+		if keyAt(keys, i) == k {
+			for j := i; j+1 < n; j++ {
+				setKeyAt(keys, j, keyAt(keys, j+1))
+			}
+			shift(slots[:n], i)
+			atomic.StoreUint32(count, uint32(n-1))
+			setKeyAt(keys, n-1, 0)
+			return
+		}
+	}
 }
 
 type leaf struct {
 	key                 []byte
 	versionLockObsolete *atomic.Uint64 //62b version 1b lock 1b obsolete
-	val                 interface{}
+	// val holds the value boxed, like a slot, since writers replace it in
+	// place while optimistic readers load it; see raw
+	val     atomic.Pointer[interface{}]
+	history atomic.Pointer[valueHistory]
+	// bits holds a compact value
+	bits atomic.Uint64
+	// sum is the checksum of WithLeafChecksums and seq the sequence of the
+	// latest write under WithChangeTracking; both stay zero otherwise
+	sum atomic.Uint64
+	seq atomic.Uint64
 }
 
 func (l *leaf) setPrefix(prefix []byte) {
 }
-func (l *leaf) findChild(b byte) *node {
+func (l *leaf) findChild(b byte) *slot {
 	return nil
 }
-func (l *leaf) grow() node {
+func (l *leaf) grow(a Allocator) node {
 	return nil
 }
 func (l *leaf) getType() nodeType {
@@ -269,6 +1307,14 @@ func (l *leaf) getPrefix() []byte {
 func (l *leaf) addChild(k byte, child node) {
 	return
 }
+func (l *leaf) removeChild(k byte) {
+}
+func (l *leaf) shrink(a Allocator) node {
+	return nil
+}
+func (l *leaf) childCount() int {
+	return 0
+}
 func (l *leaf) version() *atomic.Uint64 {
 	if l.versionLockObsolete == nil {
 		log.Printf("ERROR: nil versionLockObsolete  %p", l)
@@ -278,254 +1324,318 @@ func (l *leaf) version() *atomic.Uint64 {
 }
 
 type node4 struct {
-	childPtr            [4]node
-	prefixPtr           []byte
-	prefix              [MaxInlinePrefixLength]byte
-	versionLockObsolete *atomic.Uint64 //62b version 1b lock 1b obsolete
-	keys                [4]uint8
-	prefixLen           uint16
-	numOfChildren       uint8
+	childPtr            [4]slot
+	prefix              nodePrefix
+	versionLockObsolete *innerVersion //62b version 1b lock 1b obsolete, see innerVersion
+	keys                [1]uint32     // the children's key bytes, see keyAt
+	numOfChildren       uint32
 }
 
 func (n *node4) setPrefix(prefix []byte) {
-	length := len(prefix)
-	n.prefixLen = uint16(length)
-	if length <= MaxInlinePrefixLength {
-		n.prefix = [8]byte{}
-		copy(n.prefix[:length], prefix)
-		return
-	}
-	n.prefixPtr = prefix
+	n.prefix.set(prefix)
 }
-func (n *node4) grow() node {
-
-	newNode := &node16{
-		childPtr:            [16]node{},
-		prefixPtr:           n.prefixPtr,
-		keys:                [16]uint8{},
+func (n *node4) grow(a Allocator) node {
+	newNode := a.AllocNode16()
+	*newNode = node16{
 		prefix:              n.prefix,
-		prefixLen:           n.prefixLen,
 		numOfChildren:       n.numOfChildren,
-		versionLockObsolete: &atomic.Uint64{},
+		versionLockObsolete: inheritedVersion(n.versionLockObsolete),
 	}
 
-	copy(newNode.keys[:], n.keys[:])
+	newNode.keys[0] = n.keys[0]
 	copy(newNode.childPtr[:], n.childPtr[:])
 	return newNode
 }
 func (n *node4) getPrefix() []byte {
-	if n.prefixLen > MaxInlinePrefixLength {
-		return n.prefixPtr
-	}
-	return n.prefix[:n.prefixLen]
-
+	return n.prefix.get()
 }
 func (n *node4) getType() nodeType {
 	return nodeType4
 }
 func (n *node4) isFull() bool {
-	return n.numOfChildren == 4
+	return atomic.LoadUint32(&n.numOfChildren) == 4
 }
-func (n *node4) findChild(b byte) *node {
-	if n.numOfChildren > 0 && n.keys[0] == b {
-		return &n.childPtr[0]
-	}
-	if n.numOfChildren > 1 && n.keys[1] == b {
-		return &n.childPtr[1]
-	}
-	if n.numOfChildren > 2 && n.keys[2] == b {
-		return &n.childPtr[2]
-	}
-	if n.numOfChildren > 3 && n.keys[3] == b {
-		return &n.childPtr[3]
+func (n *node4) findChild(b byte) *slot {
+	count := int(atomic.LoadUint32(&n.numOfChildren))
+	keys := atomic.LoadUint32(&n.keys[0])
+	for i := 0; i < count && i < len(n.childPtr); i++ {
+		if byte(keys>>(8*i)) == b {
+			return &n.childPtr[i]
+		}
 	}
 	return nil
 }
 func (n *node4) addChild(k byte, child node) {
-	n.keys[n.numOfChildren] = k
-	n.childPtr[n.numOfChildren] = child
-	n.numOfChildren++
+	i := int(n.numOfChildren)
+	setKeyAt(n.keys[:], i, k)
+	n.childPtr[i].store(child)
+	atomic.StoreUint32(&n.numOfChildren, uint32(i+1))
+}
+func (n *node4) removeChild(k byte) {
+	removeKeyed(n.keys[:], n.childPtr[:], &n.numOfChildren, k)
+}
+func (n *node4) shrink(a Allocator) node {
+	return nil
+}
+func (n *node4) childCount() int {
+	return int(atomic.LoadUint32(&n.numOfChildren))
 }
 func (n *node4) version() *atomic.Uint64 {
 	if n.versionLockObsolete == nil {
 		log.Printf("ERROR: nil versionLockObsolete  %p", n)
 		panic("nil versionLockObsolete")
 	}
-	return n.versionLockObsolete
+	return &n.versionLockObsolete.version
 }
 
 type node16 struct {
-	childPtr            [16]node
-	prefixPtr           []byte
-	keys                [16]uint8
-	prefix              [MaxInlinePrefixLength]byte
-	versionLockObsolete *atomic.Uint64 //62b version 1b lock 1b obsolete
-	prefixLen           uint16
-	numOfChildren       uint8
+	childPtr            [16]slot
+	prefix              nodePrefix
+	keys                [4]uint32     // the children's key bytes, see keyAt
+	versionLockObsolete *innerVersion //62b version 1b lock 1b obsolete, see innerVersion
+	numOfChildren       uint32
 }
 
 func (n *node16) setPrefix(pre []byte) {
-	length := len(pre)
-	n.prefixLen = uint16(length)
-	if length <= MaxInlinePrefixLength {
-		n.prefix = [8]byte{}
-		for i := 0; i < length; i++ {
-			n.prefix[i] = pre[i]
-		}
-		return
-	}
-	n.prefixPtr = pre
+	n.prefix.set(pre)
 }
 func (n *node16) getType() nodeType {
 	return nodeType16
 }
-func (n *node16) findChild(b byte) *node {
-	for i := 0; i < 16; i += 4 {
-		if n.keys[i] == b {
-			return &n.childPtr[i]
+func (n *node16) findChild(b byte) *slot {
+	for w := range n.keys {
+		keys := atomic.LoadUint32(&n.keys[w])
+		if byte(keys) == b {
+			return &n.childPtr[4*w]
 		}
-		if n.keys[i+1] == b {
-			return &n.childPtr[i+1]
+		if byte(keys>>8) == b {
+			return &n.childPtr[4*w+1]
 		}
-		if n.keys[i+2] == b {
-			return &n.childPtr[i+2]
+		if byte(keys>>16) == b {
+			return &n.childPtr[4*w+2]
 		}
-		if n.keys[i+3] == b {
-			return &n.childPtr[i+3]
+		if byte(keys>>24) == b {
+			return &n.childPtr[4*w+3]
 		}
 	}
 	return nil
 }
 func (n *node16) isFull() bool {
-	return n.numOfChildren == 16
+	return atomic.LoadUint32(&n.numOfChildren) == 16
 }
 func (n *node16) getPrefix() []byte {
-	if n.prefixLen > MaxInlinePrefixLength {
-		return n.prefixPtr
-	}
-	return n.prefix[:n.prefixLen]
+	return n.prefix.get()
 }
 func (n *node16) addChild(k byte, child node) {
-	n.keys[n.numOfChildren] = k
-	n.childPtr[n.numOfChildren] = child
-	n.numOfChildren++
-}
-func (n *node16) grow() node {
-	var idxArr [256]int16
-	for i := 0; i < 256; i++ {
-		idxArr[i] = -1
-	}
-	newNode := node48{
-		childPtr:            [48]node{},
-		prefixPtr:           n.prefixPtr,
-		childIndex:          idxArr,
+	i := int(n.numOfChildren)
+	setKeyAt(n.keys[:], i, k)
+	n.childPtr[i].store(child)
+	atomic.StoreUint32(&n.numOfChildren, uint32(i+1))
+}
+func (n *node16) removeChild(k byte) {
+	removeKeyed(n.keys[:], n.childPtr[:], &n.numOfChildren, k)
+}
+func (n *node16) shrink(a Allocator) node {
+	newNode := a.AllocNode4()
+	*newNode = node4{
 		prefix:              n.prefix,
-		prefixLen:           n.prefixLen,
 		numOfChildren:       n.numOfChildren,
-		versionLockObsolete: &atomic.Uint64{},
+		versionLockObsolete: inheritedVersion(n.versionLockObsolete),
 	}
+	// a node16 shrinks once its children fit the first word of keys
+	newNode.keys[0] = n.keys[0]
+	copy(newNode.childPtr[:], n.childPtr[:n.numOfChildren])
+	return newNode
+}
+func (n *node16) childCount() int {
+	return int(atomic.LoadUint32(&n.numOfChildren))
+}
+func (n *node16) grow(a Allocator) node {
+	newNode := a.AllocNode48()
+	*newNode = node48{
+		prefix:              n.prefix,
+		numOfChildren:       n.numOfChildren,
+		versionLockObsolete: inheritedVersion(n.versionLockObsolete),
+	}
+	newNode.clearIndex()
 	for i := 0; i < int(n.numOfChildren); i++ {
-		newNode.childPtr[i] = n.childPtr[i]
-		newNode.childIndex[n.keys[i]] = int16(i)
+		newNode.childPtr[i].move(&n.childPtr[i])
+		newNode.setIndex(keyAt(n.keys[:], i), int16(i))
 	}
-	return &newNode
+	return newNode
 }
 func (n *node16) version() *atomic.Uint64 {
 	if n.versionLockObsolete == nil {
 		log.Printf("ERROR: nil versionLockObsolete  %p", n)
 		panic("nil versionLockObsolete")
 	}
-	return n.versionLockObsolete
+	return &n.versionLockObsolete.version
 }
 
 type node48 struct {
-	childPtr            [48]node
-	prefixPtr           []byte
-	childIndex          [256]int16
-	versionLockObsolete *atomic.Uint64 //62b version 1b lock 1b obsolete
-	prefix              [MaxInlinePrefixLength]byte
-	prefixLen           uint16
-	numOfChildren       uint8
+	childPtr            [48]slot
+	prefix              nodePrefix
+	childIndex          [128]uint32    // each key byte's slot or -1, see index
+	versionLockObsolete *innerVersion  //62b version 1b lock 1b obsolete, see innerVersion
+	summary             unsafe.Pointer // *nodeSummary
+	// overflow holds the children past the first 48 of a capped node. It is
+	// reached only through this node and guarded by its lock.
+	overflow      unsafe.Pointer // *node48
+	numOfChildren uint32
+	// capped nodes chain overflow nodes instead of growing into a node256
+	capped bool
 }
 
-func (n *node48) setPrefix(prefix []byte) {
-	length := len(prefix)
-	n.prefixLen = uint16(length)
-	if length <= MaxInlinePrefixLength {
-		n.prefix = [8]byte{}
-		copy(n.prefix[:length], prefix)
-		return
+// index returns the slot of the child under b, or -1. The indexes are
+// packed two to a word, so that writers can update one while optimistic
+// readers load them.
+func (n *node48) index(b byte) int16 {
+	return int16(atomic.LoadUint32(&n.childIndex[b/2]) >> (16 * (b % 2)))
+}
+
+func (n *node48) setIndex(b byte, idx int16) {
+	shift := 16 * (b % 2)
+	word := n.childIndex[b/2]&^(0xffff<<shift) | uint32(uint16(idx))<<shift
+	atomic.StoreUint32(&n.childIndex[b/2], word)
+}
+
+// clearIndex marks every byte of n, which no reader has reached yet, as
+// having no child.
+func (n *node48) clearIndex() {
+	for i := range n.childIndex {
+		n.childIndex[i] = ^uint32(0)
 	}
-	n.prefixPtr = prefix
+}
+
+// next returns the overflow node holding the children past n's first 48, or
+// nil.
+func (n *node48) next() *node48 {
+	return (*node48)(atomic.LoadPointer(&n.overflow))
+}
+
+func (n *node48) setNext(next *node48) {
+	atomic.StorePointer(&n.overflow, unsafe.Pointer(next))
+}
+
+func (n *node48) setPrefix(prefix []byte) {
+	n.prefix.set(prefix)
 }
 func (n *node48) getType() nodeType {
 	return nodeType48
 }
-func (n *node48) findChild(b byte) *node {
-	if n.childIndex[b] != -1 {
-		return &n.childPtr[n.childIndex[b]]
+func (n *node48) findChild(b byte) *slot {
+	if idx := n.index(b); idx != -1 {
+		return &n.childPtr[idx]
+	}
+	if next := n.next(); next != nil {
+		return next.findChild(b)
 	}
 	return nil
 }
 func (n *node48) addChild(b byte, child node) {
-	n.childIndex[b] = int16(n.numOfChildren)
-	n.childPtr[n.numOfChildren] = child
-	n.numOfChildren++
+	count := n.numOfChildren
+	if count == 48 {
+		next := n.next()
+		if next == nil {
+			panic("art: adding to a full node48 without a reserved overflow")
+		}
+		next.addChild(b, child)
+		return
+	}
+	n.setIndex(b, int16(count))
+	n.childPtr[count].store(child)
+	atomic.StoreUint32(&n.numOfChildren, count+1)
+}
+func (n *node48) removeChild(b byte) {
+	idx := n.index(b)
+	if idx == -1 {
+		if next := n.next(); next != nil {
+			next.removeChild(b)
+			if next.childCount() == 0 {
+				n.setNext(nil)
+			}
+		}
+		return
+	}
+	n.setIndex(b, -1)
+	// keep childPtr dense by moving the last child into the freed slot
+	last := int16(n.numOfChildren - 1)
+	if idx != last {
+		n.childPtr[idx].move(&n.childPtr[last])
+		for char := 0; char < 256; char++ {
+			if n.index(byte(char)) == last {
+				n.setIndex(byte(char), idx)
+				break
+			}
+		}
+	}
+	n.childPtr[last].store(nil)
+	atomic.StoreUint32(&n.numOfChildren, uint32(last))
+}
+func (n *node48) shrink(a Allocator) node {
+	newNode := a.AllocNode16()
+	*newNode = node16{
+		prefix:              n.prefix,
+		versionLockObsolete: inheritedVersion(n.versionLockObsolete),
+	}
+	for char := 0; char < 256; char++ {
+		if slot := n.findChild(byte(char)); slot != nil {
+			newNode.addChild(byte(char), slot.load())
+		}
+	}
+	return newNode
+}
+func (n *node48) childCount() int {
+	count := int(atomic.LoadUint32(&n.numOfChildren))
+	if next := n.next(); next != nil {
+		return count + next.childCount()
+	}
+	return count
 }
 
 func (n *node48) isFull() bool {
-	return n.numOfChildren == 48
+	return !n.capped && atomic.LoadUint32(&n.numOfChildren) == 48
 }
 func (n *node48) getPrefix() []byte {
-	if n.prefixLen > MaxInlinePrefixLength {
-		return n.prefixPtr
-	}
-	return n.prefix[:n.prefixLen]
+	return n.prefix.get()
 }
-func (n *node48) grow() node {
-	newNode := node256{
-		ChildPtr:            [256]node{},
-		prefixPtr:           n.prefixPtr,
-		prefixLen:           n.prefixLen,
+func (n *node48) grow(a Allocator) node {
+	newNode := a.AllocNode256()
+	*newNode = node256{
 		prefix:              n.prefix,
-		versionLockObsolete: &atomic.Uint64{},
+		numOfChildren:       n.numOfChildren,
+		versionLockObsolete: inheritedVersion(n.versionLockObsolete),
+		summary:             n.summary,
 	}
 	for char := 0; char < 256; char++ {
-		if n.childIndex[char] != -1 {
-			newNode.ChildPtr[char] = n.childPtr[n.childIndex[char]]
+		if idx := n.index(byte(char)); idx != -1 {
+			newNode.childPtr[char].move(&n.childPtr[idx])
 		}
 	}
-	return &newNode
+	return newNode
 }
 func (n *node48) version() *atomic.Uint64 {
 	if n.versionLockObsolete == nil {
 		log.Printf("ERROR: nil versionLockObsolete  %p", n)
 		panic("nil versionLockObsolete")
 	}
-	return n.versionLockObsolete
+	return &n.versionLockObsolete.version
 }
 
 type node256 struct {
-	ChildPtr            [256]node
-	prefixPtr           []byte
-	versionLockObsolete *atomic.Uint64 //62b version 1b lock 1b obsolete
-	prefixLen           uint16
-	prefix              [MaxInlinePrefixLength]byte
+	childPtr            [256]slot
+	prefix              nodePrefix
+	versionLockObsolete *innerVersion  //62b version 1b lock 1b obsolete, see innerVersion
+	summary             unsafe.Pointer // *nodeSummary
+	numOfChildren       uint32
 }
 
 func (n *node256) setPrefix(prefix []byte) {
-	length := len(prefix)
-	n.prefixLen = uint16(length)
-	if length <= MaxInlinePrefixLength {
-		n.prefix = [8]byte{}
-		copy(n.prefix[:length], prefix)
-		return
-	}
-	n.prefixPtr = prefix
+	n.prefix.set(prefix)
 }
-func (n *node256) findChild(b byte) *node {
-	if n.ChildPtr[b] != nil {
-		return &n.ChildPtr[b]
+func (n *node256) findChild(b byte) *slot {
+	if n.childPtr[b].load() != nil {
+		return &n.childPtr[b]
 	}
 	return nil
 
@@ -537,15 +1647,39 @@ func (n *node256) isFull() bool {
 	return false
 }
 func (n *node256) getPrefix() []byte {
-	if n.prefixLen > MaxInlinePrefixLength {
-		return n.prefixPtr
-	}
-	return n.prefix[:n.prefixLen]
+	return n.prefix.get()
 }
 func (n *node256) addChild(b byte, child node) {
-	n.ChildPtr[b] = child
+	if n.childPtr[b].load() == nil {
+		atomic.AddUint32(&n.numOfChildren, 1)
+	}
+	n.childPtr[b].store(child)
 }
-func (n *node256) grow() node {
+func (n *node256) removeChild(b byte) {
+	if n.childPtr[b].load() != nil {
+		n.childPtr[b].store(nil)
+		atomic.AddUint32(&n.numOfChildren, ^uint32(0))
+	}
+}
+func (n *node256) shrink(a Allocator) node {
+	newNode := a.AllocNode48()
+	*newNode = node48{
+		prefix:              n.prefix,
+		versionLockObsolete: inheritedVersion(n.versionLockObsolete),
+		summary:             n.summary,
+	}
+	newNode.clearIndex()
+	for char := 0; char < 256; char++ {
+		if child := n.childPtr[char].load(); child != nil {
+			newNode.addChild(byte(char), child)
+		}
+	}
+	return newNode
+}
+func (n *node256) childCount() int {
+	return int(atomic.LoadUint32(&n.numOfChildren))
+}
+func (n *node256) grow(a Allocator) node {
 	return nil
 }
 func (n *node256) version() *atomic.Uint64 {
@@ -553,7 +1687,7 @@ func (n *node256) version() *atomic.Uint64 {
 		log.Printf("ERROR: nil versionLockObsolete  %p", n)
 		panic("nil versionLockObsolete")
 	}
-	return n.versionLockObsolete
+	return &n.versionLockObsolete.version
 }
 
 // helper function
@@ -576,18 +1710,32 @@ func getCommonPrefix(s1 []byte, s2 []byte, depth int) []byte {
 	return s1[depth:minLen]
 }
 func addChild(parent node, child node, key []byte, pos int) {
+	if w, ok := parent.(*nodeWide); ok {
+		w.set(keyByte(key, pos), keyByte(key, pos+1), child)
+		return
+	}
 	if pos >= len(key) || len(key) == 0 {
 		parent.addChild(TerminationChar, child)
 	} else {
 		parent.addChild(key[pos], child)
 	}
 }
-func findChild(n node, key []byte, depth int) *node {
+func findChild(n node, key []byte, depth int) *slot {
+	if w, ok := n.(*nodeWide); ok {
+		return w.slot(keyByte(key, depth), keyByte(key, depth+1))
+	}
 	if depth >= len(key) {
 		return n.findChild(TerminationChar)
 	}
 	return n.findChild(key[depth])
 }
+func removeChild(n node, key []byte, depth int) {
+	if w, ok := n.(*nodeWide); ok {
+		w.clear(keyByte(key, depth), keyByte(key, depth+1))
+		return
+	}
+	n.removeChild(keyByte(key, depth))
+}
 func readLockOrRestart(n node) (uint64, bool) {
 	if n == nil {
 		return OBSOLETE_BIT, true
@@ -630,12 +1778,14 @@ func writeUnlock(n node) {
 	if n == nil {
 		return
 	}
+	lockReleased(n)
 	n.version().Add(LOCK_INCREMENT)
 }
 func writeUnlockObsolete(n node) {
 	if n == nil {
 		return
 	}
+	lockReleased(n)
 	// set obsolete bit and bump version in CAS loop
 	for {
 		v := n.version().Load()
@@ -649,7 +1799,11 @@ func upgradeToWriteLockOrRestart(n node, version uint64) bool {
 	if n == nil {
 		return false
 	}
-	return !n.version().CompareAndSwap(version, setLockedBit(version))
+	if !n.version().CompareAndSwap(version, setLockedBit(version)) {
+		return true
+	}
+	lockAcquired(n)
+	return false
 }
 func writeLockOrRestart(n node) bool {
 	for {
@@ -671,14 +1825,20 @@ func setLockedBit(version uint64) uint64 {
 }
 
 func newNode4() *node4 {
-	n := &node4{
-		childPtr:            [4]node{},
-		prefixPtr:           nil,
-		prefix:              [8]byte{},
-		keys:                [4]byte{},
-		prefixLen:           0,
-		numOfChildren:       0,
-		versionLockObsolete: &atomic.Uint64{},
-	}
+	return allocNode4(heapAllocator{})
+}
+
+// allocNode4 returns an empty node4 in memory from a.
+func allocNode4(a Allocator) *node4 {
+	n := a.AllocNode4()
+	*n = node4{versionLockObsolete: newInnerVersion()}
+	return n
+}
+
+// allocNode48 returns an empty node48 in memory from a.
+func allocNode48(a Allocator) *node48 {
+	n := a.AllocNode48()
+	*n = node48{versionLockObsolete: newInnerVersion()}
+	n.clearIndex()
 	return n
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -147,6 +148,41 @@ func TestPrefixHandling(t *testing.T) {
 	}
 }
 
+// TestPrefixLengths stores node prefixes of every length around the inline
+// limit, splits each of them and collapses them back through deletes, so
+// prefixes move between inline and out-of-line storage in both directions.
+func TestPrefixLengths(t *testing.T) {
+	for length := 0; length <= 3*MaxInlinePrefixLength; length++ {
+		tree := NewART[int]()
+		shared := strings.Repeat("p", length)
+		keys := []string{shared + "a", shared + "b"}
+		for split := 0; split < length; split++ {
+			// Diverging inside the shared prefix splits it at split
+			keys = append(keys, shared[:split]+"x"+strings.Repeat("q", length))
+		}
+		for i, key := range keys {
+			tree.Insert([]byte(key), i)
+		}
+		for i, key := range keys {
+			if val, found := tree.Search([]byte(key)); !found || val.(int) != i {
+				t.Fatalf("length %d: expected %q=%d, got %v (found=%v)", length, key, i, val, found)
+			}
+		}
+		if _, found := tree.Search([]byte(shared + "c")); found {
+			t.Fatalf("length %d: unexpected match for a missing key", length)
+		}
+		// Deleting the split keys collapses the prefix back together
+		for _, key := range keys[2:] {
+			tree.Delete([]byte(key))
+		}
+		for i, key := range keys[:2] {
+			if val, found := tree.Search([]byte(key)); !found || val.(int) != i {
+				t.Fatalf("length %d: expected %q=%d after collapse, got %v (found=%v)", length, key, i, val, found)
+			}
+		}
+	}
+}
+
 func TestEmptyString(t *testing.T) {
 	tree := NewART[string]()
 
@@ -245,6 +281,292 @@ func TestOverwriteValue(t *testing.T) {
 	}
 }
 
+func TestSubSliceKeys(t *testing.T) {
+	tree := NewART[int]()
+
+	buf := []byte("user:alice|user:bob|user:carol")
+	tree.Insert(buf[0:10], 1)
+	tree.Insert(buf[11:19], 2)
+	tree.Insert(buf[20:30], 3)
+
+	// Mutating the buffer after Insert must not affect stored keys
+	for i := range buf {
+		buf[i] = 'x'
+	}
+	for key, expected := range map[string]int{"user:alice": 1, "user:bob": 2, "user:carol": 3} {
+		val, found := tree.Search([]byte(key))
+		if !found || val != expected {
+			t.Errorf("For key '%s', expected %d, got %v (found=%v)", key, expected, val, found)
+		}
+	}
+
+	// Search with a sub-slice, then mutate the parent buffer
+	query := []byte("prefix-user:bob-suffix")
+	val, found := tree.Search(query[7:15])
+	if !found || val != 2 {
+		t.Errorf("Expected 2 for sub-slice search, got %v (found=%v)", val, found)
+	}
+	copy(query[7:15], "user:zed")
+	if _, found := tree.Search([]byte("user:zed")); found {
+		t.Error("Search must not alias the caller's key into the tree")
+	}
+	if val, found := tree.Search([]byte("user:bob")); !found || val != 2 {
+		t.Errorf("Tree changed after mutating search buffer: got %v (found=%v)", val, found)
+	}
+}
+
+func TestSearchHits(t *testing.T) {
+	tree := NewART[int]()
+	for i, key := range []string{"apple", "banana", "cherry", "date"} {
+		tree.Insert([]byte(key), i)
+	}
+
+	queries := [][]byte{[]byte("fig"), []byte("date"), []byte("apple"), []byte("grape"), []byte("cherry"), []byte("app")}
+	hitKeys, hitVals := tree.SearchHits(queries)
+	var got []string
+	for i, key := range hitKeys {
+		got = append(got, fmt.Sprintf("%s=%d", key, hitVals[i]))
+	}
+	expected := []string{"date=3", "apple=0", "cherry=2"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected hits %v in input order, got %v", expected, got)
+	}
+
+	if hitKeys, hitVals := tree.SearchHits([][]byte{[]byte("fig")}); len(hitKeys) != 0 || len(hitVals) != 0 {
+		t.Errorf("Expected no hits, got %q %v", hitKeys, hitVals)
+	}
+}
+
+func TestSearchInto(t *testing.T) {
+	type record struct {
+		name    string
+		payload [64]int
+	}
+	tree := NewART[record]()
+	stored := record{name: "stored"}
+	stored.payload[63] = 7
+	tree.Insert([]byte("hit"), stored)
+	tree.InsertTombstone([]byte("gone"))
+
+	var dst record
+	if !tree.SearchInto([]byte("hit"), &dst) || dst != stored {
+		t.Fatalf("SearchInto on a hit = %+v", dst)
+	}
+	sentinel := record{name: "untouched"}
+	for _, key := range []string{"miss", "hi", "gone"} {
+		dst = sentinel
+		if tree.SearchInto([]byte(key), &dst) || dst != sentinel {
+			t.Errorf("SearchInto(%q) changed dst to %q or reported a hit", key, dst.name)
+		}
+	}
+	hit := []byte("hit")
+	if allocs := testing.AllocsPerRun(100, func() { tree.SearchInto(hit, &dst) }); allocs != 0 {
+		t.Errorf("SearchInto allocated %v times per call", allocs)
+	}
+}
+
+func TestDeleteBasic(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "banana", "band"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+
+	if tree.Delete([]byte("missing")) || tree.Delete([]byte("ba")) || tree.Delete([]byte("abcd")) {
+		t.Error("Delete of absent key reported success")
+	}
+
+	deleted := map[string]bool{}
+	for _, key := range []string{"ab", "", "banana", "abd"} {
+		if !tree.Delete([]byte(key)) {
+			t.Errorf("Expected Delete('%s') to succeed", key)
+		}
+		if tree.Delete([]byte(key)) {
+			t.Errorf("Second Delete('%s') must report absence", key)
+		}
+		deleted[key] = true
+		for i, k := range keys {
+			val, found := tree.Search([]byte(k))
+			if deleted[k] && found {
+				t.Errorf("Deleted key '%s' still found", k)
+			}
+			if !deleted[k] && (!found || val != i) {
+				t.Errorf("Key '%s' lost after deleting '%s': got %v (found=%v)", k, key, val, found)
+			}
+		}
+	}
+
+	tree.Insert([]byte("ab"), 42)
+	if val, found := tree.Search([]byte("ab")); !found || val != 42 {
+		t.Errorf("Expected re-inserted key to be found, got %v (found=%v)", val, found)
+	}
+}
+
+func TestDeleteShrinkAndCollapse(t *testing.T) {
+	tree := NewART[int]()
+	const n = 256
+	key := func(i int) []byte { return []byte{'p', 'r', 'e', byte(i), 'x'} }
+	for i := 0; i < n; i++ {
+		tree.Insert(key(i), i)
+	}
+	if _, path, _ := tree.KeyExists(key(0)); path[1] != nodeType256 {
+		t.Fatalf("Expected a node256 below the root, got %v", path)
+	}
+
+	for _, remaining := range []struct {
+		count    int
+		nodeType nodeType
+	}{{40, nodeType256}, {37, nodeType48}, {13, nodeType48}, {12, nodeType16}, {4, nodeType16}, {3, nodeType4}} {
+		for i := remaining.count; i < n; i++ {
+			tree.Delete(key(i))
+		}
+		for i := 0; i < remaining.count; i++ {
+			if val, found := tree.Search(key(i)); !found || val != i {
+				t.Fatalf("Key %d lost with %d remaining", i, remaining.count)
+			}
+		}
+		if _, path, _ := tree.KeyExists(key(0)); path[1] != remaining.nodeType {
+			t.Errorf("With %d children expected %v, got path %v", remaining.count, remaining.nodeType, path)
+		}
+	}
+
+	// Two children left: deleting one collapses the node into the leaf
+	tree.Delete(key(2))
+	tree.Delete(key(1))
+	if _, path, found := tree.KeyExists(key(0)); !found || len(path) != 2 || path[1] != nodeTypeLeaf {
+		t.Errorf("Expected the parent to collapse into the last leaf, got %v (found=%v)", path, found)
+	}
+	tree.Delete(key(0))
+	if tree.CountLeaves() != 0 {
+		t.Errorf("Expected empty tree, got %d keys", tree.CountLeaves())
+	}
+}
+
+func TestDeleteCollapseMergesPrefix(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{
+		"common/prefix/alpha/one",
+		"common/prefix/alpha/two",
+		"common/prefix/beta",
+	}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+	// Removing beta leaves the "common/prefix/" node with only the alpha
+	// subtree, which must absorb its prefix
+	tree.Delete([]byte("common/prefix/beta"))
+	for i, key := range keys[:2] {
+		if val, found := tree.Search([]byte(key)); !found || val != i {
+			t.Errorf("Key '%s' lost after collapse: %v (found=%v)", key, val, found)
+		}
+	}
+	if _, path, _ := tree.KeyExists([]byte(keys[0])); len(path) != 3 {
+		t.Errorf("Expected root -> merged node -> leaf, got %v", path)
+	}
+	tree.Insert([]byte("common/prefix/gamma"), 3)
+	tree.Insert([]byte("common/pre"), 4)
+	for key, expected := range map[string]int{keys[0]: 0, keys[1]: 1, "common/prefix/gamma": 3, "common/pre": 4} {
+		if val, found := tree.Search([]byte(key)); !found || val != expected {
+			t.Errorf("Key '%s' wrong after re-split: %v (found=%v)", key, val, found)
+		}
+	}
+}
+
+func TestDeleteMinDrainsStructure(t *testing.T) {
+	const n = 10000
+	key := func(i int) []byte { return []byte(fmt.Sprintf("ts/%08d", i*37)) }
+	tree := NewART[int]()
+	for i := n - 1; i >= 0; i-- {
+		tree.Insert(key(i), i)
+	}
+	total := func(tree *Tree[int]) int {
+		sum := 0
+		for _, c := range tree.NodeCount() {
+			sum += c
+		}
+		return sum
+	}
+	for i := 0; i < n; i++ {
+		k, v, ok := tree.DeleteMin()
+		if !ok || !bytes.Equal(k, key(i)) || v != i {
+			t.Fatalf("DeleteMin #%d = %q, %d, %v", i, k, v, ok)
+		}
+		if remaining := n - i - 1; remaining%1000 == 0 || remaining < 10 {
+			// The drained tree is as small as one built from the keys it
+			// still holds
+			fresh := NewART[int]()
+			for j := i + 1; j < n; j++ {
+				fresh.Insert(key(j), j)
+			}
+			if tree.Height() != fresh.Height() || total(tree) != total(fresh) {
+				t.Fatalf("%d keys left: height %d and %d nodes, a fresh tree has %d and %d",
+					remaining, tree.Height(), total(tree), fresh.Height(), total(fresh))
+			}
+		}
+	}
+	if _, _, ok := tree.DeleteMin(); ok || tree.Len() != 0 || tree.Height() != 1 {
+		t.Errorf("DeleteMin on the drained tree: ok=%v, len %d, height %d", ok, tree.Len(), tree.Height())
+	}
+}
+
+func TestConcurrentDeleteCollapse(t *testing.T) {
+	tree := NewART[int]()
+	const goroutines = 100
+	const pairs = 50
+	chain := strings.Repeat("c", 50)
+	stableKey := func(g, i int) []byte { return []byte(fmt.Sprintf("%s/%03d/%03d/stable", chain, g, i)) }
+	churnKey := func(g, i int) []byte { return []byte(fmt.Sprintf("%s/%03d/%03d/stable/churn", chain, g, i)) }
+
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < pairs; i++ {
+			tree.Insert(stableKey(g, i), i)
+			tree.Insert(churnKey(g, i), -i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var failures atomic.Int64
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			other := (g + 1) % goroutines
+			for round := 0; round < 20; round++ {
+				for i := 0; i < pairs; i++ {
+					if !tree.Delete(churnKey(g, i)) {
+						failures.Add(1)
+					}
+					if _, found := tree.Search(churnKey(g, i)); found {
+						failures.Add(1)
+					}
+					if val, found := tree.Search(stableKey(other, i)); !found || val != i {
+						failures.Add(1)
+					}
+					tree.Insert(churnKey(g, i), -i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if f := failures.Load(); f != 0 {
+		t.Errorf("%d operations observed a lost or stale key", f)
+	}
+	for g := 0; g < goroutines; g++ {
+		for i := 0; i < pairs; i++ {
+			if val, found := tree.Search(stableKey(g, i)); !found || val != i {
+				t.Fatalf("Stable key %d/%d lost", g, i)
+			}
+			if val, found := tree.Search(churnKey(g, i)); !found || val != -i {
+				t.Fatalf("Churn key %d/%d lost", g, i)
+			}
+		}
+	}
+	if count := tree.CountLeaves(); count != goroutines*pairs*2 {
+		t.Errorf("Expected %d keys, got %d", goroutines*pairs*2, count)
+	}
+}
+
 func TestSpecialCharacters(t *testing.T) {
 	tree := NewART[int]()
 
@@ -747,6 +1069,104 @@ func TestConcurrentInsertSearch(t *testing.T) {
 	}
 }
 
+// TestConcurrentDuplicateInsert races two goroutines inserting the same new
+// key into each structural situation the insert path handles: an empty root
+// slot, an empty inner slot, a full node that must grow, a leaf that must
+// split and a prefix that must split. The upgrade to a write lock must let
+// exactly one of them create the leaf while the other overwrites it.
+func TestReadYourWrites(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"rcu", []Option{WithRCUReads()}},
+		{"self-organizing", []Option{WithSelfOrganizingNodes()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewART[int](tc.opts...)
+			const writers = 8
+			ops := 20000
+			if tc.name == "rcu" {
+				ops = 2000
+			}
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					r := rand.New(rand.NewSource(int64(w)))
+					for i := 0; i < ops; i++ {
+						// Keys from all writers interleave under shared nodes, so
+						// the path to a key is grown and split by other writers
+						// right around its insert; some are overwrites
+						key := []byte(fmt.Sprintf("k%d/%d", r.Intn(ops), w))
+						val := w*ops + i
+						if err := tree.TryInsert(key, val); err != nil {
+							t.Errorf("TryInsert(%q) = %v", key, err)
+							return
+						}
+						if got, found := tree.Search(key); !found || got.(int) != val {
+							t.Errorf("Search(%q) right after inserting %d = %v, %v", key, val, got, found)
+							return
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestConcurrentDuplicateInsert(t *testing.T) {
+	setups := map[string]struct {
+		existing []string
+		key      string
+	}{
+		"root slot":    {nil, "k"},
+		"inner slot":   {[]string{"ab1", "ab2"}, "ab3"},
+		"grow":         {[]string{"ab1", "ab2", "ab3", "ab4"}, "ab5"},
+		"leaf split":   {[]string{"abc"}, "abd"},
+		"prefix split": {[]string{"abcd1", "abcd2"}, "abx"},
+	}
+	for name, setup := range setups {
+		for round := 0; round < 2000; round++ {
+			tree := NewART[int]()
+			for _, key := range setup.existing {
+				tree.Insert([]byte(key), -1)
+			}
+			var ready, wg sync.WaitGroup
+			start := make(chan struct{})
+			for id := 1; id <= 2; id++ {
+				ready.Add(1)
+				wg.Add(1)
+				go func(id int) {
+					defer wg.Done()
+					ready.Done()
+					<-start
+					tree.Insert([]byte(setup.key), id)
+				}(id)
+			}
+			ready.Wait()
+			close(start)
+			wg.Wait()
+
+			if n := tree.CountLeaves(); n != len(setup.existing)+1 {
+				t.Fatalf("%s: expected %d keys after racing inserts, got %d", name, len(setup.existing)+1, n)
+			}
+			val, found := tree.Search([]byte(setup.key))
+			if !found || (val.(int) != 1 && val.(int) != 2) {
+				t.Fatalf("%s: expected one of the racing values, got %v (found=%v)", name, val, found)
+			}
+			for _, key := range setup.existing {
+				if val, _ := tree.Search([]byte(key)); val.(int) != -1 {
+					t.Fatalf("%s: existing key '%s' lost or changed: %v", name, key, val)
+				}
+			}
+		}
+	}
+}
+
 func TestConcurrentUpdateOperations(t *testing.T) {
 	tree := NewART[string]()
 	numGoroutines := 10
@@ -788,6 +1208,101 @@ func TestConcurrentUpdateOperations(t *testing.T) {
 	}
 }
 
+// TestInsertLinearizable checks concurrent overwrites of one key against
+// the real-time order of operations, stamped by a shared logical clock: a
+// read must return a write that started before it ended and that no other
+// write completed after, and reads that follow each other in real time must
+// not go back to an older write.
+func TestInsertLinearizable(t *testing.T) {
+	type interval struct{ start, end int64 }
+	for _, others := range [][]string{nil, {"lin/a", "lin/b", "lin/keys"}} {
+		tree := NewART[int]()
+		key := []byte("lin/key")
+		for _, other := range others {
+			tree.Insert([]byte(other), -1)
+		}
+		tree.Insert(key, 0)
+
+		const writers = 4
+		const writes = 2000
+		var clock atomic.Int64
+		// writes[id] is the interval of the Insert of value id; value 0 is
+		// the initial insert, complete before the clock starts
+		writeTimes := make([]interval, writers*writes+1)
+		type read struct {
+			interval
+			val int
+		}
+		var reads []read
+
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < writes; i++ {
+					id := w*writes + i + 1
+					start := clock.Add(1)
+					tree.Insert(key, id)
+					writeTimes[id] = interval{start, clock.Add(1)}
+				}
+			}(w)
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 4*writes; i++ {
+				start := clock.Add(1)
+				val, found := tree.Search(key)
+				end := clock.Add(1)
+				if !found {
+					t.Error("Key disappeared during overwrites")
+					return
+				}
+				reads = append(reads, read{interval{start, end}, val.(int)})
+			}
+		}()
+		wg.Wait()
+		<-done
+
+		// latestStart returns the largest start among writes ending before
+		// time, from writes sorted by end with a running maximum of starts
+		byEnd := append([]interval(nil), writeTimes...)
+		sort.Slice(byEnd, func(i, j int) bool { return byEnd[i].end < byEnd[j].end })
+		maxStart := make([]int64, len(byEnd))
+		for i, w := range byEnd {
+			maxStart[i] = w.start
+			if i > 0 {
+				maxStart[i] = max(maxStart[i], maxStart[i-1])
+			}
+		}
+		latestStart := func(time int64) int64 {
+			i := sort.Search(len(byEnd), func(i int) bool { return byEnd[i].end >= time })
+			if i == 0 {
+				return -1
+			}
+			return maxStart[i-1]
+		}
+
+		var floor int64 = -1
+		for i, r := range reads {
+			w := writeTimes[r.val]
+			if w.start > r.end {
+				t.Fatalf("Read %d returned value %d from a write that started after it", i, r.val)
+			}
+			if latest := latestStart(r.start); latest > w.end {
+				t.Fatalf("Read %d returned value %d, overwritten by a write completed before it began", i, r.val)
+			}
+			// Reads come from one goroutine, so each follows the last in
+			// real time and must not return a write older than it did
+			if w.end < floor {
+				t.Fatalf("Read %d went back to value %d after a newer write was read", i, r.val)
+			}
+			floor = max(floor, w.start)
+		}
+	}
+}
+
 func TestConcurrentMixedOperations(t *testing.T) {
 	tree := NewART[int]()
 	duration := 2 * time.Second
@@ -1020,6 +1535,19 @@ func BenchmarkSearchExisting(b *testing.B) {
 	}
 }
 
+func BenchmarkSearchSmallTree(b *testing.B) {
+	tree := NewART[int]()
+	keys := []string{"alpha", "bravo", "charlie", "delta"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search([]byte(keys[i%len(keys)]))
+	}
+}
+
 func BenchmarkSearchNonExisting(b *testing.B) {
 	tree := NewART[int]()
 	const numKeys = 100000
@@ -1140,6 +1668,47 @@ func BenchmarkMemoryUsage(b *testing.B) {
 	}
 }
 
+// BenchmarkNodeMemory reports the live heap per key for random keys, whose
+// inner nodes mostly have empty or one-byte prefixes, and for keys sharing
+// long prefixes, whose nodes need out-of-line prefix storage.
+func BenchmarkNodeMemory(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	workloads := map[string]func(i int) []byte{
+		"random": func(i int) []byte {
+			key := make([]byte, 16)
+			rng.Read(key)
+			return key
+		},
+		"long-prefix": func(i int) []byte {
+			return []byte(fmt.Sprintf("tenant/%03d/objects/%08x", i%100, rng.Uint32()))
+		},
+	}
+	for name, gen := range workloads {
+		b.Run(name, func(b *testing.B) {
+			const n = 100000
+			keys := make([][]byte, n)
+			for i := range keys {
+				keys[i] = gen(i)
+			}
+			var perKey float64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				tree := NewART[int]()
+				for j, key := range keys {
+					tree.Insert(key, j)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				perKey = float64(after.HeapAlloc-before.HeapAlloc) / n
+				runtime.KeepAlive(tree)
+			}
+			b.ReportMetric(perKey, "heap-B/key")
+		})
+	}
+}
+
 func BenchmarkCompareWithMap_Insert(b *testing.B) {
 
 	b.Run("Map", func(b *testing.B) {
@@ -1401,7 +1970,15 @@ func BenchmarkContention(b *testing.B) {
 }
 
 func BenchmarkContentionAnalysis(b *testing.B) {
-	tree := NewART[int]()
+	var grows, splits atomic.Int64
+	tree := NewART[int](WithStructureObserver(func(event StructureEvent) {
+		switch event.Kind {
+		case NodeGrew:
+			grows.Add(1)
+		case NodeSplit:
+			splits.Add(1)
+		}
+	}))
 	keys := generateRandomKeys(b.N)
 	numThreads := runtime.GOMAXPROCS(0)
 
@@ -1443,6 +2020,8 @@ func BenchmarkContentionAnalysis(b *testing.B) {
 	b.ReportMetric(float64(totalOps)/duration.Seconds(), "ops/sec")
 	b.ReportMetric(float64(restarts)/float64(totalOps)*100, "restart_pct")
 	b.ReportMetric(float64(lockWaits)/float64(totalOps)*100, "lock_wait_pct")
+	b.ReportMetric(float64(grows.Load())/float64(totalOps)*100, "grow_pct")
+	b.ReportMetric(float64(splits.Load())/float64(totalOps)*100, "split_pct")
 }
 
 func BenchmarkScalability(b *testing.B) {
@@ -0,0 +1,143 @@
+package art
+
+import "sync"
+
+// OpKind is the kind of an operation in a Batch.
+type OpKind uint8
+
+const (
+	OpInsert OpKind = iota
+	OpDelete
+)
+
+// Op is a single operation of a Batch. Value is unused for deletes.
+type Op[T any] struct {
+	Kind  OpKind
+	Key   []byte
+	Value T
+}
+
+// Batch collects inserts and deletes to be applied together by Apply.
+type Batch[T any] struct {
+	ops []Op[T]
+}
+
+// Insert queues storing val under key. The key is copied.
+func (b *Batch[T]) Insert(key []byte, val T) {
+	b.ops = append(b.ops, Op[T]{Kind: OpInsert, Key: append([]byte(nil), key...), Value: val})
+}
+
+// Delete queues removing key. The key is copied.
+func (b *Batch[T]) Delete(key []byte) {
+	b.ops = append(b.ops, Op[T]{Kind: OpDelete, Key: append([]byte(nil), key...)})
+}
+
+// Len returns the number of queued operations.
+func (b *Batch[T]) Len() int {
+	return len(b.ops)
+}
+
+type commitHook[T any] struct {
+	pre  func(ops []Op[T]) error
+	post func(ops []Op[T])
+}
+
+// batchState serializes Apply calls so commit hooks observe batches in the
+// order they take effect.
+type batchState[T any] struct {
+	mu   sync.Mutex
+	hook *commitHook[T]
+}
+
+// WithCommitHook installs hooks around every Apply. pre sees the batch's
+// operations before any is applied and may veto the batch by returning an
+// error, in which case nothing is applied, post is not called and Apply
+// returns the error. post runs once every operation has been applied.
+// Either may be nil. The hooks run with other batches held off, so they
+// observe commits one at a time in order; single-key writers are not held
+// off. Call it before the tree is shared.
+func (t *Tree[T]) WithCommitHook(pre func(ops []Op[T]) error, post func(ops []Op[T])) {
+	t.batches.hook = &commitHook[T]{pre: pre, post: post}
+}
+
+// Apply applies the batch's operations in order. Every insert is checked
+// against the tree's options first, and a rejected one aborts the batch
+// before anything is applied or any hook runs, as does a read-only tree.
+// Batches are applied one at a time, but concurrent readers may observe a
+// batch partially applied. The operations are written at once, even on a
+// tree created with WithWriteCoalescing, so post sees them all. A panic
+// during an operation, recovered as TryInsert recovers it, stops the batch
+// with the operations before it applied, and Apply returns its error
+// without calling post.
+func (t *Tree[T]) Apply(b *Batch[T]) error {
+	if t.readOnly.Load() {
+		return ErrReadOnly
+	}
+	for _, op := range b.ops {
+		if op.Kind != OpInsert {
+			continue
+		}
+		if err := t.checkInsert(op.Key, op.Value); err != nil {
+			return err
+		}
+	}
+
+	t.batches.mu.Lock()
+	defer t.batches.mu.Unlock()
+	hook := t.batches.hook
+	if hook != nil && hook.pre != nil {
+		if err := hook.pre(b.ops); err != nil {
+			return err
+		}
+	}
+	if err := t.applyOps(b.ops); err != nil {
+		return err
+	}
+	if hook != nil && hook.post != nil {
+		hook.post(b.ops)
+	}
+	return nil
+}
+
+// applyOps writes ops in order, returning the first error. A tree made
+// read-only since Apply checked is reported with ErrReadOnly before any
+// operation is written.
+func (t *Tree[T]) applyOps(ops []Op[T]) error {
+	defer t.maybeRebuildSummaries()
+	if t.coalescing != nil {
+		// Taken before writers, as Delete takes it
+		t.coalescing.applying.Lock()
+		defer t.coalescing.applying.Unlock()
+	}
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return ErrReadOnly
+	}
+	for _, op := range ops {
+		key := t.storedKey(op.Key)
+		switch op.Kind {
+		case OpInsert:
+			var val interface{} = op.Value
+			var bits uint64
+			if t.compactInts {
+				val, bits, _ = compact(op.Value)
+			}
+			// A held Insert of the key would overwrite this one later
+			if t.coalescing != nil {
+				t.coalescing.drop(key)
+			}
+			if err := t.placeStored(key, val, bits, nil); err != nil {
+				return err
+			}
+		case OpDelete:
+			if t.coalescing != nil {
+				t.coalescing.drop(key)
+			}
+			if _, err := t.tryDeleteStored(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package art
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommitHookVeto(t *testing.T) {
+	tree := NewART[int]()
+	tree.Insert([]byte("kept"), 1)
+	tree.Insert([]byte("doomed"), 2)
+
+	errVeto := errors.New("veto")
+	var seen []Op[int]
+	postCalls := 0
+	tree.WithCommitHook(func(ops []Op[int]) error {
+		seen = append([]Op[int](nil), ops...)
+		return errVeto
+	}, func([]Op[int]) {
+		postCalls++
+	})
+
+	var b Batch[int]
+	b.Insert([]byte("new"), 3)
+	b.Insert([]byte("kept"), 10)
+	b.Delete([]byte("doomed"))
+	if err := tree.Apply(&b); !errors.Is(err, errVeto) {
+		t.Fatalf("Expected the veto error, got %v", err)
+	}
+	if postCalls != 0 {
+		t.Errorf("Expected post not to run after a veto, ran %d times", postCalls)
+	}
+	expected := []Op[int]{
+		{Kind: OpInsert, Key: []byte("new"), Value: 3},
+		{Kind: OpInsert, Key: []byte("kept"), Value: 10},
+		{Kind: OpDelete, Key: []byte("doomed")},
+	}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected pre to see %v, got %v", expected, seen)
+	}
+	if got := tree.ToMap(); !reflect.DeepEqual(got, map[string]int{"kept": 1, "doomed": 2}) {
+		t.Errorf("Expected the tree unchanged after a veto, got %v", got)
+	}
+	if tree.Len() != 2 {
+		t.Errorf("Expected Len 2, got %d", tree.Len())
+	}
+}
+
+func TestCommitHookCommit(t *testing.T) {
+	tree := NewFixedKeyART[int](2)
+	var order []string
+	tree.WithCommitHook(func(ops []Op[int]) error {
+		order = append(order, fmt.Sprintf("pre %d", len(ops)))
+		return nil
+	}, func(ops []Op[int]) {
+		// Every operation is visible by the time post runs
+		if _, found := tree.Search([]byte("bb")); found {
+			t.Error("Expected bb deleted before post")
+		}
+		order = append(order, fmt.Sprintf("post %d", len(ops)))
+	})
+
+	var b Batch[int]
+	b.Insert([]byte("aa"), 1)
+	b.Insert([]byte("bb"), 2)
+	b.Delete([]byte("bb"))
+	if err := tree.Apply(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(order, []string{"pre 3", "post 3"}) {
+		t.Errorf("Expected pre then post, got %v", order)
+	}
+	if got := tree.ToMap(); !reflect.DeepEqual(got, map[string]int{"aa": 1}) {
+		t.Errorf("Expected only aa, got %v", got)
+	}
+
+	// An op the tree would reject aborts the batch before the hooks run
+	var bad Batch[int]
+	bad.Insert([]byte("cc"), 3)
+	bad.Insert([]byte("toolong"), 4)
+	if err := tree.Apply(&bad); !errors.Is(err, ErrKeyLength) {
+		t.Errorf("Expected ErrKeyLength, got %v", err)
+	}
+	if len(order) != 2 {
+		t.Errorf("Expected no hook calls for a rejected batch, got %v", order[2:])
+	}
+	if _, found := tree.Search([]byte("cc")); found {
+		t.Error("Expected nothing applied from a rejected batch")
+	}
+}
+
+func TestCommitHookCoalescing(t *testing.T) {
+	tree := NewART[int](WithWriteCoalescing(time.Hour))
+	tree.Insert([]byte("b"), 1)
+	posted := false
+	tree.WithCommitHook(nil, func([]Op[int]) {
+		posted = true
+		if val, found := tree.Search([]byte("a")); !found || val.(int) != 1 {
+			t.Errorf("Expected a=1 visible in post, got %v (found=%v)", val, found)
+		}
+	})
+
+	var b Batch[int]
+	b.Insert([]byte("a"), 1)
+	b.Insert([]byte("b"), 2)
+	if err := tree.Apply(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !posted {
+		t.Error("Expected post to run")
+	}
+	// The Insert held from before the batch does not overwrite it
+	tree.Flush()
+	if val, _ := tree.Search([]byte("b")); val.(int) != 2 {
+		t.Errorf("Expected the batch's b=2 to stand, got %v", val)
+	}
+}
+
+func TestCommitHookReadOnlyAfterCheck(t *testing.T) {
+	tree := NewART[int]()
+	tree.WithCommitHook(func([]Op[int]) error {
+		// Lands after Apply's first read-only check
+		tree.SetReadOnly(true)
+		return nil
+	}, func([]Op[int]) {
+		t.Error("Expected post not to run for a batch that did not land")
+	})
+
+	var b Batch[int]
+	b.Insert([]byte("a"), 1)
+	if err := tree.Apply(&b); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	if tree.Len() != 0 {
+		t.Errorf("Expected nothing applied, got %d keys", tree.Len())
+	}
+}
+
+func TestCommitHookSerializesBatches(t *testing.T) {
+	tree := NewART[int]()
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	tree.WithCommitHook(func([]Op[int]) error {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		return nil
+	}, func([]Op[int]) {
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				var b Batch[int]
+				b.Insert([]byte(fmt.Sprintf("g%d/%d", id, i)), i)
+				tree.Apply(&b)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if maxInFlight != 1 {
+		t.Errorf("Expected batches to commit one at a time, saw %d at once", maxInFlight)
+	}
+	if tree.Len() != 800 {
+		t.Errorf("Expected 800 keys, got %d", tree.Len())
+	}
+}
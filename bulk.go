@@ -0,0 +1,107 @@
+package art
+
+import (
+	"bytes"
+	"sort"
+)
+
+// BulkInsert inserts every entry, in key order so that consecutive inserts
+// descend through the same recently touched nodes. Later entries win over
+// earlier ones with the same key. It stops at the first entry rejected by
+// the tree's options and returns that error.
+func (t *Tree[T]) BulkInsert(entries []KV[T]) error {
+	sorted := append([]KV[T](nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Key, sorted[j].Key) < 0
+	})
+	for _, entry := range sorted {
+		if err := t.TryInsert(entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InitializeIfEmpty inserts every pair if the tree holds no keys, and
+// reports whether it did. It holds off all other writers from the check to
+// the last insert, so of several callers racing to seed a shared tree
+// exactly one succeeds and no other write interleaves with its seeding.
+// Readers are not held off and may see a partly seeded tree.
+// Pairs are inserted like Insert would: later pairs win over earlier ones
+// with the same key, and pairs rejected by the tree's options are dropped.
+// A read-only tree is never initialized.
+func (t *Tree[T]) InitializeIfEmpty(pairs []KV[T]) bool {
+	defer t.maybeRebuildSummaries()
+	resume := t.Quiesce()
+	defer resume()
+	if t.readOnly.Load() || t.Len() != 0 {
+		return false
+	}
+	for _, pair := range pairs {
+		if t.checkInsert(pair.Key, pair.Value) != nil {
+			continue
+		}
+		var val interface{} = pair.Value
+		var bits uint64
+		if t.compactInts {
+			val, bits, _ = compact(val)
+		}
+		t.place(pair.Key, val, bits, nil)
+	}
+	return true
+}
+
+// FromMap builds a tree holding every entry of m, with the bytes of each
+// string as the key. Go strings may hold arbitrary bytes, so binary keys
+// round-trip through ToMap unchanged.
+func FromMap[T any](m map[string]T, opts ...Option) *Tree[T] {
+	entries := make([]KV[T], 0, len(m))
+	for key, val := range m {
+		entries = append(entries, KV[T]{Key: []byte(key), Value: val})
+	}
+	t := NewART[T](opts...)
+	t.BulkInsert(entries)
+	return t
+}
+
+// MapValues builds a tree with the keys of t and the values fn returns for
+// them, created with opts. The source is only read and is already walked in
+// key order, so its keys are inserted as BulkInsert would without sorting a
+// copy. Like ForEach, it is weakly consistent with concurrent writers to t.
+func MapValues[T, U any](t *Tree[T], fn func(key []byte, v T) U, opts ...Option) *Tree[U] {
+	dst := NewART[U](opts...)
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		dst.Insert(l.key, fn(l.key, valueAs[T](readLeaf(l))))
+		return true
+	})
+	return dst
+}
+
+// Filter builds a tree with the entries of t for which keep returns true,
+// configured like t. Like MapValues it only reads the source and inserts the
+// kept keys in the order it walks them. The keys are copied as stored, so a
+// WithKeyTransform is not applied to them a second time.
+func Filter[T any](t *Tree[T], keep func(key []byte, v T) bool) *Tree[T] {
+	dst := t.emptyLike()
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		val := readLeaf(l)
+		if keep(l.key, valueAs[T](val)) {
+			dst.upsertStored(l.key, val)
+		}
+		return true
+	})
+	return dst
+}
+
+// ToMap copies the tree into a map keyed by the keys as strings. It holds
+// every entry in memory and is meant for small trees.
+func (t *Tree[T]) ToMap() map[string]T {
+	m := make(map[string]T)
+	t.ForEach(func(key []byte, val T) bool {
+		m[string(key)] = val
+		return true
+	})
+	return m
+}
@@ -0,0 +1,185 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFromMapToMap(t *testing.T) {
+	m := make(map[string]int, 1000)
+	for i := 0; i < 1000; i++ {
+		m[fmt.Sprintf("entry:%d", i*31)] = i
+	}
+	// Binary keys survive the string conversion
+	m["\xff\xfe"] = -1
+
+	tree := FromMap(m)
+	if n := tree.CountLeaves(); n != len(m) {
+		t.Fatalf("Expected %d keys, got %d", len(m), n)
+	}
+	for key, want := range m {
+		if val, found := tree.Search([]byte(key)); !found || val.(int) != want {
+			t.Errorf("Expected %q=%d, got %v (found=%v)", key, want, val, found)
+		}
+	}
+
+	back := tree.ToMap()
+	if len(back) != len(m) {
+		t.Fatalf("Expected %d entries back, got %d", len(m), len(back))
+	}
+	for key, want := range m {
+		if got, ok := back[key]; !ok || got != want {
+			t.Errorf("Round trip changed %q: %d -> %d (present=%v)", key, want, got, ok)
+		}
+	}
+}
+
+func TestBulkInsert(t *testing.T) {
+	tree := NewFixedKeyART[string](2)
+	err := tree.BulkInsert([]KV[string]{
+		{Key: []byte("bb"), Value: "first"},
+		{Key: []byte("aa"), Value: "a"},
+		{Key: []byte("bb"), Value: "second"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if val, _ := tree.Search([]byte("bb")); val.(string) != "second" {
+		t.Errorf("Expected the later duplicate to win, got %v", val)
+	}
+	if err := tree.BulkInsert([]KV[string]{{Key: []byte("abc")}}); err != ErrKeyLength {
+		t.Errorf("Expected ErrKeyLength, got %v", err)
+	}
+}
+
+func TestInitializeIfEmptyConcurrent(t *testing.T) {
+	tree := NewART[int]()
+	const callers = 16
+	seeds := make([][]KV[int], callers)
+	for c := range seeds {
+		for i := 0; i < 200; i++ {
+			// every caller seeds different keys, so a mix would show
+			seeds[c] = append(seeds[c], KV[int]{Key: []byte(fmt.Sprintf("seed%d/%d", c, i)), Value: c})
+		}
+	}
+	var wg sync.WaitGroup
+	var successes atomic.Int32
+	winner := make(chan int, callers)
+	for c := 0; c < callers; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			if tree.InitializeIfEmpty(seeds[c]) {
+				successes.Add(1)
+				winner <- c
+			}
+		}(c)
+	}
+	wg.Wait()
+	if n := successes.Load(); n != 1 {
+		t.Fatalf("%d initializers succeeded, want 1", n)
+	}
+	c := <-winner
+	if tree.Len() != len(seeds[c]) {
+		t.Fatalf("tree holds %d keys, want the %d of seed %d", tree.Len(), len(seeds[c]), c)
+	}
+	for _, kv := range seeds[c] {
+		if v, ok := tree.Search(kv.Key); !ok || v.(int) != c {
+			t.Fatalf("Search(%q) = %v, %v, want %d", kv.Key, v, ok, c)
+		}
+	}
+	if tree.InitializeIfEmpty(seeds[0]) {
+		t.Error("InitializeIfEmpty succeeded on a seeded tree")
+	}
+}
+
+func TestMapValues(t *testing.T) {
+	src := NewART[int]()
+	for i := 0; i < 1000; i++ {
+		src.Insert([]byte(fmt.Sprintf("record:%d", i*7)), i)
+	}
+
+	dst := MapValues(src, func(key []byte, v int) string {
+		return strconv.Itoa(v)
+	})
+	if dst.Len() != src.Len() {
+		t.Fatalf("Expected %d keys, got %d", src.Len(), dst.Len())
+	}
+	next, stop := iter.Pull2(dst.All())
+	defer stop()
+	for key, val := range src.All() {
+		mappedKey, mapped, ok := next()
+		if !ok || !bytes.Equal(mappedKey, key) || mapped != strconv.Itoa(val) {
+			t.Fatalf("Expected '%s'=%q, got '%s'=%q (ok=%v)", key, strconv.Itoa(val), mappedKey, mapped, ok)
+		}
+	}
+	if _, _, ok := next(); ok {
+		t.Error("Expected no keys beyond the source's")
+	}
+
+	// The source is unchanged
+	if val, found := src.Search([]byte("record:14")); !found || val.(int) != 2 {
+		t.Errorf("Expected the source to keep record:14=2, got %v (found=%v)", val, found)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	src := NewART[int]()
+	for i := 0; i < 1000; i++ {
+		src.Insert([]byte(fmt.Sprintf("item:%04d", i)), i)
+	}
+
+	even := Filter(src, func(key []byte, v int) bool { return v%2 == 0 })
+	if even.Len() != 500 {
+		t.Fatalf("Expected 500 even entries, got %d", even.Len())
+	}
+	i := 0
+	for key, val := range even.All() {
+		if val != 2*i || string(key) != fmt.Sprintf("item:%04d", 2*i) {
+			t.Fatalf("Position %d: expected item:%04d=%d, got '%s'=%d", i, 2*i, 2*i, key, val)
+		}
+		i++
+	}
+	if err := even.CheckInvariants(); err != nil {
+		t.Fatalf("Unexpected violation: %v", err)
+	}
+
+	if src.Len() != 1000 {
+		t.Errorf("Expected the source to keep 1000 entries, got %d", src.Len())
+	}
+	if val, found := src.Search([]byte("item:0007")); !found || val.(int) != 7 {
+		t.Errorf("Expected the source to keep item:0007=7, got %v (found=%v)", val, found)
+	}
+}
+
+// tagKey is a key transform that is not idempotent: applied to a stored
+// key a second time it changes it again.
+func tagKey(key []byte) []byte {
+	return append([]byte("tag:"), key...)
+}
+
+func TestFilterKeyTransform(t *testing.T) {
+	src := NewART[int](WithKeyTransform(tagKey))
+	for i := 0; i < 10; i++ {
+		src.Insert([]byte(strconv.Itoa(i)), i)
+	}
+	odd := Filter(src, func(key []byte, v int) bool { return v%2 == 1 })
+	if odd.Len() != 5 {
+		t.Fatalf("Expected 5 odd entries, got %d", odd.Len())
+	}
+	for i := 1; i < 10; i += 2 {
+		if val, found := odd.Search([]byte(strconv.Itoa(i))); !found || val.(int) != i {
+			t.Errorf("Expected %d=%d in the copy, got %v (found=%v)", i, i, val, found)
+		}
+	}
+	for key := range odd.All() {
+		if !bytes.HasPrefix(key, []byte("tag:")) || bytes.HasPrefix(key, []byte("tag:tag:")) {
+			t.Errorf("Expected the stored key transformed once, got %q", key)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+)
+
+// flight is a compute call in progress for one key.
+type flight[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// flights tracks the GetOrCompute calls in progress by key, in its stored
+// form.
+type flights[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flight[T]
+}
+
+// GetOrCompute returns the value stored under key. On a miss, exactly one
+// of the concurrent callers for key runs compute and inserts its result
+// while the others wait for it and share the result, so a missing key is
+// computed once rather than by every caller at the same time. A failed
+// compute is not stored: its error is returned to every waiting caller and
+// the next call computes again. A panic in compute propagates to the caller
+// that ran it, and the callers waiting for it get an error wrapping
+// ErrPanic. A key holding a tombstone is a cached miss: GetOrCompute
+// returns T's zero value and a nil error without calling compute, and
+// Lookup tells it apart from a stored zero value.
+func (t *Tree[T]) GetOrCompute(key []byte, compute func() (T, error)) (T, error) {
+	if _, val, found := t.search(key, 0, nil, 0); found {
+		val, _ = live(val, found)
+		return valueAs[T](val), nil
+	}
+
+	// Spellings the key transform stores alike share a flight
+	stored := string(t.storedKey(key))
+	t.flights.mu.Lock()
+	if f, ok := t.flights.calls[stored]; ok {
+		t.flights.mu.Unlock()
+		<-f.done
+		return f.val, f.err
+	}
+	f := &flight[T]{done: make(chan struct{})}
+	if t.flights.calls == nil {
+		t.flights.calls = make(map[string]*flight[T])
+	}
+	t.flights.calls[stored] = f
+	t.flights.mu.Unlock()
+	finished := false
+	defer func() {
+		if !finished {
+			f.err = fmt.Errorf("%w: compute of key %q", ErrPanic, key)
+		}
+		t.flights.mu.Lock()
+		delete(t.flights.calls, stored)
+		t.flights.mu.Unlock()
+		close(f.done)
+	}()
+
+	// A flight that finished between the miss and registering this one has
+	// already stored the value
+	if _, val, found := t.search(key, 0, nil, 0); found {
+		val, _ = live(val, found)
+		f.val = valueAs[T](val)
+	} else if f.val, f.err = compute(); f.err == nil {
+		f.err = t.TryInsert(key, f.val)
+	}
+	finished = true
+	return f.val, f.err
+}
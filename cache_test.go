@@ -0,0 +1,148 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrComputeOnce(t *testing.T) {
+	tree := NewART[string]()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	compute := func() (string, error) {
+		calls.Add(1)
+		<-release
+		return "computed", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 50)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, err := tree.GetOrCompute([]byte("cache:user:42"), compute)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			results[i] = val
+		}(i)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected compute to run once, ran %d times", n)
+	}
+	for i, val := range results {
+		if val != "computed" {
+			t.Errorf("Caller %d got '%s'", i, val)
+		}
+	}
+	if val, found := tree.Search([]byte("cache:user:42")); !found || val.(string) != "computed" {
+		t.Errorf("Expected computed value stored, got %v (found=%v)", val, found)
+	}
+}
+
+func TestGetOrComputeKeyTransform(t *testing.T) {
+	tree := NewART[string](WithKeyTransform(bytes.ToLower))
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	compute := func() (string, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	vals := make([]string, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		vals[0], _ = tree.GetOrCompute([]byte("A"), compute)
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		vals[1], _ = tree.GetOrCompute([]byte("a"), compute)
+	}()
+	// Give the second caller time to join the flight
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected one compute for both spellings, got %d", n)
+	}
+	if vals[0] != "v" || vals[1] != "v" {
+		t.Errorf("Expected both callers to get v, got %q", vals)
+	}
+}
+
+func TestGetOrComputeError(t *testing.T) {
+	tree := NewART[int]()
+	failure := errors.New("backend down")
+	if _, err := tree.GetOrCompute([]byte("k"), func() (int, error) { return 0, failure }); err != failure {
+		t.Errorf("Expected compute error, got %v", err)
+	}
+	if _, found := tree.Search([]byte("k")); found {
+		t.Error("Expected failed compute not to be stored")
+	}
+	val, err := tree.GetOrCompute([]byte("k"), func() (int, error) { return 7, nil })
+	if err != nil || val != 7 {
+		t.Errorf("Expected retry to compute 7, got %d (err=%v)", val, err)
+	}
+	val, _ = tree.GetOrCompute([]byte("k"), func() (int, error) { return 8, nil })
+	if val != 7 {
+		t.Errorf("Expected stored value 7 on hit, got %d", val)
+	}
+}
+
+func TestGetOrComputePanic(t *testing.T) {
+	tree := NewART[int]()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	waited := make(chan error)
+	go func() {
+		<-started
+		_, err := tree.GetOrCompute([]byte("k"), func() (int, error) { return 1, nil })
+		waited <- err
+	}()
+	go func() {
+		<-started
+		// give the other caller time to wait on the flight
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected the compute panic to reach its caller")
+			}
+		}()
+		tree.GetOrCompute([]byte("k"), func() (int, error) {
+			close(started)
+			<-release
+			panic("injected")
+		})
+	}()
+	// A waiter that joined the flight gets ErrPanic, a late one computes
+	select {
+	case err := <-waited:
+		if err != nil && !errors.Is(err, ErrPanic) {
+			t.Errorf("Waiter got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiter still blocked after the compute panicked")
+	}
+	val, err := tree.GetOrCompute([]byte("k"), func() (int, error) { return 7, nil })
+	if err != nil || (val != 7 && val != 1) {
+		t.Errorf("Expected a fresh compute after the panic, got %d (err=%v)", val, err)
+	}
+}
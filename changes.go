@@ -0,0 +1,209 @@
+package art
+
+import "sync/atomic"
+
+// changeState numbers the writes of a tree created with WithChangeTracking.
+type changeState struct {
+	gen atomic.Uint64
+}
+
+// innerVersion is the version word of an inner node. maxSeq is the highest
+// modification sequence of any leaf below the node, which lets ChangedSince
+// skip subtrees that have not changed; it stays zero unless the tree tracks
+// changes. path is the node's full path in trees created with
+// WithFullPathNodes, and nil otherwise. A node replacing another inherits
+// both, so they share the version's allocation rather than each node type
+// carrying them.
+type innerVersion struct {
+	version atomic.Uint64
+	maxSeq  atomic.Uint64
+	path    *[]byte
+}
+
+func newInnerVersion() *innerVersion {
+	return &innerVersion{}
+}
+
+// innerWord returns the version word of inner node n, or nil for a leaf or
+// a frozen copy.
+func innerWord(n node) *innerVersion {
+	switch n := n.(type) {
+	case *node4:
+		return n.versionLockObsolete
+	case *node16:
+		return n.versionLockObsolete
+	case *node48:
+		return n.versionLockObsolete
+	case *node256:
+		return n.versionLockObsolete
+	case *nodeWide:
+		return n.versionLockObsolete
+	}
+	return nil
+}
+
+// inheritedVersion returns a new inner version word carrying over the
+// subtree sequence and full path of the node whose version is old, for a
+// node replacing it. The caller holds old's write lock, so a write marking
+// old either happened before the copy or fails its validation and marks
+// the new node.
+func inheritedVersion(old *innerVersion) *innerVersion {
+	v := &innerVersion{path: old.path}
+	v.maxSeq.Store(old.maxSeq.Load())
+	return v
+}
+
+// raiseSeq sets seq to s if s is higher.
+func raiseSeq(seq *atomic.Uint64, s uint64) {
+	for {
+		cur := seq.Load()
+		if cur >= s || seq.CompareAndSwap(cur, s) {
+			return
+		}
+	}
+}
+
+// WithChangeTracking numbers every write and records the number in the
+// leaf it changes and in each node above it, so that ChangedSince visits
+// the keys written since a Generation without walking unchanged subtrees.
+// Each write pays a second descent to mark its path. Modify counts as a
+// write when fn reports a change. Deletes are not tracked. Trees of
+// zero-size values lose the smaller leaves WithChangeTracking would
+// otherwise give them.
+func WithChangeTracking() Option {
+	return func(c *config) {
+		c.changeTracking = true
+	}
+}
+
+// Generation returns the number of the latest write. Every write numbered
+// up to it has completed, so ChangedSince(gen) run at any later point
+// visits every key written after it. Generation briefly waits for
+// in-flight writers. It returns 0 unless the tree was created with
+// WithChangeTracking.
+func (t *Tree[T]) Generation() uint64 {
+	if t.changes == nil {
+		return 0
+	}
+	resume := t.Quiesce()
+	defer resume()
+	return t.changes.gen.Load()
+}
+
+// ChangedSince visits in key order the keys whose value was written after
+// generation gen, as returned by Generation, until fn returns false. Keys
+// written since and deleted again are not visited. To poll for changes,
+// take the next generation before each call:
+//
+//	next := tree.Generation()
+//	tree.ChangedSince(last, fn)
+//	last = next
+//
+// Writes concurrent with a call may or may not be visited by it, and are
+// visited by the next one. In trees created without WithChangeTracking
+// ChangedSince visits every key.
+func (t *Tree[T]) ChangedSince(gen uint64, fn func(key []byte, val T) bool) {
+	visit := func(l *leaf) bool {
+		return fn(l.key, valueAs[T](readLeaf(l)))
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	if t.changes == nil {
+		walk(t.root(), visit)
+		return
+	}
+	changedBelow(t.root(), gen, visit)
+}
+
+// changedBelow is walk visiting only the leaves below n written after gen.
+func changedBelow(n node, gen uint64, fn func(l *leaf) bool) bool {
+	if l, ok := n.(*leaf); ok {
+		if l.seq.Load() <= gen || isTombstone(l) {
+			return true
+		}
+		return fn(l)
+	}
+	if innerWord(n).maxSeq.Load() <= gen {
+		return true
+	}
+	for _, child := range readChildren(n) {
+		if !changedBelow(child, gen, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// stamp gives the write of l the next sequence number and returns it, or 0
+// if the tree does not track changes. The caller holds t.writers, so that
+// Generation never returns the number of an unfinished write, and l's write
+// lock or has not yet published l.
+func (t *Tree[T]) stamp(l *leaf) uint64 {
+	if t.changes == nil {
+		return 0
+	}
+	s := t.changes.gen.Add(1)
+	l.seq.Store(s)
+	return s
+}
+
+// carrySeq gives dst, which takes over src's value, src's sequence number.
+func (t *Tree[T]) carrySeq(dst, src *leaf) {
+	if t.changes != nil {
+		dst.seq.Store(src.seq.Load())
+	}
+}
+
+// inherit raises the subtree sequence of the new node n to that of child,
+// which it holds. The caller holds child's write lock.
+func (t *Tree[T]) inherit(n node, child node) {
+	if t.changes == nil {
+		return
+	}
+	if l, ok := child.(*leaf); ok {
+		raiseSeq(&innerWord(n).maxSeq, l.seq.Load())
+		return
+	}
+	raiseSeq(&innerWord(n).maxSeq, innerWord(child).maxSeq.Load())
+}
+
+// markPath raises the subtree sequence of every inner node on the path to
+// key to s, the sequence of a write of key. The path starts at root in RCU
+// trees, which mark a new root before publishing it, and at t's root
+// otherwise. Each node is marked before it is validated, so a node
+// replacing it concurrently either inherits the mark or the validation
+// fails and the replacement is marked.
+func (t *Tree[T]) markPath(root node, key []byte, s uint64) {
+	if s == 0 {
+		return
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+restart:
+	n := root
+	if t.rcu == nil {
+		n = t.node.load()
+	}
+	depth := 0
+	for n != nil && n.getType() != nodeTypeLeaf {
+		version, needToRestart := readLockOrRestart(n)
+		if needToRestart {
+			goto restart
+		}
+		raiseSeq(&innerWord(n).maxSeq, s)
+		prefix := n.getPrefix()
+		if checkPrefix(prefix, key, depth) != len(prefix) {
+			if !validate(n, version) {
+				goto restart
+			}
+			return
+		}
+		depth += len(prefix)
+		var next node
+		if slot := findChild(n, key, depth); slot != nil {
+			next = slot.load()
+		}
+		if !validate(n, version) {
+			goto restart
+		}
+		n = next
+	}
+}
@@ -0,0 +1,85 @@
+package art
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestChangedSince(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithRCUReads()}, {WithCompactInts()}} {
+		tree := NewART[int](append(opts, WithChangeTracking())...)
+		for i := 0; i < 1000; i++ {
+			tree.Insert([]byte(fmt.Sprintf("key/%04d", i)), i)
+		}
+		gen := tree.Generation()
+
+		var want []string
+		for i := 7; i < 1000; i += 100 {
+			key := fmt.Sprintf("key/%04d", i)
+			want = append(want, key)
+			if i%200 == 7 {
+				tree.Insert([]byte(key), -i)
+			} else {
+				tree.ReplaceIf([]byte(key), -i, func(int) bool { return true })
+			}
+		}
+		// Neither a rejected replacement nor a delete is a change
+		tree.ReplaceIf([]byte("key/0001"), 0, func(int) bool { return false })
+		tree.Delete([]byte("key/0002"))
+
+		var got []string
+		tree.ChangedSince(gen, func(key []byte, val int) bool {
+			got = append(got, string(key))
+			return true
+		})
+		if !slices.Equal(got, want) {
+			t.Errorf("%d options: ChangedSince visited %q, want %q", len(opts), got, want)
+		}
+
+		// The last generation covers every write so far
+		next := tree.Generation()
+		tree.ChangedSince(next, func(key []byte, val int) bool {
+			t.Errorf("%d options: ChangedSince(%d) visited %q", len(opts), next, key)
+			return true
+		})
+		tree.Insert([]byte("key/1000"), 1000)
+		got = got[:0]
+		tree.ChangedSince(next, func(key []byte, val int) bool {
+			got = append(got, string(key))
+			return true
+		})
+		if !slices.Equal(got, []string{"key/1000"}) {
+			t.Errorf("%d options: ChangedSince after an insert visited %q", len(opts), got)
+		}
+	}
+}
+
+func TestChangedSinceSurvivesShrinking(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithRCUReads()}} {
+		tree := NewART[int](append(opts, WithChangeTracking())...)
+		for i := 1; i < 200; i++ {
+			tree.Insert([]byte{'k', byte(i)}, i)
+		}
+		gen := tree.Generation()
+		tree.Insert([]byte{'k', 100}, -100)
+		// Deletes shrink the node256 holding the key down to a node4, and
+		// each smaller node must keep the mark of the write
+		for i := 1; i < 200; i++ {
+			if i < 98 || i > 100 {
+				tree.Delete([]byte{'k', byte(i)})
+			}
+		}
+		if _, path, _ := tree.KeyExists([]byte{'k', 100}); path[len(path)-2] != nodeType4 {
+			t.Fatalf("%d options: path to the key is %v after the deletes", len(opts), path)
+		}
+		var got [][]byte
+		tree.ChangedSince(gen, func(key []byte, val int) bool {
+			got = append(got, key)
+			return true
+		})
+		if len(got) != 1 || got[0][1] != 100 {
+			t.Errorf("%d options: ChangedSince visited %q", len(opts), got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package art
+
+import (
+	"fmt"
+	"hash/maphash"
+	"reflect"
+	"unsafe"
+)
+
+// checksumState checksums the leaves of a tree created with
+// WithLeafChecksums.
+type checksumState struct {
+	seed maphash.Seed
+}
+
+// WithLeafChecksums stores a checksum of every leaf's key and value,
+// computed whenever the value is written, which SearchChecked verifies so
+// that memory corruption or a stray write surfaces as ErrChecksum instead
+// of a wrong result. Values are covered if they are integers, strings,
+// []byte or otherwise comparable; for other types only the key is.
+// A []byte value must not be modified after it is inserted. Trees of
+// zero-size values lose the smaller leaves WithLeafChecksums would
+// otherwise give them.
+func WithLeafChecksums() Option {
+	return func(c *config) {
+		c.leafChecksums = true
+	}
+}
+
+// seal records the checksum of l's current value. The caller holds l's
+// write lock or has not yet published l.
+func (t *Tree[T]) seal(l *leaf) {
+	if t.checksums != nil {
+		l.sum.Store(t.checksums.of(l))
+	}
+}
+
+// of returns the checksum of l's key and stored value.
+func (c *checksumState) of(l *leaf) uint64 {
+	var h maphash.Hash
+	h.SetSeed(c.seed)
+	h.Write(l.key)
+	switch v := l.raw().(type) {
+	case compactValue:
+		h.WriteByte(byte(v))
+		bits := l.bits.Load()
+		h.Write((*[8]byte)(unsafe.Pointer(&bits))[:])
+	case inlineValue:
+		h.Write(l.value().([]byte))
+	case []byte:
+		h.Write(v)
+	case string:
+		h.WriteString(v)
+	default:
+		if v != nil && reflect.TypeOf(v).Comparable() {
+			maphash.WriteComparable(&h, v)
+		}
+	}
+	return h.Sum64()
+}
+
+// SearchChecked is Search for trees created with WithLeafChecksums: it
+// verifies the checksum of the leaf holding key and returns an error
+// wrapping ErrChecksum if the leaf's key or value no longer matches it. In
+// other trees it behaves like Search with a nil error.
+func (t *Tree[T]) SearchChecked(key []byte) (T, bool, error) {
+	for {
+		l, val, found := t.search(key, 0, nil, 0)
+		if !found {
+			if a, ok := t.resolveAlias(key); ok {
+				l, val, found = t.search(a.rewrite(key), 0, nil, 0)
+			}
+		}
+		if !found || t.checksums == nil {
+			val, found = live(val, found)
+			return valueAs[T](val), found, nil
+		}
+		version, needToRestart := readLockOrRestart(l)
+		if needToRestart {
+			// deleted or replaced since the search; look again
+			continue
+		}
+		want := l.sum.Load()
+		got := t.checksums.of(l)
+		val = l.value()
+		if !validate(l, version) {
+			continue
+		}
+		if got != want {
+			return valueAs[T](nil), false, fmt.Errorf("%w: key %q", ErrChecksum, l.key)
+		}
+		val, found = live(val, true)
+		return valueAs[T](val), found, nil
+	}
+}
@@ -0,0 +1,55 @@
+package art
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchCheckedDetectsCorruption(t *testing.T) {
+	tree := NewART[[]byte](WithLeafChecksums())
+	tree.Insert([]byte("block/1"), []byte("payload one"))
+	tree.Insert([]byte("block/2"), []byte("payload two"))
+	if val, found, err := tree.SearchChecked([]byte("block/1")); err != nil || !found || string(val) != "payload one" {
+		t.Fatalf("SearchChecked before corruption = %q, %v, %v", val, found, err)
+	}
+
+	// Flip a bit of the stored value behind the tree's back
+	l, _, _ := tree.search([]byte("block/1"), 0, nil, 0)
+	l.value().([]byte)[3] ^= 0x20
+
+	if _, found, err := tree.SearchChecked([]byte("block/1")); !errors.Is(err, ErrChecksum) || found {
+		t.Errorf("SearchChecked of a corrupted leaf = %v, %v", found, err)
+	}
+	if _, _, err := tree.SearchChecked([]byte("block/2")); err != nil {
+		t.Errorf("SearchChecked of an intact leaf = %v", err)
+	}
+	if _, found, err := tree.SearchChecked([]byte("block/3")); found || err != nil {
+		t.Errorf("SearchChecked of a missing key = %v, %v", found, err)
+	}
+
+	// Writing the value again records a fresh checksum
+	tree.Insert([]byte("block/1"), []byte("payload one"))
+	if _, _, err := tree.SearchChecked([]byte("block/1")); err != nil {
+		t.Errorf("SearchChecked after overwrite = %v", err)
+	}
+}
+
+func TestSearchCheckedCompactInts(t *testing.T) {
+	for _, opts := range [][]Option{{WithCompactInts()}, {WithRCUReads()}} {
+		tree := NewART[int](append(opts, WithLeafChecksums())...)
+		tree.Insert([]byte("n"), 41)
+		tree.ReplaceIf([]byte("n"), 42, func(old int) bool { return old == 41 })
+		if val, _, err := tree.SearchChecked([]byte("n")); err != nil || val != 42 {
+			t.Fatalf("SearchChecked = %d, %v", val, err)
+		}
+		l, _, _ := tree.search([]byte("n"), 0, nil, 0)
+		if _, ok := l.raw().(compactValue); ok {
+			l.bits.Store(43)
+		} else {
+			l.setRaw(43)
+		}
+		if _, _, err := tree.SearchChecked([]byte("n")); !errors.Is(err, ErrChecksum) {
+			t.Errorf("SearchChecked of a corrupted counter = %v", err)
+		}
+	}
+}
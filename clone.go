@@ -0,0 +1,30 @@
+package art
+
+// Clone returns a copy of the tree, configured like it, that writers to
+// either tree change independently. A tree created with WithRCUReads hands
+// the clone its published root and costs O(1): writers copy the nodes they
+// change, so the two trees keep sharing every subtree neither has written
+// to, and values that Modify changes in place are seen by both. Other trees
+// are quiesced and have every key copied into the clone.
+func (t *Tree[T]) Clone() *Tree[T] {
+	c := t.emptyLike()
+	if t.rcu != nil {
+		// Writers publish the root and update the size under rcu.mu
+		t.rcu.mu.Lock()
+		defer t.rcu.mu.Unlock()
+		c.rcu.root.Store(t.rcu.root.Load())
+		c.size.Store(t.size.Load())
+		if t.changes != nil {
+			c.changes.gen.Store(t.changes.gen.Load())
+		}
+		return c
+	}
+	resume := t.Quiesce()
+	defer resume()
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		c.upsertStored(l.key, readLeaf(l))
+		return true
+	})
+	return c
+}
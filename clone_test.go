@@ -0,0 +1,33 @@
+package art
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"plain": nil,
+		"rcu":   {WithRCUReads()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tree := NewART[int](opts...)
+			tree.Insert([]byte("a"), 1)
+			tree.Insert([]byte("b"), 2)
+			clone := tree.Clone()
+
+			tree.Insert([]byte("a"), 10)
+			tree.Delete([]byte("b"))
+			clone.Insert([]byte("c"), 3)
+			if got, want := tree.ToMap(), map[string]int{"a": 10}; !reflect.DeepEqual(got, want) {
+				t.Errorf("tree = %v, want %v", got, want)
+			}
+			if got, want := clone.ToMap(), map[string]int{"a": 1, "b": 2, "c": 3}; !reflect.DeepEqual(got, want) {
+				t.Errorf("clone = %v, want %v", got, want)
+			}
+			if clone.Len() != 3 || tree.Len() != 1 {
+				t.Errorf("Len = %d and %d, want 1 and 3", tree.Len(), clone.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package art
+
+import (
+	"sync"
+	"time"
+)
+
+// coalescer holds the Inserts of a tree created with WithWriteCoalescing
+// until their window closes.
+type coalescer[T any] struct {
+	window time.Duration
+	mu     sync.Mutex
+	// pending maps each key with a held Insert, in its stored form, to its
+	// latest Insert; a key is present from its first Insert until its
+	// window closes
+	pending map[string]heldInsert[T]
+	// applying is held while held values are written to the tree, which
+	// keeps a key's successive windows, and Deletes, in order
+	applying sync.Mutex
+}
+
+// heldInsert is an Insert held by a coalescer. key is as the caller passed
+// it, for TryInsert to check and transform when it is written.
+type heldInsert[T any] struct {
+	key []byte
+	val T
+}
+
+// WithWriteCoalescing delays every Insert by up to window and merges the
+// Inserts to one key made within it, so that only the latest value is
+// written, under a single acquisition of the key's leaf lock. It suits
+// counters and last-write-wins keys overwritten at high frequency from
+// many goroutines, where each Insert would otherwise contend on the same
+// leaf. The price is visibility lag: an Insert returns before it takes
+// effect, and Search and the other reads of the tree, including those by
+// the goroutine that inserted, see the previous value until the window
+// closes or Flush is called. Values the tree's options reject are dropped
+// when they are written. Delete discards the held Insert of its key;
+// other writes are not ordered with held Inserts, which overwrite them
+// when their window closes. TryInsert and the other writers are not
+// coalesced.
+func WithWriteCoalescing(window time.Duration) Option {
+	return func(c *config) {
+		c.coalesceWindow = window
+	}
+}
+
+// coalesce holds val as key's latest value, starting a window for key
+// unless one is open. Spellings of a key that the tree's key transform
+// stores alike share a window.
+func (t *Tree[T]) coalesce(key []byte, val T) {
+	c := t.coalescing
+	k := string(t.storedKey(key))
+	c.mu.Lock()
+	_, open := c.pending[k]
+	c.pending[k] = heldInsert[T]{key: append([]byte(nil), key...), val: val}
+	c.mu.Unlock()
+	if !open {
+		time.AfterFunc(c.window, func() { t.flushKey(k) })
+	}
+}
+
+// flushKey writes the Insert held for key, in its stored form, if it is
+// still held.
+func (t *Tree[T]) flushKey(key string) {
+	c := t.coalescing
+	c.applying.Lock()
+	defer c.applying.Unlock()
+	c.mu.Lock()
+	held, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if ok {
+		_ = t.TryInsert(held.key, held.val)
+	}
+}
+
+// drop forgets the Insert held for key, in its stored form, and reports
+// whether there was one. The caller holds applying, so no write of it is
+// under way.
+func (c *coalescer[T]) drop(key []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.pending[string(key)]
+	delete(c.pending, string(key))
+	return ok
+}
+
+// Flush writes every Insert held by WithWriteCoalescing now rather than
+// when its window closes. Inserts made during Flush may stay held. It does
+// nothing for other trees.
+func (t *Tree[T]) Flush() {
+	c := t.coalescing
+	if c == nil {
+		return
+	}
+	c.applying.Lock()
+	defer c.applying.Unlock()
+	c.mu.Lock()
+	held := c.pending
+	c.pending = make(map[string]heldInsert[T])
+	c.mu.Unlock()
+	for _, h := range held {
+		_ = t.TryInsert(h.key, h.val)
+	}
+}
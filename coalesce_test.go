@@ -0,0 +1,108 @@
+package art
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+// leafWrites returns how many times key's leaf has been write locked,
+// from the version it is at.
+func leafWrites[T any](tree *Tree[T], key []byte) uint64 {
+	return searchUnlocked(tree.root(), key).version().Load() / (2 * LOCK_INCREMENT)
+}
+
+func TestWriteCoalescing(t *testing.T) {
+	tree := NewART[int](WithWriteCoalescing(time.Millisecond))
+	key := []byte("counter")
+	tree.Insert(key, 0)
+	tree.Flush()
+	before := leafWrites(tree, key)
+
+	const goroutines, inserts = 8, 10000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 1; i <= inserts; i++ {
+				tree.Insert(key, g*inserts+i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	tree.Insert(key, -1)
+	tree.Flush()
+
+	if val, found := tree.Search(key); !found || val.(int) != -1 {
+		t.Errorf("Expected the last value -1, got %v (found=%v)", val, found)
+	}
+	writes := leafWrites(tree, key) - before
+	if writes == 0 || writes > goroutines*inserts/100 {
+		t.Errorf("Expected far fewer than %d leaf writes, got %d", goroutines*inserts, writes)
+	}
+	if tree.Len() != 1 {
+		t.Errorf("Expected one key, got %d", tree.Len())
+	}
+}
+
+func TestWriteCoalescingVisibility(t *testing.T) {
+	tree := NewART[int](WithWriteCoalescing(time.Hour))
+	tree.Insert([]byte("a"), 1)
+	if _, found := tree.Search([]byte("a")); found {
+		t.Error("Expected a held Insert to be invisible")
+	}
+	tree.Flush()
+	if val, found := tree.Search([]byte("a")); !found || val.(int) != 1 {
+		t.Errorf("Expected a=1 after Flush, got %v (found=%v)", val, found)
+	}
+
+	// Delete discards the held Insert rather than letting it resurrect the
+	// key later
+	tree.Insert([]byte("a"), 2)
+	tree.Insert([]byte("b"), 3)
+	if !tree.Delete([]byte("a")) || !tree.Delete([]byte("b")) {
+		t.Error("Expected Delete to report the stored and the held key")
+	}
+	if tree.Delete([]byte("b")) {
+		t.Error("Expected nothing left to delete")
+	}
+	tree.Flush()
+	if tree.Len() != 0 {
+		t.Errorf("Expected an empty tree, got %v", tree.Keys())
+	}
+
+	// Windows close by themselves
+	short := NewART[int](WithWriteCoalescing(time.Millisecond))
+	short.Insert([]byte("k"), 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, found := short.Search([]byte("k")); found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the held Insert to be written when its window closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteCoalescingKeyTransform(t *testing.T) {
+	tree := NewART[int](WithWriteCoalescing(time.Hour), WithKeyTransform(bytes.ToLower))
+	tree.Insert([]byte("A"), 1)
+	if !tree.Delete([]byte("a")) {
+		t.Error("Expected Delete to discard the held Insert of the same key")
+	}
+	tree.Flush()
+	if val, found := tree.Search([]byte("a")); found {
+		t.Errorf("Expected the deleted key to stay absent after Flush, got %v", val)
+	}
+
+	tree.Insert([]byte("B"), 1)
+	tree.Insert([]byte("b"), 2)
+	tree.Flush()
+	if val, found := tree.Search([]byte("B")); !found || val.(int) != 2 {
+		t.Errorf("Expected both spellings to coalesce to b=2, got %v (found=%v)", val, found)
+	}
+}
@@ -0,0 +1,113 @@
+package art
+
+import "bytes"
+
+// WithColumnarLeaves makes Freeze lay the frozen tree's keys and values out
+// in columns: every key back to back in one allocation and every value in
+// a []T, both in key order, rather than in a leaf and a boxed value per
+// key. The frozen inner nodes still lead Search to a leaf, which then only
+// holds its key's position in the columns, so point lookups cost what they
+// did; ForEach and ScanPrefix instead run through the columns in order,
+// without following a leaf and an interface per key. Building the columns
+// copies every key and unboxes every value, loading those stored with
+// InsertLazy. The live tree keeps its leaves either way, since each must
+// be locked and versioned on its own.
+func WithColumnarLeaves() Option {
+	return func(c *config) {
+		c.columnarLeaves = true
+	}
+}
+
+// columns holds the keys and values of a frozen tree built with
+// WithColumnarLeaves, in key order.
+type columns[T any] struct {
+	// keys holds every key back to back; key i ends at ends[i]
+	keys []byte
+	ends []int
+	vals []T
+}
+
+// key returns key i, capped so that appending to it cannot overwrite the
+// next.
+func (c *columns[T]) key(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = c.ends[i-1]
+	}
+	return c.keys[start:c.ends[i]:c.ends[i]]
+}
+
+// freezeColumns moves the keys and values of root, a tree freezeNode has
+// just copied, into columns, leaving each leaf its key's position in its
+// bits and its key in the columns.
+func freezeColumns[T any](root node, size int) *columns[T] {
+	c := &columns[T]{ends: make([]int, 0, size), vals: make([]T, 0, size)}
+	var leaves []*leaf
+	walkUnlocked(root, func(l *leaf) bool {
+		c.keys = append(c.keys, l.key...)
+		c.ends = append(c.ends, len(c.keys))
+		c.vals = append(c.vals, valueAs[T](l.value()))
+		leaves = append(leaves, l)
+		return true
+	})
+	for i, l := range leaves {
+		l.key = c.key(i)
+		l.val.Store(nil)
+		l.bits.Store(uint64(i))
+	}
+	return c
+}
+
+// search returns the value of the leaf searchUnlocked found for a key.
+func (c *columns[T]) search(l *leaf) (T, bool) {
+	if l == nil {
+		var zero T
+		return zero, false
+	}
+	return c.vals[l.bits.Load()], true
+}
+
+// scan calls fn for the keys starting with prefix among those at
+// positions lo up to hi, until fn returns false.
+func (c *columns[T]) scan(lo, hi int, prefix []byte, fn func(key []byte, val T) bool) {
+	for i := lo; i < hi; i++ {
+		key := c.key(i)
+		if !bytes.HasPrefix(key, prefix) {
+			continue
+		}
+		if !fn(key, c.vals[i]) {
+			return
+		}
+	}
+}
+
+// span returns the column positions of the first and one past the last
+// key below n, a node of a frozen tree built with WithColumnarLeaves.
+func span(n node) (lo, hi int) {
+	first, last := edgeLeaf(n, false), edgeLeaf(n, true)
+	if first == nil {
+		return 0, 0
+	}
+	return int(first.bits.Load()), int(last.bits.Load()) + 1
+}
+
+// edgeLeaf returns the first leaf below n, or the last if last is set, or
+// nil if n holds none, as it may once tombstones are left out.
+func edgeLeaf(n node, last bool) *leaf {
+	if n == nil {
+		return nil
+	}
+	if l, ok := n.(*leaf); ok {
+		return l
+	}
+	children := sortedChildren(n)
+	for i := range children {
+		if last {
+			i = len(children) - 1 - i
+		}
+		if l := edgeLeaf(children[i], last); l != nil {
+			return l
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package art
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestColumnarLeaves(t *testing.T) {
+	tree := NewART[int](WithColumnarLeaves(), WithCompactInts())
+	plain := NewART[int]()
+	for i := 0; i < 3000; i++ {
+		key := []byte(fmt.Sprintf("tenant/%02d/objects/%06d", i%13, i))
+		tree.Insert(key, i)
+		plain.Insert(key, i)
+	}
+	tree.Insert(nil, -1)
+	plain.Insert(nil, -1)
+	tree.InsertTombstone([]byte("tenant/05/objects/000005"))
+	plain.InsertTombstone([]byte("tenant/05/objects/000005"))
+	tree.InsertLazy([]byte("tenant/99/lazy"), func() int { return 99 })
+	plain.Insert([]byte("tenant/99/lazy"), 99)
+	frozen, want := tree.Freeze(), plain.Freeze()
+	if frozen.cols == nil || want.cols != nil {
+		t.Fatal("Expected only the option to build columns")
+	}
+
+	// Writes after the freeze leave the columns alone
+	for i := 0; i < 3000; i += 2 {
+		tree.Delete([]byte(fmt.Sprintf("tenant/%02d/objects/%06d", i%13, i)))
+	}
+
+	for i := 0; i < 3000; i++ {
+		key := []byte(fmt.Sprintf("tenant/%02d/objects/%06d", i%13, i))
+		val, found := frozen.Search(key)
+		wantVal, wantFound := want.Search(key)
+		if val != wantVal || found != wantFound {
+			t.Fatalf("Search(%s): expected %d (found=%v), got %d (found=%v)", key, wantVal, wantFound, val, found)
+		}
+	}
+	for _, key := range []string{"", "tenant/99/lazy", "tenant/05/objects/000005", "tenant/05", "zzz"} {
+		val, found := frozen.Search([]byte(key))
+		wantVal, wantFound := want.Search([]byte(key))
+		if val != wantVal || found != wantFound {
+			t.Errorf("Search(%q): expected %d (found=%v), got %d (found=%v)", key, wantVal, wantFound, val, found)
+		}
+	}
+
+	collect := func(f *FrozenTree[int], scan func(f *FrozenTree[int], fn func([]byte, int) bool)) []string {
+		var got []string
+		scan(f, func(key []byte, val int) bool {
+			got = append(got, fmt.Sprintf("%s=%d", key, val))
+			return len(got) < 500
+		})
+		return got
+	}
+	scans := map[string]func(f *FrozenTree[int], fn func([]byte, int) bool){
+		"ForEach": func(f *FrozenTree[int], fn func([]byte, int) bool) { f.ForEach(fn) },
+		"ScanPrefix": func(f *FrozenTree[int], fn func([]byte, int) bool) {
+			f.ScanPrefix([]byte("tenant/05/objects/0"), fn)
+		},
+		"ScanPrefix inside a node prefix": func(f *FrozenTree[int], fn func([]byte, int) bool) {
+			f.ScanPrefix([]byte("tenant/1"), fn)
+		},
+		"ScanPrefix absent": func(f *FrozenTree[int], fn func([]byte, int) bool) {
+			f.ScanPrefix([]byte("tenant/5"), fn)
+		},
+	}
+	for name, scan := range scans {
+		got, expected := collect(frozen, scan), collect(want, scan)
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("%s: expected %d entries %v..., got %d", name, len(expected), expected[:min(3, len(expected))], len(got))
+		}
+	}
+
+	// A key handed out cannot be appended over its neighbour
+	frozen.ForEach(func(key []byte, _ int) bool {
+		_ = append(key, 'x')
+		return true
+	})
+	if val, found := frozen.Search([]byte("tenant/00/objects/000000")); !found || val != 0 {
+		t.Errorf("Expected the columns intact, got %d (found=%v)", val, found)
+	}
+}
+
+func TestColumnarLeavesWideStride(t *testing.T) {
+	tree := NewART[int](WithColumnarLeaves(), WithWideStride())
+	for i := 0; i < 2000; i++ {
+		tree.Insert(binary.BigEndian.AppendUint32(nil, uint32(i*37)), i)
+	}
+	frozen := tree.Freeze()
+	for i := 0; i < 2000; i++ {
+		if val, found := frozen.Search(binary.BigEndian.AppendUint32(nil, uint32(i*37))); !found || val != i {
+			t.Fatalf("Expected %d, got %d (found=%v)", i, val, found)
+		}
+	}
+	// The prefix ends inside the two bytes a wide node branches on
+	count := 0
+	frozen.ScanPrefix([]byte{0, 0, 1}, func(key []byte, _ int) bool {
+		count++
+		return true
+	})
+	expected := 0
+	tree.ScanPrefix([]byte{0, 0, 1}, func([]byte, int) bool {
+		expected++
+		return true
+	})
+	if count != expected || count == 0 {
+		t.Errorf("Expected %d keys under the prefix, got %d", expected, count)
+	}
+}
+
+// BenchmarkFrozenForEach compares a full ForEach over 1M keys of a frozen
+// tree with and without WithColumnarLeaves. Compare the keys/s metric.
+func BenchmarkFrozenForEach(b *testing.B) {
+	const keys = 1 << 20
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"leaves", nil},
+		{"columnar", []Option{WithColumnarLeaves()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			tree := NewART[int](mode.opts...)
+			for i := 0; i < keys; i++ {
+				tree.Insert([]byte(fmt.Sprintf("metric/%04d/%08d", i%1000, i)), i)
+			}
+			frozen := tree.Freeze()
+			b.ResetTimer()
+			sum := 0
+			for i := 0; i < b.N; i++ {
+				frozen.ForEach(func(_ []byte, val int) bool {
+					sum += val
+					return true
+				})
+			}
+			b.ReportMetric(float64(keys)*float64(b.N)/b.Elapsed().Seconds(), "keys/s")
+		})
+	}
+}
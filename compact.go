@@ -0,0 +1,93 @@
+package art
+
+import "reflect"
+
+// compactValue tags a leaf whose integer value is stored unboxed in the
+// leaf's bits, holding the value's kind. Like inlineValue, converting the
+// one-byte tag to an interface does not allocate.
+type compactValue reflect.Kind
+
+// compact splits an integer of a predeclared type into its kind tag and
+// bits. Other values, including named integer types, are returned as they
+// are with ok false. Called with a type parameter rather than an interface
+// it compacts without boxing val first.
+func compact[V any](val V) (tag interface{}, bits uint64, ok bool) {
+	switch v := any(val).(type) {
+	case int:
+		return compactValue(reflect.Int), uint64(v), true
+	case int8:
+		return compactValue(reflect.Int8), uint64(v), true
+	case int16:
+		return compactValue(reflect.Int16), uint64(v), true
+	case int32:
+		return compactValue(reflect.Int32), uint64(v), true
+	case int64:
+		return compactValue(reflect.Int64), uint64(v), true
+	case uint:
+		return compactValue(reflect.Uint), uint64(v), true
+	case uint8:
+		return compactValue(reflect.Uint8), uint64(v), true
+	case uint16:
+		return compactValue(reflect.Uint16), uint64(v), true
+	case uint32:
+		return compactValue(reflect.Uint32), uint64(v), true
+	case uint64:
+		return compactValue(reflect.Uint64), v, true
+	}
+	return any(val), 0, false
+}
+
+// expand boxes bits as the integer kind k.
+func (k compactValue) expand(bits uint64) interface{} {
+	switch reflect.Kind(k) {
+	case reflect.Int:
+		return int(bits)
+	case reflect.Int8:
+		return int8(bits)
+	case reflect.Int16:
+		return int16(bits)
+	case reflect.Int32:
+		return int32(bits)
+	case reflect.Int64:
+		return int64(bits)
+	case reflect.Uint:
+		return uint(bits)
+	case reflect.Uint8:
+		return uint8(bits)
+	case reflect.Uint16:
+		return uint16(bits)
+	case reflect.Uint32:
+		return uint32(bits)
+	}
+	return bits
+}
+
+// setValue replaces l's value while l is write-locked. A compact leaf
+// overwritten with an integer of its kind only swaps its bits, so the
+// value stays unboxed across overwrites; an integer of another kind, which
+// a tree of interface values can hold, is stored boxed.
+func (l *leaf) setValue(val interface{}) {
+	if cur, ok := l.raw().(compactValue); ok {
+		if tag, bits, ok := compact(val); ok && tag == cur {
+			l.bits.Store(bits)
+			return
+		}
+	}
+	l.setRaw(val)
+}
+
+// assign replaces l's value with src's while l is write-locked. An inline
+// value lives in src's key allocation, so it is copied out by value; a
+// lazy one is moved over unloaded.
+func (l *leaf) assign(src *leaf) {
+	switch src.raw().(type) {
+	case compactValue:
+		l.bits.Store(src.bits.Load())
+		l.val.Store(src.val.Load())
+		return
+	case *lazyValue:
+		l.setRaw(src.raw())
+		return
+	}
+	l.setRaw(src.value())
+}
@@ -0,0 +1,152 @@
+package art
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestCompactInts(t *testing.T) {
+	tree := NewART[uint32](WithCompactInts(), WithVersionHistory(2))
+	for i := 0; i < 5000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("c%d", i)), uint32(i*65537))
+	}
+	tree.Insert(nil, math.MaxUint32)
+	for i := 0; i < 5000; i++ {
+		key := []byte(fmt.Sprintf("c%d", i))
+		if val, found := tree.Search(key); !found || val.(uint32) != uint32(i*65537) {
+			t.Fatalf("Expected %s=%d, got %v (found=%v)", key, uint32(i*65537), val, found)
+		}
+	}
+	if val, _ := tree.Search(nil); val.(uint32) != math.MaxUint32 {
+		t.Errorf("Expected max uint32 under the empty key, got %v", val)
+	}
+
+	// Overwrites swap the bits in place and keep the old value in history
+	key := []byte("c7")
+	tree.Insert(key, 1<<31)
+	if val, _ := tree.Search(key); val.(uint32) != 1<<31 {
+		t.Errorf("Expected overwritten value %d, got %v", 1<<31, val)
+	}
+	if prev, _ := tree.SearchVersion(key, 1); prev != 7*65537 {
+		t.Errorf("Expected previous value %d, got %d", 7*65537, prev)
+	}
+	l, _, _ := tree.search(key, 0, nil, 0)
+	if _, ok := l.raw().(compactValue); !ok {
+		t.Errorf("Expected the overwritten leaf to stay compact, got %T", l.raw())
+	}
+
+	sum := uint64(0)
+	tree.ForEach(func(_ []byte, val uint32) bool {
+		sum += uint64(val)
+		return true
+	})
+	if sum == 0 {
+		t.Error("Expected ForEach to see compact values")
+	}
+}
+
+func TestCompactIntsMixedValues(t *testing.T) {
+	type count int
+	tree := NewART[any](WithCompactInts())
+	values := map[string]any{
+		"int":     -1,
+		"int8":    int8(-8),
+		"int16":   int16(-16),
+		"int32":   int32(math.MinInt32),
+		"int64":   int64(math.MinInt64),
+		"uint":    uint(math.MaxUint),
+		"uint8":   uint8(200),
+		"uint16":  uint16(65535),
+		"uint64":  uint64(math.MaxUint64),
+		"named":   count(5),
+		"string":  "boxed",
+		"pointer": &struct{}{},
+	}
+	for key, val := range values {
+		tree.Insert([]byte(key), val)
+	}
+	for key, want := range values {
+		got, found := tree.Search([]byte(key))
+		if !found || got != want {
+			t.Errorf("Expected %s=%v (%T), got %v (%T)", key, want, want, got, got)
+		}
+	}
+	for key, compacted := range map[string]bool{"uint64": true, "named": false, "string": false} {
+		l, _, _ := tree.search([]byte(key), 0, nil, 0)
+		if _, ok := l.raw().(compactValue); ok != compacted {
+			t.Errorf("Key %s: expected compact=%v", key, compacted)
+		}
+	}
+
+	// A general value replacing a compact one is stored as usual
+	tree.Insert([]byte("int"), "now a string")
+	if got, _ := tree.Search([]byte("int")); got != "now a string" {
+		t.Errorf("Expected general overwrite, got %v", got)
+	}
+
+	// An integer of another kind replacing a compact one keeps its kind
+	tree.Insert([]byte("mixed"), 1)
+	if !tree.ReplaceIf([]byte("mixed"), int8(5), func(any) bool { return true }) {
+		t.Fatal("Expected ReplaceIf to replace the compact value")
+	}
+	if got, _ := tree.Search([]byte("mixed")); got != int8(5) {
+		t.Errorf("Expected int8(5) after ReplaceIf, got %v (%T)", got, got)
+	}
+	tree.Insert([]byte("mixed"), uint16(7))
+	if got, _ := tree.Search([]byte("mixed")); got != uint16(7) {
+		t.Errorf("Expected uint16(7) after overwrite, got %v (%T)", got, got)
+	}
+}
+
+func TestCompactIntsRCU(t *testing.T) {
+	tree := NewART[int](WithCompactInts(), WithRCUReads())
+	for i := 0; i < 1000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("k%d", i)), i<<20)
+	}
+	tree.Insert([]byte("k1"), -5)
+	if val, _ := tree.Search([]byte("k1")); val.(int) != -5 {
+		t.Errorf("Expected -5 after an RCU overwrite, got %v", val)
+	}
+	if val, _ := tree.Search([]byte("k999")); val.(int) != 999<<20 {
+		t.Errorf("Expected %d, got %v", 999<<20, val)
+	}
+}
+
+// BenchmarkCompactIntsMemory reports the live heap and allocations per key
+// for a tree of uint32 counters with and without WithCompactInts.
+func BenchmarkCompactIntsMemory(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"boxed", nil},
+		{"compact", []Option{WithCompactInts()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			const n = 100000
+			keys := make([][]byte, n)
+			for i := range keys {
+				keys[i] = []byte(fmt.Sprintf("counter:%08x", i*2654435761))
+			}
+			var perKey, allocsPerKey float64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				tree := NewART[uint32](mode.opts...)
+				for j, key := range keys {
+					tree.Insert(key, uint32(j)+1000)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				perKey = float64(after.HeapAlloc-before.HeapAlloc) / n
+				allocsPerKey = float64(after.Mallocs-before.Mallocs) / n
+				runtime.KeepAlive(tree)
+			}
+			b.ReportMetric(perKey, "heap-B/key")
+			b.ReportMetric(allocsPerKey, "allocs/key")
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package art
+
+import "runtime"
+
+// Compact quiesces writers and collapses every inner node below the root
+// left with a single child into that child, which absorbs its prefix, so
+// MaxChainLength drops back to 0. Delete already collapses the nodes it
+// empties; Compact repairs trees whose chains were built some other way. It
+// returns the number of nodes removed and does nothing for trees created
+// with WithRCUReads. Trees created with WithWideStride also have their dense
+// node256s widened.
+func (t *Tree[T]) Compact() int {
+	if t.rcu != nil {
+		return 0
+	}
+	resume := t.Quiesce()
+	defer resume()
+	removed := t.compactBelow(t.node.load())
+	if t.wideStride {
+		removed += t.widenBelow(nil, &t.node, 0)
+	}
+	return removed
+}
+
+// compactBelow collapses the single-child chains below n.
+func (t *Tree[T]) compactBelow(n node) int {
+	collapsed := 0
+	for _, slot := range slotsOf(n) {
+		for {
+			child := slot.load()
+			if child.getType() == nodeTypeLeaf || child.childCount() != 1 {
+				break
+			}
+			if !t.collapseInto(n, slot, child) {
+				break
+			}
+			collapsed++
+		}
+		collapsed += t.compactBelow(slot.load())
+	}
+	return collapsed
+}
+
+// collapseInto replaces child, stored at slot in parent, with its only
+// child. Writers are quiesced, so only readers race with it, and they
+// validate against the locks taken here. It reports false, having changed
+// nothing, if one of the locks cannot be taken.
+func (t *Tree[T]) collapseInto(parent node, slot *slot, child node) bool {
+	only := readChildren(child)[0]
+	if writeLockOrRestart(parent) {
+		return false
+	}
+	if writeLockOrRestart(child) {
+		writeUnlock(parent)
+		return false
+	}
+	if only.getType() != nodeTypeLeaf {
+		if writeLockOrRestart(only) {
+			writeUnlock(child)
+			writeUnlock(parent)
+			return false
+		}
+		merged := append(append([]byte(nil), child.getPrefix()...), only.getPrefix()...)
+		only.setPrefix(merged)
+		writeUnlock(only)
+	}
+	slot.store(only)
+	t.trace.printf("collapse node=%p type=%s into=%p", child, child.getType(), only)
+	writeUnlockObsolete(child)
+	writeUnlock(parent)
+	t.retirer.retire(child)
+	t.free(child)
+	return true
+}
+
+// CompactLive is Compact for a serving tree. Instead of quiescing writers
+// it works through the root's subtrees one at a time, yielding between
+// them, and collapses each chain under the write locks of the chain's
+// parent, the collapsed node and its child: the locks Delete takes for its
+// own collapses, held as briefly. Writers and readers elsewhere proceed
+// unblocked, and one reaching a node being collapsed restarts as it would
+// racing a Delete. After each subtree progress, if not nil, receives the
+// number of subtrees done and the number the root had when CompactLive
+// started. It returns the number of nodes removed. Chains built in a
+// subtree already done are left for the next run, and nodes replaced by a
+// concurrent write are skipped. Like Compact, it does nothing for trees
+// created with WithRCUReads; it does not widen WithWideStride nodes.
+func (t *Tree[T]) CompactLive(progress func(done, total int)) int {
+	if t.rcu != nil {
+		return 0
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	root := t.root()
+	subtrees := readChildren(root)
+	removed := 0
+	for i, sub := range subtrees {
+		removed += t.compactLive(root, sub)
+		if progress != nil {
+			progress(i+1, len(subtrees))
+		}
+		runtime.Gosched()
+	}
+	return removed
+}
+
+// compactLive collapses the chains in the subtree at n, a child of parent,
+// while writers run.
+func (t *Tree[T]) compactLive(parent, n node) int {
+	removed := 0
+	for n.getType() != nodeTypeLeaf {
+		only, ok := t.collapseLive(parent, n)
+		if !ok {
+			break
+		}
+		removed++
+		n = only
+	}
+	if n.getType() == nodeTypeLeaf {
+		return removed
+	}
+	for _, child := range readChildren(n) {
+		removed += t.compactLive(n, child)
+	}
+	return removed
+}
+
+// collapseLive is collapseInto for a tree whose writers are running. Like
+// removeLeaf it reads parent, child and child's only child optimistically
+// and upgrades the versions it read, restarting if any changed, so it
+// collapses only what the traversal saw. It reports false, having changed
+// nothing, if child no longer hangs from parent or has other than one
+// child. The collapsed node is left to the retirer: readers may still be
+// on it, so it is not handed back to the allocator.
+func (t *Tree[T]) collapseLive(parent, child node) (node, bool) {
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+restart:
+	parentVersion, obsolete := readLockOrRestart(parent)
+	if obsolete {
+		return nil, false
+	}
+	var slot *slot
+	for _, s := range slotsOf(parent) {
+		if s.load() == child {
+			slot = s
+			break
+		}
+	}
+	if !validate(parent, parentVersion) {
+		goto restart
+	}
+	if slot == nil {
+		return nil, false
+	}
+	version, obsolete := readLockOrRestart(child)
+	if obsolete {
+		return nil, false
+	}
+	if !validate(parent, parentVersion) {
+		goto restart
+	}
+	children := sortedChildren(child)
+	if !validate(child, version) {
+		goto restart
+	}
+	if len(children) != 1 {
+		return nil, false
+	}
+	only := children[0]
+	var onlyVersion uint64
+	if only.getType() != nodeTypeLeaf {
+		if onlyVersion, obsolete = readLockOrRestart(only); obsolete || !validate(child, version) {
+			goto restart
+		}
+	}
+
+	if upgradeToWriteLockOrRestart(parent, parentVersion) {
+		goto restart
+	}
+	if upgradeToWriteLockOrRestart(child, version) {
+		writeUnlock(parent)
+		goto restart
+	}
+	if only.getType() != nodeTypeLeaf {
+		if upgradeToWriteLockOrRestart(only, onlyVersion) {
+			writeUnlock(child)
+			writeUnlock(parent)
+			goto restart
+		}
+		// only now hangs where child did, so it absorbs child's prefix. Its
+		// full path, summary and overflow chain describe the keys below it,
+		// which the collapse leaves as they were.
+		merged := append(append([]byte(nil), child.getPrefix()...), only.getPrefix()...)
+		only.setPrefix(merged)
+		writeUnlock(only)
+	}
+	slot.store(only)
+	t.trace.printf("collapse node=%p type=%s version=%d into=%p", child, child.getType(), version, only)
+	writeUnlockObsolete(child)
+	writeUnlock(parent)
+	t.retirer.retire(child)
+	return only, true
+}
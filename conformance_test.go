@@ -0,0 +1,166 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// conformanceStable is the number of keys no operation removes; every
+// reader below checks it still sees all of them.
+const conformanceStable = 1000
+
+func conformanceKey(i int) []byte {
+	return []byte(fmt.Sprintf("stable/%03d", i))
+}
+
+// conformanceOps exercises every public entry point. Writers only touch
+// "churn/" keys, so readers can assert exact results for "stable/" ones.
+var conformanceOps = []struct {
+	name string
+	run  func(t *testing.T, tree *Tree[int], rng *rand.Rand)
+}{
+	{"Insert", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		tree.Insert([]byte(fmt.Sprintf("churn/%d", rng.Intn(500))), rng.Int())
+	}},
+	{"Delete", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		tree.Delete([]byte(fmt.Sprintf("churn/%d", rng.Intn(500))))
+	}},
+	{"Apply", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		var b Batch[int]
+		b.Insert([]byte(fmt.Sprintf("churn/%d", rng.Intn(500))), 1)
+		b.Delete([]byte(fmt.Sprintf("churn/%d", rng.Intn(500))))
+		if err := tree.Apply(&b); err != nil {
+			t.Errorf("Apply: %v", err)
+		}
+	}},
+	{"GetOrCompute", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		tree.GetOrCompute([]byte(fmt.Sprintf("churn/%d", rng.Intn(500))), func() (int, error) {
+			return 1, nil
+		})
+	}},
+	{"Search", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		i := rng.Intn(conformanceStable)
+		if val, found := tree.Search(conformanceKey(i)); !found || val.(int) != i {
+			t.Errorf("Search: expected stable/%03d=%d, got %v (found=%v)", i, i, val, found)
+		}
+	}},
+	{"All", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		var prev []byte
+		stable := 0
+		for key := range tree.All() {
+			if prev != nil && bytes.Compare(prev, key) >= 0 {
+				t.Errorf("All: '%s' after '%s'", key, prev)
+			}
+			if bytes.HasPrefix(key, []byte("stable/")) {
+				stable++
+			}
+			prev = key
+		}
+		if stable != conformanceStable {
+			t.Errorf("All: expected %d stable keys, got %d", conformanceStable, stable)
+		}
+	}},
+	{"Range", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		n := 0
+		for range tree.Range(conformanceKey(100), conformanceKey(200)) {
+			n++
+		}
+		if n != 100 {
+			t.Errorf("Range: expected 100 keys, got %d", n)
+		}
+	}},
+	{"ScanPrefix", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		n := 0
+		tree.ScanPrefix([]byte("stable/1"), func([]byte, int) bool {
+			n++
+			return true
+		})
+		if n != 100 {
+			t.Errorf("ScanPrefix: expected 100 keys, got %d", n)
+		}
+	}},
+	{"Glob", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		n := 0
+		tree.Glob([]byte("stable/?5*"), func([]byte, int) bool {
+			n++
+			return true
+		})
+		if n != 100 {
+			t.Errorf("Glob: expected 100 keys, got %d", n)
+		}
+	}},
+	{"Len", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		if n := tree.Len(); n < conformanceStable || n > conformanceStable+500 {
+			t.Errorf("Len: %d outside [%d, %d]", n, conformanceStable, conformanceStable+500)
+		}
+	}},
+	{"Stats", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		if n := tree.CountLeaves(); n < conformanceStable {
+			t.Errorf("CountLeaves: %d below %d", n, conformanceStable)
+		}
+		if tree.MemoryUsage() <= 0 {
+			t.Error("MemoryUsage: expected a positive size")
+		}
+	}},
+	{"Freeze", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		frozen := tree.Freeze()
+		i := rng.Intn(conformanceStable)
+		if val, found := frozen.Search(conformanceKey(i)); !found || val != i {
+			t.Errorf("Freeze: expected stable/%03d=%d, got %d (found=%v)", i, i, val, found)
+		}
+	}},
+	{"CheckInvariants", func(t *testing.T, tree *Tree[int], rng *rand.Rand) {
+		resume := tree.Quiesce()
+		defer resume()
+		if err := tree.CheckInvariants(); err != nil {
+			t.Errorf("CheckInvariants: %v", err)
+		}
+	}},
+}
+
+// TestConcurrentConformance runs every pair of public operations against
+// each other on a shared tree, and fails on any lost key, misordered or
+// incomplete traversal, structural violation or panic. Optimistic readers
+// load the node fields writers change atomically, so under -race it also
+// fails if any combination is unsafe.
+func TestConcurrentConformance(t *testing.T) {
+	tree := NewART[int]()
+	for i := 0; i < conformanceStable; i++ {
+		tree.Insert(conformanceKey(i), i)
+	}
+
+	for i, a := range conformanceOps {
+		for _, b := range conformanceOps[i:] {
+			var stop atomic.Bool
+			var wg sync.WaitGroup
+			for g, op := range []func(*testing.T, *Tree[int], *rand.Rand){a.run, b.run, a.run, b.run} {
+				wg.Add(1)
+				go func(seed int64, op func(*testing.T, *Tree[int], *rand.Rand)) {
+					defer wg.Done()
+					rng := rand.New(rand.NewSource(seed))
+					for !stop.Load() {
+						op(t, tree, rng)
+					}
+				}(int64(g), op)
+			}
+			time.Sleep(5 * time.Millisecond)
+			stop.Store(true)
+			wg.Wait()
+			if t.Failed() {
+				t.Fatalf("Failed running %s against %s", a.name, b.name)
+			}
+		}
+	}
+
+	if err := tree.CheckInvariants(); err != nil {
+		t.Errorf("Unexpected violation: %v", err)
+	}
+	if n := tree.CountLeaves(); n != tree.Len() {
+		t.Errorf("Expected Len %d to match the leaf count %d", tree.Len(), n)
+	}
+}
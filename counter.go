@@ -0,0 +1,40 @@
+package art
+
+// CounterTree is a tree of int64 counters whose increments are applied
+// atomically under the counter's leaf lock.
+type CounterTree struct {
+	tree *Tree[int64]
+}
+
+func NewCounterTree() *CounterTree {
+	return &CounterTree{
+		tree: NewART[int64](WithCompactInts()),
+	}
+}
+
+// Incr adds delta to the counter at key, creating it at delta if absent, and
+// returns the new value.
+func (c *CounterTree) Incr(key []byte, delta int64) int64 {
+	result := delta
+	c.tree.upsert(key, delta, func(old interface{}) interface{} {
+		result = old.(int64) + delta
+		return result
+	})
+	return result
+}
+
+// Get returns the current value of the counter at key.
+func (c *CounterTree) Get(key []byte) (int64, bool) {
+	val, found := c.tree.Search(key)
+	return valueAs[int64](val), found
+}
+
+// SumPrefix returns the sum of all counters whose key starts with prefix.
+func (c *CounterTree) SumPrefix(prefix []byte) int64 {
+	var sum int64
+	c.tree.ScanPrefix(prefix, func(_ []byte, val int64) bool {
+		sum += val
+		return true
+	})
+	return sum
+}
@@ -0,0 +1,60 @@
+package art
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounterTreeConcurrentIncr(t *testing.T) {
+	counters := NewCounterTree()
+
+	const goroutines = 100
+	const increments = 200
+	keys := []string{"req:api:get", "req:api:put", "req:web:get", "err:api"}
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				for j, key := range keys {
+					counters.Incr([]byte(key), int64(j+1))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var total int64
+	for j, key := range keys {
+		expected := int64(goroutines * increments * (j + 1))
+		val, found := counters.Get([]byte(key))
+		if !found || val != expected {
+			t.Errorf("For key '%s', expected %d, got %d (found=%v)", key, expected, val, found)
+		}
+		total += expected
+	}
+
+	if sum := counters.SumPrefix([]byte("req:api:")); sum != int64(goroutines*increments*3) {
+		t.Errorf("Expected req:api: sum %d, got %d", goroutines*increments*3, sum)
+	}
+	if sum := counters.SumPrefix(nil); sum != total {
+		t.Errorf("Expected total sum %d, got %d", total, sum)
+	}
+	if sum := counters.SumPrefix([]byte("missing")); sum != 0 {
+		t.Errorf("Expected 0 for missing prefix, got %d", sum)
+	}
+}
+
+func TestCounterTreeIncrReturnsNewValue(t *testing.T) {
+	counters := NewCounterTree()
+	for i := 1; i <= 10; i++ {
+		if got := counters.Incr([]byte("k"), 5); got != int64(5*i) {
+			t.Errorf("Incr #%d returned %d, expected %d", i, got, 5*i)
+		}
+	}
+	if got := counters.Incr([]byte("k"), -50); got != 0 {
+		t.Errorf("Expected 0 after negative delta, got %d", got)
+	}
+}
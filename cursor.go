@@ -0,0 +1,126 @@
+package art
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+)
+
+// cursorFormat is the first byte of a marshalled Cursor that has a position
+const cursorFormat = 1
+
+// Cursor marks how far a ScanCursor scan has got. It records the last key
+// returned rather than a position in the tree, so it stays meaningful across
+// writes, across tree instances and, once marshalled, across processes. The
+// zero Cursor starts a scan from the beginning.
+type Cursor struct {
+	last    []byte
+	started bool
+}
+
+// MarshalBinary encodes c for storage.
+func (c Cursor) MarshalBinary() ([]byte, error) {
+	if !c.started {
+		return []byte{}, nil
+	}
+	return append([]byte{cursorFormat}, c.last...), nil
+}
+
+// UnmarshalBinary decodes a cursor encoded by MarshalBinary.
+func (c *Cursor) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		*c = Cursor{}
+		return nil
+	}
+	if data[0] != cursorFormat {
+		return errors.New("art: unknown cursor format")
+	}
+	*c = Cursor{last: append([]byte(nil), data[1:]...), started: true}
+	return nil
+}
+
+// MarshalText encodes c as URL-safe base64, for use as a page token.
+func (c Cursor) MarshalText() ([]byte, error) {
+	data, _ := c.MarshalBinary()
+	return base64.RawURLEncoding.AppendEncode(nil, data), nil
+}
+
+// UnmarshalText decodes a cursor encoded by MarshalText.
+func (c *Cursor) UnmarshalText(text []byte) error {
+	data, err := base64.RawURLEncoding.AppendDecode(nil, text)
+	if err != nil {
+		return err
+	}
+	return c.UnmarshalBinary(data)
+}
+
+// ScanCursor returns up to limit entries under prefix in ascending key
+// order, starting after the position cursor marks, along with the cursor to
+// resume from and whether more entries may follow. Pass the zero Cursor to
+// start, and the returned one to continue, possibly in another process.
+//
+// Writes between calls are handled by key order: a key present for the
+// whole scan is returned exactly once, a key inserted or deleted during the
+// scan at most once, depending on whether it sits ahead of the cursor
+// when its page is read. A key overwritten after its page was returned is
+// not returned again. Aliases are not followed.
+func (t *Tree[T]) ScanCursor(prefix []byte, cursor Cursor, limit int) ([]KV[T], Cursor, bool) {
+	if limit <= 0 {
+		return nil, cursor, true
+	}
+	from := prefix
+	if cursor.started && bytes.Compare(cursor.last, prefix) >= 0 {
+		// the smallest key after the last one returned
+		from = append(append([]byte(nil), cursor.last...), 0)
+	}
+	var entries []KV[T]
+	more := false
+	defer t.epochs.unpin(t.epochs.pin())
+	walkFrom(t.root(), nil, from, func(l *leaf) bool {
+		if !bytes.HasPrefix(l.key, prefix) {
+			// keys past the prefix's range sort after all keys in it
+			return false
+		}
+		if len(entries) == limit {
+			more = true
+			return false
+		}
+		entries = append(entries, KV[T]{Key: l.key, Value: valueAs[T](readLeaf(l))})
+		return true
+	})
+	if len(entries) > 0 {
+		cursor = Cursor{last: entries[len(entries)-1].Key, started: true}
+	}
+	return entries, cursor, more
+}
+
+// walkFrom is walk visiting only the leaves whose keys are at least from.
+// path is the full path of n's parent. Subtrees whose path orders them
+// entirely before from are skipped without being read.
+func walkFrom(n node, path, from []byte, fn func(l *leaf) bool) bool {
+	if n == nil {
+		return true
+	}
+	if l, ok := n.(*leaf); ok {
+		if bytes.Compare(l.key, from) < 0 || isTombstone(l) {
+			return true
+		}
+		return fn(l)
+	}
+	prefix, children := readNode(n)
+	path = append(path[:len(path):len(path)], prefix...)
+	if !bytes.HasPrefix(from, path) {
+		// Every key below starts with path, so they all fall on the same
+		// side of from
+		if bytes.Compare(path, from) < 0 {
+			return true
+		}
+		return walk(n, fn)
+	}
+	for _, child := range children {
+		if !walkFrom(child, path, from, fn) {
+			return false
+		}
+	}
+	return true
+}
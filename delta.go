@@ -0,0 +1,135 @@
+package art
+
+import (
+	"bytes"
+	"encoding/gob"
+	"hash/fnv"
+	"io"
+	"iter"
+	"reflect"
+)
+
+// deltaHeader starts a delta stream. Base is the Fingerprint of the tree the
+// delta was computed against.
+type deltaHeader struct {
+	Base uint64
+}
+
+// deltaRecord is one change in a delta stream. The stream ends with a record
+// whose End field is set.
+type deltaRecord[T any] struct {
+	Key    []byte
+	Value  T
+	Delete bool
+	End    bool
+}
+
+// WriteDelta writes to w the inserts and deletes that turn base into target,
+// for shipping to replicas of base with ApplyPatch. Keys whose values are
+// deeply equal in both trees are omitted. Values are encoded with
+// encoding/gob, so T must be gob-encodable. Both trees should be quiescent
+// for the delta to be exact.
+func WriteDelta[T any](w io.Writer, base, target *Tree[T]) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(deltaHeader{Base: base.Fingerprint()}); err != nil {
+		return err
+	}
+	nextBase, stopBase := iter.Pull2(base.All())
+	defer stopBase()
+	nextTarget, stopTarget := iter.Pull2(target.All())
+	defer stopTarget()
+
+	baseKey, baseVal, baseOk := nextBase()
+	targetKey, targetVal, targetOk := nextTarget()
+	for baseOk || targetOk {
+		c := 0
+		switch {
+		case !baseOk:
+			c = 1
+		case !targetOk:
+			c = -1
+		default:
+			c = bytes.Compare(baseKey, targetKey)
+		}
+		var err error
+		switch {
+		case c < 0:
+			err = enc.Encode(deltaRecord[T]{Key: baseKey, Delete: true})
+			baseKey, baseVal, baseOk = nextBase()
+		case c > 0:
+			err = enc.Encode(deltaRecord[T]{Key: targetKey, Value: targetVal})
+			targetKey, targetVal, targetOk = nextTarget()
+		default:
+			if !reflect.DeepEqual(baseVal, targetVal) {
+				err = enc.Encode(deltaRecord[T]{Key: targetKey, Value: targetVal})
+			}
+			baseKey, baseVal, baseOk = nextBase()
+			targetKey, targetVal, targetOk = nextTarget()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return enc.Encode(deltaRecord[T]{End: true})
+}
+
+// ApplyPatch applies a delta written by WriteDelta to base. It returns
+// ErrPatchBase, leaving base unchanged, if base does not hold the contents
+// the delta was computed against. A patch that fails to decode part way
+// leaves the records before the failure applied.
+func ApplyPatch[T any](base *Tree[T], patch io.Reader) error {
+	dec := gob.NewDecoder(patch)
+	var header deltaHeader
+	if err := dec.Decode(&header); err != nil {
+		return err
+	}
+	if header.Base != base.Fingerprint() {
+		return ErrPatchBase
+	}
+	for {
+		var record deltaRecord[T]
+		if err := dec.Decode(&record); err != nil {
+			return err
+		}
+		switch {
+		case record.End:
+			return nil
+		// The records hold keys as the trees store them
+		case record.Delete:
+			base.removeStored(record.Key)
+		default:
+			if err := base.upsertStored(record.Key, record.Value); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Fingerprint returns a hash of the tree's keys and gob-encoded values.
+// Trees with equal contents have equal fingerprints, except that values
+// holding maps may encode in different orders.
+func (t *Tree[T]) Fingerprint() uint64 {
+	h := fnv.New64a()
+	enc := gob.NewEncoder(h)
+	for key, val := range t.All() {
+		enc.Encode(key)
+		// Unencodable values only contribute their key
+		enc.Encode(&val)
+	}
+	return h.Sum64()
+}
+
+// Equal reports whether both trees hold the same keys with deeply equal
+// values.
+func (t *Tree[T]) Equal(other *Tree[T]) bool {
+	next, stop := iter.Pull2(other.All())
+	defer stop()
+	for key, val := range t.All() {
+		otherKey, otherVal, ok := next()
+		if !ok || !bytes.Equal(key, otherKey) || !reflect.DeepEqual(val, otherVal) {
+			return false
+		}
+	}
+	_, _, ok := next()
+	return !ok
+}
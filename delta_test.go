@@ -0,0 +1,77 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	fill := func() *Tree[string] {
+		tree := NewART[string]()
+		for i := 0; i < 200; i++ {
+			tree.Insert([]byte(fmt.Sprintf("key%03d", i)), fmt.Sprintf("v%d", i))
+		}
+		return tree
+	}
+	base := fill()
+	target := fill()
+	for i := 0; i < 200; i += 7 {
+		target.Delete([]byte(fmt.Sprintf("key%03d", i)))
+	}
+	for i := 1; i < 200; i += 5 {
+		target.Insert([]byte(fmt.Sprintf("key%03d", i)), "changed")
+	}
+	for i := 0; i < 30; i++ {
+		target.Insert([]byte(fmt.Sprintf("new%03d", i)), "added")
+	}
+
+	var delta bytes.Buffer
+	if err := WriteDelta(&delta, base, target); err != nil {
+		t.Fatalf("WriteDelta failed: %v", err)
+	}
+	patch := delta.Bytes()
+
+	replica := fill()
+	if !replica.Equal(base) || replica.Equal(target) {
+		t.Fatal("Expected the replica to start equal to base")
+	}
+	if err := ApplyPatch(replica, bytes.NewReader(patch)); err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+	if !replica.Equal(target) {
+		t.Error("Expected the patched replica to equal target")
+	}
+
+	// The replica has moved past the base, so the same patch no longer applies
+	if err := ApplyPatch(replica, bytes.NewReader(patch)); err != ErrPatchBase {
+		t.Errorf("Expected ErrPatchBase on a mismatched base, got %v", err)
+	}
+	if !replica.Equal(target) {
+		t.Error("Expected a rejected patch to leave the tree unchanged")
+	}
+}
+
+func TestApplyPatchKeyTransform(t *testing.T) {
+	prefixed := WithKeyTransform(func(key []byte) []byte { return append([]byte("p/"), key...) })
+	base := NewART[int](prefixed)
+	base.Insert([]byte("a"), 1)
+	base.Insert([]byte("b"), 2)
+	target := NewART[int](prefixed)
+	target.Insert([]byte("a"), 10)
+	target.Insert([]byte("c"), 3)
+
+	var delta bytes.Buffer
+	if err := WriteDelta(&delta, base, target); err != nil {
+		t.Fatalf("WriteDelta failed: %v", err)
+	}
+	if err := ApplyPatch(base, &delta); err != nil {
+		t.Fatalf("ApplyPatch failed: %v", err)
+	}
+	if !base.Equal(target) {
+		t.Errorf("Expected the patched base to equal target, got keys %q", base.Keys())
+	}
+	if val, found := base.Search([]byte("c")); !found || val != 3 {
+		t.Errorf("Expected c=3 after the patch, got %v (found=%v)", val, found)
+	}
+}
@@ -0,0 +1,30 @@
+package art
+
+import (
+	"bytes"
+	"iter"
+)
+
+// Difference returns a new tree with t's options holding the keys of t that
+// are absent from other, with t's values. Both trees are walked once in key
+// order side by side, so it runs in O(len(t)+len(other)) whatever their
+// overlap. Keys are copied as stored, so a WithKeyTransform is not applied
+// to them a second time. Like ForEach, it is weakly consistent with
+// concurrent writers.
+func (t *Tree[T]) Difference(other *Tree[T]) *Tree[T] {
+	result := t.emptyLike()
+	next, stop := iter.Pull2(other.All())
+	defer stop()
+	otherKey, _, otherOk := next()
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		for otherOk && bytes.Compare(otherKey, l.key) < 0 {
+			otherKey, _, otherOk = next()
+		}
+		if !otherOk || !bytes.Equal(otherKey, l.key) {
+			result.upsertStored(l.key, readLeaf(l))
+		}
+		return true
+	})
+	return result
+}
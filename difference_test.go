@@ -0,0 +1,64 @@
+package art
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDifference(t *testing.T) {
+	a, b := NewART[int](), NewART[int]()
+	// a holds keys 0..999, b holds 500..1499; the even keys of a's lower
+	// half are also in b so the overlap is not a single run
+	for i := 0; i < 1000; i++ {
+		a.Insert([]byte(fmt.Sprintf("key%04d", i)), i)
+	}
+	for i := 500; i < 1500; i++ {
+		b.Insert([]byte(fmt.Sprintf("key%04d", i)), -i)
+	}
+	for i := 0; i < 500; i += 2 {
+		b.Insert([]byte(fmt.Sprintf("key%04d", i)), -i)
+	}
+
+	diff := a.Difference(b)
+	if diff.Len() != 250 {
+		t.Errorf("Expected 250 keys, got %d", diff.Len())
+	}
+	for i := 0; i < 1500; i++ {
+		key := []byte(fmt.Sprintf("key%04d", i))
+		val, found := diff.Search(key)
+		if want := i < 500 && i%2 == 1; found != want {
+			t.Errorf("Key '%s': found=%v, expected %v", key, found, want)
+		} else if found && val.(int) != i {
+			t.Errorf("Expected a's value %d for '%s', got %v", i, key, val)
+		}
+	}
+
+	if n := b.Difference(a).Len(); n != 500 {
+		t.Errorf("Expected 500 keys in b - a, got %d", n)
+	}
+	if n := a.Difference(a).Len(); n != 0 {
+		t.Errorf("Expected a - a to be empty, got %d keys", n)
+	}
+	if n := a.Difference(NewART[int]()).Len(); n != 1000 {
+		t.Errorf("Expected a - empty to hold 1000 keys, got %d", n)
+	}
+}
+
+func TestDifferenceKeyTransform(t *testing.T) {
+	a, b := NewART[int](WithKeyTransform(tagKey)), NewART[int](WithKeyTransform(tagKey))
+	for i := 0; i < 10; i++ {
+		a.Insert([]byte(fmt.Sprint(i)), i)
+		if i%2 == 0 {
+			b.Insert([]byte(fmt.Sprint(i)), i)
+		}
+	}
+	diff := a.Difference(b)
+	if diff.Len() != 5 {
+		t.Fatalf("Expected 5 keys, got %d", diff.Len())
+	}
+	for i := 1; i < 10; i += 2 {
+		if val, found := diff.Search([]byte(fmt.Sprint(i))); !found || val.(int) != i {
+			t.Errorf("Expected %d=%d in the difference, got %v (found=%v)", i, i, val, found)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package art
+
+import (
+	"errors"
+	"log"
+)
+
+var (
+	// ErrValueTypeMismatch is returned when a value is not assignable to the
+	// type declared with WithValueType.
+	ErrValueTypeMismatch = errors.New("art: value type mismatch")
+
+	// ErrKeyLength is returned when a key does not have the length a
+	// fixed-key tree was created for.
+	ErrKeyLength = errors.New("art: wrong key length")
+
+	// ErrEmptyKey is returned for the zero-length key by trees created with
+	// WithEmptyKeyPolicy(RejectEmptyKey).
+	ErrEmptyKey = errors.New("art: empty key")
+
+	// ErrChecksum is returned by SearchChecked when a leaf no longer
+	// matches the checksum recorded when its value was written.
+	ErrChecksum = errors.New("art: leaf checksum mismatch")
+
+	// ErrReadOnly is returned by writes to a tree made read-only with
+	// SetReadOnly.
+	ErrReadOnly = errors.New("art: tree is read-only")
+
+	// ErrPanic wraps a panic recovered in the middle of a write, after the
+	// locks the write held were released. It signals a bug in the tree or
+	// in a callback run during the write. GetOrCompute also returns it to
+	// the callers that waited for a compute that panicked.
+	ErrPanic = errors.New("art: recovered panic")
+
+	// ErrPatchBase is returned by ApplyPatch when the tree does not hold
+	// the contents the delta was computed against.
+	ErrPatchBase = errors.New("art: patch base mismatch")
+)
+
+// reportError passes err to the tree's error hook, or logs it without one.
+func (t *Tree[T]) reportError(err error) {
+	if t.errorHook != nil {
+		t.errorHook(err)
+		return
+	}
+	log.Printf("%v", err)
+}
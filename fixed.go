@@ -0,0 +1,96 @@
+package art
+
+import "bytes"
+
+// NewFixedKeyART creates a tree whose keys all have exactly keyLen bytes,
+// such as 8-byte integers or 16-byte UUIDs. TryInsert rejects other lengths
+// with ErrKeyLength, and SearchFixed can skip the variable-length handling
+// of Search.
+func NewFixedKeyART[T any](keyLen int, opts ...Option) *Tree[T] {
+	t := NewART[T](opts...)
+	t.keyLen = keyLen
+	return t
+}
+
+// SearchFixed is Search specialised for trees created with NewFixedKeyART.
+// Since no key is a prefix of another, the descent never needs the
+// terminator slot and a leaf only has to be compared byte for byte. Keys of
+// the wrong length are reported as absent. Like Search, it applies the
+// tree's key transform and falls back to an alias covering key.
+func (t *Tree[T]) SearchFixed(key []byte) (T, bool) {
+	if t.keyLen == 0 {
+		val, found := t.Search(key)
+		return valueAs[T](val), found
+	}
+	val, found := t.searchFixed(key)
+	if !found {
+		if a, ok := t.resolveAlias(key); ok {
+			val, found = t.searchFixed(a.rewrite(key))
+		}
+	}
+	val, found = live(val, found)
+	return valueAs[T](val), found
+}
+
+// searchFixed is the descent of SearchFixed, returning the value stored
+// under key as is.
+func (t *Tree[T]) searchFixed(key []byte) (interface{}, bool) {
+	if len(key) != t.keyLen {
+		return nil, false
+	}
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+restart:
+	var parent node
+	var parentVersion uint64
+	depth := 0
+	curNode := t.root()
+	for {
+		// Nodes reached from a validated parent are never nil, so the
+		// reflection guard of readLockOrRestart is only needed when locked
+		version := curNode.version().Load()
+		needToRestart := version&OBSOLETE_BIT != 0
+		if version&LOCK_BIT != 0 {
+			version, needToRestart = readLockOrRestart(curNode)
+		}
+		if needToRestart || !validate(parent, parentVersion) {
+			goto restart
+		}
+		if l, ok := curNode.(*leaf); ok {
+			match := bytes.Equal(l.key, key)
+			val := l.value()
+			if !validate(curNode, version) {
+				goto restart
+			}
+			if !match {
+				return nil, false
+			}
+			return val, true
+		}
+		pre := curNode.getPrefix()
+		end := depth + len(pre)
+		if end >= len(key) || !bytes.Equal(pre, key[depth:end]) {
+			if !validate(curNode, version) {
+				goto restart
+			}
+			return nil, false
+		}
+		depth = end
+		next := findChild(curNode, key, depth)
+		var child node
+		if next != nil {
+			child = next.load()
+		}
+		if !validate(curNode, version) {
+			goto restart
+		}
+		if child == nil {
+			return nil, false
+		}
+		parent = curNode
+		parentVersion = version
+		curNode = child
+	}
+}
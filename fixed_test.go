@@ -0,0 +1,123 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func generateUUIDKeys(n int) [][]byte {
+	r := rand.New(rand.NewSource(1))
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = make([]byte, 16)
+		r.Read(keys[i])
+	}
+	return keys
+}
+
+func TestSearchFixed(t *testing.T) {
+	tree := NewFixedKeyART[int](16)
+	keys := generateUUIDKeys(10000)
+	for i, key := range keys {
+		if err := tree.TryInsert(key, i); err != nil {
+			t.Fatalf("Unexpected insert error: %v", err)
+		}
+	}
+
+	for i, key := range keys {
+		val, found := tree.SearchFixed(key)
+		if !found || val != i {
+			t.Errorf("Expected %d for key %x, got %d (found=%v)", i, key, val, found)
+		}
+	}
+
+	if _, found := tree.SearchFixed(keys[0][:8]); found {
+		t.Error("Expected short key to miss")
+	}
+	if _, found := tree.SearchFixed(append(append([]byte(nil), keys[0]...), 0)); found {
+		t.Error("Expected long key to miss")
+	}
+	if _, found := tree.SearchFixed(make([]byte, 16)); found {
+		t.Error("Expected absent key to miss")
+	}
+	if err := tree.TryInsert([]byte("short"), 1); !errors.Is(err, ErrKeyLength) {
+		t.Errorf("Expected ErrKeyLength, got %v", err)
+	}
+}
+
+func TestSearchFixedTransformAndAlias(t *testing.T) {
+	tree := NewFixedKeyART[int](4, WithKeyTransform(bytes.ToLower))
+	tree.Insert([]byte("ABCD"), 1)
+	tree.Insert([]byte("wxyz"), 2)
+	tree.Alias([]byte("ab"), []byte("wx"))
+
+	for _, key := range []string{"abcd", "ABCD", "WXYZ"} {
+		want, _ := tree.Search([]byte(key))
+		if val, found := tree.SearchFixed([]byte(key)); !found || val != want {
+			t.Errorf("Expected SearchFixed(%q) to agree with Search on %v, got %d (found=%v)", key, want, val, found)
+		}
+	}
+	if val, found := tree.SearchFixed([]byte("abyz")); !found || val != 2 {
+		t.Errorf("Expected abyz to resolve through the alias to 2, got %d (found=%v)", val, found)
+	}
+}
+
+func TestSearchFixedConcurrent(t *testing.T) {
+	tree := NewFixedKeyART[int](16)
+	keys := generateUUIDKeys(20000)
+	for i := 0; i < len(keys)/2; i++ {
+		tree.Insert(keys[i], i)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(id int) {
+			defer wg.Done()
+			for i := len(keys)/2 + id; i < len(keys); i += 4 {
+				tree.Insert(keys[i], i)
+			}
+		}(g)
+		go func(id int) {
+			defer wg.Done()
+			for n := 0; n < 5; n++ {
+				for i := id; i < len(keys)/2; i += 4 {
+					if val, found := tree.SearchFixed(keys[i]); !found || val != i {
+						t.Errorf("Expected %d for pre-populated key, got %d (found=%v)", i, val, found)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkSearchFixedUUID(b *testing.B) {
+	tree := NewFixedKeyART[int](16)
+	keys := generateUUIDKeys(100000)
+	for i, key := range keys {
+		tree.Insert(key, i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.SearchFixed(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkSearchGenericUUID(b *testing.B) {
+	tree := NewFixedKeyART[int](16)
+	keys := generateUUIDKeys(100000)
+	for i, key := range keys {
+		tree.Insert(key, i)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search(keys[i%len(keys)])
+	}
+}
@@ -0,0 +1,139 @@
+package art
+
+// SearchFold looks key up ignoring ASCII case, without requiring keys to
+// have been normalized on insert: at every branch on a letter it descends
+// into both the upper and the lower case child. It returns the first match
+// in key order. The descent can branch at every letter, so it costs up to
+// two subtrees per letter in the worst case rather than a single path.
+// Keys holding a tombstone are skipped, as Search reports them absent.
+// SearchFoldAll also returns the stored keys.
+func (t *Tree[T]) SearchFold(key []byte) (val T, found bool) {
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	searchFold(t.root(), key, 0, func(l *leaf) bool {
+		v, ok := live(readLeaf(l), true)
+		if !ok {
+			return true
+		}
+		val, found = valueAs[T](v), true
+		return false
+	})
+	return val, found
+}
+
+// SearchFoldAll returns in key order every entry whose key equals key
+// ignoring ASCII case.
+func (t *Tree[T]) SearchFoldAll(key []byte) []Entry[T] {
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	var entries []Entry[T]
+	defer t.epochs.unpin(t.epochs.pin())
+	searchFold(t.root(), key, 0, func(l *leaf) bool {
+		if v, ok := live(readLeaf(l), true); ok {
+			entries = append(entries, Entry[T]{Key: l.key, Value: valueAs[T](v)})
+		}
+		return true
+	})
+	return entries
+}
+
+// searchFold visits the leaves below n matching key ignoring ASCII case, in
+// key order, until fn returns false.
+func searchFold(n node, key []byte, depth int, fn func(l *leaf) bool) bool {
+	if n == nil {
+		return true
+	}
+	if l, ok := n.(*leaf); ok {
+		if equalFold(l.key, key) {
+			return fn(l)
+		}
+		return true
+	}
+
+	if n.getType() == nodeTypeWide {
+		// A wide node branches on two bytes; its children's prefixes and
+		// leaves' keys, which include them, do the filtering
+		prefix, children := readNode(n)
+		if depth+len(prefix) > len(key) || !equalFold(prefix, key[depth:depth+len(prefix)]) {
+			return true
+		}
+		for _, child := range children {
+			if !searchFold(child, key, depth+len(prefix), fn) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var prefix []byte
+	var children [2]node
+	for {
+		version, _ := readLockOrRestart(n)
+		prefix = append(prefix[:0], n.getPrefix()...)
+		children = [2]node{}
+		if depth+len(prefix) > len(key) || !equalFold(prefix, key[depth:depth+len(prefix)]) {
+			if validate(n, version) {
+				return true
+			}
+			continue
+		}
+		next := depth + len(prefix)
+		if next >= len(key) {
+			if child := n.findChild(TerminationChar); child != nil {
+				children[0] = child.load()
+			}
+		} else {
+			// Upper case sorts first, keeping matches in key order
+			upper, lower := foldCases(key[next])
+			if child := n.findChild(upper); child != nil {
+				children[0] = child.load()
+			}
+			if lower != upper {
+				if child := n.findChild(lower); child != nil {
+					children[1] = child.load()
+				}
+			}
+		}
+		if validate(n, version) {
+			break
+		}
+	}
+	depth += len(prefix)
+	for _, child := range children {
+		if !searchFold(child, key, depth, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// foldCases returns the upper and lower case forms of an ASCII letter, or b
+// twice for any other byte.
+func foldCases(b byte) (upper, lower byte) {
+	switch {
+	case 'a' <= b && b <= 'z':
+		return b - 'a' + 'A', b
+	case 'A' <= b && b <= 'Z':
+		return b, b - 'A' + 'a'
+	}
+	return b, b
+}
+
+// equalFold reports whether a and b are equal ignoring ASCII case. Unlike
+// bytes.EqualFold it leaves non-ASCII bytes alone, matching the descent.
+func equalFold(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		_, la := foldCases(a[i])
+		_, lb := foldCases(b[i])
+		if la != lb {
+			return false
+		}
+	}
+	return true
+}
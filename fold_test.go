@@ -0,0 +1,70 @@
+package art
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSearchFold(t *testing.T) {
+	tree := NewART[int]()
+	tree.Insert([]byte("Hello"), 1)
+	tree.Insert([]byte("help"), 2)
+	tree.Insert([]byte("HELLO world"), 3)
+	tree.Insert([]byte("hello"), 4)
+	tree.Insert([]byte("héllo"), 5)
+	for i := 0; i < 200; i++ {
+		tree.Insert([]byte(fmt.Sprintf("Key-%03d", i)), 100+i)
+	}
+
+	if _, found := tree.Search([]byte("HeLLo")); found {
+		t.Error("Expected plain Search to be case sensitive")
+	}
+	if val, found := tree.SearchFold([]byte("HeLLo")); !found || val != 1 {
+		t.Errorf("Expected the first match Hello=1, got %d (found=%v)", val, found)
+	}
+	if val, found := tree.SearchFold([]byte("kEY-042")); !found || val != 142 {
+		t.Errorf("Expected Key-042=142, got %d (found=%v)", val, found)
+	}
+	if _, found := tree.SearchFold([]byte("hell")); found {
+		t.Error("Expected a case-insensitive prefix not to match")
+	}
+	if _, found := tree.SearchFold([]byte("HÉLLO")); found {
+		t.Error("Expected non-ASCII letters to be compared exactly")
+	}
+
+	var got []string
+	for _, entry := range tree.SearchFoldAll([]byte("hello")) {
+		got = append(got, fmt.Sprintf("%s=%d", entry.Key, entry.Value))
+	}
+	if fmt.Sprint(got) != "[Hello=1 hello=4]" {
+		t.Errorf("Expected both casings in key order, got %v", got)
+	}
+	if n := len(tree.SearchFoldAll([]byte("hello WORLD"))); n != 1 {
+		t.Errorf("Expected one match for 'hello WORLD', got %d", n)
+	}
+}
+
+func TestSearchFoldTombstone(t *testing.T) {
+	tree := NewART[int]()
+	tree.InsertTombstone([]byte("Hello"))
+	tree.Insert([]byte("hello"), 4)
+
+	if val, found := tree.SearchFold([]byte("HELLO")); !found || val != 4 {
+		t.Errorf("Expected the tombstone skipped for hello=4, got %d (found=%v)", val, found)
+	}
+	var got []string
+	for _, entry := range tree.SearchFoldAll([]byte("HELLO")) {
+		got = append(got, fmt.Sprintf("%s=%d", entry.Key, entry.Value))
+	}
+	if fmt.Sprint(got) != "[hello=4]" {
+		t.Errorf("Expected only the live key, got %v", got)
+	}
+
+	tree.InsertTombstone([]byte("hello"))
+	if _, found := tree.SearchFold([]byte("HELLO")); found {
+		t.Error("Expected no match when every casing holds a tombstone")
+	}
+	if entries := tree.SearchFoldAll([]byte("HELLO")); len(entries) != 0 {
+		t.Errorf("Expected no entries, got %v", entries)
+	}
+}
@@ -0,0 +1,229 @@
+package art
+
+import "bytes"
+
+// FrozenTree is an immutable copy of a Tree. Nothing can modify its nodes,
+// so its reads skip the version checks, validation and restarts of the
+// live tree and follow pointers directly. It is safe for concurrent use.
+type FrozenTree[T any] struct {
+	root      node
+	size      int
+	transform func(key []byte) []byte
+	// cols holds the keys and values for WithColumnarLeaves, or is nil
+	cols *columns[T]
+}
+
+// Freeze returns a FrozenTree holding the tree's current contents. It
+// quiesces writers and copies every node, without version words, so it
+// costs a full traversal and roughly the memory of the tree's nodes; keys
+// and values are shared, unless WithColumnarLeaves copies them into
+// columns. Later writes to t do not affect the result.
+func (t *Tree[T]) Freeze() *FrozenTree[T] {
+	resume := t.Quiesce()
+	defer resume()
+	f := &FrozenTree[T]{
+		root:      freezeNode(t.root(), false),
+		size:      t.Len(),
+		transform: t.transform,
+	}
+	if t.columnarLeaves {
+		f.cols = freezeColumns[T](f.root, f.size)
+	}
+	return f
+}
+
+// freezeNode returns a copy of the subtree at n that shares no mutable
+// state with it and has no version words. The caller quiesces writers,
+// which include the reordering of WithSelfOrganizingNodes, so n does not
+// change while it is copied. With shareLeaves it copies only the inner
+// nodes and keeps the leaves themselves, for readers of nothing but their
+// keys, which never change. Tombstones are left out, so a copied node may
+// hold empty slots.
+func freezeNode(n node, shareLeaves bool) node {
+	switch n := n.(type) {
+	case *leaf:
+		if holdsTombstone(n) {
+			return nil
+		}
+		if shareLeaves {
+			return n
+		}
+		c := &leaf{key: n.key}
+		c.val.Store(n.val.Load())
+		c.bits.Store(n.bits.Load())
+		return c
+	case *node4:
+		c := *n
+		for i := range c.childPtr {
+			if i < int(c.numOfChildren) {
+				c.childPtr[i].store(freezeNode(n.childPtr[i].load(), shareLeaves))
+			} else {
+				c.childPtr[i].store(nil)
+			}
+		}
+		c.versionLockObsolete = nil
+		return &c
+	case *node16:
+		c := *n
+		for i := range c.childPtr {
+			if i < int(c.numOfChildren) {
+				c.childPtr[i].store(freezeNode(n.childPtr[i].load(), shareLeaves))
+			} else {
+				c.childPtr[i].store(nil)
+			}
+		}
+		c.versionLockObsolete = nil
+		return &c
+	case *node48:
+		c := *n
+		for i := range c.childPtr {
+			if child := n.childPtr[i].load(); child != nil {
+				c.childPtr[i].store(freezeNode(child, shareLeaves))
+			}
+		}
+		if next := n.next(); next != nil {
+			c.setNext(freezeNode(next, shareLeaves).(*node48))
+		}
+		c.versionLockObsolete = nil
+		c.summary = nil
+		return &c
+	case *node256:
+		c := *n
+		for i := range c.childPtr {
+			if child := n.childPtr[i].load(); child != nil {
+				c.childPtr[i].store(freezeNode(child, shareLeaves))
+			}
+		}
+		c.versionLockObsolete = nil
+		c.summary = nil
+		return &c
+	case *nodeWide:
+		c := &nodeWide{}
+		n.each(func(hi, lo byte, slot *slot) {
+			if child := freezeNode(slot.load(), shareLeaves); child != nil {
+				c.set(hi, lo, child)
+			}
+		})
+		c.setPrefix(append([]byte(nil), n.getPrefix()...))
+		return c
+	}
+	return nil
+}
+
+// Search returns the value stored under key.
+func (f *FrozenTree[T]) Search(key []byte) (T, bool) {
+	if f.transform != nil {
+		key = f.transform(key)
+	}
+	l := searchUnlocked(f.root, key)
+	if f.cols != nil {
+		return f.cols.search(l)
+	}
+	if l == nil {
+		var zero T
+		return zero, false
+	}
+	val, found := live(l.value(), true)
+	return valueAs[T](val), found
+}
+
+// Len returns the number of keys.
+func (f *FrozenTree[T]) Len() int {
+	return f.size
+}
+
+// ForEach visits every key and value in ascending key order until fn
+// returns false.
+func (f *FrozenTree[T]) ForEach(fn func(key []byte, val T) bool) {
+	if f.cols != nil {
+		f.cols.scan(0, len(f.cols.vals), nil, fn)
+		return
+	}
+	walkUnlocked(f.root, func(l *leaf) bool {
+		val, found := live(l.value(), true)
+		if !found {
+			return true
+		}
+		return fn(l.key, valueAs[T](val))
+	})
+}
+
+// ScanPrefix visits in ascending order every key starting with prefix
+// until fn returns false.
+func (f *FrozenTree[T]) ScanPrefix(prefix []byte, fn func(key []byte, val T) bool) {
+	depth := 0
+	n := f.root
+	for n != nil && n.getType() != nodeTypeLeaf {
+		pre := n.getPrefix()
+		p := checkPrefix(pre, prefix, depth)
+		if depth+p >= len(prefix) || (p == len(pre) && depth+p+stride(n) > len(prefix)) {
+			break
+		}
+		if p != len(pre) {
+			return
+		}
+		depth += len(pre)
+		next := findChild(n, prefix, depth)
+		if next == nil {
+			return
+		}
+		n = next.load()
+	}
+	if f.cols != nil {
+		lo, hi := span(n)
+		f.cols.scan(lo, hi, prefix, fn)
+		return
+	}
+	walkUnlocked(n, func(l *leaf) bool {
+		if !bytes.HasPrefix(l.key, prefix) {
+			return true
+		}
+		val, found := live(l.value(), true)
+		if !found {
+			return true
+		}
+		return fn(l.key, valueAs[T](val))
+	})
+}
+
+// searchUnlocked is search for trees whose nodes are never modified. It
+// takes no locks and never restarts.
+func searchUnlocked(root node, key []byte) *leaf {
+	depth := 0
+	curNode := root
+	for curNode != nil {
+		if l, ok := curNode.(*leaf); ok {
+			if bytes.Equal(l.key, key) {
+				return l
+			}
+			return nil
+		}
+		pre := curNode.getPrefix()
+		if checkPrefix(pre, key, depth) != len(pre) {
+			return nil
+		}
+		depth += len(pre)
+		next := findChild(curNode, key, depth)
+		if next == nil {
+			return nil
+		}
+		curNode = next.load()
+	}
+	return nil
+}
+
+// walkUnlocked is walk for trees whose nodes are never modified.
+func walkUnlocked(n node, fn func(l *leaf) bool) bool {
+	if n == nil {
+		return true
+	}
+	if l, ok := n.(*leaf); ok {
+		return fn(l)
+	}
+	for _, child := range sortedChildren(n) {
+		if !walkUnlocked(child, fn) {
+			return false
+		}
+	}
+	return true
+}
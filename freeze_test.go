@@ -0,0 +1,135 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	tree := NewART[int]()
+	for i := 0; i < 3000; i++ {
+		// Long shared prefixes exercise out-of-line prefix copies
+		tree.Insert([]byte(fmt.Sprintf("tenant/%02d/objects/%06d", i%13, i)), i)
+	}
+	tree.Insert(nil, -1)
+	frozen := tree.Freeze()
+
+	// Writes after the freeze touch every kind of change: overwrites,
+	// deletes, growth and prefix splits
+	for i := 0; i < 3000; i += 3 {
+		tree.Insert([]byte(fmt.Sprintf("tenant/%02d/objects/%06d", i%13, i)), -i)
+	}
+	for i := 1; i < 3000; i += 3 {
+		tree.Delete([]byte(fmt.Sprintf("tenant/%02d/objects/%06d", i%13, i)))
+	}
+	for i := 0; i < 500; i++ {
+		tree.Insert([]byte(fmt.Sprintf("tenant/%02d/obj%d", i%13, i)), i)
+	}
+	tree.Delete(nil)
+
+	if frozen.Len() != 3001 {
+		t.Errorf("Expected frozen Len 3001, got %d", frozen.Len())
+	}
+	for i := 0; i < 3000; i++ {
+		key := []byte(fmt.Sprintf("tenant/%02d/objects/%06d", i%13, i))
+		if val, found := frozen.Search(key); !found || val != i {
+			t.Fatalf("Expected frozen %s=%d, got %d (found=%v)", key, i, val, found)
+		}
+	}
+	if val, found := frozen.Search(nil); !found || val != -1 {
+		t.Errorf("Expected the empty key in the frozen tree, got %d (found=%v)", val, found)
+	}
+	if _, found := frozen.Search([]byte("tenant/00/obj0")); found {
+		t.Error("Expected a key inserted after Freeze to be absent")
+	}
+
+	count := 0
+	frozen.ForEach(func([]byte, int) bool {
+		count++
+		return true
+	})
+	if count != frozen.Len() {
+		t.Errorf("Expected ForEach to visit %d keys, got %d", frozen.Len(), count)
+	}
+
+	var scanned []string
+	frozen.ScanPrefix([]byte("tenant/05/objects/0000"), func(key []byte, _ int) bool {
+		scanned = append(scanned, string(key))
+		return true
+	})
+	expected := []string{"tenant/05/objects/000005", "tenant/05/objects/000018", "tenant/05/objects/000031", "tenant/05/objects/000044", "tenant/05/objects/000057", "tenant/05/objects/000070", "tenant/05/objects/000083", "tenant/05/objects/000096"}
+	if fmt.Sprint(scanned) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, scanned)
+	}
+}
+
+func TestFreezeConcurrentWriters(t *testing.T) {
+	tree := NewART[int](WithCompactInts())
+	for i := 0; i < 1000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("stable%04d", i)), i)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				tree.Insert([]byte(fmt.Sprintf("churn%d/%d", id, i%100)), i)
+			}
+		}(w)
+	}
+	for round := 0; round < 20; round++ {
+		frozen := tree.Freeze()
+		for i := 0; i < 1000; i++ {
+			if val, found := frozen.Search([]byte(fmt.Sprintf("stable%04d", i))); !found || val != i {
+				t.Fatalf("Round %d: expected stable%04d=%d, got %d (found=%v)", round, i, i, val, found)
+			}
+		}
+		count := 0
+		frozen.ForEach(func([]byte, int) bool {
+			count++
+			return true
+		})
+		if count != frozen.Len() {
+			t.Fatalf("Round %d: Len %d but ForEach saw %d", round, frozen.Len(), count)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// BenchmarkFrozenSearch compares Search on a live tree with Search on its
+// frozen copy over 100k keys.
+func BenchmarkFrozenSearch(b *testing.B) {
+	tree := NewART[int]()
+	keys := make([][]byte, 100000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%016x", rand.Uint64()))
+		tree.Insert(keys[i], i)
+	}
+	frozen := tree.Freeze()
+	b.Run("live", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			rng := rand.New(rand.NewSource(rand.Int63()))
+			for pb.Next() {
+				tree.Search(keys[rng.Intn(len(keys))])
+			}
+		})
+	})
+	b.Run("frozen", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			rng := rand.New(rand.NewSource(rand.Int63()))
+			for pb.Next() {
+				frozen.Search(keys[rng.Intn(len(keys))])
+			}
+		})
+	})
+}
@@ -0,0 +1,36 @@
+package art
+
+// WithFullPathNodes records in every inner node its full path, the bytes
+// of all prefixes from the root down to and including its own, instead of
+// leaving traversals to rebuild it by accumulating prefixes. It is meant
+// for debugging and structural analysis: walks over the nodes take the
+// recorded path, so a node whose recorded path disagrees with where it
+// sits shows up in their output. A node's full path is fixed when it is
+// created, since splits, grows, shrinks and collapses keep the path of
+// every node they leave in place, so the cost is one copy of the path per
+// inner node, made when a split creates it.
+func WithFullPathNodes() Option {
+	return func(c *config) {
+		c.fullPaths = true
+	}
+}
+
+// storedPath returns the full path recorded for inner node n, or nil if
+// none was.
+func storedPath(n node) []byte {
+	if w := innerWord(n); w != nil && w.path != nil {
+		return *w.path
+	}
+	return nil
+}
+
+// recordPath records path as the full path of n, an inner node a split has
+// just created or one replacing a node at path, in trees created with
+// WithFullPathNodes.
+func (t *Tree[T]) recordPath(n node, path []byte) {
+	if !t.fullPaths {
+		return
+	}
+	p := append([]byte{}, path...)
+	innerWord(n).path = &p
+}
@@ -0,0 +1,72 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestFullPathNodes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"rcu", []Option{WithRCUReads()}},
+		{"wide", []Option{WithWideStride()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewART[int](append(tc.opts, WithFullPathNodes())...)
+			r := rand.New(rand.NewSource(1))
+			// Inserts and deletes split, grow, shrink and collapse nodes
+			for i := 0; i < 20000; i++ {
+				key := []byte(fmt.Sprintf("%x/%d", r.Intn(300), r.Intn(50)))
+				if r.Intn(3) == 0 {
+					tree.Delete(key)
+				} else {
+					tree.Insert(key, i)
+				}
+			}
+			tree.Compact()
+
+			// Rebuild every node's path from the prefixes, as the default
+			// mode does, and compare it with the recorded one
+			checked := 0
+			var check func(n node, path []byte)
+			check = func(n node, path []byte) {
+				if n.getType() == nodeTypeLeaf {
+					return
+				}
+				prefix, children := readNode(n)
+				path = append(path[:len(path):len(path)], prefix...)
+				recorded := storedPath(n)
+				if n != tree.root() && recorded == nil {
+					t.Fatalf("%s node at %q has no recorded path", n.getType(), path)
+				}
+				if recorded != nil && !bytes.Equal(recorded, path) {
+					t.Fatalf("%s node at %q recorded path %q", n.getType(), path, recorded)
+				}
+				checked++
+				for _, child := range children {
+					check(child, path)
+				}
+			}
+			check(tree.root(), nil)
+			if checked < 100 {
+				t.Errorf("only %d inner nodes checked", checked)
+			}
+
+			// Without the option nothing is recorded
+			plain := NewART[int](tc.opts...)
+			plain.Insert([]byte("ab"), 1)
+			plain.Insert([]byte("ac"), 2)
+			walkNodes(plain.root(), nil, func(n node, _ []byte, _ []node) bool {
+				if storedPath(n) != nil {
+					t.Errorf("a tree without WithFullPathNodes recorded %q", storedPath(n))
+				}
+				return true
+			})
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package art
+
+// globStates is the set of pattern positions a glob match can be at, kept
+// sorted without duplicates. A position equal to the pattern length means
+// the whole pattern has been consumed.
+type globStates []int
+
+// globClose adds the positions reachable from states by letting each '*'
+// match nothing.
+func globClose(pattern []byte, states globStates) globStates {
+	var closed globStates
+	for _, p := range states {
+		for {
+			closed = closed.add(p)
+			if p >= len(pattern) || pattern[p] != '*' {
+				break
+			}
+			p++
+		}
+	}
+	return closed
+}
+
+func (s globStates) add(p int) globStates {
+	i := 0
+	for i < len(s) && s[i] < p {
+		i++
+	}
+	if i < len(s) && s[i] == p {
+		return s
+	}
+	return append(s[:i], append(globStates{p}, s[i:]...)...)
+}
+
+// globStep advances closed states over b.
+func globStep(pattern []byte, states globStates, b byte) globStates {
+	var next globStates
+	for _, p := range states {
+		if p >= len(pattern) {
+			continue
+		}
+		switch pattern[p] {
+		case '*':
+			next = next.add(p)
+		case '?':
+			next = next.add(p + 1)
+		default:
+			if pattern[p] == b {
+				next = next.add(p + 1)
+			}
+		}
+	}
+	return globClose(pattern, next)
+}
+
+// globFeed advances states over every byte of bytes, stopping early once no
+// state is left.
+func globFeed(pattern []byte, states globStates, bytes []byte) globStates {
+	for _, b := range bytes {
+		if len(states) == 0 {
+			break
+		}
+		states = globStep(pattern, states, b)
+	}
+	return states
+}
+
+// globLiteral returns the only byte that can advance states, or false if
+// a wildcard or several different literals are possible.
+func globLiteral(pattern []byte, states globStates) (byte, bool) {
+	var lit byte
+	found := false
+	for _, p := range states {
+		if p >= len(pattern) {
+			continue
+		}
+		c := pattern[p]
+		if c == '*' || c == '?' || (found && c != lit) {
+			return 0, false
+		}
+		lit, found = c, true
+	}
+	return lit, found
+}
+
+// Glob visits in ascending order every key matching pattern until fn
+// returns false. In the pattern '*' matches any run of bytes, including an
+// empty one and including separators, and '?' matches exactly one byte;
+// every other byte matches itself, so there is no escaping. The descent is
+// pruned as soon as no pattern position survives a node's prefix, and
+// follows a single child where the pattern demands a literal byte.
+func (t *Tree[T]) Glob(pattern []byte, fn func(key []byte, val T) bool) {
+	defer t.epochs.unpin(t.epochs.pin())
+	globWalk(t.root(), pattern, globClose(pattern, globStates{0}), 0, func(l *leaf) bool {
+		return fn(l.key, valueAs[T](readLeaf(l)))
+	})
+}
+
+func globWalk(n node, pattern []byte, states globStates, depth int, fn func(l *leaf) bool) bool {
+	if n == nil {
+		return true
+	}
+	if l, ok := n.(*leaf); ok {
+		if depth > len(l.key) {
+			return true
+		}
+		states = globFeed(pattern, states, l.key[depth:])
+		if len(states) > 0 && states[len(states)-1] == len(pattern) && !isTombstone(l) {
+			return fn(l)
+		}
+		return true
+	}
+
+	prefix, children := readNode(n)
+	states = globFeed(pattern, states, prefix)
+	if len(states) == 0 {
+		return true
+	}
+	depth += len(prefix)
+	// A wide node branches on two bytes, so its children are left to be
+	// pruned by their own prefixes
+	if b, ok := globLiteral(pattern, states); ok && n.getType() != nodeTypeWide {
+		// Only the child under b can match, plus a key ending here, which
+		// sits under TerminationChar and sorts first
+		candidates := []byte{b}
+		if b != TerminationChar {
+			candidates = []byte{TerminationChar, b}
+		}
+		for _, c := range candidates {
+			if child := readChild(n, c); child != nil && !globWalk(child, pattern, states, depth, fn) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, child := range children {
+		if !globWalk(child, pattern, states, depth, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// readChild returns a validated read of n's child under b.
+func readChild(n node, b byte) node {
+	for {
+		version, _ := readLockOrRestart(n)
+		var child node
+		if next := n.findChild(b); next != nil {
+			child = next.load()
+		}
+		if validate(n, version) {
+			return child
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package art
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{
+		"user:1:active", "user:2:inactive", "user:3:active", "user:42:active",
+		"user::active", "user:1:2:active", "user:1:active:old", "users:1:active",
+		"order:1:active", "user:", "user", "u", "",
+	}
+	for i := 0; i < 200; i++ {
+		keys = append(keys, fmt.Sprintf("order:%d:pending", i))
+	}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+
+	for pattern, expected := range map[string][]string{
+		"user:*:active":  {"user:1:2:active", "user:1:active", "user:3:active", "user:42:active", "user::active"},
+		"user:?:active":  {"user:1:active", "user:3:active"},
+		"user:??:active": {"user:42:active"},
+		"*:active":       {"order:1:active", "user:1:2:active", "user:1:active", "user:3:active", "user:42:active", "user::active", "users:1:active"},
+		"user*":          {"user", "user:", "user:1:2:active", "user:1:active", "user:1:active:old", "user:2:inactive", "user:3:active", "user:42:active", "user::active", "users:1:active"},
+		"user":           {"user"},
+		"?":              {"u"},
+		"":               {""},
+		"order:19?:*":    {"order:190:pending", "order:191:pending", "order:192:pending", "order:193:pending", "order:194:pending", "order:195:pending", "order:196:pending", "order:197:pending", "order:198:pending", "order:199:pending"},
+		"nope*":          nil,
+		"user:*:act":     nil,
+	} {
+		var got []string
+		tree.Glob([]byte(pattern), func(key []byte, val int) bool {
+			if keys[val] != string(key) {
+				t.Errorf("Pattern %q: key %q came with the value of %q", pattern, key, keys[val])
+			}
+			got = append(got, string(key))
+			return true
+		})
+		if strings.Join(got, ",") != strings.Join(expected, ",") {
+			t.Errorf("Pattern %q: expected %q, got %q", pattern, expected, got)
+		}
+	}
+
+	count := 0
+	tree.Glob([]byte("order:*"), func([]byte, int) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Errorf("Expected Glob to stop after 3 keys, got %d", count)
+	}
+}
+
+func TestGlobMatchesReference(t *testing.T) {
+	tree := NewART[int]()
+	var keys []string
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("%x", i*2654435761%65536)
+		keys = append(keys, key)
+		tree.Insert([]byte(key), i)
+	}
+
+	for _, pattern := range []string{"a*", "*a", "*a*b*", "?", "??", "a?c?", "*", "**f", "f*?0", "1*1*1"} {
+		// '*' and '?' as a regexp, greedy or not makes no difference to which
+		// keys match
+		re := regexp.MustCompile("^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern)) + "$")
+		expected := map[string]bool{}
+		for _, key := range keys {
+			if re.MatchString(key) {
+				expected[key] = true
+			}
+		}
+		seen := 0
+		tree.Glob([]byte(pattern), func(key []byte, _ int) bool {
+			if !expected[string(key)] {
+				t.Errorf("Pattern %q visited non-matching key %q", pattern, key)
+			}
+			seen++
+			return true
+		})
+		if seen != len(expected) {
+			t.Errorf("Pattern %q: expected %d keys, got %d", pattern, len(expected), seen)
+		}
+	}
+}
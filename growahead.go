@@ -0,0 +1,82 @@
+package art
+
+import "sync/atomic"
+
+// WithProactiveGrow grows node16s and uncapped node48s in the background
+// once an insert leaves them one child short of full, so that the insert
+// that would fill them finds room instead of paying for the grow. Growing
+// copies every child and, into a node256, allocates 2KB, which is the
+// latency spike of an insert crossing a node size boundary; node4s are
+// cheap enough to grow in place and are left alone. The background grow
+// takes the write locks of the node and its parent, as the insert would,
+// and gives up if either is contended or the node has changed, leaving
+// the grow to the next insert. A node grown ahead can stay one child
+// short of ever needing its new type, and Delete shrinks it only at the
+// usual thresholds. The option does nothing for trees created with
+// WithRCUReads.
+func WithProactiveGrow() Option {
+	return func(c *config) {
+		c.proactiveGrow = true
+	}
+}
+
+// nearlyFull reports whether n is a node that WithProactiveGrow grows
+// ahead of the insert that would fill it.
+func nearlyFull(n node) bool {
+	switch n := n.(type) {
+	case *node16:
+		return n.childCount() >= 15
+	case *node48:
+		return !n.capped && atomic.LoadUint32(&n.numOfChildren) >= 47
+	}
+	return false
+}
+
+// growAhead grows n, a child of parent or the root if parent is nil,
+// unless it has since changed. key is a key below n, depth where n's
+// prefix starts in it and level the number of inner nodes down to n, for
+// the events it reports. pinned is an epoch pin the spawning insert took
+// while it could still reach parent and n, which growAhead unpins when
+// done so that neither is freed while it runs.
+func (t *Tree[T]) growAhead(pinned *atomic.Int64, parent, n node, key []byte, depth, level int) {
+	defer t.epochs.unpin(pinned)
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	slot := &t.node
+	if parent != nil {
+		if writeLockOrRestart(parent) {
+			return
+		}
+		slot = nil
+		for _, s := range slotsOf(parent) {
+			if s.load() == n {
+				slot = s
+				break
+			}
+		}
+		if slot == nil {
+			writeUnlock(parent)
+			return
+		}
+	}
+	if writeLockOrRestart(n) {
+		writeUnlock(parent)
+		return
+	}
+	// With parent nil the root's own lock guards the root pointer
+	if slot.load() != n || !nearlyFull(n) {
+		writeUnlock(n)
+		writeUnlock(parent)
+		return
+	}
+	grown := t.grow(n)
+	slot.store(grown)
+	t.trace.printf("grow ahead node=%p type=%s new=%p type=%s depth=%d", n, n.getType(), grown, grown.getType(), depth)
+	writeUnlockObsolete(n)
+	writeUnlock(parent)
+	t.retirer.retire(n)
+	t.free(n)
+	// the obsolete node's prefix no longer changes
+	t.observe(NodeGrew, key, depth, n.getType(), grown.getType(), n.getPrefix())
+	t.grew(n.getType(), grown.getType(), level)
+}
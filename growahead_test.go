@@ -0,0 +1,228 @@
+package art
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForType waits until the node for prefix has type typ.
+func waitForType(t *testing.T, tree *Tree[int], prefix []byte, typ nodeType) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if n := seekPrefix(tree.root(), prefix); n != nil && n.getType() == typ {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the node for %q to grow into a %s", prefix, typ)
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestProactiveGrow(t *testing.T) {
+	var mu sync.Mutex
+	var grew []nodeType
+	tree := NewART[int](WithProactiveGrow(), WithStructureObserver(func(e StructureEvent) {
+		if e.Kind == NodeGrew {
+			mu.Lock()
+			grew = append(grew, e.NewType)
+			mu.Unlock()
+		}
+	}))
+	key := func(i int) []byte { return []byte{'k', byte(i + 1)} }
+	prefix := []byte("k")
+	for i := 0; i < 15; i++ {
+		tree.Insert(key(i), i)
+	}
+	// One child short of full, the node16 grows without another insert
+	waitForType(t, tree, prefix, nodeType48)
+	for i := 15; i < 47; i++ {
+		tree.Insert(key(i), i)
+	}
+	waitForType(t, tree, prefix, nodeType256)
+	for i := 47; i < 100; i++ {
+		tree.Insert(key(i), i)
+	}
+
+	mu.Lock()
+	if !slices.Equal(grew, []nodeType{nodeType16, nodeType48, nodeType256}) {
+		t.Errorf("Expected each grow to happen once, got %v", grew)
+	}
+	mu.Unlock()
+	for i := 0; i < 100; i++ {
+		if val, found := tree.Search(key(i)); !found || val.(int) != i {
+			t.Errorf("Expected %q=%d, got %v (found=%v)", key(i), i, val, found)
+		}
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+
+	// Capped node48s never grow, ahead or otherwise
+	capped := NewART[int](WithProactiveGrow(), WithMaxNode48())
+	for i := 0; i < 60; i++ {
+		capped.Insert(key(i), i)
+		runtime.Gosched()
+	}
+	if n := seekPrefix(capped.root(), prefix); n.getType() != nodeType48 {
+		t.Errorf("Expected a capped node48, got a %s", n.getType())
+	}
+}
+
+func TestProactiveGrowConcurrent(t *testing.T) {
+	tree := NewART[int](WithProactiveGrow())
+	const goroutines, perGoroutine = 8, 2000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				// Two levels of wide fan-out keep nodes crossing boundaries
+				tree.Insert([]byte(fmt.Sprintf("%c%c%d", 'A'+i%50, 'A'+g*7+i/50%7, i)), i)
+				if i%16 == 0 {
+					runtime.Gosched()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	// Let the last background grows finish
+	resume := tree.Quiesce()
+	resume()
+	time.Sleep(10 * time.Millisecond)
+	resume = tree.Quiesce()
+	defer resume()
+
+	if tree.Len() != goroutines*perGoroutine {
+		t.Errorf("Expected %d keys, got %d", goroutines*perGoroutine, tree.Len())
+	}
+	keys := tree.Keys()
+	if len(keys) != goroutines*perGoroutine || !sort.SliceIsSorted(keys, func(i, j int) bool { return string(keys[i]) < string(keys[j]) }) {
+		t.Errorf("Expected %d sorted keys, got %d", goroutines*perGoroutine, len(keys))
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
+
+// BenchmarkBoundaryInsertLatency measures the inserts that take a node past
+// a size boundary, the 17th and 49th children, which grow the node
+// synchronously unless WithProactiveGrow has grown it already. Each insert
+// is followed by a yield, standing in for the idle time between requests
+// in which background grows run. Compare the boundary-ns metric.
+func BenchmarkBoundaryInsertLatency(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"sync", nil},
+		{"proactive", []Option{WithProactiveGrow()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			var boundary time.Duration
+			for i := 0; i < b.N; i++ {
+				tree := NewART[int](mode.opts...)
+				for c := 0; c < 64; c++ {
+					key := []byte{'k', byte(c + 1)}
+					start := time.Now()
+					tree.Insert(key, c)
+					if c == 16 || c == 48 {
+						boundary += time.Since(start)
+					}
+					runtime.Gosched()
+				}
+			}
+			b.ReportMetric(float64(boundary.Nanoseconds())/float64(2*b.N), "boundary-ns")
+		})
+	}
+}
+
+// recordingAllocator records the nodes it gets back, failing the test if
+// one comes back twice.
+type recordingAllocator struct {
+	heapAllocator
+	t     *testing.T
+	mu    sync.Mutex
+	freed map[node]bool
+}
+
+func (a *recordingAllocator) FreeNode(n node) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.freed[n] {
+		a.t.Errorf("FreeNode(%s %p) twice", n.getType(), n)
+	}
+	a.freed[n] = true
+}
+
+func (a *recordingAllocator) wasFreed(n node) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.freed[n]
+}
+
+func TestProactiveGrowHoldsEpoch(t *testing.T) {
+	alloc := &recordingAllocator{t: t, freed: map[node]bool{}}
+	tree := NewART[int](WithAllocator(alloc))
+	key := func(i int) []byte { return []byte{'k', byte(i + 1)} }
+	tree.Insert([]byte("a"), -1)
+	for i := 0; i < 15; i++ {
+		tree.Insert(key(i), i)
+	}
+	parent := tree.root()
+	n := seekPrefix(parent, []byte("k"))
+	if n == nil || !nearlyFull(n) {
+		t.Fatalf("Expected a nearly full node16 under k, got %v", n)
+	}
+
+	// The pin an insert hands its background grow keeps n from being
+	// freed even after deletes unlink it
+	pinned := tree.epochs.pin()
+	for i := 0; i < 13; i++ {
+		tree.Delete(key(i))
+	}
+	if seekPrefix(tree.root(), []byte("k")) == n {
+		t.Fatal("Expected the deletes to shrink the node16")
+	}
+	if alloc.wasFreed(n) {
+		t.Fatal("FreeNode received a node a background grow could still reach")
+	}
+	tree.growAhead(pinned, parent, n, key(14), 1, 1)
+	if !alloc.wasFreed(n) {
+		t.Error("Expected the node to be freed once the grow returned")
+	}
+
+	// Under churn no node is freed twice
+	churn := NewART[int](WithProactiveGrow(), WithAllocator(&recordingAllocator{t: t, freed: map[node]bool{}}))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for round := 0; round < 50; round++ {
+				for i := 0; i < 60; i++ {
+					churn.Insert([]byte{'c', byte(g + 1), byte(i + 1)}, i)
+				}
+				for i := 0; i < 60; i++ {
+					churn.Delete([]byte{'c', byte(g + 1), byte(i + 1)})
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	resume := churn.Quiesce()
+	resume()
+	if churn.Len() != 0 {
+		t.Errorf("Expected an empty tree, got %d keys", churn.Len())
+	}
+	if err := churn.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
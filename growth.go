@@ -0,0 +1,81 @@
+package art
+
+import "sync/atomic"
+
+// minGrowthDepth is the first depth a growth monitor reports; later reports
+// come each time the deepest leaf doubles it.
+const minGrowthDepth = 8
+
+// GrowthEventKind identifies what a GrowthEvent reports.
+type GrowthEventKind int
+
+const (
+	// GrowthNodeGrew: a full node was replaced by the next larger type
+	GrowthNodeGrew GrowthEventKind = iota
+	// GrowthDepthReached: an insert placed a leaf deeper than minGrowthDepth
+	// times a power of two for the first time
+	GrowthDepthReached
+)
+
+func (k GrowthEventKind) String() string {
+	switch k {
+	case GrowthNodeGrew:
+		return "node-grew"
+	case GrowthDepthReached:
+		return "depth-reached"
+	}
+	return "unknown"
+}
+
+// GrowthEvent reports a change in the tree's shape to a WithGrowthMonitor
+// function.
+type GrowthEvent struct {
+	Kind GrowthEventKind
+	// OldType and NewType are the node's types before and after a grow
+	OldType, NewType nodeType
+	// Depth counts the inner nodes from the root down to the grown node, or
+	// above the inserted leaf for GrowthDepthReached
+	Depth int
+}
+
+// growthState tracks the deepest leaf placed in a tree created with
+// WithGrowthMonitor.
+type growthState struct {
+	fn       func(GrowthEvent)
+	maxDepth atomic.Int64
+}
+
+// grew reports a grow of a node with depth inner nodes above and including
+// it. It is called once the grow is committed and its locks are released.
+func (t *Tree[T]) grew(oldType, newType nodeType, depth int) {
+	if t.growth == nil {
+		return
+	}
+	t.growth.fn(GrowthEvent{Kind: GrowthNodeGrew, OldType: oldType, NewType: newType, Depth: depth})
+}
+
+// placed records a leaf inserted below depth inner nodes.
+func (t *Tree[T]) placed(depth int) {
+	if t.growth != nil {
+		t.growth.placed(depth)
+	}
+}
+
+// placed reports every threshold a leaf at depth is the first to reach.
+func (g *growthState) placed(depth int) {
+	for {
+		deepest := g.maxDepth.Load()
+		if int64(depth) <= deepest {
+			return
+		}
+		if !g.maxDepth.CompareAndSwap(deepest, int64(depth)) {
+			continue
+		}
+		for threshold := minGrowthDepth; threshold <= depth; threshold *= 2 {
+			if int64(threshold) > deepest {
+				g.fn(GrowthEvent{Kind: GrowthDepthReached, Depth: threshold})
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,39 @@
+package art
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGrowthMonitor(t *testing.T) {
+	var events []GrowthEvent
+	tree := NewART[int](WithGrowthMonitor(func(event GrowthEvent) {
+		events = append(events, event)
+	}))
+	for i := 0; i < 17; i++ {
+		tree.Insert([]byte{'k', byte('a' + i)}, i)
+	}
+	// The root node4 holds the node for prefix "k", which grows twice
+	expected := []GrowthEvent{
+		{Kind: GrowthNodeGrew, OldType: nodeType4, NewType: nodeType16, Depth: 2},
+		{Kind: GrowthNodeGrew, OldType: nodeType16, NewType: nodeType48, Depth: 2},
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+
+	// Each key extending the previous one adds a level
+	events = nil
+	key := []byte("n")
+	for i := 0; i < 20; i++ {
+		tree.Insert(key, i)
+		key = append(key, 'n')
+	}
+	expected = []GrowthEvent{
+		{Kind: GrowthDepthReached, Depth: 8},
+		{Kind: GrowthDepthReached, Depth: 16},
+	}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, events)
+	}
+}
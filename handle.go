@@ -0,0 +1,75 @@
+package art
+
+// Handle remembers where SearchHandle found a key, so Reget can read the
+// key's value again without descending from the root. A Handle stays
+// usable after the key changes or is deleted; Reget then falls back to a
+// full lookup. The zero Handle looks up the empty key.
+type Handle[T any] struct {
+	key     []byte
+	leaf    *leaf
+	version uint64
+	// root is the RCU root the leaf was found under; an overwrite in an
+	// RCU tree replaces the leaf without marking the old one obsolete
+	root *rcuRoot
+}
+
+// Key returns the key the handle was created for.
+func (h Handle[T]) Key() []byte {
+	return h.key
+}
+
+// SearchHandle is Search that also returns a handle for re-reading key with
+// Reget. The handle of a miss is still valid and always re-descends.
+func (t *Tree[T]) SearchHandle(key []byte) (Handle[T], bool) {
+	h := Handle[T]{key: key}
+	for {
+		if t.rcu != nil {
+			h.root = t.rcu.root.Load()
+		}
+		l, _, found := t.search(key, 0, nil, 0)
+		if !found {
+			if a, ok := t.resolveAlias(key); ok {
+				l, _, found = t.search(a.rewrite(key), 0, nil, 0)
+			}
+		}
+		if !found {
+			return h, false
+		}
+		// Record the version the value is read under; if the leaf changed
+		// since search saw it, look it up again
+		version, needToRestart := readLockOrRestart(l)
+		if needToRestart {
+			continue
+		}
+		h.leaf, h.version = l, version
+		if val, ok := h.read(t); ok {
+			_, found = live(val, true)
+			return h, found
+		}
+	}
+}
+
+// Reget returns the value stored under h's key. While the key's leaf is
+// unchanged since SearchHandle it reads the leaf directly; otherwise it
+// looks the key up like Search.
+func (t *Tree[T]) Reget(h Handle[T]) (T, bool) {
+	if val, ok := h.read(t); ok {
+		val, found := live(val, true)
+		return valueAs[T](val), found
+	}
+	val, found := t.Search(h.key)
+	return valueAs[T](val), found
+}
+
+// read returns the value of h's leaf if the leaf still has the version h
+// recorded.
+func (h Handle[T]) read(t *Tree[T]) (interface{}, bool) {
+	if h.leaf == nil || (t.rcu != nil && t.rcu.root.Load() != h.root) {
+		return nil, false
+	}
+	val := h.leaf.value()
+	if !validate(h.leaf, h.version) {
+		return nil, false
+	}
+	return val, true
+}
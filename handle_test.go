@@ -0,0 +1,45 @@
+package art
+
+import "testing"
+
+func TestSearchHandleReget(t *testing.T) {
+	for _, rcu := range []bool{false, true} {
+		opts := []Option{WithMetrics()}
+		if rcu {
+			opts = append(opts, WithRCUReads())
+		}
+		tree := NewART[int](opts...)
+		for _, k := range []string{"counter/a", "counter/b", "counter/c", "other"} {
+			tree.Insert([]byte(k), 1)
+		}
+		visits := 0
+		tree.Metrics().testHook = func(op Operation, n node, afterRead bool) {
+			if op == OperationSearch && !afterRead {
+				visits++
+			}
+		}
+		h, ok := tree.SearchHandle([]byte("counter/b"))
+		if !ok {
+			t.Fatalf("rcu=%v: SearchHandle missed", rcu)
+		}
+		visits = 0
+		if v, ok := tree.Reget(h); !ok || v != 1 || visits != 0 {
+			t.Errorf("rcu=%v: unchanged Reget = %d, %v with %d visits", rcu, v, ok, visits)
+		}
+		tree.Insert([]byte("counter/b"), 2)
+		visits = 0
+		if v, ok := tree.Reget(h); !ok || v != 2 {
+			t.Errorf("rcu=%v: Reget after update = %d, %v", rcu, v, ok)
+		}
+		if !rcu && visits == 0 {
+			t.Errorf("Reget after update did not re-descend")
+		}
+		tree.Delete([]byte("counter/b"))
+		if _, ok := tree.Reget(h); ok {
+			t.Errorf("rcu=%v: Reget found a deleted key", rcu)
+		}
+		if h, ok := tree.SearchHandle([]byte("missing")); ok {
+			t.Errorf("rcu=%v: SearchHandle found %q", rcu, h.Key())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package art
+
+// valueHistory is a ring of a leaf's previous values, newest last. Readers
+// load it optimistically, so it is never modified once stored in a leaf.
+type valueHistory struct {
+	vals []interface{}
+	next int
+	size int
+}
+
+// pushHistory records old as the most recent previous value of l, keeping at
+// most capacity entries. The caller must hold l's write lock.
+func (l *leaf) pushHistory(old interface{}, capacity int) {
+	h := l.history.Load().clone()
+	if h == nil {
+		h = &valueHistory{vals: make([]interface{}, capacity)}
+	}
+	h.vals[h.next] = old
+	h.next = (h.next + 1) % len(h.vals)
+	if h.size < len(h.vals) {
+		h.size++
+	}
+	l.history.Store(h)
+}
+
+// previous returns the nth previous value of l, where 1 is the value
+// replaced by the latest overwrite. The caller must validate l's version.
+func (l *leaf) previous(n int) (interface{}, bool) {
+	h := l.history.Load()
+	if h == nil || n < 1 || n > h.size {
+		return nil, false
+	}
+	return h.vals[(h.next-n+len(h.vals))%len(h.vals)], true
+}
+
+// SearchVersion returns the nth most recent value of key, where 0 is the
+// current value. Older values are only kept when the tree was created with
+// WithVersionHistory.
+func (t *Tree[T]) SearchVersion(key []byte, n int) (T, bool) {
+	l, val, found := t.search(key, 0, nil, 0)
+	if !found || n < 0 {
+		var zero T
+		return zero, false
+	}
+	if n == 0 {
+		val, found = live(val, true)
+		return valueAs[T](val), found
+	}
+	for {
+		version, _ := readLockOrRestart(l)
+		val, found = l.previous(n)
+		if validate(l, version) {
+			val, found = live(val, found)
+			return valueAs[T](val), found
+		}
+	}
+}
+
+// clone returns a copy of h that can be pushed to without affecting h.
+func (h *valueHistory) clone() *valueHistory {
+	if h == nil {
+		return nil
+	}
+	c := *h
+	c.vals = append([]interface{}(nil), h.vals...)
+	return &c
+}
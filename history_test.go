@@ -0,0 +1,73 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSearchVersion(t *testing.T) {
+	tree := NewART[string](WithVersionHistory(3))
+	for i := 1; i <= 5; i++ {
+		tree.Insert([]byte("key"), fmt.Sprintf("v%d", i))
+	}
+
+	for n, expected := range []string{"v5", "v4", "v3"} {
+		val, found := tree.SearchVersion([]byte("key"), n)
+		if !found || val != expected {
+			t.Errorf("SearchVersion(%d): expected %s, got %q (found=%v)", n, expected, val, found)
+		}
+	}
+	for _, n := range []int{3, 4, 10, -1} {
+		if val, found := tree.SearchVersion([]byte("key"), n); found {
+			t.Errorf("SearchVersion(%d): expected absence, got %q", n, val)
+		}
+	}
+	if _, found := tree.SearchVersion([]byte("missing"), 0); found {
+		t.Error("Expected missing key to be absent")
+	}
+}
+
+func TestSearchVersionPartialHistory(t *testing.T) {
+	tree := NewART[int](WithVersionHistory(4))
+	tree.Insert([]byte("a"), 1)
+	tree.Insert([]byte("a"), 2)
+
+	if val, found := tree.SearchVersion([]byte("a"), 1); !found || val != 1 {
+		t.Errorf("Expected previous value 1, got %d (found=%v)", val, found)
+	}
+	if _, found := tree.SearchVersion([]byte("a"), 2); found {
+		t.Error("Expected no value two versions back")
+	}
+
+	plain := NewART[int]()
+	plain.Insert([]byte("a"), 1)
+	plain.Insert([]byte("a"), 2)
+	if _, found := plain.SearchVersion([]byte("a"), 1); found {
+		t.Error("History must be disabled by default")
+	}
+	if val, found := plain.SearchVersion([]byte("a"), 0); !found || val != 2 {
+		t.Errorf("Expected current value 2, got %d (found=%v)", val, found)
+	}
+}
+
+func TestSearchVersionConcurrent(t *testing.T) {
+	tree := NewART[int](WithVersionHistory(8))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				tree.Insert([]byte("hot"), id*1000+i)
+				tree.SearchVersion([]byte("hot"), i%8)
+			}
+		}(g)
+	}
+	wg.Wait()
+	for n := 0; n < 8; n++ {
+		if _, found := tree.SearchVersion([]byte("hot"), n); !found {
+			t.Errorf("Expected version %d to be retained", n)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package art
+
+import "unsafe"
+
+// inlineValue tags a leaf whose []byte value is stored inline: the value's
+// bytes follow the key in the key's allocation and the tag holds their
+// length. Converting a one-byte integer to an interface does not allocate,
+// so an inline value costs nothing beyond its bytes.
+type inlineValue uint8
+
+// inline returns a copy of key allocated from keys, with val moved into the
+// copy's allocation if it is a []byte of at most threshold bytes, in which
+// case the key is capped to its own length and the tag replaces val. Empty
+// keys have no allocation to share and keep val by reference.
+func inline(key []byte, val interface{}, threshold int, keys *keyArena) ([]byte, interface{}) {
+	b, ok := val.([]byte)
+	if !ok || len(key) == 0 || len(b) > threshold {
+		return keys.copy(key), val
+	}
+	buf := keys.alloc(len(key) + len(b))
+	copy(buf, key)
+	copy(buf[len(key):], b)
+	return buf[:len(key):len(key)], inlineValue(len(b))
+}
+
+// raw returns l's value as stored, without decoding it.
+func (l *leaf) raw() interface{} {
+	if p := l.val.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// setRaw stores val as l's value. The caller holds l's write lock, or no
+// reader can reach l yet.
+func (l *leaf) setRaw(val interface{}) {
+	l.val.Store(&val)
+}
+
+// value returns l's value, decoding an inline or compact one and loading
+// a lazy one.
+func (l *leaf) value() interface{} {
+	val := l.raw()
+	if k, ok := val.(compactValue); ok {
+		return k.expand(l.bits.Load())
+	}
+	if z, ok := val.(*lazyValue); ok {
+		return z.get()
+	}
+	n, ok := val.(inlineValue)
+	if !ok {
+		return val
+	}
+	return unsafe.Slice(unsafe.SliceData(l.key), len(l.key)+int(n))[len(l.key):]
+}
+
+// MemoryUsage approximates the bytes held by the tree's nodes, keys and
+// []byte or string values. Other values count only as the leaf's interface
+// slot.
+func (t *Tree[T]) MemoryUsage() int64 {
+	defer t.epochs.unpin(t.epochs.pin())
+	return memoryUsage(t.root())
+}
+
+func memoryUsage(n node) int64 {
+	if n == nil {
+		return 0
+	}
+	if l, ok := n.(*leaf); ok {
+		size := nodeSize(l)
+		switch v := readLeaf(l).(type) {
+		case []byte:
+			if _, inline := l.raw().(inlineValue); inline {
+				size += int64(len(v))
+			} else {
+				// the boxed slice header and its backing array
+				size += int64(unsafe.Sizeof(v)) + int64(cap(v))
+			}
+		case string:
+			size += int64(unsafe.Sizeof(v)) + int64(len(v))
+		}
+		return size
+	}
+	size := nodeSize(n) + summarySize(n)
+	for _, child := range readChildren(n) {
+		size += memoryUsage(child)
+	}
+	return size
+}
@@ -0,0 +1,97 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestInlineValueThreshold(t *testing.T) {
+	const threshold = 16
+	tree := NewART[[]byte](WithInlineValueThreshold(threshold))
+	values := map[string][]byte{}
+	for _, size := range []int{0, 1, threshold - 1, threshold, threshold + 1, 200} {
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("k%03d/%02d", size, i)
+			val := bytes.Repeat([]byte{byte('a' + i)}, size)
+			values[key] = val
+			tree.Insert([]byte(key), val)
+		}
+	}
+	// The empty key cannot share its allocation
+	values[""] = []byte("empty")
+	tree.Insert(nil, values[""])
+
+	for key, want := range values {
+		got, found := tree.Search([]byte(key))
+		if !found || !bytes.Equal(got.([]byte), want) {
+			t.Errorf("Expected %q for '%s', got %q (found=%v)", want, key, got, found)
+		}
+	}
+	tree.ForEach(func(key []byte, val []byte) bool {
+		if !bytes.Equal(val, values[string(key)]) {
+			t.Errorf("ForEach returned %q for '%s'", val, key)
+		}
+		return true
+	})
+
+	// Overwrites go by reference and leave the key intact
+	key := []byte(fmt.Sprintf("k%03d/%02d", threshold, 3))
+	tree.Insert(key, []byte("new"))
+	if got, _ := tree.Search(key); string(got.([]byte)) != "new" {
+		t.Errorf("Expected overwritten value 'new', got %q", got)
+	}
+	stored, _, _ := tree.SearchCanonical(key)
+	if !bytes.Equal(stored, key) {
+		t.Errorf("Expected stored key '%s', got '%s'", key, stored)
+	}
+
+	// Appending to a key handed out by the tree must not clobber its value
+	small := []byte(fmt.Sprintf("k%03d/%02d", 1, 0))
+	stored, _, _ = tree.SearchCanonical(small)
+	_ = append(stored, 'X')
+	if got, _ := tree.Search(small); !bytes.Equal(got.([]byte), values[string(small)]) {
+		t.Errorf("Inline value clobbered by append to key: %q", got)
+	}
+}
+
+func TestInlineValueMemoryUsage(t *testing.T) {
+	fill := func(opts ...Option) *Tree[[]byte] {
+		tree := NewART[[]byte](opts...)
+		for i := 0; i < 1000; i++ {
+			size := 8
+			if i%10 == 0 {
+				size = 512
+			}
+			tree.Insert([]byte(fmt.Sprintf("key%04d", i)), make([]byte, size))
+		}
+		return tree
+	}
+	byRef := fill().MemoryUsage()
+	inlined := fill(WithInlineValueThreshold(32)).MemoryUsage()
+	t.Logf("MemoryUsage by reference: %d, inline: %d", byRef, inlined)
+	if inlined >= byRef {
+		t.Errorf("Expected inline small values to use less memory: %d vs %d", inlined, byRef)
+	}
+}
+
+func BenchmarkSearchInlineValues(b *testing.B) {
+	for _, threshold := range []int{0, 32} {
+		b.Run(fmt.Sprintf("threshold=%d", threshold), func(b *testing.B) {
+			tree := NewART[[]byte](WithInlineValueThreshold(threshold))
+			keys := make([][]byte, 10000)
+			for i := range keys {
+				keys[i] = []byte(fmt.Sprintf("key%06d", i*7919%10000))
+				size := 8
+				if i%10 == 0 {
+					size = 512
+				}
+				tree.Insert(keys[i], make([]byte, size))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Search(keys[i%len(keys)])
+			}
+		})
+	}
+}
@@ -0,0 +1,617 @@
+package art
+
+import (
+	"bytes"
+	"iter"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KV is a key/value pair as stored in the tree.
+type KV[T any] struct {
+	Key   []byte
+	Value T
+}
+
+// Entry is the name the query APIs use for a KV. Keys of returned entries
+// are shared with the tree and must not be modified.
+type Entry[T any] = KV[T]
+
+// ForEach visits every key in ascending byte order until fn returns false.
+// The key passed to fn is owned by the tree and must not be modified.
+// Iteration is weakly consistent: keys present for the whole traversal are
+// always visited, keys inserted concurrently may or may not be.
+func (t *Tree[T]) ForEach(fn func(key []byte, val T) bool) {
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		return fn(l.key, valueAs[T](readLeaf(l)))
+	})
+}
+
+// ParallelForEach calls fn for every key from workers goroutines at once,
+// GOMAXPROCS of them if workers <= 0, and returns when all keys are visited.
+// The root's children are handed out as units of work, split one level
+// further while there are fewer of them than workers, so each worker walks
+// whole subtrees. Keys arrive in no particular order and fn must be safe
+// for concurrent calls. It shares ForEach's consistency guarantees.
+func (t *Tree[T]) ParallelForEach(workers int, fn func(key []byte, val T)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	parts := readChildren(t.root())
+	for expanded := true; len(parts) < workers && expanded; {
+		expanded = false
+		var next []node
+		for _, part := range parts {
+			if part.getType() == nodeTypeLeaf {
+				next = append(next, part)
+			} else {
+				next = append(next, readChildren(part)...)
+				expanded = true
+			}
+		}
+		parts = next
+	}
+
+	work := make(chan node, len(parts))
+	for _, part := range parts {
+		work <- part
+	}
+	close(work)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(parts)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range work {
+				walk(part, func(l *leaf) bool {
+					fn(l.key, valueAs[T](readLeaf(l)))
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// All returns an iterator over every key and value in ascending key order,
+// for use with range-over-func. Breaking out of the loop stops the traversal.
+func (t *Tree[T]) All() iter.Seq2[[]byte, T] {
+	return func(yield func([]byte, T) bool) {
+		t.ForEach(yield)
+	}
+}
+
+// Range returns an iterator over the keys in [start, end) in ascending
+// order. A nil end leaves the range unbounded above. The traversal descends
+// along start, so the subtrees holding smaller keys are skipped without
+// being read.
+func (t *Tree[T]) Range(start, end []byte) iter.Seq2[[]byte, T] {
+	return func(yield func([]byte, T) bool) {
+		defer t.epochs.unpin(t.epochs.pin())
+		walkFrom(t.root(), nil, start, func(l *leaf) bool {
+			if end != nil && bytes.Compare(l.key, end) >= 0 {
+				return false
+			}
+			return yield(l.key, valueAs[T](readLeaf(l)))
+		})
+	}
+}
+
+// Keys returns every key in ascending order.
+func (t *Tree[T]) Keys() [][]byte {
+	var keys [][]byte
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		keys = append(keys, l.key)
+		return true
+	})
+	return keys
+}
+
+// CountLeaves counts the keys by walking the whole tree.
+func (t *Tree[T]) CountLeaves() int {
+	count := 0
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// LenPrefix returns the number of keys starting with prefix, matching
+// exactly the keys ScanPrefix visits, aliases included. The tree keeps no
+// per-node counts, so it walks the subtree below prefix, but without reading
+// any values.
+func (t *Tree[T]) LenPrefix(prefix []byte) int {
+	count := 0
+	if _, ok := t.resolveAlias(prefix); ok {
+		t.ScanPrefix(prefix, func([]byte, T) bool {
+			count++
+			return true
+		})
+		return count
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(seekPrefix(t.root(), prefix), func(l *leaf) bool {
+		if bytes.HasPrefix(l.key, prefix) {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// ScanPrefix visits every key starting with prefix in ascending byte order
+// until fn returns false. It shares ForEach's consistency guarantees. A
+// prefix under an alias also visits the aliased keys, presented under the
+// alias.
+func (t *Tree[T]) ScanPrefix(prefix []byte, fn func(key []byte, val T) bool) {
+	if a, ok := t.resolveAlias(prefix); ok {
+		t.scanAlias(prefix, a, fn)
+		return
+	}
+	t.scanPrefix(prefix, fn)
+}
+
+func (t *Tree[T]) scanPrefix(prefix []byte, fn func(key []byte, val T) bool) {
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(seekPrefix(t.root(), prefix), func(l *leaf) bool {
+		if !bytes.HasPrefix(l.key, prefix) {
+			return true
+		}
+		return fn(l.key, valueAs[T](readLeaf(l)))
+	})
+}
+
+// ScanPrefixN returns the first n entries under prefix in ascending key
+// order, or all of them if there are fewer. The descent stops as soon as n
+// entries are collected, so a short prefix over a large subtree costs only
+// the nodes leading to those entries.
+func (t *Tree[T]) ScanPrefixN(prefix []byte, n int) []Entry[T] {
+	if n <= 0 {
+		return nil
+	}
+	entries := make([]Entry[T], 0, min(n, 64))
+	if _, ok := t.resolveAlias(prefix); ok {
+		t.ScanPrefix(prefix, func(key []byte, val T) bool {
+			entries = append(entries, Entry[T]{Key: key, Value: val})
+			return len(entries) < n
+		})
+		return entries
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	t.firstN(seekPrefix(t.root(), prefix), prefix, n, &entries)
+	return entries
+}
+
+// firstN appends the smallest entries under prefix below nd to entries
+// until it holds n, and reports whether it still needs more.
+func (t *Tree[T]) firstN(nd node, prefix []byte, n int, entries *[]Entry[T]) bool {
+	if nd == nil {
+		return true
+	}
+	t.metrics.hook(OperationSearch, nd, false)
+	if l, ok := nd.(*leaf); ok {
+		if bytes.HasPrefix(l.key, prefix) && !isTombstone(l) {
+			*entries = append(*entries, Entry[T]{Key: l.key, Value: valueAs[T](readLeaf(l))})
+		}
+		return len(*entries) < n
+	}
+	for _, child := range readChildren(nd) {
+		if !t.firstN(child, prefix, n, entries) {
+			return false
+		}
+	}
+	return true
+}
+
+// ScanPrefixes returns the entries under each of prefixes, keyed by the
+// prefix as a string. Prefixes nested inside another requested prefix share
+// its descent, and a key matching several prefixes appears under each of
+// them. Prefixes without matches are absent from the result.
+func (t *Tree[T]) ScanPrefixes(prefixes [][]byte) map[string][]Entry[T] {
+	result := make(map[string][]Entry[T])
+	for _, group := range prefixGroups(prefixes) {
+		t.ScanPrefix([]byte(group[0]), func(key []byte, val T) bool {
+			for _, prefix := range group {
+				if bytes.HasPrefix(key, []byte(prefix)) {
+					result[prefix] = append(result[prefix], Entry[T]{Key: key, Value: val})
+				}
+			}
+			return true
+		})
+	}
+	return result
+}
+
+// MultiPrefixScan visits the keys under each of prefixes until fn returns
+// false, passing fn the prefix a key was found under. Every key is visited
+// once: a key under several nested prefixes, such as "a" and "ab", is
+// attributed to the longest of them. Keys arrive in ascending order. It
+// shares ForEach's consistency guarantees.
+func (t *Tree[T]) MultiPrefixScan(prefixes [][]byte, fn func(prefix []byte, key []byte, val T) bool) {
+	for _, group := range prefixGroups(prefixes) {
+		if !t.scanGroup(group, fn) {
+			return
+		}
+	}
+}
+
+// ParallelMultiPrefixScan is MultiPrefixScan scanning prefixes that are
+// not nested in one another from workers goroutines at once, GOMAXPROCS of
+// them if workers <= 0. Keys arrive in no particular order and fn must be
+// safe for concurrent calls.
+func (t *Tree[T]) ParallelMultiPrefixScan(workers int, prefixes [][]byte, fn func(prefix []byte, key []byte, val T)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	groups := prefixGroups(prefixes)
+	work := make(chan []string, len(groups))
+	for _, group := range groups {
+		work <- group
+	}
+	close(work)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(groups)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range work {
+				t.scanGroup(group, func(prefix, key []byte, val T) bool {
+					fn(prefix, key, val)
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// scanGroup visits the keys under group[0], passing fn the longest prefix
+// in group each one starts with, and reports whether fn never returned
+// false.
+func (t *Tree[T]) scanGroup(group []string, fn func(prefix []byte, key []byte, val T) bool) bool {
+	more := true
+	t.ScanPrefix([]byte(group[0]), func(key []byte, val T) bool {
+		// group is sorted, so the last match is the longest
+		match := group[0]
+		for _, prefix := range group[1:] {
+			if bytes.HasPrefix(key, []byte(prefix)) {
+				match = prefix
+			}
+		}
+		more = fn([]byte(match), key, val)
+		return more
+	})
+	return more
+}
+
+// prefixGroups sorts prefixes and drops duplicates, then groups each prefix
+// with the prefixes extending it, so that one scan of a group's first
+// prefix covers the whole group.
+func prefixGroups(prefixes [][]byte) [][]string {
+	sorted := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		sorted[i] = string(prefix)
+	}
+	sort.Strings(sorted)
+	sorted = slices.Compact(sorted)
+
+	var groups [][]string
+	for i := 0; i < len(sorted); {
+		// Every prefix extending sorted[i] follows it directly in sorted order
+		root := sorted[i]
+		j := i + 1
+		for j < len(sorted) && strings.HasPrefix(sorted[j], root) {
+			j++
+		}
+		groups = append(groups, sorted[i:j])
+		i = j
+	}
+	return groups
+}
+
+// seekPrefix descends to the highest node whose subtree holds every key
+// starting with prefix, or returns nil if no such key can exist. Leaves are
+// returned unchecked; callers filter them against prefix.
+func seekPrefix(root node, prefix []byte) node {
+	depth := 0
+	curNode := root
+	for curNode != nil && curNode.getType() != nodeTypeLeaf {
+		version, _ := readLockOrRestart(curNode)
+		pre := curNode.getPrefix()
+		p := checkPrefix(pre, prefix, depth)
+		// a wide node stops the descent when prefix ends inside the two
+		// bytes it branches on
+		if depth+p >= len(prefix) || (p == len(pre) && depth+p+stride(curNode) > len(prefix)) {
+			if !validate(curNode, version) {
+				continue
+			}
+			return curNode
+		}
+		if p != len(pre) {
+			if !validate(curNode, version) {
+				continue
+			}
+			return nil
+		}
+		var next node
+		if child := findChild(curNode, prefix, depth+len(pre)); child != nil {
+			next = child.load()
+		}
+		if !validate(curNode, version) {
+			continue
+		}
+		depth += len(pre)
+		curNode = next
+	}
+	return curNode
+}
+
+// ForEachNode invokes fn for every inner node that has leaf children, passing
+// the node's full path prefix and its immediate leaves in key order. Nodes
+// are visited in pre-order until fn returns false.
+func (t *Tree[T]) ForEachNode(fn func(prefix []byte, leaves []KV[T]) bool) {
+	defer t.epochs.unpin(t.epochs.pin())
+	walkNodes(t.root(), nil, func(n node, path []byte, children []node) bool {
+		var leaves []KV[T]
+		for _, child := range children {
+			if l, ok := child.(*leaf); ok && !isTombstone(l) {
+				leaves = append(leaves, KV[T]{Key: l.key, Value: valueAs[T](readLeaf(l))})
+			}
+		}
+		if len(leaves) == 0 {
+			return true
+		}
+		return fn(path, leaves)
+	})
+}
+
+// Prefixes invokes fn for every prefix that at least two keys share, and
+// for the empty prefix, with the number of keys starting with it, in
+// ascending order until fn returns false. This is the set of branching
+// points a folder view of the keys needs: "a/b/c" and "a/b/d" yield "",
+// "a", "a/", "a/b" and "a/b/", each with a count of 2. Path compression
+// stores such a run of prefixes as a single node, which Prefixes expands
+// one byte at a time. The counts are taken before fn is first called, so
+// concurrent writers make them approximate.
+func (t *Tree[T]) Prefixes(fn func(prefix []byte, leafCount int) bool) {
+	type entry struct {
+		path []byte
+		// from is the length of the parent's path; the node stands for
+		// every prefix of path longer than it
+		from   int
+		leaves int
+	}
+	var entries []entry
+	var count func(n node, path []byte) int
+	count = func(n node, path []byte) int {
+		if l, ok := n.(*leaf); ok {
+			if isTombstone(l) {
+				return 0
+			}
+			return 1
+		}
+		prefix, children := readNode(n)
+		from := len(path)
+		path = append(path[:len(path):len(path)], prefix...)
+		i := len(entries)
+		entries = append(entries, entry{path: path, from: from})
+		for _, child := range children {
+			entries[i].leaves += count(child, path)
+		}
+		return entries[i].leaves
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	count(t.root(), nil)
+	for i, e := range entries {
+		from := e.from + 1
+		if i == 0 {
+			// The root also stands for the empty prefix
+			from = 0
+		} else if e.leaves < 2 {
+			// Tombstones left fewer than two keys below the node
+			continue
+		}
+		for end := from; end <= len(e.path); end++ {
+			if !fn(e.path[:end], e.leaves) {
+				return
+			}
+		}
+	}
+}
+
+// walkNodes visits the inner nodes below n in pre-order, passing each node's
+// full path, the recorded one in trees created with WithFullPathNodes, and
+// a validated snapshot of its children, until fn returns false.
+func walkNodes(n node, path []byte, fn func(n node, path []byte, children []node) bool) bool {
+	if n == nil || n.getType() == nodeTypeLeaf {
+		return true
+	}
+	prefix, children := readNode(n)
+	if recorded := storedPath(n); recorded != nil {
+		path = recorded
+	} else {
+		path = append(path[:len(path):len(path)], prefix...)
+	}
+	if !fn(n, path, children) {
+		return false
+	}
+	for _, child := range children {
+		if !walkNodes(child, path, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// readNode returns a validated copy of n's prefix and its children in key
+// order.
+func readNode(n node) ([]byte, []node) {
+	for {
+		version, _ := readLockOrRestart(n)
+		prefix := append([]byte(nil), n.getPrefix()...)
+		children := sortedChildren(n)
+		if validate(n, version) {
+			return prefix, children
+		}
+	}
+}
+
+// walk visits the leaves below n in key order until fn returns false,
+// skipping tombstones.
+func walk(n node, fn func(l *leaf) bool) bool {
+	if n == nil {
+		return true
+	}
+	if l, ok := n.(*leaf); ok {
+		if isTombstone(l) {
+			return true
+		}
+		return fn(l)
+	}
+	for _, child := range readChildren(n) {
+		if !walk(child, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// readChildren returns a validated snapshot of n's children in key order.
+// An obsolete node is frozen, so its children are read as they were when it
+// was replaced.
+func readChildren(n node) []node {
+	for {
+		version, _ := readLockOrRestart(n)
+		children := sortedChildren(n)
+		if validate(n, version) {
+			return children
+		}
+	}
+}
+
+// readLeaf returns a validated read of l's value.
+func readLeaf(l *leaf) interface{} {
+	for {
+		version, _ := readLockOrRestart(l)
+		val := l.value()
+		if validate(l, version) {
+			return val
+		}
+	}
+}
+
+func sortedChildren(n node) []node {
+	switch n := n.(type) {
+	case *node4:
+		count := min(n.childCount(), len(n.childPtr))
+		return sortByKey(unpackKeys(n.keys[:], count), n.childPtr[:count])
+	case *node16:
+		count := min(n.childCount(), len(n.childPtr))
+		return sortByKey(unpackKeys(n.keys[:], count), n.childPtr[:count])
+	case *node48:
+		children := make([]node, 0, n.childCount())
+		next := n.next()
+		for b := 0; b < 256; b++ {
+			if idx := n.index(byte(b)); idx != -1 {
+				if child := n.childPtr[idx].load(); child != nil {
+					children = append(children, child)
+				}
+			} else if next != nil {
+				if slot := next.findChild(byte(b)); slot != nil {
+					if child := slot.load(); child != nil {
+						children = append(children, child)
+					}
+				}
+			}
+		}
+		return children
+	case *node256:
+		var children []node
+		for b := 0; b < 256; b++ {
+			if child := n.childPtr[b].load(); child != nil {
+				children = append(children, child)
+			}
+		}
+		return children
+	case *nodeWide:
+		children := make([]node, 0, n.childCount())
+		n.each(func(_, _ byte, slot *slot) {
+			children = append(children, slot.load())
+		})
+		return children
+	}
+	return nil
+}
+
+// sortByKey copies children ordered by their key byte using insertion sort,
+// which is the fastest option for node4 and node16 sizes.
+func sortByKey(keys []uint8, ptrs []slot) []node {
+	k := make([]uint8, 0, len(keys))
+	children := make([]node, 0, len(ptrs))
+	for i := range keys {
+		child := ptrs[i].load()
+		if child == nil {
+			continue
+		}
+		j := len(k)
+		k = append(k, keys[i])
+		children = append(children, child)
+		for ; j > 0 && k[j-1] > keys[i]; j-- {
+			k[j] = k[j-1]
+			children[j] = children[j-1]
+		}
+		k[j] = keys[i]
+		children[j] = child
+	}
+	return children
+}
+
+// valueAs converts a stored value back to T, yielding the zero value for nil.
+func valueAs[T any](v interface{}) T {
+	val, _ := v.(T)
+	return val
+}
+
+// KeyRange is an inclusive range of integer keys, as reported by KeyRanges.
+type KeyRange struct {
+	Lo, Hi uint64
+}
+
+// KeyRanges returns the keys of the tree as integers, by encode, merged
+// into maximal runs of consecutive values in ascending order, so that a
+// sparse tree of integer keys reads as for example 1-1000 and 5000-5010.
+// Keys encoded big-endian, as fixed-length integer keys usually are, come
+// out of the walk already in order; other encodings are sorted first.
+// Keys that encode to the same integer count once.
+func (t *Tree[T]) KeyRanges(encode func(key []byte) uint64) []KeyRange {
+	var values []uint64
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		values = append(values, encode(l.key))
+		return true
+	})
+	if !slices.IsSorted(values) {
+		slices.Sort(values)
+	}
+	var ranges []KeyRange
+	for _, v := range values {
+		last := len(ranges) - 1
+		switch {
+		case last >= 0 && v <= ranges[last].Hi:
+			// Sorted, so a repeat of the last value
+		case last >= 0 && v == ranges[last].Hi+1:
+			ranges[last].Hi = v
+		default:
+			ranges = append(ranges, KeyRange{Lo: v, Hi: v})
+		}
+	}
+	return ranges
+}
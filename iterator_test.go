@@ -0,0 +1,417 @@
+package art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestForEachOrder(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"b", "a", "ab", "abc", "", "ba", "z", "key_10", "key_2", "key_1"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("n%d", i*7919%1000)
+		keys = append(keys, key)
+		tree.Insert([]byte(key), i)
+	}
+
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	var got []string
+	tree.ForEach(func(key []byte, _ int) bool {
+		got = append(got, string(key))
+		return true
+	})
+	if len(got) != len(sorted) {
+		t.Fatalf("Expected %d keys, got %d", len(sorted), len(got))
+	}
+	for i := range sorted {
+		if got[i] != sorted[i] {
+			t.Fatalf("Position %d: expected %q, got %q", i, sorted[i], got[i])
+		}
+	}
+}
+
+func TestScanPrefix(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"app", "apple", "application", "apply", "apt", "banana", "ap"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+
+	for prefix, expected := range map[string][]string{
+		"":        {"ap", "app", "apple", "application", "apply", "apt", "banana"},
+		"app":     {"app", "apple", "application", "apply"},
+		"appl":    {"apple", "application", "apply"},
+		"applic":  {"application"},
+		"b":       {"banana"},
+		"c":       nil,
+		"apples":  nil,
+		"applez":  nil,
+		"ap":      {"ap", "app", "apple", "application", "apply", "apt"},
+		"banana!": nil,
+	} {
+		var got []string
+		tree.ScanPrefix([]byte(prefix), func(key []byte, _ int) bool {
+			got = append(got, string(key))
+			return true
+		})
+		if fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("ScanPrefix(%q): expected %v, got %v", prefix, expected, got)
+		}
+	}
+}
+
+func TestLenPrefix(t *testing.T) {
+	tree := NewART[int]()
+	tenants := []string{"acme", "acme2", "globex", "initech", "umbrella"}
+	var all []string
+	for i, tenant := range tenants {
+		for j := 0; j < 10+i*37; j++ {
+			key := fmt.Sprintf("tenant/%s/obj/%d", tenant, j*7919)
+			tree.Insert([]byte(key), j)
+			all = append(all, key)
+		}
+	}
+
+	sum := 0
+	for _, tenant := range append(tenants, "none") {
+		prefix := "tenant/" + tenant + "/"
+		expected := 0
+		for _, key := range all {
+			if strings.HasPrefix(key, prefix) {
+				expected++
+			}
+		}
+		if n := tree.LenPrefix([]byte(prefix)); n != expected {
+			t.Errorf("LenPrefix(%q): expected %d, got %d", prefix, expected, n)
+		}
+		sum += tree.LenPrefix([]byte(prefix))
+	}
+	if sum != tree.Len() {
+		t.Errorf("Expected the tenants' counts to sum to Len %d, got %d", tree.Len(), sum)
+	}
+
+	// Without the trailing separator "acme" also matches "acme2", as in
+	// ScanPrefix
+	if n := tree.LenPrefix([]byte("tenant/acme")); n != 10+47 {
+		t.Errorf("Expected 'tenant/acme' to count both acme tenants, got %d", n)
+	}
+	if n := tree.LenPrefix(nil); n != tree.Len() {
+		t.Errorf("Expected the empty prefix to count all %d keys, got %d", tree.Len(), n)
+	}
+}
+
+func TestForEachNode(t *testing.T) {
+	tree := NewART[int]()
+	for i, key := range []string{"ab", "ac", "ad", "xy"} {
+		tree.Insert([]byte(key), i)
+	}
+
+	groups := map[string][]string{}
+	tree.ForEachNode(func(prefix []byte, leaves []KV[int]) bool {
+		for _, kv := range leaves {
+			if !bytes.HasPrefix(kv.Key, prefix) {
+				t.Errorf("Leaf %q does not start with node prefix %q", kv.Key, prefix)
+			}
+			groups[string(prefix)] = append(groups[string(prefix)], string(kv.Key))
+		}
+		return true
+	})
+
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %v", groups)
+	}
+	if fmt.Sprint(groups["a"]) != "[ab ac ad]" {
+		t.Errorf("Expected group 'a' to hold [ab ac ad], got %v", groups["a"])
+	}
+	if fmt.Sprint(groups[""]) != "[xy]" {
+		t.Errorf("Expected root group to hold [xy], got %v", groups[""])
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	tree := NewART[int]()
+	for i, key := range []string{"a/b/c", "a/b/d"} {
+		tree.Insert([]byte(key), i)
+	}
+	collect := func() map[string]int {
+		got := map[string]int{}
+		tree.Prefixes(func(prefix []byte, leafCount int) bool {
+			got[string(prefix)] = leafCount
+			return true
+		})
+		return got
+	}
+	// Every shared prefix is visited, although path compression stores
+	// them in one node
+	if got, want := collect(), map[string]int{"": 2, "a": 2, "a/": 2, "a/b": 2, "a/b/": 2}; !maps.Equal(got, want) {
+		t.Errorf("Prefixes = %v, want %v", got, want)
+	}
+	tree.Insert([]byte("a/x"), 2)
+	tree.Insert([]byte("q"), 3)
+	tree.Insert([]byte("a/b/cat"), 4)
+	want := map[string]int{"": 5, "a": 4, "a/": 4, "a/b": 3, "a/b/": 3, "a/b/c": 2}
+	if got := collect(); !maps.Equal(got, want) {
+		t.Errorf("Prefixes after splitting = %v, want %v", got, want)
+	}
+	visits := 0
+	tree.Prefixes(func([]byte, int) bool {
+		visits++
+		return false
+	})
+	if visits != 1 {
+		t.Errorf("Prefixes continued after fn returned false: %d visits", visits)
+	}
+}
+
+func TestScanPrefixes(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"tenant:a:x", "tenant:a:y", "tenant:ab:z", "tenant:b:x", "user:1", "user:2", "other"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+
+	result := tree.ScanPrefixes([][]byte{
+		[]byte("tenant:a"),
+		[]byte("tenant:"),
+		[]byte("user:"),
+		[]byte("tenant:a:"),
+		[]byte("missing"),
+		[]byte("user:"),
+	})
+
+	expected := map[string][]string{
+		"tenant:":   {"tenant:a:x", "tenant:a:y", "tenant:ab:z", "tenant:b:x"},
+		"tenant:a":  {"tenant:a:x", "tenant:a:y", "tenant:ab:z"},
+		"tenant:a:": {"tenant:a:x", "tenant:a:y"},
+		"user:":     {"user:1", "user:2"},
+	}
+	if len(result) != len(expected) {
+		t.Errorf("Expected %d groups, got %d: %v", len(expected), len(result), result)
+	}
+	for prefix, keys := range expected {
+		var got []string
+		for _, e := range result[prefix] {
+			got = append(got, string(e.Key))
+			if v, _ := tree.Search(e.Key); v != e.Value {
+				t.Errorf("Entry %q has value %d, tree holds %v", e.Key, e.Value, v)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(keys) {
+			t.Errorf("Prefix %q: expected %v, got %v", prefix, keys, got)
+		}
+	}
+}
+
+func TestMultiPrefixScan(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"a", "a1", "ab", "ab1", "abc", "b1", "c1"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+	prefixes := [][]byte{[]byte("ab"), []byte("a"), []byte("b"), []byte("ab"), []byte("x")}
+	expected := "a:a a:a1 ab:ab ab:ab1 ab:abc b:b1"
+
+	var got []string
+	tree.MultiPrefixScan(prefixes, func(prefix, key []byte, val int) bool {
+		got = append(got, string(prefix)+":"+string(key))
+		return true
+	})
+	if strings.Join(got, " ") != expected {
+		t.Errorf("MultiPrefixScan visited %v, expected %s", got, expected)
+	}
+
+	got = got[:0]
+	tree.MultiPrefixScan(prefixes, func(prefix, key []byte, val int) bool {
+		got = append(got, string(key))
+		return len(got) < 3
+	})
+	if len(got) != 3 {
+		t.Errorf("MultiPrefixScan went on after fn returned false: %v", got)
+	}
+
+	var mu sync.Mutex
+	got = got[:0]
+	tree.ParallelMultiPrefixScan(4, prefixes, func(prefix, key []byte, val int) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, string(prefix)+":"+string(key))
+	})
+	sort.Strings(got)
+	if strings.Join(got, " ") != expected {
+		t.Errorf("ParallelMultiPrefixScan visited %v, expected %s", got, expected)
+	}
+}
+
+func TestRangeOverFunc(t *testing.T) {
+	tree := NewART[int]()
+	for i := 0; i < 100; i++ {
+		tree.Insert([]byte(fmt.Sprintf("k%02d", i)), i)
+	}
+
+	expected := 0
+	for key, val := range tree.All() {
+		if val != expected || string(key) != fmt.Sprintf("k%02d", expected) {
+			t.Errorf("Expected k%02d=%d, got %s=%d", expected, expected, key, val)
+		}
+		expected++
+	}
+	if expected != 100 {
+		t.Errorf("Expected 100 entries, got %d", expected)
+	}
+
+	// The runtime panics if the traversal yields again after a break
+	visited := 0
+	for _, val := range tree.All() {
+		visited++
+		if val == 9 {
+			break
+		}
+	}
+	if visited != 10 {
+		t.Errorf("Expected traversal to stop after 10 entries, got %d", visited)
+	}
+
+	var got []int
+	for _, val := range tree.Range([]byte("k10"), []byte("k15")) {
+		got = append(got, val)
+	}
+	if len(got) != 5 || got[0] != 10 || got[4] != 14 {
+		t.Errorf("Expected values 10..14, got %v", got)
+	}
+	visited = 0
+	for range tree.Range([]byte("k90"), nil) {
+		visited++
+	}
+	if visited != 10 {
+		t.Errorf("Expected 10 entries in unbounded range, got %d", visited)
+	}
+
+	// Bounds that are not keys: between two keys, a prefix of keys, and
+	// longer than the keys around them
+	for _, c := range []struct {
+		start, end  string
+		first, last int
+	}{
+		{"k105", "k12", 11, 11},
+		{"k2", "k3", 20, 29},
+		{"k", "k02", 0, 1},
+		{"k99x", "", -1, -1},
+		{"", "k01", 0, 0},
+	} {
+		var end []byte
+		if c.end != "" {
+			end = []byte(c.end)
+		}
+		first, last := -1, -1
+		for _, val := range tree.Range([]byte(c.start), end) {
+			if first < 0 {
+				first = val
+			}
+			last = val
+		}
+		if first != c.first || last != c.last {
+			t.Errorf("Range(%q, %q) = %d..%d, want %d..%d", c.start, c.end, first, last, c.first, c.last)
+		}
+	}
+}
+
+func TestParallelForEach(t *testing.T) {
+	// Every key shares the root's only child, so the work is split below it
+	tree := NewART[int]()
+	for i := 0; i < 100000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("key:%x", i*2654435761)), i)
+	}
+
+	for _, workers := range []int{0, 1, 8, 1000} {
+		var visited atomic.Int64
+		var seen sync.Map
+		tree.ParallelForEach(workers, func(key []byte, val int) {
+			visited.Add(1)
+			if _, dup := seen.LoadOrStore(string(key), val); dup {
+				t.Errorf("Key '%s' visited twice with %d workers", key, workers)
+			}
+		})
+		if n := visited.Load(); n != 100000 {
+			t.Errorf("Expected 100000 visits with %d workers, got %d", workers, n)
+		}
+	}
+
+	var n atomic.Int64
+	NewART[int]().ParallelForEach(4, func([]byte, int) { n.Add(1) })
+	if n.Load() != 0 {
+		t.Errorf("Expected no visits on an empty tree, got %d", n.Load())
+	}
+}
+
+func TestScanPrefixN(t *testing.T) {
+	tree := NewART[int](WithMetrics())
+	for i := 0; i < 5000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("word%05d", i)), i)
+		tree.Insert([]byte(fmt.Sprintf("xray%05d", i)), i)
+	}
+	visits := 0
+	tree.Metrics().testHook = func(Operation, node, bool) { visits++ }
+
+	got := tree.ScanPrefixN([]byte("word"), 5)
+	if len(got) != 5 {
+		t.Fatalf("ScanPrefixN returned %d entries, want 5", len(got))
+	}
+	for i, e := range got {
+		if want := fmt.Sprintf("word%05d", i); string(e.Key) != want || e.Value != i {
+			t.Errorf("entry %d = %q:%d, want %q:%d", i, e.Key, e.Value, want, i)
+		}
+	}
+	partial := visits
+
+	visits = 0
+	if all := tree.ScanPrefixN([]byte("word"), 1<<30); len(all) != 5000 {
+		t.Fatalf("unbounded ScanPrefixN returned %d entries", len(all))
+	}
+	if partial*100 > visits {
+		t.Errorf("ScanPrefixN(5) visited %d nodes, the whole subtree is %d", partial, visits)
+	}
+	if got := tree.ScanPrefixN([]byte("zulu"), 5); len(got) != 0 {
+		t.Errorf("ScanPrefixN over an absent prefix returned %d entries", len(got))
+	}
+}
+
+func TestKeyRanges(t *testing.T) {
+	tree := NewFixedKeyART[struct{}](8)
+	for _, i := range []uint64{11, 3, 1, 10, 2} {
+		tree.Insert(binary.BigEndian.AppendUint64(nil, i), struct{}{})
+	}
+	got := tree.KeyRanges(binary.BigEndian.Uint64)
+	if fmt.Sprint(got) != "[{1 3} {10 11}]" {
+		t.Errorf("Expected ranges 1-3 and 10-11, got %v", got)
+	}
+
+	// Decimal strings do not sort numerically, and "07" and "7" are the
+	// same integer
+	decimal := NewART[int]()
+	for _, key := range []string{"9", "10", "8", "7", "07", "12", "18446744073709551615"} {
+		decimal.Insert([]byte(key), 0)
+	}
+	got = decimal.KeyRanges(func(key []byte) uint64 {
+		v, _ := strconv.ParseUint(string(key), 10, 64)
+		return v
+	})
+	if fmt.Sprint(got) != "[{7 10} {12 12} {18446744073709551615 18446744073709551615}]" {
+		t.Errorf("Expected ranges 7-10, 12 and the maximum, got %v", got)
+	}
+	if got := NewART[int]().KeyRanges(binary.BigEndian.Uint64); got != nil {
+		t.Errorf("Expected no ranges for an empty tree, got %v", got)
+	}
+}
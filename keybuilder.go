@@ -0,0 +1,44 @@
+package art
+
+import "strconv"
+
+// KeyBuilder assembles keys in a buffer reused across lookups, so hot loops
+// that build a key per Search allocate only while the buffer grows. The
+// zero value is ready to use. A KeyBuilder is not safe for concurrent use.
+type KeyBuilder struct {
+	buf []byte
+}
+
+// Reset empties the key, keeping the buffer.
+func (b *KeyBuilder) Reset() {
+	b.buf = b.buf[:0]
+}
+
+// AppendString appends the bytes of s.
+func (b *KeyBuilder) AppendString(s string) {
+	b.buf = append(b.buf, s...)
+}
+
+// AppendByte appends c.
+func (b *KeyBuilder) AppendByte(c byte) {
+	b.buf = append(b.buf, c)
+}
+
+// AppendInt appends i in decimal, as fmt's %d formats it.
+func (b *KeyBuilder) AppendInt(i int64) {
+	b.buf = strconv.AppendInt(b.buf, i, 10)
+}
+
+// Bytes returns the key built so far. The slice aliases the buffer and is
+// only valid until the next call that changes the builder; Search and
+// SearchBuilder do not retain it.
+func (b *KeyBuilder) Bytes() []byte {
+	return b.buf
+}
+
+// SearchBuilder returns the value stored under the key b holds, without
+// copying it.
+func (t *Tree[T]) SearchBuilder(b *KeyBuilder) (T, bool) {
+	val, found := t.Search(b.Bytes())
+	return valueAs[T](val), found
+}
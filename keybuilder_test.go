@@ -0,0 +1,45 @@
+package art
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKeyBuilder(t *testing.T) {
+	tree := NewART[int]()
+	for i := 0; i < 10000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("user:%d/profile", i)), i)
+	}
+
+	var kb KeyBuilder
+	kb.AppendString("user:")
+	kb.AppendInt(-42)
+	kb.AppendByte('/')
+	if got := string(kb.Bytes()); got != "user:-42/" {
+		t.Errorf("Expected 'user:-42/', got '%s'", got)
+	}
+
+	lookups := func() {
+		for i := 0; i < 10000; i++ {
+			kb.Reset()
+			kb.AppendString("user:")
+			kb.AppendInt(int64(i))
+			kb.AppendString("/profile")
+			if val, found := tree.SearchBuilder(&kb); !found || val != i {
+				t.Fatalf("Expected user:%d/profile=%d, got %d (found=%v)", i, i, val, found)
+			}
+		}
+	}
+	lookups()
+	// 10000 lookups per run, so a per-lookup allocation would show as
+	// thousands
+	if allocs := testing.AllocsPerRun(10, lookups); allocs > 1 {
+		t.Errorf("Expected O(1) allocations for 10000 lookups, got %.0f", allocs)
+	}
+
+	kb.Reset()
+	kb.AppendString("user:10000/profile")
+	if _, found := tree.SearchBuilder(&kb); found {
+		t.Error("Expected an absent key to miss")
+	}
+}
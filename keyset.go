@@ -0,0 +1,62 @@
+package art
+
+// Set is an immutable set of keys, taken from a tree by KeySetSnapshot.
+// It is safe for concurrent use.
+type Set struct {
+	root node
+	// published is set when root is the published root of a tree created
+	// with WithRCUReads, whose leaves never change but may hold tombstones
+	published bool
+	size      int
+	transform func(key []byte) []byte
+}
+
+// KeySetSnapshot returns the set of the tree's current keys, for callers
+// that need a consistent membership view but not the values. In a tree
+// created with WithRCUReads it shares the published root and costs O(1).
+// Other trees quiesce writers like Freeze but copy only the inner nodes:
+// the leaves are shared, since a leaf's key never changes, so the set
+// costs the memory of the inner nodes alone. Keys holding a tombstone are
+// not in the set.
+func (t *Tree[T]) KeySetSnapshot() *Set {
+	if t.rcu != nil {
+		t.rcu.mu.Lock()
+		defer t.rcu.mu.Unlock()
+		return &Set{root: t.rcu.root.Load().node, published: true, size: t.Len(), transform: t.transform}
+	}
+	resume := t.Quiesce()
+	defer resume()
+	return &Set{root: freezeNode(t.root(), true), size: t.Len(), transform: t.transform}
+}
+
+// Has reports whether key is in the set.
+func (s *Set) Has(key []byte) bool {
+	if s.transform != nil {
+		key = s.transform(key)
+	}
+	l := searchUnlocked(s.root, key)
+	return l != nil && !s.dead(l)
+}
+
+// Len returns the number of keys.
+func (s *Set) Len() int {
+	return s.size
+}
+
+// ForEach visits every key in ascending order until fn returns false. The
+// keys are shared with the tree and must not be modified.
+func (s *Set) ForEach(fn func(key []byte) bool) {
+	walkUnlocked(s.root, func(l *leaf) bool {
+		if s.dead(l) {
+			return true
+		}
+		return fn(l.key)
+	})
+}
+
+// dead reports whether l holds a tombstone. A copied set left tombstones
+// out, and its leaves are shared with the live tree, so only a published
+// root's leaves are read.
+func (s *Set) dead(l *leaf) bool {
+	return s.published && holdsTombstone(l)
+}
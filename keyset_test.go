@@ -0,0 +1,58 @@
+package art
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestKeySetSnapshot(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"rcu", []Option{WithRCUReads()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewART[int](tc.opts...)
+			var before []string
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("key/%03d", i)
+				tree.Insert([]byte(key), i)
+				before = append(before, key)
+			}
+			set := tree.KeySetSnapshot()
+
+			// A burst of writes reshaping the tree leaves the set as it was
+			for i := 0; i < 500; i += 2 {
+				tree.Delete([]byte(fmt.Sprintf("key/%03d", i)))
+				tree.Insert([]byte(fmt.Sprintf("key/%03d/new", i+1)), i)
+				tree.Insert([]byte(fmt.Sprintf("key/%03d", i+1)), -i)
+			}
+			tree.Insert([]byte("other"), 0)
+
+			if set.Len() != len(before) {
+				t.Errorf("Len() = %d, want %d", set.Len(), len(before))
+			}
+			for _, key := range before {
+				if !set.Has([]byte(key)) {
+					t.Fatalf("Has(%q) = false", key)
+				}
+			}
+			for _, key := range []string{"key/001/new", "other", "key/", "key/500"} {
+				if set.Has([]byte(key)) {
+					t.Errorf("Has(%q) = true for a key added after the snapshot or never present", key)
+				}
+			}
+			var got []string
+			set.ForEach(func(key []byte) bool {
+				got = append(got, string(key))
+				return true
+			})
+			if !slices.Equal(got, before) {
+				t.Errorf("ForEach visited %d keys, want the %d present before the burst", len(got), len(before))
+			}
+		})
+	}
+}
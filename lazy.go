@@ -0,0 +1,33 @@
+package art
+
+import "sync"
+
+// lazyValue is the value of a leaf stored with InsertLazy. The loader runs
+// on the first read and its result replaces it.
+type lazyValue struct {
+	once sync.Once
+	load func() interface{}
+	val  interface{}
+}
+
+func (z *lazyValue) get() interface{} {
+	z.once.Do(func() {
+		z.val = z.load()
+		z.load = nil
+	})
+	return z.val
+}
+
+// InsertLazy stores loader under key in place of a value. The first read of
+// the key, by Search or any other lookup or iteration, calls loader and
+// keeps its result as the key's value; readers racing on that first read
+// wait for the one call rather than making their own. A loader that is
+// never read never runs: Insert and Delete drop it unread, though updates
+// that read the old value, and WithVersionHistory, run it. The result is
+// not checked against WithValueType.
+func (t *Tree[T]) InsertLazy(key []byte, loader func() T) {
+	if t.checkKey(key) != nil {
+		return
+	}
+	t.upsert(key, &lazyValue{load: func() interface{} { return loader() }}, nil)
+}
@@ -0,0 +1,79 @@
+package art
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInsertLazyLoadsOnce(t *testing.T) {
+	tree := NewART[string]()
+	var calls atomic.Int32
+	tree.InsertLazy([]byte("report/2024"), func() string {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "expensive"
+	})
+	tree.Insert([]byte("report/2023"), "cheap")
+	if calls.Load() != 0 {
+		t.Fatalf("loader ran before the key was read")
+	}
+
+	var wg sync.WaitGroup
+	vals := make([]interface{}, 50)
+	for i := range vals {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vals[i], _ = tree.Search([]byte("report/2024"))
+		}(i)
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader ran %d times, want 1", n)
+	}
+	for i, v := range vals {
+		if v != "expensive" {
+			t.Errorf("Search %d = %v", i, v)
+		}
+	}
+
+	tree.InsertLazy([]byte("report/2025"), func() string {
+		t.Error("overwritten loader ran")
+		return ""
+	})
+	tree.Insert([]byte("report/2025"), "eager")
+	if v, _ := tree.Search([]byte("report/2025")); v != "eager" {
+		t.Errorf("Search after overwrite = %v", v)
+	}
+}
+
+func TestInsertLazyOverwriteStaysLazy(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"locked", nil},
+		{"rcu", []Option{WithRCUReads()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewART[int](tc.opts...)
+			tree.Insert([]byte("k"), 1)
+			var calls atomic.Int32
+			tree.InsertLazy([]byte("k"), func() int {
+				calls.Add(1)
+				return 2
+			})
+			if n := calls.Load(); n != 0 {
+				t.Fatalf("loader ran %d times before any read", n)
+			}
+			if v, ok := tree.Search([]byte("k")); !ok || v != 2 {
+				t.Errorf("Search = %v, %v", v, ok)
+			}
+			if n := calls.Load(); n != 1 {
+				t.Errorf("loader ran %d times, want 1", n)
+			}
+		})
+	}
+}
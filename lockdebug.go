@@ -0,0 +1,63 @@
+//go:build artdebug
+
+package art
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lockedSince maps every write-locked node to the time its lock was taken.
+var lockedSince sync.Map
+
+func lockAcquired(n node) {
+	lockedSince.Store(n, time.Now())
+}
+
+func lockReleased(n node) {
+	lockedSince.Delete(n)
+}
+
+// LockedNodes describes every node of the tree currently holding its write
+// lock and for how long, longest-held first. Only available in builds with
+// the artdebug tag.
+func (t *Tree[T]) LockedNodes() []string {
+	type held struct {
+		n     node
+		since time.Time
+	}
+	all := make(map[node]time.Time)
+	lockedSince.Range(func(k, v any) bool {
+		all[k.(node)] = v.(time.Time)
+		return true
+	})
+	var locked []held
+	if len(all) != 0 {
+		// The lock helpers do not know a node's tree, so keep the nodes
+		// reachable from t's root. The walk cannot validate, since the
+		// nodes it is looking for are locked.
+		defer t.epochs.unpin(t.epochs.pin())
+		var visit func(n node)
+		visit = func(n node) {
+			if since, ok := all[n]; ok {
+				locked = append(locked, held{n: n, since: since})
+			}
+			if n.getType() != nodeTypeLeaf {
+				for _, child := range sortedChildren(n) {
+					visit(child)
+				}
+			}
+		}
+		visit(t.root())
+	}
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].since.Before(locked[j].since)
+	})
+	report := make([]string, len(locked))
+	for i, h := range locked {
+		report[i] = fmt.Sprintf("%s %p locked for %s", h.n.getType(), h.n, time.Since(h.since))
+	}
+	return report
+}
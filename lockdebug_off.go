@@ -0,0 +1,7 @@
+//go:build !artdebug
+
+package art
+
+// Lock tracking is compiled out unless built with the artdebug tag.
+func lockAcquired(n node) {}
+func lockReleased(n node) {}
@@ -0,0 +1,46 @@
+//go:build artdebug
+
+package art
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockedNodes(t *testing.T) {
+	tree := NewART[int]()
+	for i := 0; i < 100; i++ {
+		tree.Insert([]byte(fmt.Sprintf("key%d", i)), i)
+	}
+	if locked := tree.LockedNodes(); len(locked) != 0 {
+		t.Fatalf("Expected no locked nodes when idle, got %v", locked)
+	}
+
+	other := NewART[int]()
+	other.Insert([]byte("key"), 0)
+	otherRoot := other.node.load()
+	if writeLockOrRestart(otherRoot) {
+		t.Fatal("Failed to lock the other tree's root")
+	}
+	defer writeUnlock(otherRoot)
+
+	root := tree.node.load()
+	if writeLockOrRestart(root) {
+		t.Fatal("Failed to lock root")
+	}
+	time.Sleep(5 * time.Millisecond)
+	locked := tree.LockedNodes()
+	writeUnlock(root)
+
+	if len(locked) != 1 || !strings.Contains(locked[0], fmt.Sprintf("%p", root)) {
+		t.Fatalf("Expected only the held root lock to be reported, got %v", locked)
+	}
+	if !strings.HasPrefix(locked[0], root.getType().String()) {
+		t.Errorf("Expected report to name the node type, got %q", locked[0])
+	}
+	if locked := tree.LockedNodes(); len(locked) != 0 {
+		t.Errorf("Expected no locked nodes after unlock, got %v", locked)
+	}
+}
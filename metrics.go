@@ -0,0 +1,167 @@
+package art
+
+import "sync/atomic"
+
+// Operation identifies the tree operation that restarted.
+type Operation int
+
+const (
+	OperationInsert Operation = iota
+	OperationSearch
+	OperationDelete
+	numOperations
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OperationInsert:
+		return "insert"
+	case OperationSearch:
+		return "search"
+	case OperationDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// RestartCause identifies which OLC check sent an operation back to the root.
+type RestartCause int
+
+const (
+	// CauseReadLock: the node was obsolete when its version was read
+	CauseReadLock RestartCause = iota
+	// CauseParentValidation: the parent changed after the child was read
+	CauseParentValidation
+	// CauseNodeValidation: the node changed while it was being read
+	CauseNodeValidation
+	// CausePrefixRace: the node changed while its prefix was being compared
+	CausePrefixRace
+	// CauseUpgradeLock: a writer failed to upgrade its read to a write lock
+	CauseUpgradeLock
+	numRestartCauses
+)
+
+func (c RestartCause) String() string {
+	switch c {
+	case CauseReadLock:
+		return "read-lock"
+	case CauseParentValidation:
+		return "parent-validation"
+	case CauseNodeValidation:
+		return "node-validation"
+	case CausePrefixRace:
+		return "prefix-race"
+	case CauseUpgradeLock:
+		return "upgrade-lock"
+	}
+	return "unknown"
+}
+
+// Metrics counts OLC restarts by operation and cause. A nil *Metrics is
+// valid and counts nothing.
+type Metrics struct {
+	restarts [numOperations][numRestartCauses]atomic.Uint64
+	// testHook runs before and after each node's version is read during a
+	// descent, letting tests simulate concurrent writers deterministically
+	testHook func(op Operation, n node, afterRead bool)
+}
+
+// Metrics returns the tree's restart counters, or nil unless the tree was
+// created with WithMetrics.
+func (t *Tree[T]) Metrics() *Metrics {
+	return t.metrics
+}
+
+// Restarts returns the total number of restarts of op.
+func (m *Metrics) Restarts(op Operation) uint64 {
+	if m == nil {
+		return 0
+	}
+	var total uint64
+	for cause := range m.restarts[op] {
+		total += m.restarts[op][cause].Load()
+	}
+	return total
+}
+
+// ByCause returns the restart counts of every operation broken down by cause.
+func (m *Metrics) ByCause() map[Operation]map[RestartCause]uint64 {
+	breakdown := make(map[Operation]map[RestartCause]uint64, numOperations)
+	for op := Operation(0); op < numOperations; op++ {
+		breakdown[op] = make(map[RestartCause]uint64, numRestartCauses)
+		for cause := RestartCause(0); cause < numRestartCauses; cause++ {
+			var count uint64
+			if m != nil {
+				count = m.restarts[op][cause].Load()
+			}
+			breakdown[op][cause] = count
+		}
+	}
+	return breakdown
+}
+
+func (m *Metrics) restart(op Operation, cause RestartCause) {
+	if m == nil {
+		return
+	}
+	m.restarts[op][cause].Add(1)
+}
+
+func (m *Metrics) hook(op Operation, n node, afterRead bool) {
+	if m == nil || m.testHook == nil {
+		return
+	}
+	m.testHook(op, n, afterRead)
+}
+
+// MissReason identifies where a lookup traced with SearchCtx concluded the
+// key was absent.
+type MissReason int
+
+const (
+	// MissNone: the key was found
+	MissNone MissReason = iota
+	// MissPrefix: a node's prefix diverged from the key
+	MissPrefix
+	// MissNoChild: a node had no child for the key's next byte
+	MissNoChild
+	// MissLeafKey: the descent ended at a leaf holding another key
+	MissLeafKey
+	// MissSummary: a node summary ruled the key out
+	MissSummary
+	// MissUntraced: the tree uses WithRCUReads, whose lookups record no
+	// reason
+	MissUntraced
+)
+
+func (r MissReason) String() string {
+	switch r {
+	case MissNone:
+		return "none"
+	case MissPrefix:
+		return "prefix"
+	case MissNoChild:
+		return "no-child"
+	case MissLeafKey:
+		return "leaf-key"
+	case MissSummary:
+		return "summary"
+	case MissUntraced:
+		return "untraced"
+	}
+	return "unknown"
+}
+
+// SearchResult describes how one SearchCtx lookup went.
+type SearchResult struct {
+	// Restarts counts the times the descent went back to the root
+	Restarts int
+	// Miss is why the key was reported absent, MissNone if it was found
+	Miss MissReason
+}
+
+func (r *SearchResult) miss(reason MissReason) {
+	if r != nil {
+		r.Miss = reason
+	}
+}
@@ -0,0 +1,293 @@
+package art
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// onceHook returns a test hook that runs fn the first time op reaches the
+// node at the root's child slot for b, before or after its version is read.
+func onceHook[T any](tree *Tree[T], op Operation, b byte, afterRead bool, fn func()) func(Operation, node, bool) {
+	done := false
+	return func(o Operation, n node, after bool) {
+		if done || o != op || after != afterRead {
+			return
+		}
+		if child := tree.node.load().findChild(b); child == nil || child.load() != n {
+			return
+		}
+		done = true
+		fn()
+	}
+}
+
+func TestMetricsRestartCauses(t *testing.T) {
+	tree := NewART[int](WithMetrics())
+	tree.Insert([]byte("abc1"), 1)
+	tree.Insert([]byte("abc2"), 2)
+	m := tree.Metrics()
+
+	expectRestart := func(op Operation, cause RestartCause, action func()) {
+		t.Helper()
+		before := m.ByCause()[op][cause]
+		action()
+		m.testHook = nil
+		if after := m.ByCause()[op][cause]; after <= before {
+			t.Errorf("Expected %s restart caused by %s, counters: %v", op, cause, m.ByCause()[op])
+		}
+	}
+	bump := func(n func() node) func() {
+		// A lock and unlock pair, as a concurrent writer would leave it
+		return func() { n().version().Add(2 * LOCK_INCREMENT) }
+	}
+	child := func() node { return tree.node.load().findChild('a').load() }
+	root := func() node { return tree.node.load() }
+
+	// A concurrent grow makes the child obsolete before it is read
+	m.testHook = onceHook(tree, OperationSearch, 'a', false, func() {
+		for _, k := range []string{"abc3", "abc4", "abc5"} {
+			tree.Insert([]byte(k), 0)
+		}
+	})
+	expectRestart(OperationSearch, CauseReadLock, func() { tree.Search([]byte("abc1")) })
+
+	// A write to the root lands between reading it and reading the child
+	m.testHook = onceHook(tree, OperationSearch, 'a', false, bump(root))
+	expectRestart(OperationSearch, CauseParentValidation, func() { tree.Search([]byte("abc1")) })
+
+	// The child changes while a mismatching prefix is compared
+	m.testHook = onceHook(tree, OperationSearch, 'a', true, bump(child))
+	expectRestart(OperationSearch, CausePrefixRace, func() { tree.Search([]byte("axx")) })
+
+	// The child changes while its child slot is looked up
+	m.testHook = onceHook(tree, OperationSearch, 'a', true, bump(child))
+	expectRestart(OperationSearch, CauseNodeValidation, func() { tree.Search([]byte("abc1")) })
+
+	// The root changes before an insert upgrades its lock
+	m.testHook = onceHook(tree, OperationInsert, 'a', true, bump(root))
+	expectRestart(OperationInsert, CauseUpgradeLock, func() { tree.Insert([]byte("abg"), 0) })
+
+	// The root changes between reading it and reading the child
+	m.testHook = onceHook(tree, OperationDelete, 'a', false, bump(root))
+	expectRestart(OperationDelete, CauseParentValidation, func() { tree.Delete([]byte("abg")) })
+
+	for _, key := range []string{"abc1", "abc2", "abc3", "abc4", "abc5"} {
+		if _, found := tree.Search([]byte(key)); !found {
+			t.Errorf("Key '%s' lost during forced restarts", key)
+		}
+	}
+	if _, found := tree.Search([]byte("abg")); found {
+		t.Error("Expected 'abg' to be deleted after its restart")
+	}
+}
+
+func TestMetricsContended(t *testing.T) {
+	tree := NewART[int](WithMetrics())
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 5000; i++ {
+				key := []byte(fmt.Sprintf("k%d", i%200))
+				tree.Insert(key, id)
+				tree.Search(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var total uint64
+	for op, causes := range tree.Metrics().ByCause() {
+		var sum uint64
+		for _, count := range causes {
+			sum += count
+		}
+		if sum != tree.Metrics().Restarts(op) {
+			t.Errorf("%s: breakdown sums to %d, Restarts reports %d", op, sum, tree.Metrics().Restarts(op))
+		}
+		total += sum
+	}
+	t.Logf("Restarts under contention: %d %v", total, tree.Metrics().ByCause())
+
+	if NewART[int]().Metrics() != nil {
+		t.Error("Metrics must be disabled by default")
+	}
+}
+
+// TestSearchCtxNoFalseMisses has writers repeatedly grow and shrink the
+// nodes holding a fixed set of keys while readers look those keys up; every
+// miss is a bug, and its reason says where the descent went wrong.
+func TestSearchCtxNoFalseMisses(t *testing.T) {
+	tree := NewART[int]()
+	var stable [][]byte
+	for b := byte('a'); b <= 'p'; b++ {
+		for _, c := range []byte("AB") {
+			k := []byte{b, c}
+			tree.Insert(k, int(b))
+			stable = append(stable, k)
+		}
+	}
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for b := byte('a'); b <= 'p'; b++ {
+		writers.Add(1)
+		go func(b byte) {
+			defer writers.Done()
+			for round := 0; round < 200; round++ {
+				for c := byte('C'); c <= 'z'; c++ {
+					tree.Insert([]byte{b, c}, round)
+				}
+				for c := byte('C'); c <= 'z'; c++ {
+					tree.Delete([]byte{b, c})
+				}
+			}
+		}(b)
+	}
+	var mu sync.Mutex
+	reasons := map[MissReason]int{}
+	restarts := 0
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var res SearchResult
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, k := range stable {
+					v, ok := tree.SearchCtx(k, &res)
+					mu.Lock()
+					restarts += res.Restarts
+					if !ok {
+						reasons[res.Miss]++
+					} else if v != int(k[0]) {
+						t.Errorf("SearchCtx(%q) = %d", k, v)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+	if len(reasons) != 0 {
+		t.Fatalf("stable keys missed: %v", reasons)
+	}
+	t.Logf("restarts: %d", restarts)
+}
+
+func TestSearchCtxMissReasons(t *testing.T) {
+	tree := NewART[int]()
+	for _, k := range []string{"abc1", "abc2", "xyz"} {
+		tree.Insert([]byte(k), 1)
+	}
+	cases := []struct {
+		key  string
+		want MissReason
+	}{
+		{"abc1", MissNone},
+		{"abd1", MissPrefix},
+		{"abc3", MissNoChild},
+		{"xyw", MissLeafKey},
+	}
+	var res SearchResult
+	for _, c := range cases {
+		tree.SearchCtx([]byte(c.key), &res)
+		if res.Miss != c.want || res.Restarts != 0 {
+			t.Errorf("SearchCtx(%q) = %+v, want miss %v", c.key, res, c.want)
+		}
+	}
+}
+
+// disjointTree returns a tree whose keys fall into two subtrees, a/ and b/,
+// each split into groups deep enough that growing a group's node changes
+// neither the subtree's top node nor the root.
+func disjointTree() *Tree[int] {
+	tree := NewART[int](WithMetrics())
+	for _, top := range []string{"a", "b"} {
+		for g := 0; g < 4; g++ {
+			for k := 0; k < 3; k++ {
+				tree.Insert([]byte(fmt.Sprintf("%s/grp%d/k%d", top, g, k)), k)
+			}
+		}
+	}
+	return tree
+}
+
+// TestDisjointWriterNoReaderRestarts interleaves a writer growing and
+// splitting nodes under b/ with every step of a reader's descent under a/;
+// OLC only invalidates the path the writer changed, so the reader never
+// restarts.
+func TestDisjointWriterNoReaderRestarts(t *testing.T) {
+	tree := disjointTree()
+	m := tree.Metrics()
+	writes := 0
+	m.testHook = func(op Operation, n node, afterRead bool) {
+		if op != OperationSearch {
+			return
+		}
+		// grow b/grp1's node4 and split its keys' prefixes
+		tree.Insert([]byte(fmt.Sprintf("b/grp1/new%d", writes)), writes)
+		tree.Insert([]byte(fmt.Sprintf("b/grp2/k0/%d", writes)), writes)
+		writes++
+	}
+	for g := 0; g < 4; g++ {
+		for k := 0; k < 3; k++ {
+			if _, found := tree.Search([]byte(fmt.Sprintf("a/grp%d/k%d", g, k))); !found {
+				t.Fatalf("a/grp%d/k%d not found", g, k)
+			}
+		}
+	}
+	m.testHook = nil
+	if writes == 0 {
+		t.Fatal("the writer never ran")
+	}
+	if restarts := m.Restarts(OperationSearch); restarts != 0 {
+		t.Errorf("readers of a/ restarted %d times: %v", restarts, m.ByCause()[OperationSearch])
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// BenchmarkDisjointSubtreeRestarts reads keys under a/ while a writer
+// inserts under b/, reporting and requiring zero reader restarts.
+func BenchmarkDisjointSubtreeRestarts(b *testing.B) {
+	tree := disjointTree()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			tree.Insert([]byte(fmt.Sprintf("b/grp%d/w%d", i%4, i)), i)
+		}
+	}()
+	keys := make([][]byte, 12)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("a/grp%d/k%d", i/3, i%3))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Search(keys[i%len(keys)])
+	}
+	b.StopTimer()
+	close(stop)
+	<-done
+	restarts := tree.Metrics().Restarts(OperationSearch)
+	b.ReportMetric(float64(restarts), "restarts")
+	if restarts != 0 {
+		b.Fatalf("readers of a/ restarted %d times: %v", restarts, tree.Metrics().ByCause()[OperationSearch])
+	}
+}
@@ -0,0 +1,142 @@
+package art
+
+import (
+	"bytes"
+	"slices"
+)
+
+// KNearest returns the n keys nearest to key, with their values, in
+// ascending key order. Nearness is by position: key itself, if present,
+// comes first, then the window grows outward from where key sorts,
+// alternately taking the next smaller and the next larger key, smaller
+// first. Near either end of the tree, once one side runs out the window
+// extends further into the other. Aliases are not followed.
+func (t *Tree[T]) KNearest(key []byte, n int) []KV[T] {
+	if n <= 0 {
+		return nil
+	}
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	// No more than n keys are needed on either side
+	var above, below []*leaf
+	defer t.epochs.unpin(t.epochs.pin())
+	walkFrom(t.root(), nil, key, func(l *leaf) bool {
+		above = append(above, l)
+		return len(above) < n
+	})
+	walkBefore(t.root(), nil, key, func(l *leaf) bool {
+		below = append(below, l)
+		return len(below) < n
+	})
+
+	var window []*leaf
+	if len(above) > 0 && bytes.Equal(above[0].key, key) {
+		window, above = append(window, above[0]), above[1:]
+	}
+	for len(window) < n && (len(below) > 0 || len(above) > 0) {
+		if len(below) > 0 {
+			window, below = append(window, below[0]), below[1:]
+		}
+		if len(window) < n && len(above) > 0 {
+			window, above = append(window, above[0]), above[1:]
+		}
+	}
+	slices.SortFunc(window, func(a, b *leaf) int {
+		return bytes.Compare(a.key, b.key)
+	})
+	entries := make([]KV[T], len(window))
+	for i, l := range window {
+		entries[i] = KV[T]{Key: l.key, Value: valueAs[T](readLeaf(l))}
+	}
+	return entries
+}
+
+// walkBefore is walkFrom in descending order: it visits the leaves whose
+// keys are below to, largest first, skipping without reading the subtrees
+// whose path orders them entirely at or after to.
+func walkBefore(n node, path, to []byte, fn func(l *leaf) bool) bool {
+	if n == nil {
+		return true
+	}
+	if l, ok := n.(*leaf); ok {
+		if bytes.Compare(l.key, to) >= 0 || isTombstone(l) {
+			return true
+		}
+		return fn(l)
+	}
+	prefix, children := readNode(n)
+	path = append(path[:len(path):len(path)], prefix...)
+	if !bytes.HasPrefix(to, path) && bytes.Compare(path, to) > 0 {
+		// Every key below starts with path, so they all sort after to
+		return true
+	}
+	for i := len(children) - 1; i >= 0; i-- {
+		if !walkBefore(children[i], path, to, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Siblings visits, in ascending key order, the leaves other than key's
+// that hang directly off the node key's leaf hangs off, until fn returns
+// false. Since paths are compressed, these are the keys that agree with key
+// up to that node and differ from it in the byte that picks its child, or
+// in the two bytes of a WithWideStride node. Of "ca", "can", "car", "cart"
+// and "cat", Siblings("cat") visits "ca" and "can" but not "car", which
+// sits with "cart" below a node of its own. If key is absent, Siblings
+// visits the leaves it would have as siblings once inserted: the leaves of
+// the node it would be added to, the single leaf it would split off into a
+// new node with, or none if it would split a node's prefix. Subtrees are
+// not visited, and aliases are not followed.
+func (t *Tree[T]) Siblings(key []byte, fn func(key []byte, val T) bool) {
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	var path []byte
+	defer t.epochs.unpin(t.epochs.pin())
+	n := t.root()
+	for {
+		var prefix []byte
+		var children []node
+		var next node
+		for {
+			version, _ := readLockOrRestart(n)
+			prefix = append(prefix[:0], n.getPrefix()...)
+			children = sortedChildren(n)
+			next = nil
+			if slot := findChild(n, key, len(path)+len(prefix)); slot != nil {
+				next = slot.load()
+			}
+			if validate(n, version) {
+				break
+			}
+		}
+		path = append(path, prefix...)
+		if !bytes.HasPrefix(key, path) {
+			// An insert would split this prefix, leaving key alone with
+			// this node in a new one
+			return
+		}
+		l, isLeaf := next.(*leaf)
+		if next != nil && !isLeaf {
+			n = next
+			continue
+		}
+		if isLeaf && !bytes.Equal(l.key, key) {
+			// An insert would pair key with this leaf in a new node
+			children = []node{l}
+		}
+		for _, child := range children {
+			sibling, ok := child.(*leaf)
+			if !ok || bytes.Equal(sibling.key, key) || isTombstone(sibling) {
+				continue
+			}
+			if !fn(sibling.key, valueAs[T](readLeaf(sibling))) {
+				return
+			}
+		}
+		return
+	}
+}
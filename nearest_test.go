@@ -0,0 +1,101 @@
+package art
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestKNearest(t *testing.T) {
+	tree := NewART[int]()
+	for i := 10; i < 100; i += 2 {
+		tree.Insert([]byte(fmt.Sprintf("key%03d", i)), i)
+	}
+	values := func(kvs []KV[int]) []int {
+		var got []int
+		for _, kv := range kvs {
+			if kv.Key == nil || string(kv.Key) != fmt.Sprintf("key%03d", kv.Value) {
+				t.Errorf("entry %q holds %d", kv.Key, kv.Value)
+			}
+			got = append(got, kv.Value)
+		}
+		return got
+	}
+	for _, tc := range []struct {
+		target string
+		n      int
+		want   []int
+	}{
+		// A present key and the window around it
+		{"key050", 5, []int{46, 48, 50, 52, 54}},
+		// An absent key between two present ones; the smaller side comes first
+		{"key051", 4, []int{48, 50, 52, 54}},
+		{"key051", 3, []int{48, 50, 52}},
+		// Near the ends the window runs out on one side
+		{"key012", 4, []int{10, 12, 14, 16}},
+		{"key000", 3, []int{10, 12, 14}},
+		{"key099", 3, []int{94, 96, 98}},
+		{"key050", 100, nil},
+		{"key050", 0, nil},
+	} {
+		got := values(tree.KNearest([]byte(tc.target), tc.n))
+		if tc.n == 100 {
+			if len(got) != tree.Len() || !slices.IsSorted(got) {
+				t.Errorf("KNearest(%q, %d) returned %d keys, want all %d in order", tc.target, tc.n, len(got), tree.Len())
+			}
+			continue
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("KNearest(%q, %d) = %v, want %v", tc.target, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestSiblings(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithRCUReads()}} {
+		tree := NewART[int](opts...)
+		for i, key := range []string{"cat", "car", "can", "ca", "cart", "dog"} {
+			tree.Insert([]byte(key), i)
+		}
+		siblings := func(key string) []string {
+			var keys []string
+			tree.Siblings([]byte(key), func(key []byte, val int) bool {
+				keys = append(keys, string(key))
+				return true
+			})
+			return keys
+		}
+		if got := siblings("cat"); !slices.Equal(got, []string{"ca", "can"}) {
+			t.Errorf("Expected the leaf siblings of cat to be [ca can], got %q", got)
+		}
+		if got := siblings("cart"); !slices.Equal(got, []string{"car"}) {
+			t.Errorf("Expected the leaf siblings of cart to be [car], got %q", got)
+		}
+		tree.Delete([]byte("ca"))
+		tree.Delete([]byte("cart"))
+		if got := siblings("cat"); !slices.Equal(got, []string{"can", "car"}) {
+			t.Errorf("Expected siblings [can car], got %q", got)
+		}
+		// An absent key gets the siblings it would have
+		if got := siblings("cap"); !slices.Equal(got, []string{"can", "car", "cat"}) {
+			t.Errorf("Expected cap to have siblings [can car cat], got %q", got)
+		}
+		if got := siblings("dot"); !slices.Equal(got, []string{"dog"}) {
+			t.Errorf("Expected dot to pair with [dog], got %q", got)
+		}
+		if got := siblings("egg"); !slices.Equal(got, []string{"dog"}) {
+			t.Errorf("Expected egg to join the root's leaf [dog], got %q", got)
+		}
+		if got := siblings("cow"); got != nil {
+			t.Errorf("Expected no leaf siblings for cow, got %q", got)
+		}
+		var first []string
+		tree.Siblings([]byte("cat"), func(key []byte, _ int) bool {
+			first = append(first, string(key))
+			return false
+		})
+		if len(first) != 1 {
+			t.Errorf("Expected Siblings to stop when fn returns false, got %q", first)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package art
+
+// StructureEventKind identifies the structural change a StructureEvent
+// reports.
+type StructureEventKind int
+
+const (
+	// NodeGrew: a full node was replaced by the next larger type holding
+	// the new child
+	NodeGrew StructureEventKind = iota
+	// NodeSplit: a leaf or a node prefix was split under a new node4
+	NodeSplit
+	// ChildAdded: a child was added to a node with room for it
+	ChildAdded
+)
+
+func (k StructureEventKind) String() string {
+	switch k {
+	case NodeGrew:
+		return "grew"
+	case NodeSplit:
+		return "split"
+	case ChildAdded:
+		return "child-added"
+	}
+	return "unknown"
+}
+
+// StructureEvent describes one structural change made by an insert.
+type StructureEvent struct {
+	Kind StructureEventKind
+	// Key is the inserted key that caused the change
+	Key []byte
+	// Depth is the number of key bytes above the changed node
+	Depth int
+	// OldType and NewType are the node's types before and after a grow; both
+	// are the node's type for other events
+	OldType, NewType nodeType
+	// Prefix is the prefix of the grown node or of the node4 a split
+	// created, and nil for ChildAdded
+	Prefix []byte
+}
+
+// observe reports a structural change to the tree's observer, if any. It is
+// called once the change is committed and its locks are released.
+func (t *Tree[T]) observe(kind StructureEventKind, key []byte, depth int, oldType, newType nodeType, prefix []byte) {
+	if t.observer == nil {
+		return
+	}
+	t.observer(StructureEvent{
+		Kind:    kind,
+		Key:     key,
+		Depth:   depth,
+		OldType: oldType,
+		NewType: newType,
+		Prefix:  prefix,
+	})
+}
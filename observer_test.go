@@ -0,0 +1,43 @@
+package art
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStructureObserverGrowSequence(t *testing.T) {
+	var events []StructureEvent
+	tree := NewART[int](WithStructureObserver(func(event StructureEvent) {
+		events = append(events, event)
+	}))
+	for i := 0; i < 17; i++ {
+		tree.Insert([]byte{'k', byte('a' + i)}, i)
+	}
+
+	var grows []string
+	counts := map[StructureEventKind]int{}
+	for _, event := range events {
+		counts[event.Kind]++
+		if event.Kind == NodeGrew {
+			grows = append(grows, fmt.Sprintf("%s->%s@%q", event.OldType, event.NewType, event.Prefix))
+			if event.Depth != 0 {
+				t.Errorf("Expected the grown node at depth 0, got %d", event.Depth)
+			}
+		}
+	}
+	expected := []string{
+		fmt.Sprintf("%s->%s@%q", nodeType4, nodeType16, "k"),
+		fmt.Sprintf("%s->%s@%q", nodeType16, nodeType48, "k"),
+	}
+	if fmt.Sprint(grows) != fmt.Sprint(expected) {
+		t.Errorf("Expected grows %v, got %v", expected, grows)
+	}
+	// "ka" lands in the root, "kb" splits it off under a node4, and all
+	// other keys but the two that grow the node are plain additions
+	if counts[NodeSplit] != 1 || counts[ChildAdded] != 14 || len(events) != 17 {
+		t.Errorf("Expected 1 split and 14 additions in 17 events, got %v", counts)
+	}
+	if events[1].Kind != NodeSplit || string(events[1].Prefix) != "k" || string(events[1].Key) != "kb" {
+		t.Errorf("Expected 'kb' to split under prefix 'k', got %+v", events[1])
+	}
+}
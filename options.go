@@ -0,0 +1,273 @@
+package art
+
+import (
+	"io"
+	"reflect"
+	"time"
+)
+
+// Option configures a Tree at construction time.
+type Option func(*config)
+
+type config struct {
+	traceWriter       io.Writer
+	valueType         reflect.Type
+	historyLen        int
+	metrics           bool
+	retireBudget      int64
+	keyTransform      func(key []byte) []byte
+	rcuReads          bool
+	inlineThreshold   int
+	compactInts       bool
+	summaryBitsPerKey int
+	numaShards        int
+	errorHook         func(error)
+	selfCheckInterval time.Duration
+	keyValidator      func(key []byte) error
+	maxNode48         bool
+	structureObserver func(StructureEvent)
+	growthMonitor     func(GrowthEvent)
+	rejectEmptyKey    bool
+	wideStride        bool
+	leafChecksums     bool
+	changeTracking    bool
+	keyArena          bool
+	allocator         Allocator
+	selfOrganizing    bool
+	fullPaths         bool
+	coalesceWindow    time.Duration
+	proactiveGrow     bool
+	columnarLeaves    bool
+}
+
+// WithOperationTrace logs every insert, grow, split and search miss to w,
+// with node addresses, versions and depths. It is meant for diagnosing OLC
+// interleavings and is far too slow for production use.
+func WithOperationTrace(w io.Writer) Option {
+	return func(c *config) {
+		c.traceWriter = w
+	}
+}
+
+// WithValueType makes TryInsert reject values that are not assignable to typ
+// with ErrValueTypeMismatch. It guards Tree[any] against type pollution that
+// would otherwise surface as a failed type assertion much later.
+func WithValueType(typ reflect.Type) Option {
+	return func(c *config) {
+		c.valueType = typ
+	}
+}
+
+// WithVersionHistory keeps the n most recent values of every key, including
+// the current one, for retrieval with SearchVersion. Each key costs up to n-1
+// extra interface slots (16 bytes each plus any boxed value) once it has been
+// overwritten; keys written only once pay nothing.
+func WithVersionHistory(n int) Option {
+	return func(c *config) {
+		c.historyLen = n
+	}
+}
+
+// WithMetrics enables the per-operation restart counters exposed by
+// Tree.Metrics. Without it the counters cost a single nil check.
+func WithMetrics() Option {
+	return func(c *config) {
+		c.metrics = true
+	}
+}
+
+// WithRetireBudget caps the memory held by nodes that were replaced or
+// removed but not yet freed at roughly bytes. A writer that would exceed it
+// forces a garbage collection first, trading write throughput for a bounded
+// peak during bursts of churn. Nodes still referenced by in-flight readers
+// cannot be freed, so concurrent traffic may hold the total slightly above
+// the budget.
+func WithRetireBudget(bytes int) Option {
+	return func(c *config) {
+		c.retireBudget = int64(bytes)
+	}
+}
+
+// WithKeyTransform normalizes every key passed to Insert, Search, Delete and
+// the other single-key operations with fn before it reaches the tree, so
+// that for example keys differing only in case can share an entry. fn must
+// return a new slice rather than modify its argument. Scans and prefix
+// operations see the stored, transformed keys.
+func WithKeyTransform(fn func(key []byte) []byte) Option {
+	return func(c *config) {
+		c.keyTransform = fn
+	}
+}
+
+// WithKeyValidator checks every key passed to Insert, TryInsert, BulkInsert,
+// GetOrCompute and Apply with fn before it reaches the tree, so keys that
+// break an application invariant are rejected at the boundary. A rejected
+// key is not stored: the error-returning operations return fn's error
+// unchanged, and Insert drops the key. fn sees the key as the caller
+// passed it, before any WithKeyTransform.
+func WithKeyValidator(fn func(key []byte) error) Option {
+	return func(c *config) {
+		c.keyValidator = fn
+	}
+}
+
+// EmptyKeyPolicy decides whether a tree accepts the zero-length key.
+type EmptyKeyPolicy int
+
+const (
+	// AllowEmptyKey stores the empty key like any other, in the slot its
+	// terminator selects. It is the default.
+	AllowEmptyKey EmptyKeyPolicy = iota
+	// RejectEmptyKey treats the empty key as a caller bug: TryInsert and
+	// InsertTombstone refuse it with ErrEmptyKey, Insert drops it, and
+	// lookups of it miss.
+	RejectEmptyKey
+)
+
+// WithEmptyKeyPolicy sets how the tree treats the zero-length key, for
+// applications that never mean to store one and would rather have it
+// refused than silently kept.
+func WithEmptyKeyPolicy(p EmptyKeyPolicy) Option {
+	return func(c *config) {
+		c.rejectEmptyKey = p == RejectEmptyKey
+	}
+}
+
+// WithRCUReads switches the tree from optimistic lock coupling to
+// read-copy-update: writers copy every node on the path to their change and
+// atomically publish a new root, so readers take no locks, validate nothing
+// and never restart. Writes allocate a path of nodes each and are
+// serialized, so this suits read-mostly workloads. Modify and other
+// in-place leaf updates still lock the leaf they change.
+func WithRCUReads() Option {
+	return func(c *config) {
+		c.rcuReads = true
+	}
+}
+
+// WithInlineValueThreshold stores []byte values of up to bytes bytes inline,
+// in the same allocation as their leaf's key, and larger ones by reference
+// as usual. Inline values cost no separate allocation and sit next to the
+// key the lookup has just compared. Only values set when a key is first
+// inserted are inlined; overwrites are stored by reference. Reads return the
+// value boxed in an interface, which for an inline value allocates its slice
+// header, so this trades a little read cost for memory. The threshold is
+// capped at MaxInlineValueLength and ignored for other value types.
+func WithInlineValueThreshold(bytes int) Option {
+	return func(c *config) {
+		c.inlineThreshold = min(bytes, MaxInlineValueLength)
+	}
+}
+
+// WithCompactInts stores values of the predeclared integer types unboxed in
+// their leaf, which has room for them within its allocation, instead of
+// boxing each one into a separate heap object. Inserts and increments then
+// allocate one object fewer and overwrites update the stored bits in place.
+// The option mainly saves allocations rather than memory: every key still
+// has its leaf allocation, boxed integers already share the runtime's tiny
+// allocation blocks, and reads box the value again, which allocates for
+// values above 255. Other value types, including named integer types, are
+// stored as usual.
+func WithCompactInts() Option {
+	return func(c *config) {
+		c.compactInts = true
+	}
+}
+
+// WithMaxNode48 stops nodes from growing past node48, bounding every node
+// at about 1KB instead of the 2KB pointer array of a node256. A node48 that
+// fills up chains an overflow node48 for its further children, and that one
+// another, so a node with all 256 children spans six. Lookups of children
+// past the first 48 scan the chain, which makes dense nodes slower to
+// search and iterate; sparse nodes are unaffected.
+func WithMaxNode48() Option {
+	return func(c *config) {
+		c.maxNode48 = true
+	}
+}
+
+// WithNodeSummaries keeps a Bloom filter of the keys below every node48 and
+// node256 with a large enough subtree, so a lookup for an absent key can
+// stop at the first such node whose filter rules it out instead of
+// descending to where the key would diverge. It pays off when keys share
+// long prefixes and lookups often miss in their last bytes. Each key costs
+// about bitsPerKey bits in every summarized node above it, inserts and
+// lookups hash the key once they reach one, and the filters are rebuilt,
+// briefly quiescing writers, whenever the key count doubles. Summaries are
+// not used with WithRCUReads.
+func WithNodeSummaries(bitsPerKey int) Option {
+	return func(c *config) {
+		c.summaryBitsPerKey = bitsPerKey
+	}
+}
+
+// WithNUMAShards sets the number of shards of a tree created with
+// NewShardedART, which ShardedTree.Resize can change later. Without it, or
+// with n <= 0, the tree gets one shard per NUMA node the system reports. Go
+// neither pins goroutines to sockets nor places allocations on a chosen
+// node, so shards are not bound to a node; they divide contention, and
+// callers that pin their own worker threads can route keys to them with
+// ShardedTree.ShardOf. NewART ignores it.
+func WithNUMAShards(n int) Option {
+	return func(c *config) {
+		c.numaShards = n
+	}
+}
+
+// WithStructureObserver calls fn for every grow, split and child addition an
+// insert makes, once the change is committed and its locks are released, so
+// fn may be slow or call back into the tree. It is meant for studying how a
+// workload drives structural change; without it inserts pay only a nil
+// check. Calls from concurrent inserts are not serialized, and trees
+// created with WithRCUReads report no events.
+func WithStructureObserver(fn func(StructureEvent)) Option {
+	return func(c *config) {
+		c.structureObserver = fn
+	}
+}
+
+// WithGrowthMonitor calls fn whenever an insert grows a node, and whenever
+// it places a leaf deeper below the root than any before it, reporting
+// depths 8, 16, 32 and so on the first time each is reached. An application
+// can alert on unexpected depths to catch a key distribution that degrades
+// the tree in production. fn runs once the change is committed and its
+// locks are released; without the option inserts pay only nil checks.
+// Prefix splits that push existing keys deeper are not counted, and trees
+// created with WithRCUReads report nothing.
+func WithGrowthMonitor(fn func(GrowthEvent)) Option {
+	return func(c *config) {
+		c.growthMonitor = fn
+	}
+}
+
+// WithErrorHook sets the function that receives errors the tree detects in
+// the background, such as self-check failures, and panics recovered in the
+// middle of a write. Without it they are logged.
+func WithErrorHook(fn func(error)) Option {
+	return func(c *config) {
+		c.errorHook = fn
+	}
+}
+
+// WithSelfCheck runs a background consistency check every interval while
+// the tree is reachable: it quiesces writers, runs CheckInvariants and
+// compares a leaf count with Len, reporting any divergence to the error
+// hook. The check is costly and only compiled into builds with the
+// artdebug tag; elsewhere this option does nothing.
+func WithSelfCheck(interval time.Duration) Option {
+	return func(c *config) {
+		c.selfCheckInterval = interval
+	}
+}
+
+// assignable reports whether val may be stored in a tree declared for typ.
+func assignable(val interface{}, typ reflect.Type) bool {
+	if val == nil {
+		switch typ.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return true
+		}
+		return false
+	}
+	return reflect.TypeOf(val).AssignableTo(typ)
+}
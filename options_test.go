@@ -0,0 +1,259 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithValueType(t *testing.T) {
+	tree := NewART[any](WithValueType(reflect.TypeOf(0)))
+
+	if err := tree.TryInsert([]byte("int"), 42); err != nil {
+		t.Errorf("Expected matching type to insert, got %v", err)
+	}
+	if err := tree.TryInsert([]byte("string"), "nope"); !errors.Is(err, ErrValueTypeMismatch) {
+		t.Errorf("Expected ErrValueTypeMismatch, got %v", err)
+	}
+	if err := tree.TryInsert([]byte("nil"), nil); !errors.Is(err, ErrValueTypeMismatch) {
+		t.Errorf("Expected nil to be rejected for int, got %v", err)
+	}
+	tree.Insert([]byte("dropped"), 1.5)
+
+	if val, found := tree.Search([]byte("int")); !found || val.(int) != 42 {
+		t.Errorf("Expected 42, got %v (found=%v)", val, found)
+	}
+	for _, key := range []string{"string", "nil", "dropped"} {
+		if _, found := tree.Search([]byte(key)); found {
+			t.Errorf("Rejected value for '%s' must not be stored", key)
+		}
+	}
+
+	// Interface types accept any implementation
+	stringers := NewART[any](WithValueType(reflect.TypeOf((*fmt.Stringer)(nil)).Elem()))
+	if err := stringers.TryInsert([]byte("type"), reflect.TypeOf(0)); err != nil {
+		t.Errorf("Expected reflect.Type to satisfy fmt.Stringer, got %v", err)
+	}
+	if err := stringers.TryInsert([]byte("int"), 1); !errors.Is(err, ErrValueTypeMismatch) {
+		t.Errorf("Expected int to be rejected for fmt.Stringer, got %v", err)
+	}
+}
+
+func TestTryInsertWithoutOptions(t *testing.T) {
+	tree := NewART[any]()
+	for i, val := range []any{1, "two", 3.0, nil} {
+		if err := tree.TryInsert([]byte{byte('a' + i)}, val); err != nil {
+			t.Errorf("Unexpected error for %v: %v", val, err)
+		}
+	}
+}
+
+func TestKeyTransformSearchCanonical(t *testing.T) {
+	tree := NewART[int](WithKeyTransform(bytes.ToLower))
+	tree.Insert([]byte("User:Alice"), 1)
+	tree.Insert([]byte("USER:ALICE"), 2)
+
+	stored, val, found := tree.SearchCanonical([]byte("uSeR:aLiCe"))
+	if !found || val != 2 {
+		t.Fatalf("Expected case-insensitive match with value 2, got %d (found=%v)", val, found)
+	}
+	if string(stored) != "user:alice" {
+		t.Errorf("Expected canonical key 'user:alice', got '%s'", stored)
+	}
+	if n := tree.CountLeaves(); n != 1 {
+		t.Errorf("Expected both spellings to share one entry, got %d", n)
+	}
+	if _, _, found := tree.KeyExists([]byte("User:ALICE")); !found {
+		t.Error("Expected KeyExists to apply the transform")
+	}
+
+	// Without a transform the stored key is exactly the query
+	plain := NewART[int]()
+	plain.Insert([]byte("Key"), 1)
+	if stored, _, found := plain.SearchCanonical([]byte("Key")); !found || string(stored) != "Key" {
+		t.Errorf("Expected stored key 'Key', got '%s' (found=%v)", stored, found)
+	}
+	if _, _, found := plain.SearchCanonical([]byte("key")); found {
+		t.Error("Expected exact-match miss without a transform")
+	}
+
+	if !tree.Delete([]byte("USER:alice")) || tree.CountLeaves() != 0 {
+		t.Error("Expected Delete to apply the transform")
+	}
+}
+
+func TestWithKeyValidator(t *testing.T) {
+	errTooLong := errors.New("key over 32 bytes")
+	tree := NewART[int](WithKeyValidator(func(key []byte) error {
+		if len(key) > 32 {
+			return errTooLong
+		}
+		return nil
+	}))
+	tree.Insert([]byte("short"), 1)
+
+	long := bytes.Repeat([]byte("k"), 64)
+	if err := tree.TryInsert(long, 2); !errors.Is(err, errTooLong) {
+		t.Fatalf("Expected the validator's error for a 64-byte key, got %v", err)
+	}
+	if _, found := tree.Search(long); found {
+		t.Error("Rejected key must not be stored")
+	}
+	if n := tree.Len(); n != 1 {
+		t.Errorf("Expected the rejected insert to leave 1 key, got %d", n)
+	}
+
+	valid := bytes.Repeat([]byte("k"), 32)
+	if err := tree.TryInsert(valid, 3); err != nil {
+		t.Fatalf("Unexpected error for a 32-byte key: %v", err)
+	}
+	if val, found := tree.Search(valid); !found || val.(int) != 3 {
+		t.Errorf("Expected 32-byte key to map to 3, got %v (found=%v)", val, found)
+	}
+}
+
+func TestWithMaxNode48(t *testing.T) {
+	for _, opts := range [][]Option{{WithMaxNode48()}, {WithMaxNode48(), WithRCUReads()}} {
+		tree := NewART[int](opts...)
+		for b := 1; b <= 200; b++ {
+			tree.Insert([]byte{'x', byte(b)}, b)
+		}
+		for b := 1; b <= 200; b++ {
+			if val, found := tree.Search([]byte{'x', byte(b)}); !found || val.(int) != b {
+				t.Fatalf("Expected child %d to be found, got %v (found=%v)", b, val, found)
+			}
+		}
+		counts := tree.NodeCount()
+		if counts[nodeType256] != 0 {
+			t.Errorf("Expected no node256 with the cap, got %d", counts[nodeType256])
+		}
+		// 200 children span a node48 and four overflow nodes
+		if counts[nodeType48] != 5 {
+			t.Errorf("Expected 5 node48s, got %d", counts[nodeType48])
+		}
+		if err := tree.CheckInvariants(); err != nil {
+			t.Fatalf("Unexpected violation: %v", err)
+		}
+		prev := 0
+		for key, val := range tree.All() {
+			if val != prev+1 || key[1] != byte(val) {
+				t.Fatalf("Expected child %d after %d, got '%x'=%d", prev+1, prev, key, val)
+			}
+			prev = val
+		}
+		if prev != 200 {
+			t.Errorf("Expected iteration to end at 200, got %d", prev)
+		}
+
+		for b := 1; b <= 200; b += 2 {
+			tree.Delete([]byte{'x', byte(b)})
+		}
+		if err := tree.CheckInvariants(); err != nil {
+			t.Fatalf("Unexpected violation after deletes: %v", err)
+		}
+		for b := 1; b <= 200; b++ {
+			if _, found := tree.Search([]byte{'x', byte(b)}); found != (b%2 == 0) {
+				t.Errorf("Expected child %d found=%v after deletes", b, b%2 == 0)
+			}
+		}
+	}
+
+	// Without the cap the same node grows into a node256
+	tree := NewART[int]()
+	for b := 1; b <= 200; b++ {
+		tree.Insert([]byte{'x', byte(b)}, b)
+	}
+	if n := tree.NodeCount()[nodeType256]; n != 1 {
+		t.Errorf("Expected 1 node256 without the cap, got %d", n)
+	}
+}
+
+func TestWithEmptyKeyPolicy(t *testing.T) {
+	reject := NewART[string](WithEmptyKeyPolicy(RejectEmptyKey))
+	if err := reject.TryInsert([]byte{}, "empty"); err != ErrEmptyKey {
+		t.Errorf("TryInsert of the empty key = %v, want ErrEmptyKey", err)
+	}
+	reject.Insert(nil, "empty")
+	if _, found := reject.Search([]byte{}); found || reject.Len() != 0 {
+		t.Errorf("rejecting tree stored the empty key")
+	}
+	if err := reject.TryInsert([]byte("a"), "a"); err != nil {
+		t.Errorf("TryInsert of a non-empty key = %v", err)
+	}
+
+	allow := NewART[string](WithEmptyKeyPolicy(AllowEmptyKey))
+	if err := allow.TryInsert([]byte{}, "empty"); err != nil {
+		t.Fatalf("TryInsert of the empty key = %v", err)
+	}
+	allow.Insert([]byte("a"), "a")
+	if val, found := allow.Search(nil); !found || val != "empty" {
+		t.Errorf("Search of the empty key = %v, %v", val, found)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	upper := func(key []byte) []byte { return bytes.ToUpper(key) }
+	alloc := newCountingAllocator(t)
+	tests := []struct {
+		name string
+		tree *Tree[int]
+		want TreeConfig
+	}{
+		{
+			name: "defaults",
+			tree: NewART[int](),
+			want: TreeConfig{},
+		},
+		{
+			name: "fixed keys",
+			tree: NewFixedKeyART[int](8, WithCompactInts(), WithMaxNode48(), WithEmptyKeyPolicy(RejectEmptyKey)),
+			want: TreeConfig{KeyLength: 8, CompactInts: true, MaxNode48: true, RejectEmptyKey: true},
+		},
+		{
+			name: "summaries",
+			tree: NewART[int](WithNodeSummaries(10), WithKeyTransform(upper), WithMetrics(), WithVersionHistory(3),
+				WithWriteCoalescing(time.Millisecond)),
+			want: TreeConfig{NodeSummaryBitsPerKey: 10, KeyTransform: true, Metrics: true, VersionHistory: 3,
+				WriteCoalescingWindow: time.Millisecond},
+		},
+		{
+			name: "rcu",
+			tree: NewART[int](WithRCUReads(), WithNodeSummaries(10), WithRetireBudget(1<<20), WithInlineValueThreshold(1000)),
+			want: TreeConfig{RCUReads: true, RetireBudget: 1 << 20, InlineValueThreshold: MaxInlineValueLength},
+		},
+		{
+			name: "hooks",
+			tree: NewART[int](WithErrorHook(func(error) {}), WithStructureObserver(func(StructureEvent) {}),
+				WithGrowthMonitor(func(GrowthEvent) {}), WithKeyValidator(func([]byte) error { return nil })),
+			want: TreeConfig{ErrorHook: true, StructureObserver: true, GrowthMonitor: true, KeyValidator: true},
+		},
+		{
+			name: "layout",
+			tree: NewART[int](WithWideStride(), WithFullPathNodes(), WithSelfOrganizingNodes(), WithLeafChecksums(),
+				WithChangeTracking(), WithKeyArena(), WithAllocator(alloc), WithValueType(reflect.TypeOf(0)), WithProactiveGrow(),
+				WithColumnarLeaves()),
+			want: TreeConfig{WideStride: true, FullPathNodes: true, SelfOrganizingNodes: true, LeafChecksums: true,
+				ChangeTracking: true, KeyArena: true, Allocator: alloc, ValueType: reflect.TypeOf(0), ProactiveGrow: true,
+				ColumnarLeaves: true},
+		},
+	}
+	for _, tt := range tests {
+		tt.want.MaxInlinePrefixLength = MaxInlinePrefixLength
+		tt.want.TerminationChar = TerminationChar
+		if got := tt.tree.Config(); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+
+	tree := NewART[int](WithMetrics())
+	tree.SetReadOnly(true)
+	if cfg := tree.Config(); !cfg.ReadOnly || !cfg.Metrics {
+		t.Errorf("Expected the read-only state in %+v", cfg)
+	}
+	if cfg := tree.emptyLike().Config(); cfg.ReadOnly || !cfg.Metrics {
+		t.Errorf("Expected a tree configured like it to be writable, got %+v", cfg)
+	}
+}
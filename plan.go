@@ -0,0 +1,94 @@
+package art
+
+import "bytes"
+
+// InsertOutcome is what InsertPlan predicts an insert would do.
+type InsertOutcome[T any] struct {
+	// Err is the error TryInsert would return, in which case nothing else
+	// is set
+	Err error
+	// Overwrite reports that the key is present, holding Old
+	Overwrite bool
+	Old       T
+	// For a new key, Change, Depth, OldType and NewType describe the
+	// structural change the insert would make, as its StructureEvent would
+	Change           StructureEventKind
+	Depth            int
+	OldType, NewType nodeType
+}
+
+// InsertPlan reports what inserting val under key would do, without doing
+// it: whether the key is new or would be overwritten, and for a new key
+// whether it would be added to a node with room, split a leaf or prefix,
+// or grow a full node. It descends like insert but only reads, taking no
+// locks. The plan holds for the tree as it was; a concurrent write can
+// make the real insert do something else. Reading the old value of a key
+// holding a lazy value runs its loader.
+func (t *Tree[T]) InsertPlan(key []byte, val T) InsertOutcome[T] {
+	if err := t.checkInsert(key, val); err != nil {
+		return InsertOutcome[T]{Err: err}
+	}
+	if t.readOnly.Load() {
+		return InsertOutcome[T]{Err: ErrReadOnly}
+	}
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+restart:
+	depth := 0
+	n := t.root()
+	for {
+		version, needToRestart := readLockOrRestart(n)
+		if needToRestart {
+			goto restart
+		}
+		if l, ok := n.(*leaf); ok {
+			if bytes.Equal(l.key, key) {
+				old := l.value()
+				if !validate(n, version) {
+					goto restart
+				}
+				return InsertOutcome[T]{Overwrite: true, Old: valueAs[T](old)}
+			}
+			if !validate(n, version) {
+				goto restart
+			}
+			return InsertOutcome[T]{Change: NodeSplit, Depth: depth, OldType: nodeType4, NewType: nodeType4}
+		}
+		pre := n.getPrefix()
+		if p := checkPrefix(pre, key, depth); p != len(pre) {
+			if !validate(n, version) {
+				goto restart
+			}
+			return InsertOutcome[T]{Change: NodeSplit, Depth: depth, OldType: nodeType4, NewType: nodeType4}
+		}
+		var child node
+		if next := findChild(n, key, depth+len(pre)); next != nil {
+			child = next.load()
+		}
+		typ, full := n.getType(), n.isFull()
+		if !validate(n, version) {
+			goto restart
+		}
+		if child == nil {
+			if full {
+				return InsertOutcome[T]{Change: NodeGrew, Depth: depth, OldType: typ, NewType: grownType(typ)}
+			}
+			return InsertOutcome[T]{Change: ChildAdded, Depth: depth, OldType: typ, NewType: typ}
+		}
+		depth += len(pre)
+		n = child
+	}
+}
+
+// grownType returns the type a full node of type typ grows into.
+func grownType(typ nodeType) nodeType {
+	switch typ {
+	case nodeType4:
+		return nodeType16
+	case nodeType16:
+		return nodeType48
+	}
+	return nodeType256
+}
@@ -0,0 +1,59 @@
+package art
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInsertPlan(t *testing.T) {
+	var events []StructureEvent
+	tree := NewART[int](WithStructureObserver(func(e StructureEvent) {
+		events = append(events, e)
+	}))
+	for i, key := range []string{"k/a", "k/b", "k/c", "k/d"} {
+		tree.Insert([]byte(key), i)
+	}
+
+	plan := tree.InsertPlan([]byte("k/b"), 9)
+	if !plan.Overwrite || plan.Old != 1 || plan.Err != nil {
+		t.Errorf("plan for an existing key = %+v", plan)
+	}
+
+	// Each prediction matches what the insert then reports
+	for _, tc := range []struct {
+		key     string
+		change  StructureEventKind
+		oldType nodeType
+		newType nodeType
+	}{
+		{"k/e", NodeGrew, nodeType4, nodeType16},
+		{"k/f", ChildAdded, nodeType16, nodeType16},
+		{"k/ax", NodeSplit, nodeType4, nodeType4},
+		{"j", ChildAdded, nodeType4, nodeType4},
+		{"kz", NodeSplit, nodeType4, nodeType4},
+	} {
+		before := tree.Len()
+		plan := tree.InsertPlan([]byte(tc.key), 0)
+		if tree.Len() != before {
+			t.Fatalf("InsertPlan(%q) changed the tree", tc.key)
+		}
+		if plan.Overwrite || plan.Change != tc.change || plan.OldType != tc.oldType || plan.NewType != tc.newType {
+			t.Errorf("InsertPlan(%q) = %v %s->%s, want %v %s->%s", tc.key, plan.Change, plan.OldType, plan.NewType, tc.change, tc.oldType, tc.newType)
+		}
+		events = nil
+		tree.Insert([]byte(tc.key), 0)
+		if len(events) != 1 {
+			t.Fatalf("Insert(%q) reported %d events", tc.key, len(events))
+		}
+		e := events[0]
+		if e.Kind != plan.Change || e.Depth != plan.Depth || e.OldType != plan.OldType || e.NewType != plan.NewType {
+			t.Errorf("Insert(%q) reported %v at depth %d %s->%s, plan said %v at depth %d %s->%s",
+				tc.key, e.Kind, e.Depth, e.OldType, e.NewType, plan.Change, plan.Depth, plan.OldType, plan.NewType)
+		}
+	}
+
+	tree.SetReadOnly(true)
+	if plan := tree.InsertPlan([]byte("new"), 0); !errors.Is(plan.Err, ErrReadOnly) {
+		t.Errorf("plan on a read-only tree = %+v", plan)
+	}
+}
@@ -0,0 +1,102 @@
+package art
+
+import (
+	"bytes"
+	"sort"
+)
+
+// SearchProfile summarizes the cost of a set of lookups.
+type SearchProfile struct {
+	Lookups int
+	Found   int
+	// MeanNodes and P99Nodes count the nodes visited per lookup, leaves
+	// included
+	MeanNodes float64
+	P99Nodes  int
+	// MeanPrefixBytes counts the key bytes compared against node prefixes
+	// and stored leaf keys per lookup
+	MeanPrefixBytes float64
+	// WorstKey is the first key visiting WorstNodes nodes, the most of any
+	WorstKey   []byte
+	WorstNodes int
+}
+
+// ProfileSearch looks up every key while counting the nodes visited and
+// bytes compared, and returns aggregate statistics. It uses an instrumented
+// copy of the search descent and is meant for diagnosing slow keys, not for
+// lookups.
+func (t *Tree[T]) ProfileSearch(keys [][]byte) SearchProfile {
+	profile := SearchProfile{Lookups: len(keys)}
+	if len(keys) == 0 {
+		return profile
+	}
+	visits := make([]int, len(keys))
+	var totalNodes, totalBytes int
+	for i, key := range keys {
+		nodes, compared, found := t.searchCost(key)
+		visits[i] = nodes
+		totalNodes += nodes
+		totalBytes += compared
+		if found {
+			profile.Found++
+		}
+		if nodes > profile.WorstNodes {
+			profile.WorstNodes = nodes
+			profile.WorstKey = key
+		}
+	}
+	sort.Ints(visits)
+	profile.MeanNodes = float64(totalNodes) / float64(len(keys))
+	profile.MeanPrefixBytes = float64(totalBytes) / float64(len(keys))
+	profile.P99Nodes = visits[(len(visits)*99+99)/100-1]
+	return profile
+}
+
+// searchCost descends towards key like search, counting the nodes visited
+// and bytes compared by the final, validated attempt.
+func (t *Tree[T]) searchCost(key []byte) (nodes, compared int, found bool) {
+	defer t.epochs.unpin(t.epochs.pin())
+restart:
+	nodes, compared = 0, 0
+	depth := 0
+	var parent node
+	var parentVersion uint64
+	curNode := t.root()
+	for curNode != nil {
+		version, needToRestart := readLockOrRestart(curNode)
+		if needToRestart || !validate(parent, parentVersion) {
+			goto restart
+		}
+		nodes++
+		if l, ok := curNode.(*leaf); ok {
+			compared += min(len(l.key), len(key))
+			found = bytes.Equal(l.key, key)
+			if !validate(curNode, version) {
+				goto restart
+			}
+			return nodes, compared, found
+		}
+		pre := curNode.getPrefix()
+		p := checkPrefix(pre, key, depth)
+		// The mismatching byte is compared too
+		compared += min(p+1, len(pre))
+		if p != len(pre) {
+			if !validate(curNode, version) {
+				goto restart
+			}
+			return nodes, compared, false
+		}
+		depth += len(pre)
+		var child node
+		if next := findChild(curNode, key, depth); next != nil {
+			child = next.load()
+		}
+		if !validate(curNode, version) {
+			goto restart
+		}
+		parent = curNode
+		parentVersion = version
+		curNode = child
+	}
+	return nodes, compared, false
+}
@@ -0,0 +1,50 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestProfileSearch(t *testing.T) {
+	tree := NewART[int]()
+	var shallow, deep [][]byte
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("%c", 'A'+i))
+		shallow = append(shallow, key)
+		tree.Insert(key, i)
+	}
+	// Each key diverges one byte later than the previous, chaining node4s
+	var chain []byte
+	for i := 0; i < 20; i++ {
+		key := append(append([]byte("z"), chain...), 'x')
+		deep = append(deep, key)
+		tree.Insert(key, i)
+		chain = append(chain, 'y')
+	}
+
+	shallowProfile := tree.ProfileSearch(shallow)
+	deepProfile := tree.ProfileSearch(deep)
+	if shallowProfile.Found != len(shallow) || deepProfile.Found != len(deep) {
+		t.Fatalf("Expected every key found, got %d/%d and %d/%d",
+			shallowProfile.Found, len(shallow), deepProfile.Found, len(deep))
+	}
+	if deepProfile.MeanNodes <= shallowProfile.MeanNodes {
+		t.Errorf("Expected deep keys to visit more nodes: %.1f vs %.1f",
+			deepProfile.MeanNodes, shallowProfile.MeanNodes)
+	}
+	if shallowProfile.MeanNodes != 2 {
+		t.Errorf("Expected root and leaf for shallow keys, got %.1f", shallowProfile.MeanNodes)
+	}
+	if deepProfile.P99Nodes != deepProfile.WorstNodes {
+		t.Errorf("Expected p99 of 20 lookups to be the worst, got %d vs %d",
+			deepProfile.P99Nodes, deepProfile.WorstNodes)
+	}
+	if !bytes.Equal(deepProfile.WorstKey, deep[len(deep)-1]) && !bytes.Equal(deepProfile.WorstKey, deep[len(deep)-2]) {
+		t.Errorf("Expected the longest chain key to be worst, got '%s'", deepProfile.WorstKey)
+	}
+
+	if empty := tree.ProfileSearch(nil); empty.Lookups != 0 || empty.MeanNodes != 0 {
+		t.Errorf("Expected an empty profile, got %+v", empty)
+	}
+}
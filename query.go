@@ -0,0 +1,210 @@
+package art
+
+import (
+	"bytes"
+	"container/heap"
+	"math/rand"
+	"sort"
+)
+
+// ScanSortedByValue returns the entries under prefix ordered by value
+// according to less, keeping only the first limit of them (all of them if
+// limit <= 0). With a descending less this is a top-N query. Only limit
+// entries are held in memory at any time.
+func (t *Tree[T]) ScanSortedByValue(prefix []byte, less func(a, b T) bool, limit int) []KV[T] {
+	h := &kvHeap[T]{less: less}
+	t.ScanPrefix(prefix, func(key []byte, val T) bool {
+		kv := KV[T]{Key: key, Value: val}
+		if limit <= 0 || h.Len() < limit {
+			heap.Push(h, kv)
+		} else if less(val, h.kvs[0].Value) {
+			h.kvs[0] = kv
+			heap.Fix(h, 0)
+		}
+		return true
+	})
+	sort.SliceStable(h.kvs, func(i, j int) bool {
+		return less(h.kvs[i].Value, h.kvs[j].Value)
+	})
+	return h.kvs
+}
+
+// Sample returns up to n entries chosen uniformly at random from the whole
+// tree in a single traversal, using reservoir sampling so the total count
+// does not need to be known and only n entries are held in memory. A nil
+// rng draws from the math/rand package-level source. Sample returns nil
+// when n <= 0.
+func (t *Tree[T]) Sample(n int, rng *rand.Rand) []Entry[T] {
+	if n <= 0 {
+		return nil
+	}
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+	reservoir := make([]Entry[T], 0, n)
+	seen := 0
+	t.ForEach(func(key []byte, val T) bool {
+		seen++
+		if len(reservoir) < n {
+			reservoir = append(reservoir, Entry[T]{Key: key, Value: val})
+		} else if j := intn(seen); j < n {
+			reservoir[j] = Entry[T]{Key: key, Value: val}
+		}
+		return true
+	})
+	return reservoir
+}
+
+// kvHeap keeps the entry that sorts last according to less at its root, so
+// it can be evicted when a better entry arrives.
+type kvHeap[T any] struct {
+	kvs  []KV[T]
+	less func(a, b T) bool
+}
+
+func (h *kvHeap[T]) Len() int           { return len(h.kvs) }
+func (h *kvHeap[T]) Less(i, j int) bool { return h.less(h.kvs[j].Value, h.kvs[i].Value) }
+func (h *kvHeap[T]) Swap(i, j int)      { h.kvs[i], h.kvs[j] = h.kvs[j], h.kvs[i] }
+func (h *kvHeap[T]) Push(x any)         { h.kvs = append(h.kvs, x.(KV[T])) }
+func (h *kvHeap[T]) Pop() any {
+	kv := h.kvs[len(h.kvs)-1]
+	h.kvs = h.kvs[:len(h.kvs)-1]
+	return kv
+}
+
+// Slice returns the entries at sorted positions [offset, offset+limit),
+// fewer if the tree runs out. Skipped entries are counted without reading
+// their values, but the traversal still visits them, so large offsets cost
+// as much as scanning up to them.
+func (t *Tree[T]) Slice(offset, limit int) []Entry[T] {
+	if offset < 0 || limit <= 0 {
+		return nil
+	}
+	var entries []Entry[T]
+	pos := 0
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		if pos >= offset {
+			entries = append(entries, Entry[T]{Key: l.key, Value: valueAs[T](readLeaf(l))})
+		}
+		pos++
+		return len(entries) < limit
+	})
+	return entries
+}
+
+// Rank returns the number of keys sorting strictly before key, and whether
+// key itself is stored. The tree keeps no per-node subtree counts, so it
+// walks the keys in order up to key, costing O(rank).
+func (t *Tree[T]) Rank(key []byte) (rank int, found bool) {
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		switch c := bytes.Compare(l.key, key); {
+		case c < 0:
+			rank++
+			return true
+		case c == 0:
+			found = true
+		}
+		return false
+	})
+	return rank, found
+}
+
+// Select returns the entry at sorted position rank, counting from 0, the
+// inverse of Rank. Like Slice it walks the keys before it.
+func (t *Tree[T]) Select(rank int) ([]byte, T, bool) {
+	var zero T
+	if rank < 0 {
+		return nil, zero, false
+	}
+	entries := t.Slice(rank, 1)
+	if len(entries) == 0 {
+		return nil, zero, false
+	}
+	return entries[0].Key, entries[0].Value, true
+}
+
+// KeysOfLength returns in key order every entry whose key is exactly n
+// bytes long. Every key below a node is at least as long as the node's
+// depth, so subtrees deeper than n are skipped without visiting them.
+func (t *Tree[T]) KeysOfLength(n int) []Entry[T] {
+	var entries []Entry[T]
+	defer t.epochs.unpin(t.epochs.pin())
+	keysOfLength(t.root(), 0, n, func(l *leaf) {
+		entries = append(entries, Entry[T]{Key: l.key, Value: valueAs[T](readLeaf(l))})
+	})
+	return entries
+}
+
+func keysOfLength(nd node, depth, n int, fn func(l *leaf)) {
+	if nd == nil {
+		return
+	}
+	if l, ok := nd.(*leaf); ok {
+		if len(l.key) == n && !isTombstone(l) {
+			fn(l)
+		}
+		return
+	}
+	prefix, children := readNode(nd)
+	depth += len(prefix)
+	if depth > n {
+		return
+	}
+	for _, child := range children {
+		keysOfLength(child, depth, n, fn)
+	}
+}
+
+// ScanReverse visits in ascending order every key ending with suffix until
+// fn returns false. It walks the whole tree rather than keeping a second,
+// reversed index, so it suits occasional suffix queries. In a fixed-key tree
+// the suffix occupies known positions, and subtrees whose path already
+// disagrees with it there are skipped.
+func (t *Tree[T]) ScanReverse(suffix []byte, fn func(key []byte, val T) bool) {
+	start := -1
+	if t.keyLen > 0 {
+		if start = t.keyLen - len(suffix); start < 0 {
+			return
+		}
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+	scanSuffix(t.root(), nil, suffix, start, func(l *leaf) bool {
+		return fn(l.key, valueAs[T](readLeaf(l)))
+	})
+}
+
+// scanSuffix visits the leaves below n ending with suffix. With start >= 0
+// every key has suffix at start, and path, the bytes consumed above n, must
+// agree with it.
+func scanSuffix(n node, path, suffix []byte, start int, fn func(l *leaf) bool) bool {
+	if n == nil {
+		return true
+	}
+	if l, ok := n.(*leaf); ok {
+		if !bytes.HasSuffix(l.key, suffix) || isTombstone(l) {
+			return true
+		}
+		return fn(l)
+	}
+	prefix, children := readNode(n)
+	path = append(path[:len(path):len(path)], prefix...)
+	if start >= 0 {
+		for i := max(start, len(path)-len(prefix)); i < len(path) && i-start < len(suffix); i++ {
+			if path[i] != suffix[i-start] {
+				return true
+			}
+		}
+	}
+	for _, child := range children {
+		if !scanSuffix(child, path, suffix, start, fn) {
+			return false
+		}
+	}
+	return true
+}
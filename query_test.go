@@ -0,0 +1,246 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestScanSortedByValue(t *testing.T) {
+	tree := NewART[int]()
+	scores := rand.New(rand.NewSource(7)).Perm(500)
+	for i, score := range scores {
+		tree.Insert([]byte(fmt.Sprintf("game1:player%03d", i)), score)
+		tree.Insert([]byte(fmt.Sprintf("game2:player%03d", i)), score+1000)
+	}
+
+	top := tree.ScanSortedByValue([]byte("game1:"), func(a, b int) bool { return a > b }, 10)
+	if len(top) != 10 {
+		t.Fatalf("Expected 10 entries, got %d", len(top))
+	}
+	for i, kv := range top {
+		if kv.Value != 499-i {
+			t.Errorf("Position %d: expected score %d, got %d (%s)", i, 499-i, kv.Value, kv.Key)
+		}
+	}
+
+	all := tree.ScanSortedByValue([]byte("game2:"), func(a, b int) bool { return a < b }, 0)
+	if len(all) != 500 {
+		t.Fatalf("Expected 500 entries without limit, got %d", len(all))
+	}
+	if !sort.SliceIsSorted(all, func(i, j int) bool { return all[i].Value < all[j].Value }) {
+		t.Error("Expected ascending order without limit")
+	}
+
+	if got := tree.ScanSortedByValue([]byte("none:"), func(a, b int) bool { return a < b }, 5); len(got) != 0 {
+		t.Errorf("Expected no entries for missing prefix, got %v", got)
+	}
+}
+
+func TestSampleUniform(t *testing.T) {
+	tree := NewART[int]()
+	const numKeys = 20
+	for i := 0; i < numKeys; i++ {
+		tree.Insert([]byte(fmt.Sprintf("key%02d", i)), i)
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	const runs = 20000
+	const n = 5
+	counts := make([]int, numKeys)
+	for r := 0; r < runs; r++ {
+		sample := tree.Sample(n, rng)
+		if len(sample) != n {
+			t.Fatalf("Expected %d entries, got %d", n, len(sample))
+		}
+		seen := map[int]bool{}
+		for _, e := range sample {
+			if seen[e.Value] {
+				t.Fatalf("Entry %s sampled twice in one run", e.Key)
+			}
+			seen[e.Value] = true
+			counts[e.Value]++
+		}
+	}
+
+	// Each key is expected runs*n/numKeys = 5000 times with a standard
+	// deviation of about 61; allow a generous 5 sigma
+	expected := float64(runs * n / numKeys)
+	for i, c := range counts {
+		if diff := float64(c) - expected; diff > 300 || diff < -300 {
+			t.Errorf("Key %d sampled %d times, expected about %.0f", i, c, expected)
+		}
+	}
+
+	if got := tree.Sample(100, rng); len(got) != numKeys {
+		t.Errorf("Expected all %d entries when n exceeds the size, got %d", numKeys, len(got))
+	}
+	if got := tree.Sample(n, nil); len(got) != n {
+		t.Errorf("Expected %d entries from the default source, got %d", n, len(got))
+	}
+	for _, n := range []int{0, -1} {
+		if got := tree.Sample(n, nil); got != nil {
+			t.Errorf("Expected no entries for n=%d, got %v", n, got)
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	tree := NewART[int]()
+	for _, i := range rand.Perm(100) {
+		tree.Insert([]byte(fmt.Sprintf("key%03d", i)), i)
+	}
+
+	window := tree.Slice(10, 5)
+	if len(window) != 5 {
+		t.Fatalf("Expected 5 entries, got %d", len(window))
+	}
+	for i, entry := range window {
+		if entry.Value != 10+i || string(entry.Key) != fmt.Sprintf("key%03d", 10+i) {
+			t.Errorf("Expected key%03d=%d at %d, got %s=%d", 10+i, 10+i, i, entry.Key, entry.Value)
+		}
+	}
+
+	if tail := tree.Slice(97, 10); len(tail) != 3 || tail[0].Value != 97 {
+		t.Errorf("Expected the last 3 entries, got %v", tail)
+	}
+	if past := tree.Slice(100, 5); len(past) != 0 {
+		t.Errorf("Expected no entries past the end, got %d", len(past))
+	}
+	if none := tree.Slice(0, 0); len(none) != 0 {
+		t.Errorf("Expected no entries for limit 0, got %d", len(none))
+	}
+}
+
+func TestRankSelect(t *testing.T) {
+	tree := NewART[int]()
+	// Even numbers only, so odd queries fall between keys
+	for i := 0; i < 2000; i += 2 {
+		tree.Insert([]byte(fmt.Sprintf("%06d", i)), i)
+	}
+
+	for rank := 0; rank < 1000; rank++ {
+		key, val, ok := tree.Select(rank)
+		if !ok || val != 2*rank || string(key) != fmt.Sprintf("%06d", 2*rank) {
+			t.Fatalf("Select(%d): expected %06d=%d, got '%s'=%d (ok=%v)", rank, 2*rank, 2*rank, key, val, ok)
+		}
+		if r, found := tree.Rank(key); !found || r != rank {
+			t.Fatalf("Rank('%s'): expected %d, got %d (found=%v)", key, rank, r, found)
+		}
+	}
+	for i := 1; i < 2000; i += 2 {
+		if r, found := tree.Rank([]byte(fmt.Sprintf("%06d", i))); found || r != (i+1)/2 {
+			t.Fatalf("Rank of absent %06d: expected %d, got %d (found=%v)", i, (i+1)/2, r, found)
+		}
+	}
+	if r, found := tree.Rank([]byte("999999")); found || r != 1000 {
+		t.Errorf("Expected a key past the end to rank 1000, got %d (found=%v)", r, found)
+	}
+	for _, rank := range []int{-1, 1000} {
+		if _, _, ok := tree.Select(rank); ok {
+			t.Errorf("Expected Select(%d) to be out of range", rank)
+		}
+	}
+}
+
+func TestRankKeyTransform(t *testing.T) {
+	tree := NewART[int](WithKeyTransform(bytes.ToLower))
+	for i, key := range []string{"a", "B", "c"} {
+		tree.Insert([]byte(key), i)
+	}
+	if r, found := tree.Rank([]byte("b")); !found || r != 1 {
+		t.Errorf("Rank('b'): expected 1, got %d (found=%v)", r, found)
+	}
+	if r, found := tree.Rank([]byte("C")); !found || r != 2 {
+		t.Errorf("Rank('C'): expected 2, got %d (found=%v)", r, found)
+	}
+}
+
+func TestKeysOfLength(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"", "a", "ab", "abc", "abd", "abcd", "b", "bc", "bcdefghijklmnop"}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		key := make([]byte, 1+rng.Intn(20))
+		for j := range key {
+			key[j] = "abyz"[rng.Intn(4)]
+		}
+		keys = append(keys, string(key))
+	}
+	byLength := map[int]map[string]bool{}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+		if byLength[len(key)] == nil {
+			byLength[len(key)] = map[string]bool{}
+		}
+		byLength[len(key)][key] = true
+	}
+
+	for n := 0; n <= 22; n++ {
+		entries := tree.KeysOfLength(n)
+		if len(entries) != len(byLength[n]) {
+			t.Errorf("Length %d: expected %d keys, got %d", n, len(byLength[n]), len(entries))
+		}
+		for i, entry := range entries {
+			if len(entry.Key) != n || !byLength[n][string(entry.Key)] {
+				t.Errorf("Length %d: unexpected key %q", n, entry.Key)
+			}
+			if i > 0 && string(entries[i-1].Key) >= string(entry.Key) {
+				t.Errorf("Length %d: %q out of order after %q", n, entry.Key, entries[i-1].Key)
+			}
+		}
+	}
+}
+
+func TestScanReverse(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"a.com", "b.org", "c.com", "com", "d.com.au", "e.net", "x.com", ".com", "mail.example.com"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+
+	var visited []string
+	tree.ScanReverse([]byte(".com"), func(key []byte, val int) bool {
+		if keys[val] != string(key) {
+			t.Errorf("Expected '%s' to map to its index, got %d", key, val)
+		}
+		visited = append(visited, string(key))
+		return true
+	})
+	expected := []string{".com", "a.com", "c.com", "mail.example.com", "x.com"}
+	if fmt.Sprint(visited) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, visited)
+	}
+
+	n := 0
+	tree.ScanReverse([]byte(".com"), func([]byte, int) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("Expected the scan to stop after 2 keys, got %d", n)
+	}
+
+	// Fixed-key trees prune on the suffix's known position
+	fixed := NewFixedKeyART[int](6)
+	for i := 0; i < 1000; i++ {
+		fixed.Insert([]byte(fmt.Sprintf("%03d.%02d", i, i%7)), i)
+	}
+	count := 0
+	fixed.ScanReverse([]byte(".03"), func(key []byte, val int) bool {
+		if val%7 != 3 {
+			t.Errorf("Unexpected key '%s'", key)
+		}
+		count++
+		return true
+	})
+	if count != 143 {
+		t.Errorf("Expected 143 keys ending with '.03', got %d", count)
+	}
+	fixed.ScanReverse([]byte("toolongsuffix"), func(key []byte, val int) bool {
+		t.Errorf("Unexpected key '%s' for a suffix longer than the keys", key)
+		return true
+	})
+}
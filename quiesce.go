@@ -0,0 +1,10 @@
+package art
+
+// Quiesce blocks new writers and waits for in-flight writers to finish,
+// giving a point at which the tree cannot change. Reads proceed normally.
+// The returned function lets writers continue and must be called exactly
+// once.
+func (t *Tree[T]) Quiesce() (resume func()) {
+	t.writers.Lock()
+	return t.writers.Unlock
+}
@@ -0,0 +1,58 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQuiesceSnapshotConsistency(t *testing.T) {
+	tree := NewART[int]()
+	var stop atomic.Bool
+	var inserted atomic.Int64
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; !stop.Load(); i++ {
+				tree.Insert([]byte(fmt.Sprintf("w%d:%06d", id, i)), i)
+				inserted.Add(1)
+			}
+		}(g)
+	}
+
+	for round := 0; round < 5; round++ {
+		time.Sleep(10 * time.Millisecond)
+		resume := tree.Quiesce()
+		before := inserted.Load()
+		keys := tree.Keys()
+		count := tree.CountLeaves()
+		time.Sleep(time.Millisecond)
+		after := tree.CountLeaves()
+		resume()
+
+		if len(keys) != count || count != after {
+			t.Errorf("Round %d: snapshot inconsistent: keys=%d count=%d after=%d", round, len(keys), count, after)
+		}
+		if int64(count) < before {
+			t.Errorf("Round %d: counted %d keys but %d inserts had completed", round, count, before)
+		}
+		for i := 1; i < len(keys); i++ {
+			if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+				t.Errorf("Round %d: keys not strictly ascending at %d", round, i)
+				break
+			}
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+
+	if int64(tree.CountLeaves()) != inserted.Load() {
+		t.Errorf("Expected %d keys after writers stopped, got %d", inserted.Load(), tree.CountLeaves())
+	}
+}
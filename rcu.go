@@ -0,0 +1,253 @@
+package art
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// rcuState holds the root of a tree created with WithRCUReads. Nodes
+// reachable from a published root are never modified: a writer copies every
+// node on the path to its change and publishes a new root, so readers follow
+// pointers without versions, validation or restarts. Replaced nodes are
+// reclaimed by the collector once the last reader drops them.
+type rcuState struct {
+	// mu serializes writers, which all rebuild from the latest root
+	mu   sync.Mutex
+	root atomic.Pointer[rcuRoot]
+}
+
+type rcuRoot struct {
+	node node
+}
+
+func newRCUState(a Allocator) *rcuState {
+	s := &rcuState{}
+	s.root.Store(&rcuRoot{node: allocNode4(a)})
+	return s
+}
+
+// root returns the tree's current root node.
+func (t *Tree[T]) root() node {
+	if t.rcu != nil {
+		return t.rcu.root.Load().node
+	}
+	return t.node.load()
+}
+
+// rcuSearch is search for RCU trees. It never restarts.
+func (t *Tree[T]) rcuSearch(key []byte) (*leaf, interface{}, bool) {
+	l := searchUnlocked(t.rcu.root.Load().node, key)
+	if l == nil {
+		return nil, nil, false
+	}
+	return l, l.value(), true
+}
+
+// rcuUpsert is upsert for RCU trees, s being the write's sequence number.
+func (t *Tree[T]) rcuUpsert(key []byte, l *leaf, update func(old interface{}) interface{}, s uint64) {
+	t.rcu.mu.Lock()
+	defer t.rcu.mu.Unlock()
+	root := t.rcuInsert(t.rcu.root.Load().node, key, l, update, 0)
+	t.markPath(root, key, s)
+	t.rcu.root.Store(&rcuRoot{node: root})
+}
+
+// rcuInsert returns a copy of n with l inserted below it, sharing every
+// subtree the insert does not touch.
+func (t *Tree[T]) rcuInsert(n node, key []byte, l *leaf, update func(old interface{}) interface{}, depth int) node {
+	if old, ok := n.(*leaf); ok {
+		if bytes.Equal(old.key, key) {
+			replaced := &leaf{
+				key:                 old.key,
+				versionLockObsolete: &atomic.Uint64{},
+			}
+			replaced.val.Store(old.val.Load())
+			replaced.history.Store(old.history.Load())
+			replaced.bits.Store(old.bits.Load())
+			if update != nil {
+				replaced.setValue(update(old.value()))
+			} else {
+				replaced.assign(l)
+			}
+			if l != nil {
+				t.carrySeq(replaced, l)
+			}
+			if t.historyLen > 1 {
+				replaced.pushHistory(old.value(), t.historyLen-1)
+			}
+			t.seal(replaced)
+			t.rewritten(holdsTombstone(old), holdsTombstone(replaced))
+			return replaced
+		}
+		newNode := allocNode4(t.alloc)
+		commonPrefix := getCommonPrefix(key, old.key, depth)
+		newNode.setPrefix(commonPrefix)
+		depth += len(commonPrefix)
+		addChild(newNode, old, old.key, depth)
+		addChild(newNode, l, key, depth)
+		t.inherit(newNode, old)
+		t.recordPath(newNode, key[:depth])
+		t.added(holdsTombstone(l))
+		return newNode
+	}
+
+	pre := n.getPrefix()
+	p := checkPrefix(pre, key, depth)
+	if p != len(pre) {
+		newNode := allocNode4(t.alloc)
+		curPrefix := append([]byte(nil), pre...)
+		moved := cloneNode(t.alloc, n)
+		addChild(newNode, l, key, depth+p)
+		addChild(newNode, moved, curPrefix, p)
+		newNode.setPrefix(curPrefix[:p])
+		moved.setPrefix(curPrefix[p:])
+		t.inherit(newNode, moved)
+		t.recordPath(newNode, key[:depth+p])
+		t.added(holdsTombstone(l))
+		return newNode
+	}
+	depth += len(pre)
+	if next := findChild(n, key, depth); next != nil && next.load() != nil {
+		c := cloneNode(t.alloc, n)
+		findChild(c, key, depth).store(t.rcuInsert(next.load(), key, l, update, depth))
+		return c
+	}
+	var c node
+	if n.isFull() {
+		c = t.grow(n)
+	} else {
+		c = cloneNode(t.alloc, n)
+		reserveOverflow(t.alloc, c)
+	}
+	addChild(c, l, key, depth)
+	t.added(holdsTombstone(l))
+	return c
+}
+
+// rcuReplace is ReplaceIf for RCU trees. It publishes the new value in a
+// copy of the leaf like any other write, so snapshots keep the old one.
+func (t *Tree[T]) rcuReplace(key []byte, newVal interface{}, pred func(old interface{}) bool) bool {
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	t.rcu.mu.Lock()
+	defer t.rcu.mu.Unlock()
+	root := t.rcu.root.Load().node
+	l := searchUnlocked(root, key)
+	if l == nil {
+		return false
+	}
+	if old, found := live(l.value(), true); !found || !pred(old) {
+		return false
+	}
+	root = t.rcuInsert(root, key, nil, func(interface{}) interface{} { return newVal }, 0)
+	if t.changes != nil {
+		t.markPath(root, key, t.stamp(searchUnlocked(root, key)))
+	}
+	t.rcu.root.Store(&rcuRoot{node: root})
+	return true
+}
+
+// rcuDelete is delete for RCU trees.
+func (t *Tree[T]) rcuDelete(key []byte) bool {
+	t.rcu.mu.Lock()
+	defer t.rcu.mu.Unlock()
+	root := t.rcu.root.Load().node
+	l := searchUnlocked(root, key)
+	replaced, removed := rcuRemove(t.alloc, root, key, 0, true)
+	if removed {
+		t.rcu.root.Store(&rcuRoot{node: replaced})
+		t.removed(l)
+	}
+	return removed
+}
+
+// rcuRemove returns a copy of inner node n without key, restructured like
+// removeLeaf restructures in place, and whether key was found. New nodes
+// come from a.
+func rcuRemove(a Allocator, n node, key []byte, depth int, isRoot bool) (node, bool) {
+	pre := n.getPrefix()
+	if checkPrefix(pre, key, depth) != len(pre) {
+		return n, false
+	}
+	depth += len(pre)
+	next := findChild(n, key, depth)
+	if next == nil || next.load() == nil {
+		return n, false
+	}
+	child := next.load()
+	if l, ok := child.(*leaf); !ok {
+		replaced, removed := rcuRemove(a, child, key, depth, false)
+		if !removed {
+			return n, false
+		}
+		c := cloneNode(a, n)
+		findChild(c, key, depth).store(replaced)
+		return c, true
+	} else if !bytes.Equal(l.key, key) {
+		return n, false
+	}
+
+	c := cloneNode(a, n)
+	removeChild(c, key, depth)
+	count := c.childCount()
+	switch {
+	case !isRoot && count == 1:
+		sibling := sortedChildren(c)[0]
+		if sibling.getType() == nodeTypeLeaf {
+			return sibling, true
+		}
+		// the sibling now hangs where n did, so it absorbs n's prefix
+		merged := cloneNode(a, sibling)
+		merged.setPrefix(append(append([]byte(nil), pre...), sibling.getPrefix()...))
+		return merged, true
+	case underfullAt(c.getType(), count):
+		return c.shrink(a), true
+	}
+	return c, true
+}
+
+// cloneNode returns an unpublished copy of inner node n, in memory from a,
+// with its own version.
+func cloneNode(a Allocator, n node) node {
+	switch n := n.(type) {
+	case *node4:
+		c := a.AllocNode4()
+		*c = *n
+		c.versionLockObsolete = inheritedVersion(n.versionLockObsolete)
+		return c
+	case *node16:
+		c := a.AllocNode16()
+		*c = *n
+		c.versionLockObsolete = inheritedVersion(n.versionLockObsolete)
+		return c
+	case *node48:
+		c := a.AllocNode48()
+		*c = *n
+		c.versionLockObsolete = inheritedVersion(n.versionLockObsolete)
+		if next := n.next(); next != nil {
+			c.setNext(cloneNode(a, next).(*node48))
+		}
+		return c
+	case *node256:
+		c := a.AllocNode256()
+		*c = *n
+		c.versionLockObsolete = inheritedVersion(n.versionLockObsolete)
+		return c
+	case *nodeWide:
+		// rows are shared by pointer, so each is copied too
+		c := a.AllocNodeWide()
+		*c = *n
+		c.versionLockObsolete = inheritedVersion(n.versionLockObsolete)
+		for hi := range c.rows {
+			if row := n.row(byte(hi)); row != nil {
+				copied := *row
+				c.rows[hi] = unsafe.Pointer(&copied)
+			}
+		}
+		return c
+	}
+	return n
+}
@@ -0,0 +1,147 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRCUReads(t *testing.T) {
+	tree := NewART[int](WithRCUReads(), WithVersionHistory(2))
+	keys := make([][]byte, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		// Shared prefixes of varying length exercise splits at every depth
+		keys = append(keys, []byte(fmt.Sprintf("%d/%x", i%7, i*2654435761)))
+	}
+	keys = append(keys, []byte("0"), []byte("0/"), []byte(""))
+	for i, key := range keys {
+		tree.Insert(key, i)
+	}
+	for i, key := range keys {
+		if val, found := tree.Search(key); !found || val.(int) != i {
+			t.Fatalf("Expected %s=%d, got %v (found=%v)", key, i, val, found)
+		}
+	}
+
+	tree.Insert(keys[0], -1)
+	if prev, _ := tree.SearchVersion(keys[0], 1); prev != 0 {
+		t.Errorf("Expected history to survive a copied overwrite, got %d", prev)
+	}
+	tree.Insert(keys[0], 0)
+
+	for i := 0; i < len(keys); i += 2 {
+		if !tree.Delete(keys[i]) {
+			t.Errorf("Expected to delete '%s'", keys[i])
+		}
+	}
+	if tree.Delete(keys[0]) {
+		t.Error("Expected a second delete to miss")
+	}
+	for i, key := range keys {
+		_, found := tree.Search(key)
+		if found != (i%2 == 1) {
+			t.Errorf("Key '%s' found=%v after deleting even keys", key, found)
+		}
+	}
+
+	var prev []byte
+	count := 0
+	tree.ForEach(func(key []byte, val int) bool {
+		if count > 0 && bytes.Compare(prev, key) >= 0 {
+			t.Errorf("Keys out of order: '%s' after '%s'", key, prev)
+		}
+		prev = key
+		count++
+		return true
+	})
+	if count != len(keys)/2 {
+		t.Errorf("Expected %d keys, got %d", len(keys)/2, count)
+	}
+	if tree.Len() != count {
+		t.Errorf("Expected Len %d, got %d", count, tree.Len())
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Errorf("Unexpected violation: %v", err)
+	}
+}
+
+func TestRCUConcurrentReaders(t *testing.T) {
+	tree := NewART[int](WithRCUReads(), WithMetrics())
+	stable := make([][]byte, 1000)
+	for i := range stable {
+		stable[i] = []byte(fmt.Sprintf("stable%04d", i))
+		tree.Insert(stable[i], i)
+	}
+
+	var stop atomic.Bool
+	var writers, readers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(id int) {
+			defer writers.Done()
+			for i := 0; !stop.Load(); i++ {
+				key := []byte(fmt.Sprintf("churn%d/%d", id, i%300))
+				tree.Insert(key, i)
+				tree.Delete(key)
+			}
+		}(w)
+	}
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < 20000; i++ {
+				idx := rand.Intn(len(stable))
+				if val, found := tree.Search(stable[idx]); !found || val.(int) != idx {
+					t.Errorf("Reader lost %s: %v (found=%v)", stable[idx], val, found)
+					return
+				}
+			}
+		}()
+	}
+	readers.Wait()
+	stop.Store(true)
+	writers.Wait()
+
+	if n := tree.Metrics().Restarts(OperationSearch); n != 0 {
+		t.Errorf("Expected RCU searches never to restart, got %d", n)
+	}
+}
+
+// BenchmarkReadHeavy runs the 95% read mix of TestReadHeavyWorkload against
+// both concurrency schemes and reports search restarts per operation.
+func BenchmarkReadHeavy(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"olc", []Option{WithMetrics()}},
+		{"rcu", []Option{WithMetrics(), WithRCUReads()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			tree := NewART[int](mode.opts...)
+			keys := make([][]byte, 10000)
+			for i := range keys {
+				keys[i] = []byte(fmt.Sprintf("%016x", rand.Uint64()))
+				tree.Insert(keys[i], i)
+			}
+			var seed atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				rng := rand.New(rand.NewSource(seed.Add(1)))
+				for pb.Next() {
+					key := keys[rng.Intn(len(keys))]
+					if rng.Intn(100) < 95 {
+						tree.Search(key)
+					} else {
+						tree.Insert(key, rng.Int())
+					}
+				}
+			})
+			b.ReportMetric(float64(tree.Metrics().Restarts(OperationSearch))/float64(b.N), "restarts/op")
+		})
+	}
+}
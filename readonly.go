@@ -0,0 +1,19 @@
+package art
+
+// SetReadOnly turns the tree read-only or writable again. While read-only,
+// TryInsert and the other inserts fail with ErrReadOnly, and Delete,
+// ReplaceIf and Modify change nothing and report false; reads proceed
+// normally. SetReadOnly waits for in-flight writers, so once it returns
+// with ro set no write is still being applied. A Batch checks once, when
+// Apply starts; one already applying when the tree turns read-only loses
+// its remaining operations.
+func (t *Tree[T]) SetReadOnly(ro bool) {
+	resume := t.Quiesce()
+	defer resume()
+	t.readOnly.Store(ro)
+}
+
+// ReadOnly reports whether the tree is read-only.
+func (t *Tree[T]) ReadOnly() bool {
+	return t.readOnly.Load()
+}
@@ -0,0 +1,48 @@
+package art
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetReadOnly(t *testing.T) {
+	tree := NewART[int]()
+	tree.Insert([]byte("a"), 1)
+	tree.Insert([]byte("b"), 2)
+
+	tree.SetReadOnly(true)
+	if !tree.ReadOnly() {
+		t.Fatal("ReadOnly() = false after SetReadOnly(true)")
+	}
+	if err := tree.TryInsert([]byte("c"), 3); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("TryInsert on a read-only tree = %v, want ErrReadOnly", err)
+	}
+	tree.Insert([]byte("a"), 10)
+	if tree.Delete([]byte("b")) {
+		t.Error("Delete on a read-only tree reported a removal")
+	}
+	if tree.ReplaceIf([]byte("a"), 11, func(int) bool { return true }) {
+		t.Error("ReplaceIf on a read-only tree reported a replacement")
+	}
+	var b Batch[int]
+	b.Insert([]byte("d"), 4)
+	if err := tree.Apply(&b); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Apply on a read-only tree = %v, want ErrReadOnly", err)
+	}
+	for key, want := range map[string]int{"a": 1, "b": 2} {
+		if v, ok := tree.Search([]byte(key)); !ok || v.(int) != want {
+			t.Errorf("read-only tree: Search(%q) = %v, %v, want %d", key, v, ok, want)
+		}
+	}
+	if _, ok := tree.Search([]byte("c")); ok || tree.Len() != 2 {
+		t.Errorf("read-only tree gained a key: len %d", tree.Len())
+	}
+
+	tree.SetReadOnly(false)
+	if err := tree.TryInsert([]byte("c"), 3); err != nil {
+		t.Fatalf("TryInsert after SetReadOnly(false) = %v", err)
+	}
+	if v, _ := tree.Search([]byte("c")); !tree.Delete([]byte("b")) || tree.Len() != 2 || v != 3 {
+		t.Errorf("writes did not resume after SetReadOnly(false)")
+	}
+}
@@ -0,0 +1,78 @@
+package art
+
+import "fmt"
+
+// heldLocks records the write locks a write holds, so that a panic in the
+// middle of it can release them instead of leaving nodes locked forever,
+// which would stall every later operation reaching them. A write takes at
+// most four locks at once: a delete collapsing a node holds its parent, the
+// node, the leaf and the remaining child.
+type heldLocks struct {
+	nodes [4]node
+	count int
+}
+
+func (h *heldLocks) add(n node) {
+	h.nodes[h.count] = n
+	h.count++
+}
+
+func (h *heldLocks) drop(n node) {
+	for i := 0; i < h.count; i++ {
+		if h.nodes[i] == n {
+			h.count--
+			h.nodes[i] = h.nodes[h.count]
+			h.nodes[h.count] = nil
+			return
+		}
+	}
+}
+
+// upgrade is upgradeToWriteLockOrRestart recording the lock it takes.
+func (h *heldLocks) upgrade(n node, version uint64) bool {
+	if upgradeToWriteLockOrRestart(n, version) {
+		return true
+	}
+	if n != nil {
+		h.add(n)
+	}
+	return false
+}
+
+// lock is writeLockOrRestart recording the lock it takes.
+func (h *heldLocks) lock(n node) bool {
+	if writeLockOrRestart(n) {
+		return true
+	}
+	h.add(n)
+	return false
+}
+
+func (h *heldLocks) unlock(n node) {
+	if n != nil {
+		h.drop(n)
+	}
+	writeUnlock(n)
+}
+
+func (h *heldLocks) unlockObsolete(n node) {
+	if n != nil {
+		h.drop(n)
+	}
+	writeUnlockObsolete(n)
+}
+
+// recover is deferred by writes. On a panic it releases the locks still
+// held and stores an error wrapping ErrPanic in err instead of letting the
+// panic unwind with nodes locked. The write may or may not have taken
+// effect.
+func (h *heldLocks) recover(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	for h.count > 0 {
+		h.unlock(h.nodes[h.count-1])
+	}
+	*err = fmt.Errorf("%w: %v", ErrPanic, r)
+}
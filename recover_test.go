@@ -0,0 +1,112 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// panicWriter is a trace writer that panics on lines starting with on,
+// which the tree writes while holding the locks of the traced change. It
+// calls check, if set, just before.
+type panicWriter struct {
+	on    string
+	check func()
+}
+
+func (w *panicWriter) Write(p []byte) (int, error) {
+	if w.on != "" && bytes.HasPrefix(p, []byte(w.on)) {
+		if w.check != nil {
+			w.check()
+		}
+		panic("injected: " + w.on)
+	}
+	return len(p), nil
+}
+
+func TestRecoverReleasesLocks(t *testing.T) {
+	w := &panicWriter{}
+	var reported []error
+	tree := NewART[int](WithOperationTrace(w), WithErrorHook(func(err error) {
+		reported = append(reported, err)
+	}))
+	for i := 0; i < 4; i++ {
+		tree.Insert([]byte{'k', byte('a' + i)}, i)
+	}
+
+	// A panic while the full node is being grown
+	w.on = "grow"
+	if err := tree.TryInsert([]byte("ke"), 4); !errors.Is(err, ErrPanic) {
+		t.Fatalf("TryInsert with a panicking grow = %v", err)
+	}
+	// A panic in an update callback, run holding the leaf's lock
+	w.on = ""
+	err := tree.upsert([]byte("ka"), 0, func(old interface{}) interface{} {
+		panic("injected: update")
+	})
+	if !errors.Is(err, ErrPanic) {
+		t.Fatalf("upsert with a panicking update = %v", err)
+	}
+	// A panic while a leaf is being unlinked
+	w.on = "delete"
+	if tree.Delete([]byte("kb")) {
+		t.Errorf("Delete with a panic reported success")
+	}
+	if len(reported) != 3 {
+		t.Errorf("error hook received %v", reported)
+	}
+
+	// Every lock was released, so writes to the same nodes go through
+	w.on = ""
+	for i := 0; i < 100; i++ {
+		tree.Insert([]byte(fmt.Sprintf("k%c%d", 'a'+i%5, i)), i)
+	}
+	tree.Insert([]byte("ka"), 10)
+	tree.Delete([]byte("kc"))
+	if val, found := tree.Search([]byte("ka")); !found || val != 10 {
+		t.Errorf("Search(ka) = %v, %v", val, found)
+	}
+	if _, found := tree.Search([]byte("kc")); found {
+		t.Errorf("kc found after its delete")
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverReleasesFourLocks(t *testing.T) {
+	w := &panicWriter{}
+	tree := NewART[int](WithOperationTrace(w), WithErrorHook(func(error) {}))
+	for _, k := range []string{"ka", "kbx", "kby"} {
+		tree.Insert([]byte(k), 0)
+	}
+	// Deleting ka collapses the node under k into its other child, the
+	// node under kb, while holding the root, both nodes and the leaf
+	root := tree.node.load()
+	parent := root.findChild('k').load()
+	held := []node{root, parent, parent.findChild('a').load(), parent.findChild('b').load()}
+	w.on = "collapse"
+	w.check = func() {
+		for _, n := range held {
+			if n.version().Load()&LOCK_BIT == 0 {
+				t.Errorf("%s %p not locked when the collapse panicked", n.getType(), n)
+			}
+		}
+	}
+	if tree.Delete([]byte("ka")) {
+		t.Errorf("Delete with a panic reported success")
+	}
+	for _, n := range held {
+		if n.version().Load()&LOCK_BIT != 0 {
+			t.Errorf("%s %p still locked after the panic", n.getType(), n)
+		}
+	}
+
+	w.on = ""
+	tree.Insert([]byte("kbz"), 0)
+	tree.Delete([]byte("kbx"))
+	if _, found := tree.Search([]byte("kbz")); !found {
+		t.Error("kbz not found after the panic")
+	}
+}
@@ -0,0 +1,134 @@
+package art
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// reclaimPasses bounds the collections a writer forces before giving up on
+// getting under the retire budget, which concurrent readers still holding
+// retired nodes can prevent.
+const reclaimPasses = 4
+
+// retirer accounts for nodes that were replaced or removed but may still be
+// read by concurrent optimistic readers. The Go collector frees them once no
+// reader holds a reference; a cleanup attached at retirement releases their
+// size from the account at that point. A writer that would push the account
+// past the budget forces collections first, which applies backpressure to
+// churning writers instead of letting limbo memory spike.
+type retirer struct {
+	budget  int64
+	retired atomic.Int64
+	peak    atomic.Int64
+	// mu serializes forced reclamation passes
+	mu sync.Mutex
+}
+
+// RetiredBytes returns the approximate memory held by retired nodes that
+// have not been freed yet, or 0 unless the tree was created with
+// WithRetireBudget.
+func (t *Tree[T]) RetiredBytes() int64 {
+	if t.retirer == nil {
+		return 0
+	}
+	return t.retirer.retired.Load()
+}
+
+// retire accounts for nodes, which the caller has just made obsolete. They
+// are accounted together so none of them is pinned by the caller's stack
+// while a reclamation pass runs for another.
+func (r *retirer) retire(nodes ...node) {
+	if r == nil {
+		return
+	}
+	// Cleanups are attached first so that a collection forced by another
+	// writer cannot free a node that is already counted but not watched
+	var size int64
+	for _, n := range nodes {
+		size += nodeSize(n)
+		switch n := n.(type) {
+		case *leaf:
+			watch(r, n)
+		case *node4:
+			watch(r, n)
+		case *node16:
+			watch(r, n)
+		case *node48:
+			watch(r, n)
+		case *node256:
+			watch(r, n)
+		case *nodeWide:
+			watch(r, n)
+		}
+	}
+	reclaimed := false
+	for {
+		cur := r.retired.Load()
+		if cur+size > r.budget && !reclaimed {
+			r.reclaim(size)
+			reclaimed = true
+			continue
+		}
+		if r.retired.CompareAndSwap(cur, cur+size) {
+			for peak := r.peak.Load(); cur+size > peak && !r.peak.CompareAndSwap(peak, cur+size); peak = r.peak.Load() {
+			}
+			break
+		}
+	}
+}
+
+// reclaim forces collections until size more bytes fit in the budget.
+func (r *retirer) reclaim(size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < reclaimPasses && r.retired.Load()+size > r.budget; i++ {
+		runtime.GC()
+		// Cleanups run on their own goroutine once the collection finishes
+		for j := 0; j < 100 && r.retired.Load()+size > r.budget; j++ {
+			runtime.Gosched()
+		}
+	}
+}
+
+// watch releases n's size from the account once the collector frees it.
+func watch[N any](r *retirer, n *N) {
+	runtime.AddCleanup(n, func(size int64) {
+		r.retired.Add(-size)
+	}, nodeSize(any(n).(node)))
+}
+
+// nodeSize approximates the memory a retired node keeps alive on its own.
+func nodeSize(n node) int64 {
+	switch n := n.(type) {
+	case *leaf:
+		size := int64(unsafe.Sizeof(*n)) + int64(cap(n.key))
+		if n.versionLockObsolete != &n.bits {
+			// the separately allocated version word
+			size += int64(unsafe.Sizeof(n.bits))
+		}
+		return size
+	case *node4:
+		return int64(unsafe.Sizeof(*n)) + n.prefix.heap()
+	case *node16:
+		return int64(unsafe.Sizeof(*n)) + n.prefix.heap()
+	case *node48:
+		size := int64(unsafe.Sizeof(*n)) + n.prefix.heap()
+		if next := n.next(); next != nil {
+			size += nodeSize(next)
+		}
+		return size
+	case *node256:
+		return int64(unsafe.Sizeof(*n)) + n.prefix.heap()
+	case *nodeWide:
+		size := int64(unsafe.Sizeof(*n)) + n.prefix.heap()
+		for hi := range n.rows {
+			if row := n.row(byte(hi)); row != nil {
+				size += int64(unsafe.Sizeof(*row))
+			}
+		}
+		return size
+	}
+	return 0
+}
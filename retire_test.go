@@ -0,0 +1,34 @@
+package art
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRetireBudget(t *testing.T) {
+	const budget = 64 << 10
+	tree := NewART[int](WithRetireBudget(budget))
+
+	for round := 0; round < 50; round++ {
+		// Growing to node256 and deleting back retires every node type
+		for i := 0; i < 300; i++ {
+			tree.Insert([]byte(fmt.Sprintf("%03d", i)), i)
+		}
+		for i := 0; i < 300; i++ {
+			tree.Delete([]byte(fmt.Sprintf("%03d", i)))
+		}
+	}
+
+	if peak := tree.retirer.peak.Load(); peak > budget {
+		t.Errorf("Retired memory peaked at %d bytes, budget is %d", peak, budget)
+	}
+	if tree.retirer.peak.Load() == 0 {
+		t.Error("Expected churn to retire nodes")
+	}
+	if n := tree.CountLeaves(); n != 0 {
+		t.Errorf("Expected an empty tree after churn, got %d keys", n)
+	}
+	if NewART[int]().RetiredBytes() != 0 {
+		t.Error("Expected no accounting without a budget")
+	}
+}
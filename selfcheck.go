@@ -0,0 +1,45 @@
+//go:build artdebug
+
+package art
+
+import (
+	"fmt"
+	"time"
+	"weak"
+)
+
+// startSelfCheck runs selfCheck every interval until the tree is collected.
+// The goroutine holds the tree weakly so that it does not keep it alive.
+func (t *Tree[T]) startSelfCheck(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t.selfCheckInterval = interval
+	ref := weak.Make(t)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			tree := ref.Value()
+			if tree == nil {
+				return
+			}
+			tree.selfCheck()
+		}
+	}()
+}
+
+// selfCheck verifies the tree's structure and size counter at a quiescent
+// point and reports any violation to the error hook.
+func (t *Tree[T]) selfCheck() {
+	resume := t.Quiesce()
+	err := t.CheckInvariants()
+	count, size := t.CountLeaves(), t.Len()
+	resume()
+	if err == nil && count != size {
+		err = fmt.Errorf("art: self-check: %d leaves but size counter is %d", count, size)
+	}
+	if err != nil {
+		t.reportError(err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !artdebug
+
+package art
+
+import "time"
+
+// Self-checks are compiled out unless built with the artdebug tag.
+func (t *Tree[T]) startSelfCheck(interval time.Duration) {}
@@ -0,0 +1,61 @@
+//go:build artdebug
+
+package art
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSelfCheckContended(t *testing.T) {
+	var mu sync.Mutex
+	var reports []error
+	hook := func(err error) {
+		mu.Lock()
+		reports = append(reports, err)
+		mu.Unlock()
+	}
+	tree := NewART[int](WithSelfCheck(time.Millisecond), WithErrorHook(hook))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 20000; i++ {
+				key := []byte(fmt.Sprintf("%d", (id*7919+i)%2000))
+				if i%3 == 0 {
+					tree.Delete(key)
+				} else {
+					tree.Insert(key, i)
+				}
+				tree.Search(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+	tree.selfCheck()
+
+	mu.Lock()
+	if len(reports) != 0 {
+		t.Errorf("Self-check reported violations under contention: %v", reports)
+	}
+	reports = nil
+	mu.Unlock()
+
+	// A diverging size counter is reported by the next background pass
+	tree.size.Add(1)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(reports)
+		mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("Expected the self-check to report the size divergence")
+}
@@ -0,0 +1,61 @@
+package art
+
+// WithSelfOrganizingNodes makes Search reorder the children of node4s and
+// node16s by use: a lookup passing through a child findChild reaches late
+// swaps it with the one before it, so under skewed access the hot children
+// drift to the front of the scan. Each swap write-locks the node, which
+// restarts concurrent readers of it, so the option costs write traffic on
+// reads until the order settles, and findChild's unrolled scans leave
+// little to gain; measure with BenchmarkSelfOrganizingZipf before enabling
+// it. The swap is a write like any other, so Quiesce excludes it: it is
+// skipped while writers are quiesced or the node is contended. RCU trees,
+// whose nodes are shared with snapshots, are never reordered.
+func WithSelfOrganizingNodes() Option {
+	return func(c *config) {
+		c.selfOrganizing = true
+	}
+}
+
+// promote transposes the child at slot at of n, which the caller reached at
+// version, with the child before it. It returns the slot now holding that
+// child and n's version after the swap, or at and version unchanged when
+// there is nothing to do, writers are quiesced, or n is locked or changed
+// since.
+func (t *Tree[T]) promote(n node, at *slot, version uint64) (*slot, uint64) {
+	var keys []uint32
+	var children []slot
+	first := 1
+	switch n := n.(type) {
+	case *node4:
+		keys, children = n.keys[:], n.childPtr[:min(n.childCount(), len(n.childPtr))]
+	case *node16:
+		// findChild compares a node16's keys a word of four at a time, so
+		// moves within the first four slots gain nothing
+		keys, children = n.keys[:], n.childPtr[:min(n.childCount(), len(n.childPtr))]
+		first = 4
+	default:
+		return at, version
+	}
+	i := first
+	for i < len(children) && &children[i] != at {
+		i++
+	}
+	if i >= len(children) {
+		return at, version
+	}
+	// A lookup does not wait for Quiesce to end, it leaves the order alone
+	if !t.writers.TryRLock() {
+		return at, version
+	}
+	defer t.writers.RUnlock()
+	if upgradeToWriteLockOrRestart(n, version) {
+		return at, version
+	}
+	prev := keyAt(keys, i-1)
+	setKeyAt(keys, i-1, keyAt(keys, i))
+	setKeyAt(keys, i, prev)
+	children[i-1].swap(&children[i])
+	locked := n.version().Load()
+	writeUnlock(n)
+	return &children[i-1], locked + LOCK_INCREMENT
+}
@@ -0,0 +1,138 @@
+package art
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// skewedKeys returns every three-letter key over a twelve-letter alphabet,
+// so each inner node is a node16, in insertion order.
+func skewedKeys() [][]byte {
+	const alphabet = "abcdefghijkl"
+	var keys [][]byte
+	for _, a := range alphabet {
+		for _, b := range alphabet {
+			for _, c := range alphabet {
+				keys = append(keys, []byte{byte(a), byte(b), byte(c)})
+			}
+		}
+	}
+	return keys
+}
+
+func TestSelfOrganizingNodes(t *testing.T) {
+	tree := NewART[int](WithSelfOrganizingNodes())
+	keys := skewedKeys()
+	for i, key := range keys {
+		tree.Insert(key, i)
+	}
+	// A key searched for often enough sits among the four slots findChild
+	// compares first in every node on its path
+	hot := keys[len(keys)-1]
+	for i := 0; i < 12; i++ {
+		tree.Search(hot)
+	}
+	n := tree.node.load()
+	for depth := range hot {
+		n16 := n.(*node16)
+		slot := bytes.IndexByte(unpackKeys(n16.keys[:], n16.childCount()), hot[depth])
+		if slot < 0 || slot >= 4 {
+			t.Fatalf("node at depth %d holds %q in slot %d", depth, hot[depth], slot)
+		}
+		n = n16.childPtr[slot].load()
+	}
+
+	// Reordering under Zipfian reads races with writers without losing keys
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			zipf := rand.NewZipf(r, 1.2, 1, uint64(len(keys)-1))
+			for i := 0; i < 20000; i++ {
+				k := zipf.Uint64()
+				if v, ok := tree.Search(keys[k]); ok && v != int(k) {
+					t.Errorf("Search(%q) = %v, want %d", keys[k], v, k)
+					return
+				}
+			}
+		}(int64(w))
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < len(keys); i += 7 {
+			tree.Delete(keys[i])
+			tree.Insert(keys[i], i)
+		}
+	}()
+	wg.Wait()
+	for i, key := range keys {
+		if v, ok := tree.Search(key); !ok || v != i {
+			t.Fatalf("Search(%q) = %v, %v, want %d", key, v, ok, i)
+		}
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelfOrganizingQuiesced(t *testing.T) {
+	tree := NewART[int](WithSelfOrganizingNodes())
+	for i, k := range []string{"xa", "xb", "xc", "xd"} {
+		tree.Insert([]byte(k), i)
+	}
+	n := tree.node.load().findChild('x').load().(*node4)
+	order := func() string { return string(unpackKeys(n.keys[:], n.childCount())) }
+
+	// Quiesce excludes the swaps with the other writes, so nothing moves
+	resume := tree.Quiesce()
+	for i := 0; i < 4; i++ {
+		tree.Search([]byte("xd"))
+	}
+	if got := order(); got != "abcd" {
+		resume()
+		t.Fatalf("Expected no reordering while quiesced, got %q", got)
+	}
+	if n.version().Load()&LOCK_BIT != 0 {
+		t.Error("Expected the node unlocked after the skipped swaps")
+	}
+	resume()
+
+	tree.Search([]byte("xd"))
+	if got := order(); got != "abdc" {
+		t.Errorf("Expected one swap after resuming, got %q", got)
+	}
+}
+
+func BenchmarkSelfOrganizingZipf(b *testing.B) {
+	keys := skewedKeys()
+	// shuffled so the hot keys are not the ones inserted first
+	rand.New(rand.NewSource(1)).Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"static", nil},
+		{"self-organizing", []Option{WithSelfOrganizingNodes()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			tree := NewART[int](bc.opts...)
+			for i := len(keys) - 1; i >= 0; i-- {
+				tree.Insert(keys[i], i)
+			}
+			zipf := rand.NewZipf(rand.New(rand.NewSource(2)), 1.2, 1, uint64(len(keys)-1))
+			picks := make([][]byte, 1<<16)
+			for i := range picks {
+				picks[i] = keys[zipf.Uint64()]
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Search(picks[i%len(picks)])
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package art
+
+import "unsafe"
+
+// zeroSize reports whether values of T hold no data, as for a Tree[struct{}]
+// used as a set.
+func zeroSize[T any]() bool {
+	var zero T
+	return unsafe.Sizeof(zero) == 0
+}
+
+// newSetLeaf returns a leaf for a tree whose values hold no data. Such a
+// value never takes the compact form, so the leaf keeps its version word in
+// its otherwise unused bits rather than in a separate allocation.
+func newSetLeaf(key []byte, val interface{}) *leaf {
+	l := &leaf{key: key}
+	l.setRaw(val)
+	l.versionLockObsolete = &l.bits
+	return l
+}
@@ -0,0 +1,72 @@
+package art
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSetLeaves(t *testing.T) {
+	set := NewART[struct{}]()
+	for i := 0; i < 1000; i++ {
+		set.Insert([]byte(fmt.Sprintf("member-%04d", i)), struct{}{})
+	}
+	set.Insert([]byte("member-0007"), struct{}{})
+	for i := 0; i < 1000; i += 2 {
+		if !set.Delete([]byte(fmt.Sprintf("member-%04d", i))) {
+			t.Fatalf("Expected member-%04d to be deleted", i)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		val, found := set.Search([]byte(fmt.Sprintf("member-%04d", i)))
+		if found != (i%2 == 1) {
+			t.Fatalf("Expected member-%04d found=%v", i, i%2 == 1)
+		}
+		if found && val != (struct{}{}) {
+			t.Fatalf("Expected struct{}{} for member-%04d, got %v", i, val)
+		}
+	}
+	if set.Len() != 500 {
+		t.Errorf("Expected 500 members, got %d", set.Len())
+	}
+	if err := set.CheckInvariants(); err != nil {
+		t.Fatalf("Unexpected violation: %v", err)
+	}
+
+	l, _, _ := set.search([]byte("member-0001"), 0, nil, 0)
+	if l.versionLockObsolete != &l.bits {
+		t.Error("Expected a set leaf to keep its version word inline")
+	}
+	if ints := NewART[int](); ints.setLeaves {
+		t.Error("Expected Tree[int] to keep separate version words")
+	}
+}
+
+func TestSetLeavesMemory(t *testing.T) {
+	fill := func(setLeaves bool) *Tree[struct{}] {
+		tree := NewART[struct{}]()
+		tree.setLeaves = setLeaves
+		for i := 0; i < 10000; i++ {
+			tree.Insert([]byte(fmt.Sprintf("member-%05d", i)), struct{}{})
+		}
+		return tree
+	}
+	allocs := func(setLeaves bool) float64 {
+		tree := fill(setLeaves)
+		i := 0
+		return testing.AllocsPerRun(10000, func() {
+			tree.Insert([]byte(fmt.Sprintf("extra-%05d", i)), struct{}{})
+			i++
+		})
+	}
+
+	before, after := fill(false).MemoryUsage(), fill(true).MemoryUsage()
+	t.Logf("MemoryUsage per key: %.1f separate, %.1f inline", float64(before)/10000, float64(after)/10000)
+	if before-after != 10000*8 {
+		t.Errorf("Expected inline version words to save 8 bytes per key, saved %d in total", before-after)
+	}
+	beforeAllocs, afterAllocs := allocs(false), allocs(true)
+	t.Logf("Allocations per insert: %.2f separate, %.2f inline", beforeAllocs, afterAllocs)
+	if beforeAllocs-afterAllocs < 0.99 {
+		t.Errorf("Expected one allocation fewer per insert, got %.2f vs %.2f", beforeAllocs, afterAllocs)
+	}
+}
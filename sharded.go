@@ -0,0 +1,339 @@
+package art
+
+import (
+	"bytes"
+	"hash/maphash"
+	"iter"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardedTree spreads keys over independent trees by key hash, so writers
+// of unrelated keys never contend on the same nodes, not even the root.
+// Each shard is a complete Tree built with the same options. Point
+// operations touch one shard, or one per generation left by Resize;
+// ordered traversals merge all of them.
+type ShardedTree[T any] struct {
+	layout atomic.Pointer[shardLayout[T]]
+	seed   maphash.Seed
+	opts   []Option
+	// transform and validateKey are the WithKeyTransform and
+	// WithKeyValidator of opts, applied here rather than by the shards so
+	// that keys are routed in their stored form
+	transform   func(key []byte) []byte
+	validateKey func(key []byte) error
+	// resize is held shared by writers and exclusively by Resize while it
+	// swaps the layout
+	resize sync.RWMutex
+}
+
+// shardLayout is one generation of shards. Keys inserted while it is
+// current stay in its shards; once Resize replaces it, it becomes prev of
+// the new layout and only loses keys, which are updated and deleted where
+// they are but never added to it again, so every key lives in exactly one
+// generation.
+type shardLayout[T any] struct {
+	shards []*Tree[T]
+	prev   *shardLayout[T]
+}
+
+func (l *shardLayout[T]) len() int {
+	n := 0
+	for _, shard := range l.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// live returns the chain starting at l without the generations that have
+// lost all their keys, which can never gain any again.
+func (l *shardLayout[T]) live() *shardLayout[T] {
+	if l == nil {
+		return nil
+	}
+	rest := l.prev.live()
+	if l.len() == 0 {
+		return rest
+	}
+	if rest == l.prev {
+		return l
+	}
+	return &shardLayout[T]{shards: l.shards, prev: rest}
+}
+
+// NewShardedART creates a ShardedTree with one shard per NUMA node, or the
+// number set with WithNUMAShards. The remaining options configure every
+// shard. A WithKeyTransform is applied before a key is routed, so keys
+// that transform alike share a shard and an entry.
+func NewShardedART[T any](opts ...Option) *ShardedTree[T] {
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	n := cfg.numaShards
+	if n <= 0 {
+		n = numaNodes()
+	}
+	s := &ShardedTree[T]{
+		seed:        maphash.MakeSeed(),
+		opts:        append(opts[:len(opts):len(opts)], WithKeyTransform(nil), WithKeyValidator(nil)),
+		transform:   cfg.keyTransform,
+		validateKey: cfg.keyValidator,
+	}
+	s.layout.Store(s.newLayout(n))
+	return s
+}
+
+func (s *ShardedTree[T]) newLayout(n int) *shardLayout[T] {
+	l := &shardLayout[T]{shards: make([]*Tree[T], n)}
+	for i := range l.shards {
+		l.shards[i] = NewART[T](s.opts...)
+	}
+	return l
+}
+
+// Resize changes the number of shards to n online. It builds n empty
+// shards and atomically swaps them in as the current layout, which keeps
+// the old shards as a previous generation instead of rehashing their keys:
+// new keys go to the new shards, while keys already stored are updated and
+// deleted where they are. Lookups of keys that miss the current shard also
+// search the previous generations, so spreading an outgrown tree costs
+// them a lookup per generation until the old keys are deleted; Resize
+// drops generations that have emptied. Readers never wait for Resize, and
+// writers only for the swap itself. Resize does nothing if n <= 0.
+func (s *ShardedTree[T]) Resize(n int) {
+	if n <= 0 {
+		return
+	}
+	next := s.newLayout(n)
+	s.resize.Lock()
+	defer s.resize.Unlock()
+	next.prev = s.layout.Load().live()
+	s.layout.Store(next)
+}
+
+// numaNodes returns the number of NUMA nodes Linux reports, or 1 where it
+// cannot tell.
+func numaNodes() int {
+	online, err := os.ReadFile("/sys/devices/system/node/online")
+	if err != nil {
+		return 1
+	}
+	return max(countNodeList(strings.TrimSpace(string(online))), 1)
+}
+
+// countNodeList counts the nodes in a sysfs list of ranges such as "0-1" or
+// "0,2-3", returning 0 if it is malformed.
+func countNodeList(list string) int {
+	count := 0
+	for _, part := range strings.Split(list, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return 0
+			}
+		}
+		count += last - first + 1
+	}
+	return count
+}
+
+// Shards returns the number of shards in the current layout.
+func (s *ShardedTree[T]) Shards() int {
+	return len(s.layout.Load().shards)
+}
+
+// ShardOf returns the index of the shard of the current layout that key
+// routes to, for callers that partition their own workers by shard. Keys
+// stored before the last Resize may still live in an older shard.
+func (s *ShardedTree[T]) ShardOf(key []byte) int {
+	return s.layout.Load().index(s.seed, s.stored(key))
+}
+
+// stored returns key in the form the shards store it.
+func (s *ShardedTree[T]) stored(key []byte) []byte {
+	if s.transform != nil {
+		return s.transform(key)
+	}
+	return key
+}
+
+func (l *shardLayout[T]) index(seed maphash.Seed, key []byte) int {
+	return int(maphash.Bytes(seed, key) % uint64(len(l.shards)))
+}
+
+func (l *shardLayout[T]) shard(seed maphash.Seed, key []byte) *Tree[T] {
+	return l.shards[l.index(seed, key)]
+}
+
+// Insert stores val under key, replacing any existing value.
+func (s *ShardedTree[T]) Insert(key []byte, val T) {
+	if s.validateKey != nil && s.validateKey(key) != nil {
+		return
+	}
+	key = s.stored(key)
+	s.resize.RLock()
+	defer s.resize.RUnlock()
+	l := s.layout.Load()
+	if !s.replaceOld(l, key, val) {
+		l.shard(s.seed, key).Insert(key, val)
+	}
+}
+
+// TryInsert is Insert reporting values rejected by the tree's options.
+func (s *ShardedTree[T]) TryInsert(key []byte, val T) error {
+	if s.validateKey != nil {
+		if err := s.validateKey(key); err != nil {
+			return err
+		}
+	}
+	key = s.stored(key)
+	s.resize.RLock()
+	defer s.resize.RUnlock()
+	l := s.layout.Load()
+	if s.replaceOld(l, key, val) {
+		return nil
+	}
+	return l.shard(s.seed, key).TryInsert(key, val)
+}
+
+// replaceOld overwrites key where a previous generation of l holds it.
+// A value the options reject is replaced nowhere, and then left for the
+// current shard to reject again.
+func (s *ShardedTree[T]) replaceOld(l *shardLayout[T], key []byte, val T) bool {
+	for old := l.prev; old != nil; old = old.prev {
+		if old.shard(s.seed, key).ReplaceIf(key, val, func(T) bool { return true }) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns the value stored under key.
+func (s *ShardedTree[T]) Search(key []byte) (T, bool) {
+	key = s.stored(key)
+	for l := s.layout.Load(); l != nil; l = l.prev {
+		if val, found := l.shard(s.seed, key).Search(key); found {
+			return valueAs[T](val), true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Delete removes key and reports whether it was present.
+func (s *ShardedTree[T]) Delete(key []byte) bool {
+	key = s.stored(key)
+	s.resize.RLock()
+	defer s.resize.RUnlock()
+	for l := s.layout.Load(); l != nil; l = l.prev {
+		if l.shard(s.seed, key).Delete(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of keys across all shards.
+func (s *ShardedTree[T]) Len() int {
+	n := 0
+	for l := s.layout.Load(); l != nil; l = l.prev {
+		n += l.len()
+	}
+	return n
+}
+
+// trees returns the shards of every generation.
+func (s *ShardedTree[T]) trees() []*Tree[T] {
+	var trees []*Tree[T]
+	for l := s.layout.Load(); l != nil; l = l.prev {
+		trees = append(trees, l.shards...)
+	}
+	return trees
+}
+
+// All returns an iterator over every key in ascending order. It merges the
+// shards' own ordered iterators, and since each key lives in exactly one
+// shard the merge neither drops nor repeats keys. Like Tree.All it is
+// weakly consistent with concurrent writers.
+func (s *ShardedTree[T]) All() iter.Seq2[[]byte, T] {
+	return s.merge(func(t *Tree[T]) iter.Seq2[[]byte, T] {
+		return t.All()
+	})
+}
+
+// ForEach visits every key in ascending order until fn returns false.
+func (s *ShardedTree[T]) ForEach(fn func(key []byte, val T) bool) {
+	for key, val := range s.All() {
+		if !fn(key, val) {
+			return
+		}
+	}
+}
+
+// ScanPrefix visits in ascending order every key starting with prefix
+// until fn returns false.
+func (s *ShardedTree[T]) ScanPrefix(prefix []byte, fn func(key []byte, val T) bool) {
+	scan := s.merge(func(t *Tree[T]) iter.Seq2[[]byte, T] {
+		return func(yield func([]byte, T) bool) {
+			t.ScanPrefix(prefix, yield)
+		}
+	})
+	for key, val := range scan {
+		if !fn(key, val) {
+			return
+		}
+	}
+}
+
+// merge yields the union of seq over every shard in ascending key order.
+func (s *ShardedTree[T]) merge(seq func(t *Tree[T]) iter.Seq2[[]byte, T]) iter.Seq2[[]byte, T] {
+	return func(yield func([]byte, T) bool) {
+		type head struct {
+			key  []byte
+			val  T
+			ok   bool
+			next func() ([]byte, T, bool)
+		}
+		trees := s.trees()
+		heads := make([]head, len(trees))
+		for i, shard := range trees {
+			next, stop := iter.Pull2(seq(shard))
+			defer stop()
+			key, val, ok := next()
+			heads[i] = head{key: key, val: val, ok: ok, next: next}
+		}
+		var last []byte
+		for {
+			// Shards are few, so a linear scan for the smallest head beats
+			// a heap
+			least := -1
+			for i := range heads {
+				if heads[i].ok && (least < 0 || bytes.Compare(heads[i].key, heads[least].key) < 0) {
+					least = i
+				}
+			}
+			if least < 0 {
+				return
+			}
+			// A key deleted from an old generation and reinserted into
+			// the current one while the merge runs can turn up in both
+			h := &heads[least]
+			if last == nil || !bytes.Equal(h.key, last) {
+				if !yield(h.key, h.val) {
+					return
+				}
+				last = h.key
+			}
+			h.key, h.val, h.ok = h.next()
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestShardedTree(t *testing.T) {
+	tree := NewShardedART[int](WithNUMAShards(4))
+	if tree.Shards() != 4 {
+		t.Fatalf("Expected 4 shards, got %d", tree.Shards())
+	}
+	for i := 0; i < 5000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("key%05d", i)), i)
+	}
+	for i, shard := range tree.layout.Load().shards {
+		if n := shard.Len(); n < 1000 || n > 1500 {
+			t.Errorf("Shard %d holds %d of 5000 keys", i, n)
+		}
+	}
+	for i := 0; i < 5000; i += 2 {
+		if !tree.Delete([]byte(fmt.Sprintf("key%05d", i))) {
+			t.Errorf("Expected to delete key%05d", i)
+		}
+	}
+	if tree.Len() != 2500 {
+		t.Errorf("Expected Len 2500, got %d", tree.Len())
+	}
+	if val, found := tree.Search([]byte("key00007")); !found || val != 7 {
+		t.Errorf("Expected key00007=7, got %d (found=%v)", val, found)
+	}
+	if _, found := tree.Search([]byte("key00008")); found {
+		t.Error("Expected key00008 deleted")
+	}
+
+	// The merge yields every remaining key exactly once, in order
+	var prev []byte
+	count := 0
+	for key, val := range tree.All() {
+		if prev != nil && bytes.Compare(prev, key) >= 0 {
+			t.Fatalf("Key '%s' after '%s'", key, prev)
+		}
+		if want := fmt.Sprintf("key%05d", val); string(key) != want {
+			t.Errorf("Expected %s for value %d, got %s", want, val, key)
+		}
+		prev = key
+		count++
+	}
+	if count != 2500 {
+		t.Errorf("Expected 2500 keys from All, got %d", count)
+	}
+
+	var scanned []int
+	tree.ScanPrefix([]byte("key001"), func(_ []byte, val int) bool {
+		scanned = append(scanned, val)
+		return len(scanned) < 10
+	})
+	if fmt.Sprint(scanned) != "[101 103 105 107 109 111 113 115 117 119]" {
+		t.Errorf("Unexpected prefix scan %v", scanned)
+	}
+}
+
+func TestShardedKeyTransform(t *testing.T) {
+	tree := NewShardedART[int](WithNUMAShards(8), WithKeyTransform(bytes.ToLower))
+	for i := 0; i < 50; i++ {
+		tree.Insert([]byte(fmt.Sprintf("key%02d", i)), i)
+		tree.Insert([]byte(fmt.Sprintf("KEY%02d", i)), i+100)
+	}
+	if n := tree.Len(); n != 50 {
+		t.Errorf("Len = %d, want 50", n)
+	}
+	if val, found := tree.Search([]byte("kEy07")); !found || val != 107 {
+		t.Errorf("Search(kEy07) = %d, %v", val, found)
+	}
+	if tree.ShardOf([]byte("KEY07")) != tree.ShardOf([]byte("key07")) {
+		t.Error("Keys equal after the transform route to different shards")
+	}
+	tree.Resize(3)
+	tree.Insert([]byte("Key07"), 7)
+	if !tree.Delete([]byte("KEY07")) {
+		t.Error("Delete(KEY07) missed the key")
+	}
+	if _, found := tree.Search([]byte("key07")); found || tree.Len() != 49 {
+		t.Errorf("After Delete: found %v, Len %d", found, tree.Len())
+	}
+	for key := range tree.All() {
+		if !bytes.Equal(key, bytes.ToLower(key)) {
+			t.Errorf("All yielded %q, not in stored form", key)
+		}
+	}
+}
+
+func TestShardedTreeConcurrent(t *testing.T) {
+	tree := NewShardedART[int](WithNUMAShards(8))
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := []byte(fmt.Sprintf("g%02d/%d", id, i))
+				tree.Insert(key, i)
+				if val, found := tree.Search(key); !found || val != i {
+					t.Errorf("Lost %s", key)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if tree.Len() != 16000 {
+		t.Errorf("Expected 16000 keys, got %d", tree.Len())
+	}
+}
+
+func TestShardedResize(t *testing.T) {
+	tree := NewShardedART[int](WithNUMAShards(4))
+	key := func(i int) []byte { return []byte(fmt.Sprintf("key%07d", i)) }
+	const n = 100000
+	for i := 0; i < n; i++ {
+		tree.Insert(key(i), i)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := r; ; i = (i + 7919) % n {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if val, found := tree.Search(key(i)); !found || val != i {
+					t.Errorf("Lost %s during resize: %d (found=%v)", key(i), val, found)
+					return
+				}
+			}
+		}(r)
+	}
+	tree.Resize(8)
+	close(stop)
+	wg.Wait()
+
+	if tree.Shards() != 8 || tree.Len() != n {
+		t.Fatalf("Expected %d keys in 8 shards, got %d in %d", n, tree.Len(), tree.Shards())
+	}
+	current := tree.layout.Load()
+	for i := n; i < n+1000; i++ {
+		tree.Insert(key(i), i)
+		if _, found := current.shards[tree.ShardOf(key(i))].Search(key(i)); !found {
+			t.Fatalf("Expected %s in current shard %d", key(i), tree.ShardOf(key(i)))
+		}
+	}
+	for _, shard := range current.shards {
+		if shard.Len() < 50 {
+			t.Errorf("Expected new keys spread over all shards, got %d in one", shard.Len())
+		}
+	}
+	// Old keys are updated where they are rather than duplicated
+	tree.Insert(key(0), -1)
+	if val, _ := tree.Search(key(0)); val != -1 || tree.Len() != n+1000 || current.len() != 1000 {
+		t.Errorf("Expected key0000000=-1 and %d keys, got %d and %d", n+1000, val, tree.Len())
+	}
+	count := 0
+	for range tree.All() {
+		count++
+	}
+	if count != n+1000 {
+		t.Errorf("Expected All to yield %d keys, got %d", n+1000, count)
+	}
+
+	// Once its keys are gone the old generation is dropped
+	for i := 0; i < n; i++ {
+		if !tree.Delete(key(i)) {
+			t.Fatalf("Expected to delete %s", key(i))
+		}
+	}
+	tree.Resize(2)
+	if l := tree.layout.Load(); l.prev == nil || l.prev.shards[0] != current.shards[0] || l.prev.prev != nil {
+		t.Error("Expected the emptied generation to be dropped")
+	}
+	if val, found := tree.Search(key(n)); !found || val != n || tree.Len() != 1000 {
+		t.Errorf("Expected %d keys after a second resize, got %d", 1000, tree.Len())
+	}
+}
+
+func TestCountNodeList(t *testing.T) {
+	for list, want := range map[string]int{"0": 1, "0-1": 2, "0,2-3": 3, "0-7": 8, "": 0, "x": 0, "3-1": 0} {
+		if got := countNodeList(list); got != want {
+			t.Errorf("countNodeList(%q) = %d, expected %d", list, got, want)
+		}
+	}
+	if n := NewShardedART[int]().Shards(); n < 1 {
+		t.Errorf("Expected at least one shard by default, got %d", n)
+	}
+}
+
+// BenchmarkShardedInsert repeats BenchmarkMultiThreadInsert against a single
+// tree and a tree with 8 shards. Only aggregate throughput is comparable:
+// run it on the target machine with -cpu set to its core count, and on
+// multi-socket hosts compare runs pinned to one socket (numactl
+// --cpunodebind=0 --membind=0) with unpinned runs to separate contention
+// from cross-socket traffic.
+func BenchmarkShardedInsert(b *testing.B) {
+	for _, numThreads := range []int{1, 8, 64, 1024, 10000} {
+		for _, mode := range []struct {
+			name string
+			// newTree returns the insert function of a fresh tree
+			newTree func() func(key []byte, val int)
+		}{
+			{"single", func() func([]byte, int) { return NewART[int]().Insert }},
+			{"sharded", func() func([]byte, int) { return NewShardedART[int](WithNUMAShards(8)).Insert }},
+		} {
+			b.Run(fmt.Sprintf("%s/Threads-%d", mode.name, numThreads), func(b *testing.B) {
+				insert := mode.newTree()
+				keys := generateRandomKeys(b.N)
+				keysPerThread := b.N / numThreads
+				var wg sync.WaitGroup
+				b.ResetTimer()
+				for t := 0; t < numThreads; t++ {
+					wg.Add(1)
+					go func(threadID int) {
+						defer wg.Done()
+						start := threadID * keysPerThread
+						end := start + keysPerThread
+						if threadID == numThreads-1 {
+							end = b.N
+						}
+						for i := start; i < end; i++ {
+							insert(keys[i], i)
+						}
+					}(t)
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package art
+
+import (
+	"runtime"
+	"sync"
+)
+
+// SnapshotManager tracks a tree's live snapshots by epoch. Each snapshot
+// takes the next epoch when it is created, and leaves the set when it is
+// released or collected. Nodes that writers replaced after a snapshot was
+// taken stay reachable only through that snapshot, and the garbage
+// collector frees them once it is gone. The epochs are informational:
+// MinEpoch reports the oldest live snapshot but does not drive freeing.
+type SnapshotManager struct {
+	mu    sync.Mutex
+	epoch uint64
+	live  map[uint64]struct{}
+}
+
+// Epoch returns the epoch of the most recent snapshot, 0 before the first.
+func (m *SnapshotManager) Epoch() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.epoch
+}
+
+// MinEpoch returns the epoch of the oldest live snapshot, or false if there
+// is none.
+func (m *SnapshotManager) MinEpoch() (uint64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var oldest uint64
+	found := false
+	for epoch := range m.live {
+		if !found || epoch < oldest {
+			oldest, found = epoch, true
+		}
+	}
+	return oldest, found
+}
+
+// Live returns the number of snapshots neither released nor collected.
+func (m *SnapshotManager) Live() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.live)
+}
+
+func (m *SnapshotManager) register() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.live == nil {
+		m.live = make(map[uint64]struct{})
+	}
+	m.epoch++
+	m.live[m.epoch] = struct{}{}
+	return m.epoch
+}
+
+func (m *SnapshotManager) forget(epoch uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.live, epoch)
+}
+
+// Snapshot is an immutable read view of a tree as it was when the snapshot
+// was taken. Writers continue on the live tree without affecting it. It is
+// safe for concurrent use until released.
+type Snapshot[T any] struct {
+	*FrozenTree[T]
+	epoch uint64
+	mgr   *SnapshotManager
+}
+
+// Snapshot returns a read view of the tree's current contents and
+// registers it with the tree's SnapshotManager. Only trees created with
+// WithRCUReads get cheap snapshots: their writers copy the nodes they
+// change, so the snapshot shares the published root and costs O(1). Any
+// other tree is quiesced and copied in full with Freeze, which costs O(n)
+// time and memory per snapshot. A snapshot of an RCU tree sees values
+// written by Modify, which changes the pointed-to structs in place.
+func (t *Tree[T]) Snapshot() *Snapshot[T] {
+	var frozen *FrozenTree[T]
+	if t.rcu != nil {
+		// Writers publish the root and update the size under rcu.mu
+		t.rcu.mu.Lock()
+		frozen = &FrozenTree[T]{root: t.rcu.root.Load().node, size: t.Len(), transform: t.transform}
+		t.rcu.mu.Unlock()
+	} else {
+		frozen = t.Freeze()
+	}
+	s := &Snapshot[T]{FrozenTree: frozen, epoch: t.snapshots.register(), mgr: &t.snapshots}
+	runtime.AddCleanup(s, t.snapshots.forget, s.epoch)
+	return s
+}
+
+// Snapshots returns the manager tracking the tree's snapshots.
+func (t *Tree[T]) Snapshots() *SnapshotManager {
+	return &t.snapshots
+}
+
+// Epoch returns the epoch the snapshot was taken at.
+func (s *Snapshot[T]) Epoch() uint64 {
+	return s.epoch
+}
+
+// Release drops the snapshot's view so the nodes only it retains can be
+// freed, and removes it from its manager. The snapshot must not be read
+// afterwards. Releasing is optional; an unreachable snapshot is forgotten
+// when it is collected.
+func (s *Snapshot[T]) Release() {
+	s.FrozenTree = nil
+	s.mgr.forget(s.epoch)
+}
+
+// SharedNodeCount returns the number of nodes, leaves included, that the
+// tree and other physically share, for reasoning about what keeping other
+// costs: the nodes other does not share are those it alone keeps alive. A
+// Clone of an RCU tree shares everything at first, and each later write to
+// either tree copies its root-to-key path out of the shared part. Clones
+// of other trees, and trees built separately, share nothing. Concurrent
+// writers make the count approximate.
+func (t *Tree[T]) SharedNodeCount(other *Tree[T]) int {
+	theirs := make(map[node]struct{})
+	func() {
+		defer other.epochs.unpin(other.epochs.pin())
+		eachNode(other.root(), func(n node) {
+			theirs[n] = struct{}{}
+		})
+	}()
+	shared := 0
+	defer t.epochs.unpin(t.epochs.pin())
+	eachNode(t.root(), func(n node) {
+		if _, ok := theirs[n]; ok {
+			shared++
+		}
+	})
+	return shared
+}
+
+// eachNode visits every node below n, leaves included.
+func eachNode(n node, fn func(n node)) {
+	fn(n)
+	if n.getType() == nodeTypeLeaf {
+		return
+	}
+	for _, child := range readChildren(n) {
+		eachNode(child, fn)
+	}
+}
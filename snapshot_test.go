@@ -0,0 +1,109 @@
+package art
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+)
+
+func snapshotContents[T any](s *Snapshot[T]) map[string]T {
+	contents := make(map[string]T)
+	s.ForEach(func(key []byte, val T) bool {
+		contents[string(key)] = val
+		return true
+	})
+	return contents
+}
+
+func TestSnapshotsAtDifferentTimes(t *testing.T) {
+	for _, rcu := range []bool{false, true} {
+		var opts []Option
+		if rcu {
+			opts = append(opts, WithRCUReads())
+		}
+		tree := NewART[int](opts...)
+		live := map[string]int{}
+		set := func(key string, val int) {
+			tree.Insert([]byte(key), val)
+			live[key] = val
+		}
+		for i := 0; i < 100; i++ {
+			set(fmt.Sprintf("key%03d", i), i)
+		}
+
+		var snaps []*Snapshot[int]
+		var want []map[string]int
+		take := func() {
+			snaps = append(snaps, tree.Snapshot())
+			want = append(want, maps.Clone(live))
+		}
+		take()
+		for i := 0; i < 100; i += 2 {
+			set(fmt.Sprintf("key%03d", i), -i)
+		}
+		set("new", 1)
+		take()
+		for i := 0; i < 50; i++ {
+			tree.Delete([]byte(fmt.Sprintf("key%03d", i)))
+			delete(live, fmt.Sprintf("key%03d", i))
+		}
+		if tree.ReplaceIf([]byte("new"), 2, func(old int) bool { return old == 1 }) {
+			live["new"] = 2
+		}
+		take()
+		set("newer", 3)
+
+		for i, s := range snaps {
+			if got := snapshotContents(s); !maps.Equal(got, want[i]) {
+				t.Errorf("rcu=%v: snapshot %d holds %d keys, want %d", rcu, i, len(got), len(want[i]))
+			}
+			if s.Len() != len(want[i]) {
+				t.Errorf("rcu=%v: snapshot %d Len = %d, want %d", rcu, i, s.Len(), len(want[i]))
+			}
+			if v, ok := s.Search([]byte("new")); ok != (i > 0) || (ok && v != want[i]["new"]) {
+				t.Errorf("rcu=%v: snapshot %d Search(new) = %d, %v", rcu, i, v, ok)
+			}
+		}
+
+		m := tree.Snapshots()
+		if oldest, ok := m.MinEpoch(); !ok || oldest != snaps[0].Epoch() || m.Live() != 3 {
+			t.Errorf("rcu=%v: MinEpoch = %d, %v with %d live", rcu, oldest, ok, m.Live())
+		}
+		snaps[0].Release()
+		if oldest, _ := m.MinEpoch(); oldest != snaps[1].Epoch() || m.Live() != 2 {
+			t.Errorf("rcu=%v: after Release MinEpoch = %d with %d live", rcu, oldest, m.Live())
+		}
+	}
+}
+
+func TestSharedNodeCount(t *testing.T) {
+	tree := NewART[int](WithRCUReads())
+	for i := 0; i < 1000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("key%04d", i)), i)
+	}
+	total := 0
+	for _, c := range tree.NodeCount() {
+		total += c
+	}
+	clone := tree.Clone()
+	if shared := tree.SharedNodeCount(clone); shared != total {
+		t.Fatalf("SharedNodeCount right after Clone = %d, want all %d nodes", shared, total)
+	}
+
+	// An overwrite copies the leaf and every node above it
+	key := []byte("key0421")
+	_, path, _ := tree.KeyExists(key)
+	tree.Insert(key, -1)
+	if shared := tree.SharedNodeCount(clone); shared != total-len(path) {
+		t.Errorf("SharedNodeCount after an overwrite = %d, want %d - %d copied", shared, total, len(path))
+	}
+	if shared := clone.SharedNodeCount(tree); shared != total-len(path) {
+		t.Errorf("SharedNodeCount from the clone = %d, want %d", shared, total-len(path))
+	}
+
+	plain := NewART[int]()
+	plain.Insert(key, 0)
+	if shared := plain.SharedNodeCount(plain.Clone()); shared != 0 {
+		t.Errorf("a copied clone shares %d nodes", shared)
+	}
+}
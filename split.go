@@ -0,0 +1,22 @@
+package art
+
+import "bytes"
+
+// Split returns two new trees, left holding the keys below key and right the
+// keys at or above it. The original tree is left unchanged and both halves
+// share its options. Keys are copied as stored, so a WithKeyTransform is
+// not applied to them a second time. Like ForEach, the split is weakly
+// consistent with concurrent writers.
+func (t *Tree[T]) Split(key []byte) (left, right *Tree[T]) {
+	left, right = t.emptyLike(), t.emptyLike()
+	defer t.epochs.unpin(t.epochs.pin())
+	walk(t.root(), func(l *leaf) bool {
+		dst := right
+		if bytes.Compare(l.key, key) < 0 {
+			dst = left
+		}
+		dst.upsertStored(l.key, readLeaf(l))
+		return true
+	})
+	return left, right
+}
@@ -0,0 +1,76 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tree := NewART[int]()
+	for i := 0; i < 1000; i++ {
+		tree.Insert([]byte(fmt.Sprintf("key%04d", i)), i)
+	}
+	pivot := []byte("key0500")
+	left, right := tree.Split(pivot)
+
+	if n := left.CountLeaves(); n != 500 {
+		t.Errorf("Expected 500 keys on the left, got %d", n)
+	}
+	if n := right.CountLeaves(); n != 500 {
+		t.Errorf("Expected 500 keys on the right, got %d", n)
+	}
+	left.ForEach(func(key []byte, val int) bool {
+		if bytes.Compare(key, pivot) >= 0 {
+			t.Errorf("Key '%s' at or above pivot on the left", key)
+		}
+		return true
+	})
+	right.ForEach(func(key []byte, val int) bool {
+		if bytes.Compare(key, pivot) < 0 {
+			t.Errorf("Key '%s' below pivot on the right", key)
+		}
+		return true
+	})
+
+	// The union of both halves is the original
+	tree.ForEach(func(key []byte, val int) bool {
+		half := right
+		if bytes.Compare(key, pivot) < 0 {
+			half = left
+		}
+		got, found := half.Search(key)
+		if !found || got.(int) != val {
+			t.Errorf("Key '%s' missing from its half: %v (found=%v)", key, got, found)
+		}
+		return true
+	})
+	if n := tree.CountLeaves(); n != 1000 {
+		t.Errorf("Expected the original to keep 1000 keys, got %d", n)
+	}
+
+	left, right = tree.Split([]byte("a"))
+	if left.CountLeaves() != 0 || right.CountLeaves() != 1000 {
+		t.Errorf("Expected everything on the right of 'a', got %d/%d", left.CountLeaves(), right.CountLeaves())
+	}
+}
+
+func TestSplitKeyTransform(t *testing.T) {
+	tree := NewART[int](WithKeyTransform(tagKey))
+	for i := 0; i < 10; i++ {
+		tree.Insert([]byte(fmt.Sprint(i)), i)
+	}
+	left, right := tree.Split([]byte("tag:5"))
+	if left.Len() != 5 || right.Len() != 5 {
+		t.Fatalf("Expected 5 keys on each side, got %d and %d", left.Len(), right.Len())
+	}
+	for i := 0; i < 10; i++ {
+		half := left
+		if i >= 5 {
+			half = right
+		}
+		if val, found := half.Search([]byte(fmt.Sprint(i))); !found || val.(int) != i {
+			t.Errorf("Expected %d=%d in its half, got %v (found=%v)", i, i, val, found)
+		}
+	}
+}
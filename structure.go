@@ -0,0 +1,275 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+)
+
+// KeyExists reports whether key is stored along with the types of the nodes
+// its descent traversed, ending with the leaf when found, and the depth
+// (number of key bytes consumed by node prefixes) at which it stopped, in
+// the key as the tree's key transform stores it. It is meant for structural
+// profiling rather than lookups.
+func (t *Tree[T]) KeyExists(key []byte) (depth int, nodePath []nodeType, found bool) {
+	if t.transform != nil {
+		key = t.transform(key)
+	}
+	defer t.epochs.unpin(t.epochs.pin())
+restart:
+	depth = 0
+	nodePath = nodePath[:0]
+	var parent node
+	var parentVersion uint64
+	curNode := t.root()
+	for curNode != nil {
+		version, needToRestart := readLockOrRestart(curNode)
+		if needToRestart || !validate(parent, parentVersion) {
+			goto restart
+		}
+		nodePath = append(nodePath, curNode.getType())
+		if l, ok := curNode.(*leaf); ok {
+			// A tombstone's key is absent, as it is to Search
+			_, found = live(l.raw(), bytes.Equal(l.key, key))
+			if !validate(curNode, version) {
+				goto restart
+			}
+			return depth, nodePath, found
+		}
+		pre := curNode.getPrefix()
+		p := checkPrefix(pre, key, depth)
+		if p != len(pre) {
+			if !validate(curNode, version) {
+				goto restart
+			}
+			return depth + p, nodePath, false
+		}
+		depth += len(pre)
+		var child node
+		if next := findChild(curNode, key, depth); next != nil {
+			child = next.load()
+		}
+		if !validate(curNode, version) {
+			goto restart
+		}
+		parent = curNode
+		parentVersion = version
+		curNode = child
+	}
+	return depth, nodePath, false
+}
+
+// CheckInvariants walks the whole tree and returns an error describing the
+// first structural violation found: a reachable node that is obsolete or
+// locked, a child count that disagrees with the occupied slots, a duplicate
+// or misplaced child, a non-root inner node with fewer than two children,
+// or a leaf whose key does not match its path. Concurrent writers can cause
+// false reports, so call it on a quiescent tree.
+func (t *Tree[T]) CheckInvariants() error {
+	defer t.epochs.unpin(t.epochs.pin())
+	return checkNode(t.root(), nil, true)
+}
+
+func checkNode(n node, path []byte, isRoot bool) error {
+	version := n.version().Load()
+	if version&OBSOLETE_BIT != 0 {
+		return fmt.Errorf("art: %s %p at %q is obsolete but reachable", n.getType(), n, path)
+	}
+	if version&LOCK_BIT != 0 {
+		return fmt.Errorf("art: %s %p at %q is locked", n.getType(), n, path)
+	}
+	if l, ok := n.(*leaf); ok {
+		if !bytes.HasPrefix(l.key, path) {
+			return fmt.Errorf("art: leaf %q is stored under path %q", l.key, path)
+		}
+		return nil
+	}
+
+	path = append(path[:len(path):len(path)], n.getPrefix()...)
+	if w, ok := n.(*nodeWide); ok {
+		return checkWide(w, path)
+	}
+	slots, err := childSlots(n)
+	if err != nil {
+		return fmt.Errorf("%w at %q", err, path)
+	}
+	if len(slots) != n.childCount() {
+		return fmt.Errorf("art: %s %p at %q counts %d children but holds %d", n.getType(), n, path, n.childCount(), len(slots))
+	}
+	if !isRoot && len(slots) < 2 {
+		return fmt.Errorf("art: %s %p at %q has %d children", n.getType(), n, path, len(slots))
+	}
+	for b, child := range slots {
+		if l, ok := child.(*leaf); ok {
+			depth := len(path)
+			fits := (len(l.key) > depth && l.key[depth] == b) || (b == TerminationChar && len(l.key) == depth)
+			if !fits {
+				return fmt.Errorf("art: leaf %q is stored in slot %#x at %q", l.key, b, path)
+			}
+		} else if pre := child.getPrefix(); len(pre) > 0 && pre[0] != b {
+			return fmt.Errorf("art: %s %p with prefix %q is stored in slot %#x at %q", child.getType(), child, pre, b, path)
+		}
+		if err := checkNode(child, path, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// childSlots maps each occupied key byte of inner node n to its child,
+// reporting slots that are duplicated or dangling.
+func childSlots(n node) (map[byte]node, error) {
+	slots := make(map[byte]node)
+	add := func(b byte, child node) error {
+		if child == nil {
+			return fmt.Errorf("art: %s %p has an empty slot for %#x", n.getType(), n, b)
+		}
+		if _, dup := slots[b]; dup {
+			return fmt.Errorf("art: %s %p has two children for %#x", n.getType(), n, b)
+		}
+		slots[b] = child
+		return nil
+	}
+	var err error
+	switch n := n.(type) {
+	case *node4:
+		for i := 0; i < n.childCount() && err == nil; i++ {
+			err = add(keyAt(n.keys[:], i), n.childPtr[i].load())
+		}
+	case *node16:
+		for i := 0; i < n.childCount() && err == nil; i++ {
+			err = add(keyAt(n.keys[:], i), n.childPtr[i].load())
+		}
+	case *node48:
+		// a capped node's overflow chain holds the rest of its children
+		for n := n; n != nil && err == nil; n = n.next() {
+			count := int(atomic.LoadUint32(&n.numOfChildren))
+			for b := 0; b < 256 && err == nil; b++ {
+				idx := n.index(byte(b))
+				if idx == -1 {
+					continue
+				}
+				if int(idx) >= count {
+					return nil, fmt.Errorf("art: node48 %p indexes %#x past its %d children", n, b, count)
+				}
+				err = add(byte(b), n.childPtr[idx].load())
+			}
+		}
+	case *node256:
+		for b := 0; b < 256 && err == nil; b++ {
+			if child := n.childPtr[b].load(); child != nil {
+				err = add(byte(b), child)
+			}
+		}
+	}
+	return slots, err
+}
+
+// MaxChainLength returns the length of the longest run of consecutive inner
+// nodes with a single child. Path compression and the collapse on delete
+// keep it at 0, so a higher value means lookups chase pointers that a
+// prefix could replace; Compact removes such chains. The root, which is
+// never collapsed, does not count.
+func (t *Tree[T]) MaxChainLength() int {
+	longest := 0
+	defer t.epochs.unpin(t.epochs.pin())
+	for _, child := range readChildren(t.root()) {
+		longest = max(longest, maxChain(child, 0))
+	}
+	return longest
+}
+
+// maxChain returns the longest single-child run below n, where run is the
+// length of the run ending at n's parent.
+func maxChain(n node, run int) int {
+	if n.getType() == nodeTypeLeaf {
+		return run
+	}
+	children := readChildren(n)
+	if len(children) == 1 {
+		run++
+	} else {
+		run = 0
+	}
+	longest := run
+	for _, child := range children {
+		longest = max(longest, maxChain(child, run))
+	}
+	return longest
+}
+
+// Height returns the number of nodes on the longest path from the root to a
+// leaf, both included. A tree without keys, which holds only its root, has
+// height 1. Concurrent writers make the result approximate.
+func (t *Tree[T]) Height() int {
+	defer t.epochs.unpin(t.epochs.pin())
+	return height(t.root())
+}
+
+func height(n node) int {
+	if n.getType() == nodeTypeLeaf {
+		return 1
+	}
+	h := 0
+	for _, child := range readChildren(n) {
+		h = max(h, height(child))
+	}
+	return h + 1
+}
+
+// NodeCount returns the number of reachable nodes of each type, leaves
+// included. The overflow nodes of a tree created with WithMaxNode48 count as
+// node48s. Concurrent writers make the counts approximate.
+func (t *Tree[T]) NodeCount() map[nodeType]int {
+	counts := make(map[nodeType]int)
+	defer t.epochs.unpin(t.epochs.pin())
+	countNodes(t.root(), counts)
+	return counts
+}
+
+func countNodes(n node, counts map[nodeType]int) {
+	counts[n.getType()]++
+	if n.getType() == nodeTypeLeaf {
+		return
+	}
+	if n48, ok := n.(*node48); ok {
+		for o := n48.next(); o != nil; o = o.next() {
+			counts[nodeType48]++
+		}
+	}
+	for _, child := range readChildren(n) {
+		countNodes(child, counts)
+	}
+}
+
+// CompressionStats reports how well path compression works on the stored
+// keys. rawKeyBytes is the total length of all keys. compressedBytes counts
+// the key bytes that prefixes save: a prefix shared by n keys stores its
+// bytes once instead of n times, saving n-1 copies of them. Keys with long
+// common prefixes save a large fraction of rawKeyBytes; random keys save
+// little beyond their first bytes. Concurrent writers make the counts
+// approximate.
+func (t *Tree[T]) CompressionStats() (compressedBytes, rawKeyBytes int64) {
+	defer t.epochs.unpin(t.epochs.pin())
+	compressedBytes, rawKeyBytes, _ = compressionBelow(t.root())
+	return compressedBytes, rawKeyBytes
+}
+
+// compressionBelow returns CompressionStats' counts for the subtree at n and
+// the number of keys in it.
+func compressionBelow(n node) (compressed, raw, keys int64) {
+	if l, ok := n.(*leaf); ok {
+		return 0, int64(len(l.key)), 1
+	}
+	prefix, children := readNode(n)
+	for _, child := range children {
+		c, r, k := compressionBelow(child)
+		compressed += c
+		raw += r
+		keys += k
+	}
+	if keys > 1 {
+		compressed += int64(len(prefix)) * (keys - 1)
+	}
+	return compressed, raw, keys
+}
@@ -0,0 +1,356 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKeyExistsNodePath(t *testing.T) {
+	tree := NewART[int]()
+	for i := 0; i < 30; i++ {
+		tree.Insert([]byte{'a', byte('A' + i)}, i)
+	}
+	tree.Insert([]byte("b"), 100)
+
+	depth, path, found := tree.KeyExists([]byte("aC"))
+	if !found {
+		t.Fatal("Expected to find key 'aC'")
+	}
+	expected := []nodeType{nodeType4, nodeType48, nodeTypeLeaf}
+	if fmt.Sprint(path) != fmt.Sprint(expected) {
+		t.Errorf("Expected path %v, got %v", expected, path)
+	}
+	if depth != 1 {
+		t.Errorf("Expected depth 1, got %d", depth)
+	}
+
+	depth, path, found = tree.KeyExists([]byte("b"))
+	if !found || fmt.Sprint(path) != fmt.Sprint([]nodeType{nodeType4, nodeTypeLeaf}) || depth != 0 {
+		t.Errorf("Unexpected result for 'b': depth=%d path=%v found=%v", depth, path, found)
+	}
+
+	_, path, found = tree.KeyExists([]byte("a~"))
+	if found {
+		t.Error("Expected 'a~' to be absent")
+	}
+	if fmt.Sprint(path) != fmt.Sprint([]nodeType{nodeType4, nodeType48}) {
+		t.Errorf("Expected miss to stop at the node48, got %v", path)
+	}
+}
+
+func TestCheckInvariantsAndLen(t *testing.T) {
+	tree := NewART[int]()
+	keys := make([][]byte, 0, 3000)
+	for i := 0; i < 3000; i++ {
+		keys = append(keys, []byte(fmt.Sprintf("%d/%x", i%13, i*2654435761)))
+	}
+	keys = append(keys, []byte(""), []byte("1"), []byte("1/"))
+	for i, key := range keys {
+		tree.Insert(key, i)
+		tree.Insert(key, i) // overwrites do not count
+	}
+	for i := 0; i < len(keys); i += 3 {
+		tree.Delete(keys[i])
+		tree.Delete(keys[i])
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatalf("Unexpected violation: %v", err)
+	}
+	if tree.Len() != tree.CountLeaves() || tree.Len() != len(keys)-(len(keys)+2)/3 {
+		t.Errorf("Expected Len %d to match CountLeaves %d", tree.Len(), tree.CountLeaves())
+	}
+
+	// A reachable obsolete node is reported
+	child := tree.node.load().findChild('5').load()
+	child.version().Add(OBSOLETE_BIT)
+	err := tree.CheckInvariants()
+	child.version().Add(^uint64(0))
+	if err == nil || !strings.Contains(err.Error(), "obsolete") {
+		t.Errorf("Expected an obsolete-node violation, got %v", err)
+	}
+}
+
+func TestMaxChainLengthAndCompact(t *testing.T) {
+	tree := NewART[int]()
+	keys := []string{"abcd1", "abcd2", "b"}
+	for i, key := range keys {
+		tree.Insert([]byte(key), i)
+	}
+	if n := tree.MaxChainLength(); n != 0 {
+		t.Fatalf("Expected no chain after inserts, got %d", n)
+	}
+
+	// Split the node under 'a' into a chain of three single-child nodes
+	// with prefixes "a", "b" and "c" above the original, now prefixed "d"
+	slot := tree.node.load().findChild('a')
+	bottom := slot.load()
+	bottom.setPrefix([]byte("d"))
+	var top node = bottom
+	for _, b := range []byte("cba") {
+		n := newNode4()
+		n.setPrefix([]byte{b})
+		n.addChild(top.getPrefix()[0], top)
+		top = n
+	}
+	slot.store(top)
+
+	if n := tree.MaxChainLength(); n != 3 {
+		t.Fatalf("Expected a chain of 3, got %d", n)
+	}
+	for i, key := range keys {
+		if val, found := tree.Search([]byte(key)); !found || val.(int) != i {
+			t.Fatalf("Expected '%s' to map to %d through the chain, got %v (found=%v)", key, i, val, found)
+		}
+	}
+
+	if n := tree.Compact(); n != 3 {
+		t.Errorf("Expected Compact to remove 3 nodes, got %d", n)
+	}
+	if n := tree.MaxChainLength(); n != 0 {
+		t.Errorf("Expected no chain after Compact, got %d", n)
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatalf("Unexpected violation: %v", err)
+	}
+	for i, key := range keys {
+		if val, found := tree.Search([]byte(key)); !found || val.(int) != i {
+			t.Errorf("Expected '%s' to map to %d after Compact, got %v (found=%v)", key, i, val, found)
+		}
+	}
+	if prefix := string(tree.node.load().findChild('a').load().getPrefix()); prefix != "abcd" {
+		t.Errorf("Expected the collapsed node to absorb prefix 'abcd', got '%s'", prefix)
+	}
+}
+
+// stretchChains stretches the prefix of every inner node below root into
+// a chain of single-byte nodes above it.
+func stretchChains(root node) {
+	for _, slot := range slotsOf(root) {
+		bottom := slot.load()
+		if bottom.getType() == nodeTypeLeaf {
+			continue
+		}
+		prefix := append([]byte(nil), bottom.getPrefix()...)
+		bottom.setPrefix(prefix[len(prefix)-1:])
+		top := bottom
+		for i := len(prefix) - 2; i >= 0; i-- {
+			n := newNode4()
+			n.setPrefix([]byte{prefix[i]})
+			n.addChild(top.getPrefix()[0], top)
+			top = n
+		}
+		slot.store(top)
+	}
+}
+
+func TestCompactLiveUnderLoad(t *testing.T) {
+	tree := NewART[int]()
+	const subtrees = 40
+	for c := 0; c < subtrees; c++ {
+		for i := 0; i < 3; i++ {
+			tree.Insert([]byte(fmt.Sprintf("%c/abcdef%d", 'A'+c, i)), i)
+		}
+	}
+	stretchChains(tree.node.load())
+	nodes := func() int {
+		sum := 0
+		for _, c := range tree.NodeCount() {
+			sum += c
+		}
+		return sum
+	}
+	before := nodes()
+
+	// A workload of inserts and searches runs throughout
+	var stop atomic.Bool
+	var ops atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; !stop.Load(); i++ {
+				tree.Insert([]byte(fmt.Sprintf("%c/abcdef%d/w%d/%d", 'A'+i%subtrees, i%3, w, i)), i)
+				key := fmt.Sprintf("%c/abcdef%d", 'A'+i%subtrees, i%3)
+				if v, ok := tree.Search([]byte(key)); !ok || v.(int) != i%3 {
+					t.Errorf("Search(%q) = %v, %v during compaction", key, v, ok)
+					return
+				}
+				ops.Add(1)
+				runtime.Gosched()
+			}
+		}(w)
+	}
+	var calls, opsDuring int
+	start := ops.Load()
+	removed := tree.CompactLive(func(done, total int) {
+		calls++
+		if done != calls || total != subtrees {
+			t.Errorf("progress(%d, %d) on call %d", done, total, calls)
+		}
+	})
+	opsDuring = int(ops.Load() - start)
+	stop.Store(true)
+	wg.Wait()
+
+	if calls != subtrees {
+		t.Errorf("progress called %d times, want %d", calls, subtrees)
+	}
+	if want := subtrees * 7; removed != want {
+		t.Errorf("CompactLive removed %d nodes, want %d", removed, want)
+	}
+	if n := tree.MaxChainLength(); n != 0 {
+		t.Errorf("MaxChainLength() = %d after CompactLive", n)
+	}
+	if opsDuring == 0 {
+		t.Error("the workload made no progress while CompactLive ran")
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	// The workload's inserts add nodes, so compare against a tree with the
+	// same keys built from scratch
+	fresh := NewART[int]()
+	tree.ForEach(func(key []byte, v int) bool {
+		fresh.Insert(key, v)
+		return true
+	})
+	if got, want := nodes(), func() int {
+		sum := 0
+		for _, c := range fresh.NodeCount() {
+			sum += c
+		}
+		return sum
+	}(); got != want {
+		t.Errorf("compacted tree has %d nodes, a fresh one %d (%d before compaction)", got, want, before)
+	}
+}
+
+func TestCompactLiveConcurrentWriters(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	sets := map[string][]Option{
+		"plain":     nil,
+		"max48":     {WithMaxNode48()},
+		"fullpath":  {WithFullPathNodes()},
+		"summaries": {WithNodeSummaries(8)},
+		"arena":     {WithKeyArena()},
+	}
+	for name, opts := range sets {
+		t.Run(name, func(t *testing.T) {
+			const subtrees, workers, rounds = 20, 4, 20
+			tree := NewART[int](opts...)
+			base := 0
+			for c := 0; c < subtrees; c++ {
+				for i := 0; i < 3; i++ {
+					tree.Insert([]byte(fmt.Sprintf("%c/abcdef%d", 'A'+c, i)), i)
+					base++
+				}
+			}
+			// Each worker owns the keys naming it, so its model is exact.
+			// The last byte spans 64 values, enough to fill a node48.
+			key := func(w, i int) []byte {
+				return []byte(fmt.Sprintf("%c/abcdef%d/w%d/%c", 'A'+i%subtrees, i%3, w, '0'+i%64))
+			}
+			models := make([]map[string]int, workers)
+			var ops atomic.Int64
+			for round := 0; round < rounds; round++ {
+				stretchChains(tree.node.load())
+				var stop atomic.Bool
+				var wg sync.WaitGroup
+				for w := 0; w < workers; w++ {
+					if models[w] == nil {
+						models[w] = map[string]int{}
+					}
+					wg.Add(1)
+					go func(w int, model map[string]int) {
+						defer wg.Done()
+						rng := rand.New(rand.NewSource(int64(round*workers + w)))
+						for !stop.Load() {
+							k := key(w, rng.Intn(subtrees*3*64))
+							if rng.Intn(3) == 0 {
+								_, had := model[string(k)]
+								if deleted := tree.Delete(k); deleted != had {
+									t.Errorf("Delete(%q) = %v, want %v", k, deleted, had)
+									return
+								}
+								delete(model, string(k))
+							} else {
+								v := rng.Int()
+								tree.Insert(k, v)
+								model[string(k)] = v
+							}
+							ops.Add(1)
+						}
+					}(w, models[w])
+				}
+				for ops.Load() < int64(round+1)*2000 {
+					tree.CompactLive(nil)
+					runtime.Gosched()
+				}
+				stop.Store(true)
+				wg.Wait()
+				if t.Failed() {
+					return
+				}
+				// chains skipped for racing a writer are left for a later run
+				tree.CompactLive(nil)
+				if err := tree.CheckInvariants(); err != nil {
+					t.Fatalf("round %d: %v", round, err)
+				}
+				want := base
+				for _, model := range models {
+					want += len(model)
+				}
+				if n, leaves := tree.Len(), tree.CountLeaves(); n != want || leaves != want {
+					t.Fatalf("round %d: Len() = %d, CountLeaves() = %d, want %d", round, n, leaves, want)
+				}
+				for _, model := range models {
+					for k, v := range model {
+						if got, ok := tree.Search([]byte(k)); !ok || got.(int) != v {
+							t.Fatalf("round %d: Search(%q) = %v, %v, want %d", round, k, got, ok, v)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompressionStats(t *testing.T) {
+	tree := NewART[int]()
+	for i, key := range []string{"abcd1", "abcd2", "b"} {
+		tree.Insert([]byte(key), i)
+	}
+	// "abcd" is stored once for two keys
+	if compressed, raw := tree.CompressionStats(); compressed != 4 || raw != 11 {
+		t.Errorf("CompressionStats = %d, %d, want 4, 11", compressed, raw)
+	}
+
+	ratio := func(keys func(i int) []byte) float64 {
+		tree := NewART[int]()
+		for i := 0; i < 10000; i++ {
+			tree.Insert(keys(i), i)
+		}
+		compressed, raw := tree.CompressionStats()
+		return float64(compressed) / float64(raw)
+	}
+	shared := ratio(func(i int) []byte {
+		return []byte(fmt.Sprintf("tenant/acme/objects/%06d", i))
+	})
+	rng := rand.New(rand.NewSource(1))
+	random := ratio(func(i int) []byte {
+		key := make([]byte, 16)
+		for j := range key {
+			key[j] = byte(1 + rng.Intn(255))
+		}
+		return key
+	})
+	if shared < 0.8 || random > 0.2 {
+		t.Errorf("compressed fraction of shared-prefix keys = %.2f, of random keys = %.2f", shared, random)
+	}
+}
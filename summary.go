@@ -0,0 +1,160 @@
+package art
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+	"unsafe"
+)
+
+// minSummaryKeys is the smallest subtree RebuildSummaries gives a summary;
+// below it the few remaining nodes cost less to descend than to hash for.
+const minSummaryKeys = 64
+
+// summaryProbes is the number of bits each key sets in a summary.
+const summaryProbes = 3
+
+// summaryState holds the settings of a tree created with WithNodeSummaries.
+type summaryState struct {
+	bitsPerKey int
+	seed       maphash.Seed
+	// builtAt is the key count at the last rebuild
+	builtAt atomic.Int64
+}
+
+func (s *summaryState) hash(key []byte) uint64 {
+	return maphash.Bytes(s.seed, key)
+}
+
+// nodeSummary is a Bloom filter over the full keys of the leaves below a
+// node48 or node256. A key whose bits are not all set is certainly absent
+// from the subtree; deleted keys leave their bits behind, which only costs
+// false positives.
+type nodeSummary struct {
+	bits []atomic.Uint64
+	mask uint64
+}
+
+func newNodeSummary(keys, bitsPerKey int) *nodeSummary {
+	size := uint64(64)
+	for size < uint64(keys*bitsPerKey) {
+		size <<= 1
+	}
+	return &nodeSummary{bits: make([]atomic.Uint64, size/64), mask: size - 1}
+}
+
+func (s *nodeSummary) add(h uint64) {
+	h1, h2 := h, h>>32|1
+	for i := 0; i < summaryProbes; i++ {
+		bit := h1 & s.mask
+		s.bits[bit/64].Or(1 << (bit % 64))
+		h1 += h2
+	}
+}
+
+func (s *nodeSummary) mayContain(h uint64) bool {
+	h1, h2 := h, h>>32|1
+	for i := 0; i < summaryProbes; i++ {
+		bit := h1 & s.mask
+		if s.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+		h1 += h2
+	}
+	return true
+}
+
+// summaryOf returns n's summary, or nil if it has none.
+func summaryOf(n node) *nodeSummary {
+	switch n := n.(type) {
+	case *node48:
+		return (*nodeSummary)(atomic.LoadPointer(&n.summary))
+	case *node256:
+		return (*nodeSummary)(atomic.LoadPointer(&n.summary))
+	}
+	return nil
+}
+
+// summaryHash hashes key for the summaries below the tree's root on first
+// use, so lookups that meet no summary never hash.
+type summaryHash struct {
+	h      uint64
+	hashed bool
+}
+
+func (h *summaryHash) of(s *summaryState, key []byte) uint64 {
+	if !h.hashed {
+		h.h, h.hashed = s.hash(key), true
+	}
+	return h.h
+}
+
+// RebuildSummaries quiesces writers and replaces the summaries of every
+// node48 and node256 with at least minSummaryKeys keys below it, sized for
+// the keys present now. A tree created with WithNodeSummaries rebuilds
+// itself whenever its key count doubles; call this after bulk deletes to
+// drop stale bits, or after a bulk load to summarize straight away. It does
+// nothing for trees without summaries.
+func (t *Tree[T]) RebuildSummaries() {
+	if t.summaries == nil || t.rcu != nil {
+		return
+	}
+	resume := t.Quiesce()
+	defer resume()
+	t.summaries.builtAt.Store(t.size.Load())
+	t.summarize(t.node.load())
+}
+
+// summarize rebuilds the summaries below n and returns the hashes of its
+// keys.
+func (t *Tree[T]) summarize(n node) []uint64 {
+	if l, ok := n.(*leaf); ok {
+		return []uint64{t.summaries.hash(l.key)}
+	}
+	var hashes []uint64
+	for _, child := range readChildren(n) {
+		hashes = append(hashes, t.summarize(child)...)
+	}
+	var summary *nodeSummary
+	if len(hashes) >= minSummaryKeys {
+		summary = newNodeSummary(len(hashes), t.summaries.bitsPerKey)
+		for _, h := range hashes {
+			summary.add(h)
+		}
+	}
+	switch n := n.(type) {
+	case *node48:
+		// Readers validate the version around their read of the pointer
+		writeLockOrRestart(n)
+		atomic.StorePointer(&n.summary, unsafe.Pointer(summary))
+		writeUnlock(n)
+	case *node256:
+		writeLockOrRestart(n)
+		atomic.StorePointer(&n.summary, unsafe.Pointer(summary))
+		writeUnlock(n)
+	}
+	return hashes
+}
+
+// maybeRebuildSummaries rebuilds the summaries once the key count has
+// doubled since the last rebuild. It must be called without holding the
+// writers lock.
+func (t *Tree[T]) maybeRebuildSummaries() {
+	if t.summaries == nil {
+		return
+	}
+	size, built := t.size.Load(), t.summaries.builtAt.Load()
+	if size < minSummaryKeys || size < 2*built {
+		return
+	}
+	if t.summaries.builtAt.CompareAndSwap(built, size) {
+		t.RebuildSummaries()
+	}
+}
+
+// summarySize returns the bytes of n's summary.
+func summarySize(n node) int64 {
+	if s := summaryOf(n); s != nil {
+		return int64(len(s.bits)) * 8
+	}
+	return 0
+}
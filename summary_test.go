@@ -0,0 +1,138 @@
+package art
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// commonPrefixKey returns a key of the family used by the summary tests:
+// 200 tenants below a shared prefix, each with binary object ids.
+func commonPrefixKey(tenant int, object uint32) []byte {
+	key := []byte("tenant/")
+	key = append(key, byte(tenant), '/')
+	key = append(key, "objects/"...)
+	return binary.BigEndian.AppendUint32(key, object)
+}
+
+func TestNodeSummariesNoFalseNegatives(t *testing.T) {
+	var trace bytes.Buffer
+	tree := NewART[int](WithNodeSummaries(10))
+	rng := rand.New(rand.NewSource(1))
+	keys := make([][]byte, 0, 30000)
+	for i := 0; i < 30000; i++ {
+		key := commonPrefixKey(i%200, rng.Uint32())
+		keys = append(keys, key)
+		tree.Insert(key, i)
+	}
+
+	summarized := 0
+	walkNodes(tree.root(), nil, func(n node, _ []byte, _ []node) bool {
+		if summaryOf(n) != nil {
+			summarized++
+		}
+		return true
+	})
+	if summarized == 0 {
+		t.Fatal("Expected automatic rebuilds to summarize some nodes")
+	}
+
+	check := func(stage string) {
+		for i, key := range keys {
+			if key == nil {
+				continue
+			}
+			if val, found := tree.Search(key); !found || val.(int) != i {
+				t.Fatalf("%s: expected %x=%d, got %v (found=%v)", stage, key, i, val, found)
+			}
+		}
+	}
+	check("after load")
+
+	// Deletes leave stale bits, reinserts and growth after the last rebuild
+	// must still be found
+	for i := 0; i < len(keys); i += 5 {
+		tree.Delete(keys[i])
+		keys[i] = nil
+	}
+	for i := 0; i < 5000; i++ {
+		key := commonPrefixKey(i%220, rng.Uint32())
+		keys = append(keys, key)
+		tree.Insert(key, len(keys)-1)
+	}
+	check("after churn")
+	tree.RebuildSummaries()
+	check("after rebuild")
+
+	tree.trace = &tracer{w: &trace}
+	misses := 0
+	for i := 0; i < 1000; i++ {
+		if _, found := tree.Search(commonPrefixKey(i%200, rng.Uint32())); !found {
+			misses++
+		}
+	}
+	tree.trace = nil
+	if rejected := bytes.Count(trace.Bytes(), []byte("reason=summary")); rejected < misses*9/10 {
+		t.Errorf("Expected summaries to reject most of %d misses, got %d", misses, rejected)
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Errorf("Unexpected violation: %v", err)
+	}
+}
+
+func TestNodeSummariesConcurrent(t *testing.T) {
+	tree := NewART[int](WithNodeSummaries(8))
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(id)))
+			for i := 0; i < 5000; i++ {
+				key := commonPrefixKey(rng.Intn(64), uint32(id)<<24|uint32(i))
+				tree.Insert(key, i)
+				// Growth, rebuilds and other writers' bits must never hide
+				// a key this goroutine has already inserted
+				if _, found := tree.Search(key); !found {
+					t.Errorf("Writer %d lost %x right after inserting it", id, key)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if n := tree.CountLeaves(); n != 40000 {
+		t.Errorf("Expected 40000 keys, got %d", n)
+	}
+}
+
+// BenchmarkSearchNonExistingCommonPrefix is BenchmarkSearchNonExisting over
+// keys sharing long prefixes, whose misses differ only in the last bytes,
+// with and without node summaries.
+func BenchmarkSearchNonExistingCommonPrefix(b *testing.B) {
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"summaries", []Option{WithNodeSummaries(10)}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			tree := NewART[int](mode.opts...)
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 100000; i++ {
+				tree.Insert(commonPrefixKey(i%200, rng.Uint32()), i)
+			}
+			probes := make([][]byte, 4096)
+			for i := range probes {
+				probes[i] = commonPrefixKey(i%200, rng.Uint32())
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tree.Search(probes[i%len(probes)])
+			}
+		})
+	}
+}
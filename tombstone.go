@@ -0,0 +1,114 @@
+package art
+
+// State is what Lookup knows about a key.
+type State int
+
+const (
+	// Unknown: the tree holds nothing under the key
+	Unknown State = iota
+	// Found: the key holds a value
+	Found
+	// Tombstone: the key was marked known-absent with InsertTombstone
+	Tombstone
+)
+
+func (s State) String() string {
+	switch s {
+	case Unknown:
+		return "unknown"
+	case Found:
+		return "found"
+	case Tombstone:
+		return "tombstone"
+	}
+	return "invalid"
+}
+
+// tombstone is the value of a leaf marked by InsertTombstone.
+type tombstone struct{}
+
+// live hides a tombstone a lookup found, reporting its key absent like
+// Search does.
+func live(val interface{}, found bool) (interface{}, bool) {
+	if _, dead := val.(tombstone); dead {
+		return nil, false
+	}
+	return val, found
+}
+
+// holdsTombstone reports whether l holds a tombstone. The caller holds l's
+// write lock, or no writer can reach l.
+func holdsTombstone(l *leaf) bool {
+	_, dead := l.raw().(tombstone)
+	return dead
+}
+
+// isTombstone is holdsTombstone for optimistic readers: it reads l's value
+// under its version, without running the loader of a lazy value.
+func isTombstone(l *leaf) bool {
+	for {
+		version, _ := readLockOrRestart(l)
+		dead := holdsTombstone(l)
+		if validate(l, version) {
+			return dead
+		}
+	}
+}
+
+// added counts a leaf the caller just linked into the tree in Len, unless
+// dead, which the caller read before publishing the leaf.
+func (t *Tree[T]) added(dead bool) {
+	if !dead {
+		t.size.Add(1)
+	}
+}
+
+// removed uncounts l, a leaf the caller just unlinked.
+func (t *Tree[T]) removed(l *leaf) {
+	if !holdsTombstone(l) {
+		t.size.Add(-1)
+	}
+}
+
+// rewritten updates Len after an overwrite under the leaf's lock that may
+// have replaced a tombstone with a value or a value with a tombstone.
+func (t *Tree[T]) rewritten(wasDead, dead bool) {
+	switch {
+	case wasDead && !dead:
+		t.size.Add(1)
+	case !wasDead && dead:
+		t.size.Add(-1)
+	}
+}
+
+// InsertTombstone records key as known to be absent, replacing any value
+// it holds, so a cache in front of a backend can remember misses as well
+// as hits. The tombstone occupies a leaf but is not a key: Search and the
+// other lookups, including those of frozen trees and snapshots, report
+// the key absent, iteration skips it, and Len does not count it. Only
+// Lookup and GetOrCompute tell it apart from a missing key. Inserting a
+// value under the key replaces the tombstone, and Delete removes it.
+func (t *Tree[T]) InsertTombstone(key []byte) {
+	if t.checkKey(key) != nil {
+		return
+	}
+	t.upsert(key, tombstone{}, nil)
+}
+
+// Lookup returns the value stored under key and whether the key holds a
+// value, a tombstone, or nothing.
+func (t *Tree[T]) Lookup(key []byte) (T, State) {
+	_, val, found := t.search(key, 0, nil, 0)
+	if !found {
+		if a, ok := t.resolveAlias(key); ok {
+			_, val, found = t.search(a.rewrite(key), 0, nil, 0)
+		}
+	}
+	if !found {
+		return valueAs[T](nil), Unknown
+	}
+	if _, dead := val.(tombstone); dead {
+		return valueAs[T](nil), Tombstone
+	}
+	return valueAs[T](val), Found
+}
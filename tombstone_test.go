@@ -0,0 +1,186 @@
+package art
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLookupTombstones(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCompactInts()}, {WithRCUReads()}} {
+		tree := NewART[int](opts...)
+		tree.Insert([]byte("user/1"), 10)
+		tree.InsertTombstone([]byte("user/2"))
+		cases := []struct {
+			key   string
+			val   int
+			state State
+		}{
+			{"user/1", 10, Found},
+			{"user/2", 0, Tombstone},
+			{"user/3", 0, Unknown},
+		}
+		for _, c := range cases {
+			if v, s := tree.Lookup([]byte(c.key)); v != c.val || s != c.state {
+				t.Errorf("Lookup(%q) = %d, %v, want %d, %v", c.key, v, s, c.val, c.state)
+			}
+		}
+		if _, ok := tree.Search([]byte("user/2")); ok {
+			t.Errorf("Search found a tombstone")
+		}
+		if tree.Len() != 1 {
+			t.Errorf("Len = %d, want 1", tree.Len())
+		}
+
+		tree.Insert([]byte("user/2"), 20)
+		if v, s := tree.Lookup([]byte("user/2")); v != 20 || s != Found {
+			t.Errorf("Lookup after overwrite = %d, %v", v, s)
+		}
+		tree.InsertTombstone([]byte("user/1"))
+		if _, s := tree.Lookup([]byte("user/1")); s != Tombstone {
+			t.Errorf("Lookup of a value replaced by a tombstone = %v", s)
+		}
+		tree.Delete([]byte("user/1"))
+		if _, s := tree.Lookup([]byte("user/1")); s != Unknown {
+			t.Errorf("Lookup after Delete = %v", s)
+		}
+	}
+}
+
+func TestTombstoneHiddenFromLookups(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithRCUReads()}, {WithLeafChecksums(), WithVersionHistory(2)}} {
+		tree := NewART[any](opts...)
+		key := []byte("gone")
+		tree.Insert(key, 1)
+		tree.InsertTombstone(key)
+
+		if val, found := tree.Search(key); found || val != nil {
+			t.Errorf("Search = %v, %v", val, found)
+		}
+		var dst any = "untouched"
+		if tree.SearchInto(key, &dst) || dst != "untouched" {
+			t.Errorf("SearchInto = %v", dst)
+		}
+		var res SearchResult
+		if val, found := tree.SearchCtx(key, &res); found || val != nil {
+			t.Errorf("SearchCtx = %v, %v", val, found)
+		}
+		if stored, val, found := tree.SearchCanonical(key); found || stored != nil || val != nil {
+			t.Errorf("SearchCanonical = %q, %v, %v", stored, val, found)
+		}
+		if val, found, err := tree.SearchChecked(key); found || val != nil || err != nil {
+			t.Errorf("SearchChecked = %v, %v, %v", val, found, err)
+		}
+		h, found := tree.SearchHandle(key)
+		if found {
+			t.Error("SearchHandle found a tombstone")
+		}
+		if val, found := tree.Reget(h); found || val != nil {
+			t.Errorf("Reget = %v, %v", val, found)
+		}
+		if val, found := tree.SearchVersion(key, 0); found || val != nil {
+			t.Errorf("SearchVersion = %v, %v", val, found)
+		}
+		if val, found := tree.Freeze().Search(key); found || val != nil {
+			t.Errorf("Freeze().Search = %v, %v", val, found)
+		}
+		snap := tree.Snapshot()
+		if val, found := snap.Search(key); found || val != nil {
+			t.Errorf("Snapshot().Search = %v, %v", val, found)
+		}
+		snap.Release()
+		if _, _, found := tree.KeyExists(key); found {
+			t.Error("KeyExists found a tombstone")
+		}
+
+		// GetOrCompute treats the tombstone as a remembered miss
+		val, err := tree.GetOrCompute(key, func() (any, error) {
+			t.Error("GetOrCompute computed a tombstoned key")
+			return 2, nil
+		})
+		if val != nil || err != nil {
+			t.Errorf("GetOrCompute = %v, %v", val, err)
+		}
+		if _, s := tree.Lookup(key); s != Tombstone {
+			t.Errorf("Lookup after the lookups = %v", s)
+		}
+	}
+
+	fixed := NewFixedKeyART[int](4)
+	fixed.Insert([]byte("abcd"), 1)
+	fixed.InsertTombstone([]byte("abcd"))
+	if val, found := fixed.SearchFixed([]byte("abcd")); found || val != 0 {
+		t.Errorf("SearchFixed = %v, %v", val, found)
+	}
+}
+
+func TestTombstoneSkippedByIteration(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithRCUReads()}} {
+		tree := NewART[int](opts...)
+		tree.Insert([]byte("a"), 1)
+		tree.Insert([]byte("b"), 2)
+		tree.InsertTombstone([]byte("b"))
+		tree.InsertTombstone([]byte("c"))
+
+		if m := tree.ToMap(); !reflect.DeepEqual(m, map[string]int{"a": 1}) {
+			t.Errorf("ToMap = %v", m)
+		}
+		if n := tree.Len(); n != 1 {
+			t.Errorf("Len = %d", n)
+		}
+		if keys := tree.Keys(); len(keys) != 1 || string(keys[0]) != "a" {
+			t.Errorf("Keys = %q", keys)
+		}
+		if n := tree.CountLeaves(); n != 1 {
+			t.Errorf("CountLeaves = %d", n)
+		}
+		set := tree.KeySetSnapshot()
+		if set.Has([]byte("b")) || !set.Has([]byte("a")) || set.Len() != 1 {
+			t.Errorf("KeySetSnapshot: Has(b) = %v, Has(a) = %v, Len = %d", set.Has([]byte("b")), set.Has([]byte("a")), set.Len())
+		}
+		var setKeys []string
+		set.ForEach(func(key []byte) bool {
+			setKeys = append(setKeys, string(key))
+			return true
+		})
+		if !reflect.DeepEqual(setKeys, []string{"a"}) {
+			t.Errorf("KeySetSnapshot().ForEach = %q", setKeys)
+		}
+		var frozen []string
+		tree.Freeze().ForEach(func(key []byte, _ int) bool {
+			frozen = append(frozen, string(key))
+			return true
+		})
+		if !reflect.DeepEqual(frozen, []string{"a"}) {
+			t.Errorf("Freeze().ForEach = %q", frozen)
+		}
+		mapped := MapValues(tree, func(_ []byte, v int) int { return v * 10 })
+		if m := mapped.ToMap(); !reflect.DeepEqual(m, map[string]int{"a": 10}) {
+			t.Errorf("MapValues = %v", m)
+		}
+
+		tree.Insert([]byte("b"), 3)
+		if n := tree.Len(); n != 2 {
+			t.Errorf("Len after replacing a tombstone = %d", n)
+		}
+		tree.Delete([]byte("c"))
+		tree.InsertTombstone([]byte("a"))
+		if n := tree.Len(); n != 1 {
+			t.Errorf("Len after tombstoning a value = %d", n)
+		}
+	}
+}
+
+func TestDeleteMinSkipsTombstones(t *testing.T) {
+	tree := NewART[int]()
+	tree.InsertTombstone([]byte("a"))
+	tree.Insert([]byte("b"), 2)
+	if k, v, ok := tree.DeleteMin(); !ok || string(k) != "b" || v != 2 {
+		t.Errorf("DeleteMin = %q, %d, %v, want b, 2, true", k, v, ok)
+	}
+	if k, v, ok := tree.DeleteMin(); ok {
+		t.Errorf("DeleteMin with only a tombstone left = %q, %d, %v", k, v, ok)
+	}
+	if _, s := tree.Lookup([]byte("a")); s != Tombstone {
+		t.Errorf("Lookup of the passed over tombstone = %v", s)
+	}
+}
@@ -0,0 +1,23 @@
+package art
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// tracer serializes trace lines from concurrent operations. A nil tracer is
+// disabled and costs a single nil check per event.
+type tracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (tr *tracer) printf(format string, args ...interface{}) {
+	if tr == nil {
+		return
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	fmt.Fprintf(tr.w, format+"\n", args...)
+}
@@ -0,0 +1,50 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOperationTrace(t *testing.T) {
+	var buf bytes.Buffer
+	tree := NewART[int](WithOperationTrace(&buf))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := []byte(fmt.Sprintf("%c%03d", 'a'+i%40, id*1000+i))
+				tree.Insert(key, i)
+				tree.Search(key)
+				tree.Search([]byte(fmt.Sprintf("missing-%d-%d", id, i)))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	trace := buf.String()
+	for _, event := range []string{"grow ", "split leaf", "search miss", "insert key="} {
+		if !strings.Contains(trace, event) {
+			t.Errorf("Expected trace to contain %q events", event)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(trace), "\n") {
+		if strings.HasPrefix(line, "search miss") && !strings.Contains(line, "missing-") {
+			t.Errorf("False miss for an inserted key: %s", line)
+		}
+	}
+}
+
+func TestOperationTraceDisabled(t *testing.T) {
+	tree := NewART[int]()
+	if tree.trace != nil {
+		t.Fatal("Tracing must be off by default")
+	}
+	tree.Insert([]byte("a"), 1)
+	tree.Search([]byte("b"))
+}
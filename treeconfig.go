@@ -0,0 +1,111 @@
+package art
+
+import (
+	"reflect"
+	"time"
+)
+
+// TreeConfig describes how a tree is set up: the options it was built with,
+// as the tree resolved them, and the constants of this implementation.
+// Options that take a callback are reported only as present or absent.
+type TreeConfig struct {
+	// MaxInlinePrefixLength is the longest compressed prefix a node stores
+	// in its own fixed array. Longer prefixes are kept whole in a separate
+	// allocation the node points to, and are still compared in full.
+	MaxInlinePrefixLength int
+	// TerminationChar is the byte under which a key that ends at an inner
+	// node is stored.
+	TerminationChar byte
+	// KeyLength is the fixed key length of NewFixedKeyART, or zero.
+	KeyLength int
+	// ValueType is the type set by WithValueType, or nil.
+	ValueType reflect.Type
+	// VersionHistory is the number of old values kept per key.
+	VersionHistory int
+	// RetireBudget is the byte budget of WithRetireBudget, or zero.
+	RetireBudget         int64
+	InlineValueThreshold int
+	// NodeSummaryBitsPerKey is the size of the per-node filters of
+	// WithNodeSummaries, or zero when they are off, as they always are
+	// with RCUReads.
+	NodeSummaryBitsPerKey int
+	// SelfCheckInterval is the period of the running background check, and
+	// zero when there is none, including builds without the artdebug tag.
+	SelfCheckInterval time.Duration
+	// WriteCoalescingWindow is the delay of WithWriteCoalescing, or zero.
+	WriteCoalescingWindow time.Duration
+	// Allocator is the custom node allocator, or nil for the heap.
+	Allocator Allocator
+
+	OperationTrace      bool
+	Metrics             bool
+	KeyTransform        bool
+	KeyValidator        bool
+	RejectEmptyKey      bool
+	RCUReads            bool
+	CompactInts         bool
+	MaxNode48           bool
+	WideStride          bool
+	StructureObserver   bool
+	GrowthMonitor       bool
+	ErrorHook           bool
+	LeafChecksums       bool
+	ChangeTracking      bool
+	KeyArena            bool
+	SelfOrganizingNodes bool
+	FullPathNodes       bool
+	ProactiveGrow       bool
+	ColumnarLeaves      bool
+	// ReadOnly is the current SetReadOnly state. It is the only field that
+	// can change over the life of a tree.
+	ReadOnly bool
+}
+
+// Config returns the tree's effective configuration, for logging it and
+// for checking in tests that a tree was built as intended. Sharding is not
+// part of it: WithNUMAShards configures a ShardedTree, and each of its
+// shards is an ordinary tree.
+func (t *Tree[T]) Config() TreeConfig {
+	c := TreeConfig{
+		MaxInlinePrefixLength: MaxInlinePrefixLength,
+		TerminationChar:       TerminationChar,
+		KeyLength:             t.keyLen,
+		ValueType:             t.valueType,
+		VersionHistory:        t.historyLen,
+		InlineValueThreshold:  t.inlineThreshold,
+		SelfCheckInterval:     t.selfCheckInterval,
+		OperationTrace:        t.trace != nil,
+		Metrics:               t.metrics != nil,
+		KeyTransform:          t.transform != nil,
+		KeyValidator:          t.validateKey != nil,
+		RejectEmptyKey:        t.rejectEmptyKey,
+		RCUReads:              t.rcu != nil,
+		CompactInts:           t.compactInts,
+		MaxNode48:             t.maxNode48,
+		WideStride:            t.wideStride,
+		StructureObserver:     t.observer != nil,
+		GrowthMonitor:         t.growth != nil,
+		ErrorHook:             t.errorHook != nil,
+		LeafChecksums:         t.checksums != nil,
+		ChangeTracking:        t.changes != nil,
+		KeyArena:              t.keys != nil,
+		SelfOrganizingNodes:   t.selfOrganizing,
+		FullPathNodes:         t.fullPaths,
+		ProactiveGrow:         t.proactiveGrow,
+		ColumnarLeaves:        t.columnarLeaves,
+		ReadOnly:              t.readOnly.Load(),
+	}
+	if t.retirer != nil {
+		c.RetireBudget = t.retirer.budget
+	}
+	if t.coalescing != nil {
+		c.WriteCoalescingWindow = t.coalescing.window
+	}
+	if t.summaries != nil && t.rcu == nil {
+		c.NodeSummaryBitsPerKey = t.summaries.bitsPerKey
+	}
+	if _, ok := t.alloc.(heapAllocator); !ok {
+		c.Allocator = t.alloc
+	}
+	return c
+}
@@ -0,0 +1,71 @@
+package art
+
+import "encoding/binary"
+
+// KeyEncoder converts typed keys to byte keys. Encode must be order
+// preserving: a < b must imply bytes.Compare(Encode(a), Encode(b)) < 0, so
+// that iteration over the byte tree yields keys in K's natural order.
+type KeyEncoder[K any] interface {
+	Encode(key K) []byte
+	Decode(b []byte) K
+}
+
+// Uint64Encoder encodes uint64 keys as 8 big-endian bytes.
+type Uint64Encoder struct{}
+
+func (Uint64Encoder) Encode(key uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, key)
+}
+func (Uint64Encoder) Decode(b []byte) uint64 {
+	return binary.BigEndian.Uint64(b)
+}
+
+// Int64Encoder encodes int64 keys as 8 big-endian bytes with the sign bit
+// flipped, so negative keys sort before positive ones.
+type Int64Encoder struct{}
+
+func (Int64Encoder) Encode(key int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(key)^(1<<63))
+}
+func (Int64Encoder) Decode(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b) ^ (1 << 63))
+}
+
+// StringEncoder encodes string keys as their raw bytes. Strings containing
+// TerminationChar share a slot with shorter keys and are not supported.
+type StringEncoder struct{}
+
+func (StringEncoder) Encode(key string) []byte {
+	return []byte(key)
+}
+func (StringEncoder) Decode(b []byte) string {
+	return string(b)
+}
+
+// Tree2 is a Tree keyed by K instead of raw bytes.
+type Tree2[K, V any] struct {
+	tree *Tree[V]
+	enc  KeyEncoder[K]
+}
+
+func NewTree2[K, V any](enc KeyEncoder[K]) *Tree2[K, V] {
+	return &Tree2[K, V]{
+		tree: NewART[V](),
+		enc:  enc,
+	}
+}
+
+func (t *Tree2[K, V]) Insert(key K, val V) {
+	t.tree.Insert(t.enc.Encode(key), val)
+}
+func (t *Tree2[K, V]) Search(key K) (V, bool) {
+	val, found := t.tree.Search(t.enc.Encode(key))
+	return valueAs[V](val), found
+}
+
+// ForEach visits every key in K's order until fn returns false.
+func (t *Tree2[K, V]) ForEach(fn func(key K, val V) bool) {
+	t.tree.ForEach(func(key []byte, val V) bool {
+		return fn(t.enc.Decode(key), val)
+	})
+}
@@ -0,0 +1,88 @@
+package art
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type timeEncoder struct{}
+
+func (timeEncoder) Encode(key time.Time) []byte {
+	return Int64Encoder{}.Encode(key.UnixNano())
+}
+func (timeEncoder) Decode(b []byte) time.Time {
+	return time.Unix(0, Int64Encoder{}.Decode(b))
+}
+
+func TestTree2TimeOrder(t *testing.T) {
+	tree := NewTree2[time.Time, int](timeEncoder{})
+
+	base := time.Date(1970, 6, 1, 0, 0, 0, 0, time.UTC)
+	perm := rand.Perm(500)
+	for _, i := range perm {
+		// Span both sides of the epoch to exercise sign handling
+		ts := base.Add(time.Duration(i-250) * 24 * time.Hour)
+		tree.Insert(ts, i)
+	}
+
+	var prev time.Time
+	count := 0
+	tree.ForEach(func(key time.Time, val int) bool {
+		if count > 0 && !key.After(prev) {
+			t.Errorf("Keys out of order: %v after %v", key, prev)
+		}
+		if val != count {
+			t.Errorf("Expected value %d at position %d, got %d", count, count, val)
+		}
+		prev = key
+		count++
+		return true
+	})
+	if count != 500 {
+		t.Errorf("Expected 500 keys, got %d", count)
+	}
+
+	val, found := tree.Search(base)
+	if !found || val != 250 {
+		t.Errorf("Expected 250 for base time, got %d (found=%v)", val, found)
+	}
+}
+
+func TestTree2Encoders(t *testing.T) {
+	ints := NewTree2[int64, int64](Int64Encoder{})
+	for _, k := range []int64{5, -3, 0, -1 << 62, 1 << 62, -1} {
+		ints.Insert(k, k)
+	}
+	var got []int64
+	ints.ForEach(func(key int64, val int64) bool {
+		if key != val {
+			t.Errorf("Key %d decoded with value %d", key, val)
+		}
+		got = append(got, key)
+		return true
+	})
+	expected := []int64{-1 << 62, -3, -1, 0, 5, 1 << 62}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, got)
+			break
+		}
+	}
+
+	strs := NewTree2[string, int](StringEncoder{})
+	for i, k := range []string{"banana", "apple", "app", "cherry"} {
+		strs.Insert(k, i)
+	}
+	var order []string
+	strs.ForEach(func(key string, _ int) bool {
+		order = append(order, key)
+		return len(order) < 3
+	})
+	if len(order) != 3 || order[0] != "app" || order[1] != "apple" || order[2] != "banana" {
+		t.Errorf("Unexpected string order or early stop: %v", order)
+	}
+}
@@ -0,0 +1,123 @@
+package art
+
+// lockLeaf returns the leaf holding key with its write lock held, or nil if
+// key is absent. A leaf only becomes obsolete when deleted, so losing the
+// race for its lock simply retries the lookup.
+func (t *Tree[T]) lockLeaf(key []byte) *leaf {
+	for {
+		l, _, found := t.search(key, 0, nil, 0)
+		if !found {
+			return nil
+		}
+		if !writeLockOrRestart(l) {
+			return l
+		}
+	}
+}
+
+// Modify calls fn with the pointer stored under key while holding the key's
+// leaf write lock, so the pointed-to struct can be updated in place,
+// serialized against other Modify calls and against writers of that key.
+// Readers that dereference a pointer obtained from Search are not
+// serialized. fn must not look up or write key itself: either would wait
+// for the lock fn runs under and never return. A value stored with
+// InsertLazy is loaded before fn is called. It returns false if key is
+// absent or holds a tombstone, otherwise fn's result, which should report
+// whether the struct was changed.
+func Modify[S any](t *Tree[*S], key []byte, fn func(*S) bool) bool {
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return false
+	}
+	l := t.lockLeaf(key)
+	if l == nil {
+		return false
+	}
+	defer writeUnlock(l)
+	val, found := live(l.value(), true)
+	if !found || !fn(valueAs[*S](val)) {
+		return false
+	}
+	t.markPath(nil, l.key, t.stamp(l))
+	return true
+}
+
+// ReplaceIf replaces the value stored under key with newVal if pred returns
+// true for the old value, and reports whether it did. pred runs while
+// holding the key's leaf write lock, so it sees a value no other writer can
+// change before the replacement; this extends compare-and-swap to
+// conditions such as "only if the stored timestamp is older". It returns
+// false if key is absent, holds a tombstone, or newVal would be rejected by
+// TryInsert.
+func (t *Tree[T]) ReplaceIf(key []byte, newVal T, pred func(old T) bool) bool {
+	if t.checkInsert(key, newVal) != nil {
+		return false
+	}
+	t.writers.RLock()
+	defer t.writers.RUnlock()
+	if t.readOnly.Load() {
+		return false
+	}
+	if t.rcu != nil {
+		return t.rcuReplace(key, newVal, func(old interface{}) bool {
+			return pred(valueAs[T](old))
+		})
+	}
+	l := t.lockLeaf(key)
+	if l == nil {
+		return false
+	}
+	defer writeUnlock(l)
+	old := l.value()
+	if _, found := live(old, true); !found || !pred(valueAs[T](old)) {
+		return false
+	}
+	l.setValue(newVal)
+	t.seal(l)
+	if t.historyLen > 1 {
+		l.pushHistory(old, t.historyLen-1)
+	}
+	t.markPath(nil, l.key, t.stamp(l))
+	return true
+}
+
+// EnsurePath makes sure every ancestor of the path formed by joining
+// segments with sep exists, like mkdir -p: for segments a, b, c it ensures
+// the keys "a", "a/b" and "a/b/c", creating missing ones with T's zero value
+// and leaving existing ones untouched. Ancestors that already exist cost an
+// optimistic lookup; only missing ones, including those holding a
+// tombstone, take a write. It returns the full joined key. A missing key is
+// inserted only if TryInsert would accept it: EnsurePath stops at the first
+// one the tree's options reject, or a read-only tree refuses, and returns
+// that error with the ancestors before it left in place.
+func (t *Tree[T]) EnsurePath(segments [][]byte, sep byte) ([]byte, error) {
+	var zero T
+	keep := func(old interface{}) interface{} {
+		if _, dead := old.(tombstone); dead {
+			return zero
+		}
+		return old
+	}
+	var path []byte
+	for i, segment := range segments {
+		if i > 0 {
+			path = append(path, sep)
+		}
+		path = append(path, segment...)
+		if _, val, found := t.search(path, 0, nil, 0); found {
+			if _, found = live(val, found); found {
+				continue
+			}
+		}
+		if err := t.checkInsert(path, zero); err != nil {
+			return nil, err
+		}
+		// keep preserves a value inserted concurrently since the lookup and
+		// replaces a tombstone
+		if err := t.upsert(path, zero, keep); err != nil {
+			return nil, err
+		}
+	}
+	return path, nil
+}
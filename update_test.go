@@ -0,0 +1,274 @@
+package art
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+type account struct {
+	balance int
+	updates int
+}
+
+func TestModifyConcurrent(t *testing.T) {
+	tree := NewART[*account]()
+	acct := &account{}
+	tree.Insert([]byte("acct:1"), acct)
+
+	const goroutines = 50
+	const increments = 1000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				Modify(tree, []byte("acct:1"), func(a *account) bool {
+					a.balance += 2
+					a.updates++
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	val, _ := tree.Search([]byte("acct:1"))
+	if val.(*account) != acct {
+		t.Error("Modify must preserve the stored pointer")
+	}
+	if acct.updates != goroutines*increments || acct.balance != 2*goroutines*increments {
+		t.Errorf("Lost updates: updates=%d balance=%d", acct.updates, acct.balance)
+	}
+}
+
+func TestModifyAbsent(t *testing.T) {
+	tree := NewART[*account]()
+	tree.Insert([]byte("a"), &account{})
+	called := false
+	if Modify(tree, []byte("b"), func(*account) bool { called = true; return true }) || called {
+		t.Error("Modify of an absent key must return false without calling fn")
+	}
+	if Modify(tree, []byte("a"), func(*account) bool { return false }) {
+		t.Error("Modify must return fn's result")
+	}
+	tree.Delete([]byte("a"))
+	if Modify(tree, []byte("a"), func(*account) bool { return true }) {
+		t.Error("Modify of a deleted key must return false")
+	}
+}
+
+func TestModifyLazyAndTombstone(t *testing.T) {
+	tree := NewART[*account]()
+	tree.InsertLazy([]byte("a"), func() *account { return &account{balance: 10} })
+	ok := Modify(tree, []byte("a"), func(a *account) bool {
+		a.balance++
+		return true
+	})
+	if val, _ := tree.Search([]byte("a")); !ok || val.(*account).balance != 11 {
+		t.Errorf("Expected Modify to update the loaded value, got %v (ok=%v)", val, ok)
+	}
+
+	tree.InsertTombstone([]byte("b"))
+	called := false
+	if Modify(tree, []byte("b"), func(*account) bool { called = true; return true }) || called {
+		t.Error("Modify of a tombstone must return false without calling fn")
+	}
+}
+
+func TestEnsurePath(t *testing.T) {
+	tree := NewART[int]()
+	tree.Insert([]byte("usr"), 1)
+	tree.Insert([]byte("usr/local/share"), 3)
+
+	segments := [][]byte{[]byte("usr"), []byte("local"), []byte("share"), []byte("doc"), []byte("art")}
+	full, err := tree.EnsurePath(segments, '/')
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(full) != "usr/local/share/doc/art" {
+		t.Errorf("Expected joined key 'usr/local/share/doc/art', got '%s'", full)
+	}
+
+	expected := map[string]int{
+		"usr":                     1,
+		"usr/local":               0,
+		"usr/local/share":         3,
+		"usr/local/share/doc":     0,
+		"usr/local/share/doc/art": 0,
+	}
+	if n := tree.CountLeaves(); n != len(expected) {
+		t.Errorf("Expected %d keys, got %d", len(expected), n)
+	}
+	for key, want := range expected {
+		val, found := tree.Search([]byte(key))
+		if !found || val.(int) != want {
+			t.Errorf("Expected %s=%d, got %v (found=%v)", key, want, val, found)
+		}
+	}
+}
+
+func TestEnsurePathTombstone(t *testing.T) {
+	tree := NewART[int]()
+	tree.InsertTombstone([]byte("a"))
+	full, err := tree.EnsurePath([][]byte{[]byte("a"), []byte("b")}, '/')
+	if err != nil || string(full) != "a/b" {
+		t.Fatalf("Expected 'a/b', got '%s' (err=%v)", full, err)
+	}
+	for _, key := range []string{"a", "a/b"} {
+		if val, found := tree.Search([]byte(key)); !found || val.(int) != 0 {
+			t.Errorf("Expected %s=0, got %v (found=%v)", key, val, found)
+		}
+	}
+	if n := tree.Len(); n != 2 {
+		t.Errorf("Expected Len 2, got %d", n)
+	}
+}
+
+func TestEnsurePathRejected(t *testing.T) {
+	noDoc := errors.New("no doc")
+	tree := NewART[int](WithKeyValidator(func(key []byte) error {
+		if bytes.HasSuffix(key, []byte("/doc")) {
+			return noDoc
+		}
+		return nil
+	}))
+	segments := [][]byte{[]byte("usr"), []byte("doc"), []byte("art")}
+	if _, err := tree.EnsurePath(segments, '/'); err != noDoc {
+		t.Fatalf("Expected the validator's error, got %v", err)
+	}
+	if _, found := tree.Search([]byte("usr")); !found {
+		t.Error("Expected the ancestor before the rejected key to stay")
+	}
+	if n := tree.Len(); n != 1 {
+		t.Errorf("Expected only usr to be inserted, got %d keys", n)
+	}
+
+	tree.SetReadOnly(true)
+	if _, err := tree.EnsurePath([][]byte{[]byte("etc")}, '/'); err != ErrReadOnly {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	if _, err := tree.EnsurePath([][]byte{[]byte("usr")}, '/'); err != nil {
+		t.Errorf("Expected existing keys to need no write, got %v", err)
+	}
+}
+
+func TestReplaceIfMonotonic(t *testing.T) {
+	tree := NewART[int]()
+	key := []byte("clock")
+	tree.Insert(key, 0)
+
+	const goroutines = 8
+	const stamps = 2000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < stamps; i++ {
+				stamp := i*goroutines + g + 1
+				tree.ReplaceIf(key, stamp, func(cur int) bool { return cur < stamp })
+			}
+		}(g)
+	}
+
+	// A reader must never see the value go backwards
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		prev := 0
+		for i := 0; i < 20000; i++ {
+			val, _ := tree.Search(key)
+			if val.(int) < prev {
+				t.Errorf("Value decreased from %d to %d", prev, val)
+				return
+			}
+			prev = val.(int)
+		}
+	}()
+	wg.Wait()
+	<-done
+
+	if val, _ := tree.Search(key); val.(int) != goroutines*stamps {
+		t.Errorf("Expected the largest stamp %d to win, got %v", goroutines*stamps, val)
+	}
+	if tree.ReplaceIf(key, 0, func(int) bool { return false }) {
+		t.Error("ReplaceIf must report a false predicate")
+	}
+	if tree.ReplaceIf([]byte("absent"), 1, func(int) bool { return true }) {
+		t.Error("ReplaceIf of an absent key must return false")
+	}
+	if _, found := tree.Search([]byte("absent")); found {
+		t.Error("ReplaceIf must not insert an absent key")
+	}
+}
+
+// TestReplaceIfMaxTimestamp has writers advance a stored timestamp with
+// random values: each only replaces an older one, so the stored value must
+// always end up the largest timestamp any writer tried.
+func TestReplaceIfMaxTimestamp(t *testing.T) {
+	tree := NewART[int64]()
+	key := []byte("last-seen")
+	tree.Insert(key, 0)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	maxima := make([]int64, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < 2000; i++ {
+				stamp := rng.Int63n(1 << 40)
+				maxima[w] = max(maxima[w], stamp)
+				advanced := tree.ReplaceIf(key, stamp, func(old int64) bool { return old < stamp })
+				cur, _ := tree.Search(key)
+				if cur.(int64) < stamp {
+					t.Errorf("Stored %d is older than %d just offered (advanced=%v)", cur, stamp, advanced)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	var highest int64
+	for _, m := range maxima {
+		highest = max(highest, m)
+	}
+	if val, _ := tree.Search(key); val.(int64) != highest {
+		t.Errorf("Expected the maximum timestamp %d, got %v", highest, val)
+	}
+}
+
+func TestReplaceIfTombstone(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"locked", nil},
+		{"rcu", []Option{WithRCUReads()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := NewART[int](tc.opts...)
+			tree.InsertTombstone([]byte("k"))
+			called := false
+			if tree.ReplaceIf([]byte("k"), 5, func(int) bool { called = true; return true }) {
+				t.Error("ReplaceIf of a tombstoned key must return false")
+			}
+			if called {
+				t.Error("ReplaceIf must not pass a tombstone to pred")
+			}
+			if val, found := tree.Search([]byte("k")); found {
+				t.Errorf("ReplaceIf revived a tombstone: %v", val)
+			}
+			if n := tree.Len(); n != 0 {
+				t.Errorf("Len = %d, want 0", n)
+			}
+		})
+	}
+}
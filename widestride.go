@@ -0,0 +1,292 @@
+package art
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"unsafe"
+)
+
+// nodeWide is an inner node that consumes two key bytes instead of one,
+// replacing a dense node256 and the node256s below it so that descents
+// through dense regions, such as runs of sequential integer keys, visit one
+// node where they visited two. Children are kept in rows indexed by the
+// first byte and allocated on first use. A child's prefix starts with both
+// bytes it is stored under, the way other children's prefixes start with
+// their one branch byte.
+//
+// The single-byte node methods do not apply: wide nodes are reached through
+// findChild, addChild and removeChild, which see the whole key.
+type nodeWide struct {
+	rows                [256]unsafe.Pointer // *[256]slot, see row
+	prefix              nodePrefix
+	versionLockObsolete *innerVersion //62b version 1b lock 1b obsolete, see innerVersion
+	numOfChildren       uint32
+}
+
+// allocNodeWide returns an empty wide node in memory from a.
+func allocNodeWide(a Allocator) *nodeWide {
+	n := a.AllocNodeWide()
+	*n = nodeWide{versionLockObsolete: newInnerVersion()}
+	return n
+}
+
+func (n *nodeWide) setPrefix(prefix []byte) {
+	n.prefix.set(prefix)
+}
+func (n *nodeWide) findChild(b byte) *slot {
+	return nil
+}
+func (n *nodeWide) getType() nodeType {
+	return nodeTypeWide
+}
+func (n *nodeWide) isFull() bool {
+	return false
+}
+func (n *nodeWide) getPrefix() []byte {
+	return n.prefix.get()
+}
+func (n *nodeWide) addChild(b byte, child node) {
+	panic("art: wide node needs two key bytes")
+}
+func (n *nodeWide) removeChild(b byte) {
+	panic("art: wide node needs two key bytes")
+}
+func (n *nodeWide) shrink(a Allocator) node {
+	return nil
+}
+func (n *nodeWide) childCount() int {
+	return int(atomic.LoadUint32(&n.numOfChildren))
+}
+func (n *nodeWide) grow(a Allocator) node {
+	return nil
+}
+func (n *nodeWide) version() *atomic.Uint64 {
+	if n.versionLockObsolete == nil {
+		log.Printf("ERROR: nil versionLockObsolete  %p", n)
+		panic("nil versionLockObsolete")
+	}
+	return &n.versionLockObsolete.version
+}
+
+// row returns the children under hi, or nil if none was ever added. Rows
+// are allocated by writers while optimistic readers load them.
+func (n *nodeWide) row(hi byte) *[256]slot {
+	return (*[256]slot)(atomic.LoadPointer(&n.rows[hi]))
+}
+
+// slot returns the child slot for hi and lo, or nil if it is empty.
+func (n *nodeWide) slot(hi, lo byte) *slot {
+	row := n.row(hi)
+	if row == nil || row[lo].load() == nil {
+		return nil
+	}
+	return &row[lo]
+}
+
+func (n *nodeWide) set(hi, lo byte, child node) {
+	row := n.row(hi)
+	if row == nil {
+		row = new([256]slot)
+		atomic.StorePointer(&n.rows[hi], unsafe.Pointer(row))
+	}
+	if row[lo].load() == nil {
+		atomic.AddUint32(&n.numOfChildren, 1)
+	}
+	row[lo].store(child)
+}
+
+func (n *nodeWide) clear(hi, lo byte) {
+	if row := n.row(hi); row != nil && row[lo].load() != nil {
+		row[lo].store(nil)
+		atomic.AddUint32(&n.numOfChildren, ^uint32(0))
+	}
+}
+
+// each calls fn with every child and its address in key order.
+func (n *nodeWide) each(fn func(hi, lo byte, slot *slot)) {
+	for hi := range n.rows {
+		row := n.row(byte(hi))
+		if row == nil {
+			continue
+		}
+		for lo := range row {
+			if row[lo].load() != nil {
+				fn(byte(hi), byte(lo), &row[lo])
+			}
+		}
+	}
+}
+
+// keyByte returns key's byte at pos, or TerminationChar past its end.
+func keyByte(key []byte, pos int) byte {
+	if pos >= len(key) {
+		return TerminationChar
+	}
+	return key[pos]
+}
+
+// stride returns the number of key bytes n consumes below its prefix.
+func stride(n node) int {
+	if n.getType() == nodeTypeWide {
+		return 2
+	}
+	return 1
+}
+
+// WithWideStride lets Compact replace dense node256s with wide nodes that
+// consume two key bytes at once: a node256 whose children are mostly inner
+// nodes branching on the very next byte absorbs them, so a descent through
+// it visits one node instead of two. Trees of sequential integer keys have
+// such regions; sparse trees have none and are left unchanged. Wide nodes
+// never shrink back. Trees created with WithRCUReads are never compacted.
+func WithWideStride() Option {
+	return func(c *config) {
+		c.wideStride = true
+	}
+}
+
+// widenBelow replaces every dense node256 in the subtree at slot, whose
+// parent is parent and whose prefix starts at depth, with a wide node. It
+// returns the number of nodes absorbed.
+func (t *Tree[T]) widenBelow(parent node, slot *slot, depth int) int {
+	n := slot.load()
+	if n.getType() == nodeTypeLeaf {
+		return 0
+	}
+	absorbed := 0
+	if dense(n) {
+		absorbed += t.widen(parent, slot, depth)
+		n = slot.load()
+	}
+	depth += len(n.getPrefix())
+	for _, child := range slotsOf(n) {
+		absorbed += t.widenBelow(n, child, depth)
+	}
+	return absorbed
+}
+
+// dense reports whether n is a node256 worth widening: at least three
+// quarters of its children are inner nodes whose prefix is just their branch
+// byte, so they can be absorbed.
+func dense(n node) bool {
+	n256, ok := n.(*node256)
+	if !ok {
+		return false
+	}
+	absorbable := 0
+	for i := range n256.childPtr {
+		child := n256.childPtr[i].load()
+		if child == nil || child.getType() == nodeTypeLeaf {
+			continue
+		}
+		switch len(child.getPrefix()) {
+		case 0:
+			return false
+		case 1:
+			if child.getType() == nodeTypeWide {
+				return false
+			}
+			absorbable++
+		}
+	}
+	return absorbable*4 >= n256.childCount()*3
+}
+
+// widen replaces the node256 at slot with a wide node holding its leaves,
+// its children with longer prefixes, and the children of its children with
+// one-byte prefixes, which are absorbed. Writers are quiesced, so only
+// readers race with it, and they validate against the locks taken here.
+func (t *Tree[T]) widen(parent node, slot *slot, depth int) int {
+	n := slot.load().(*node256)
+	at := depth + len(n.getPrefix())
+	w := allocNodeWide(t.alloc)
+	w.setPrefix(append([]byte(nil), n.getPrefix()...))
+	if parent != nil {
+		writeLockOrRestart(parent)
+	}
+	writeLockOrRestart(n)
+	t.inherit(w, n)
+	t.recordPath(w, storedPath(n))
+	var absorbed []node
+	for hi := range n.childPtr {
+		child := n.childPtr[hi].load()
+		switch {
+		case child == nil:
+		case child.getType() == nodeTypeLeaf:
+			w.set(byte(hi), keyByte(child.(*leaf).key, at+1), child)
+		case len(child.getPrefix()) > 1:
+			w.set(byte(hi), child.getPrefix()[1], child)
+		default:
+			writeLockOrRestart(child)
+			slots, _ := childSlots(child)
+			for lo, grandchild := range slots {
+				if grandchild.getType() != nodeTypeLeaf {
+					// the grandchild now hangs below both bytes
+					writeLockOrRestart(grandchild)
+					grandchild.setPrefix(append([]byte{byte(hi)}, grandchild.getPrefix()...))
+					writeUnlock(grandchild)
+				}
+				w.set(byte(hi), lo, grandchild)
+			}
+			absorbed = append(absorbed, child)
+		}
+	}
+	slot.store(w)
+	t.trace.printf("widen node=%p type=%s into=%p absorbed=%d depth=%d", n, n.getType(), w, len(absorbed), depth)
+	for _, child := range absorbed {
+		writeUnlockObsolete(child)
+	}
+	writeUnlockObsolete(n)
+	if parent != nil {
+		writeUnlock(parent)
+	}
+	t.retirer.retire(append(absorbed, n)...)
+	t.free(append(absorbed, n)...)
+	return len(absorbed)
+}
+
+// slotsOf returns the addresses of n's child slots.
+func slotsOf(n node) []*slot {
+	var slots []*slot
+	if w, ok := n.(*nodeWide); ok {
+		w.each(func(_, _ byte, slot *slot) {
+			slots = append(slots, slot)
+		})
+		return slots
+	}
+	children, _ := childSlots(n)
+	for b := range children {
+		slots = append(slots, n.findChild(b))
+	}
+	return slots
+}
+
+// checkWide is checkNode's check of wide node n's slots, path being the key
+// bytes leading to its children.
+func checkWide(n *nodeWide, path []byte) error {
+	count := 0
+	var err error
+	n.each(func(hi, lo byte, slot *slot) {
+		count++
+		if err != nil {
+			return
+		}
+		child := slot.load()
+		if l, ok := child.(*leaf); ok {
+			depth := len(path)
+			if keyByte(l.key, depth) != hi || keyByte(l.key, depth+1) != lo {
+				err = fmt.Errorf("art: leaf %q is stored in slot %#x%02x at %q", l.key, hi, lo, path)
+			}
+		} else if pre := child.getPrefix(); len(pre) < 2 || pre[0] != hi || pre[1] != lo {
+			err = fmt.Errorf("art: %s %p with prefix %q is stored in slot %#x%02x at %q", child.getType(), child, pre, hi, lo, path)
+		}
+		if err == nil {
+			err = checkNode(child, path, false)
+		}
+	})
+	if err == nil && count != n.childCount() {
+		err = fmt.Errorf("art: wide %p at %q counts %d children but holds %d", n, path, n.childCount(), count)
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+package art
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// denseKey returns the i'th of a run of sequential four-byte keys, using
+// only the bytes 1-255 so that keys of different lengths never collide with
+// TerminationChar.
+func denseKey(i int) []byte {
+	return []byte{'k', byte(1 + i/65025%255), byte(1 + i/255%255), byte(1 + i%255)}
+}
+
+func TestWideStrideDescent(t *testing.T) {
+	tree := NewART[string](WithWideStride())
+	want := map[string]string{}
+	put := func(key []byte) {
+		tree.Insert(key, string(key))
+		want[string(key)] = string(key)
+	}
+	for i := 0; i < 40000; i++ {
+		put(denseKey(i))
+		switch i % 97 {
+		case 0:
+			// ends inside the two bytes a wide node branches on
+			put(denseKey(i)[:3])
+		case 1:
+			put(append(denseKey(i), "/long"...))
+		}
+	}
+	put([]byte("k"))
+	before := tree.NodeCount()
+	pathBefore := descentPath(t, tree, denseKey(12345))
+
+	if removed := tree.Compact(); removed == 0 {
+		t.Fatalf("Compact absorbed no nodes")
+	}
+	counts := tree.NodeCount()
+	if counts[nodeTypeWide] == 0 || counts[nodeType256] >= before[nodeType256] {
+		t.Fatalf("node counts %v after widening %v", counts, before)
+	}
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if path := descentPath(t, tree, denseKey(12345)); len(path) >= len(pathBefore) {
+		t.Errorf("descent visits %v, was %v", path, pathBefore)
+	}
+
+	check := func(stage string) {
+		t.Helper()
+		for k, v := range want {
+			if got, ok := tree.Search([]byte(k)); !ok || got != v {
+				t.Fatalf("%s: Search(%q) = %v, %v", stage, k, got, ok)
+			}
+		}
+		if _, ok := tree.Search(denseKey(40000)); ok {
+			t.Fatalf("%s: found a key never inserted", stage)
+		}
+		var keys []string
+		tree.ForEach(func(key []byte, val string) bool {
+			keys = append(keys, string(key))
+			return true
+		})
+		if len(keys) != len(want) || !sort.StringsAreSorted(keys) {
+			t.Fatalf("%s: ForEach visited %d keys, sorted %v", stage, len(keys), sort.StringsAreSorted(keys))
+		}
+		for _, prefix := range [][]byte{denseKey(300)[:2], denseKey(300)[:3], denseKey(300)} {
+			n := 0
+			for k := range want {
+				if bytes.HasPrefix([]byte(k), prefix) {
+					n++
+				}
+			}
+			if got := tree.LenPrefix(prefix); got != n {
+				t.Errorf("%s: LenPrefix(%q) = %d, want %d", stage, prefix, got, n)
+			}
+		}
+	}
+	check("widened")
+
+	// Writers keep working on wide nodes, including keys ending between
+	// the two bytes
+	for i := 0; i < 40000; i += 3 {
+		tree.Delete(denseKey(i))
+		delete(want, string(denseKey(i)))
+	}
+	for i := 0; i < 40000; i += 7 {
+		put(denseKey(i)[:3])
+	}
+	put(denseKey(45000))
+	if err := tree.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	check("updated")
+
+	frozen := tree.Freeze()
+	for k, v := range want {
+		if got, ok := frozen.Search([]byte(k)); !ok || got != v {
+			t.Fatalf("frozen Search(%q) = %v, %v", k, got, ok)
+		}
+	}
+	if got := tree.SearchFoldAll(denseKey(2)); len(got) != 1 {
+		t.Errorf("SearchFoldAll found %d entries", len(got))
+	}
+	n := 0
+	tree.Glob([]byte("k\x01\x02?"), func([]byte, string) bool {
+		n++
+		return true
+	})
+	if n == 0 {
+		t.Errorf("Glob found nothing below a wide node")
+	}
+}
+
+// descentPath returns the node types the descent to key visits.
+func descentPath[T any](tb testing.TB, tree *Tree[T], key []byte) []nodeType {
+	_, path, found := tree.KeyExists(key)
+	if !found {
+		tb.Fatalf("%q not found", key)
+	}
+	return append([]nodeType(nil), path...)
+}
+
+// BenchmarkWideStride reports the mean nodes per lookup and the lookup
+// throughput before and after Compact widens dense node256s.
+func BenchmarkWideStride(b *testing.B) {
+	dense := make([][]byte, 60000)
+	for i := range dense {
+		dense[i] = denseKey(i)
+	}
+	for _, keys := range []struct {
+		name string
+		keys [][]byte
+	}{
+		{"sequential", generateSequentialKeys(10000, 8)},
+		{"dense", dense},
+	} {
+		for _, wide := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/wide=%v", keys.name, wide), func(b *testing.B) {
+				tree := NewART[int](WithWideStride())
+				for i, key := range keys.keys {
+					tree.Insert(key, i)
+				}
+				if wide {
+					tree.Compact()
+				}
+				nodes := tree.ProfileSearch(keys.keys).MeanNodes
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					tree.Search(keys.keys[i%len(keys.keys)])
+				}
+				b.ReportMetric(nodes, "nodes/op")
+			})
+		}
+	}
+}